@@ -0,0 +1,504 @@
+package v1alpha1
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+
+	"github.com/weaveworks/gitopssets-controller/api/v1beta1"
+)
+
+// ConvertTo converts this GitOpsSet to the Hub version (v1beta1).
+func (src *GitOpsSet) ConvertTo(dstRaw conversion.Hub) error {
+	dst, ok := dstRaw.(*v1beta1.GitOpsSet)
+	if !ok {
+		return fmt.Errorf("unsupported conversion to %T", dstRaw)
+	}
+
+	dst.ObjectMeta = src.ObjectMeta
+	dst.Spec = convertSpecToHub(src.Spec)
+	dst.Status = convertStatusToHub(src.Status)
+
+	return nil
+}
+
+// ConvertFrom converts from the Hub version (v1beta1) to this version.
+func (dst *GitOpsSet) ConvertFrom(srcRaw conversion.Hub) error {
+	src, ok := srcRaw.(*v1beta1.GitOpsSet)
+	if !ok {
+		return fmt.Errorf("unsupported conversion from %T", srcRaw)
+	}
+
+	dst.ObjectMeta = src.ObjectMeta
+	dst.Spec = convertSpecFromHub(src.Spec)
+	dst.Status = convertStatusFromHub(src.Status)
+
+	return nil
+}
+
+func convertSpecToHub(in GitOpsSetSpec) v1beta1.GitOpsSetSpec {
+	out := v1beta1.GitOpsSetSpec{
+		Suspend:              in.Suspend,
+		ServiceAccountName:   in.ServiceAccountName,
+		ForceConflicts:       in.ForceConflicts,
+		DriftDetection:       v1beta1.DriftDetectionMode(in.DriftDetection),
+		Wait:                 in.Wait,
+		Timeout:              in.Timeout,
+		Mode:                 v1beta1.GitOpsSetMode(in.Mode),
+		DeletionPolicy:       v1beta1.DeletionPolicy(in.DeletionPolicy),
+		AdoptionPolicy:       v1beta1.AdoptionPolicy(in.AdoptionPolicy),
+		GeneratorErrorPolicy: v1beta1.GeneratorErrorPolicy(in.GeneratorErrorPolicy),
+		MaxRenderedResources: in.MaxRenderedResources,
+		RetryInterval:        in.RetryInterval,
+	}
+
+	if in.Generators != nil {
+		out.Generators = make([]v1beta1.GitOpsSetGenerator, len(in.Generators))
+		for i, gen := range in.Generators {
+			out.Generators[i] = v1beta1.GitOpsSetGenerator{
+				List:          (*v1beta1.ListGenerator)(gen.List),
+				PullRequests:  convertPullRequestsToHub(gen.PullRequests),
+				GitRepository: convertGitRepositoryToHub(gen.GitRepository),
+				OCIRepository: convertOCIRepositoryToHub(gen.OCIRepository),
+				Matrix:        convertMatrixToHub(gen.Matrix),
+				Cluster:       (*v1beta1.ClusterGenerator)(gen.Cluster),
+				APIClient:     convertAPIClientToHub(gen.APIClient),
+				ImagePolicy:   (*v1beta1.ImagePolicyGenerator)(gen.ImagePolicy),
+				Config:        (*v1beta1.ConfigGenerator)(gen.Config),
+				Plugin:        (*v1beta1.PluginGenerator)(gen.Plugin),
+			}
+		}
+	}
+
+	if in.Templates != nil {
+		out.Templates = make([]v1beta1.GitOpsSetTemplate, len(in.Templates))
+		for i := range in.Templates {
+			out.Templates[i] = v1beta1.GitOpsSetTemplate(in.Templates[i])
+		}
+	}
+
+	if in.PruneProtection != nil {
+		out.PruneProtection = (*v1beta1.PruneProtection)(in.PruneProtection)
+	}
+
+	return out
+}
+
+func convertSpecFromHub(in v1beta1.GitOpsSetSpec) GitOpsSetSpec {
+	out := GitOpsSetSpec{
+		Suspend:              in.Suspend,
+		ServiceAccountName:   in.ServiceAccountName,
+		ForceConflicts:       in.ForceConflicts,
+		DriftDetection:       DriftDetectionMode(in.DriftDetection),
+		Wait:                 in.Wait,
+		Timeout:              in.Timeout,
+		Mode:                 GitOpsSetMode(in.Mode),
+		DeletionPolicy:       DeletionPolicy(in.DeletionPolicy),
+		AdoptionPolicy:       AdoptionPolicy(in.AdoptionPolicy),
+		GeneratorErrorPolicy: GeneratorErrorPolicy(in.GeneratorErrorPolicy),
+		MaxRenderedResources: in.MaxRenderedResources,
+		RetryInterval:        in.RetryInterval,
+	}
+
+	if in.Generators != nil {
+		out.Generators = make([]GitOpsSetGenerator, len(in.Generators))
+		for i, gen := range in.Generators {
+			out.Generators[i] = GitOpsSetGenerator{
+				List:          (*ListGenerator)(gen.List),
+				PullRequests:  convertPullRequestsFromHub(gen.PullRequests),
+				GitRepository: convertGitRepositoryFromHub(gen.GitRepository),
+				OCIRepository: convertOCIRepositoryFromHub(gen.OCIRepository),
+				Matrix:        convertMatrixFromHub(gen.Matrix),
+				Cluster:       (*ClusterGenerator)(gen.Cluster),
+				APIClient:     convertAPIClientFromHub(gen.APIClient),
+				ImagePolicy:   (*ImagePolicyGenerator)(gen.ImagePolicy),
+				Config:        (*ConfigGenerator)(gen.Config),
+				Plugin:        (*PluginGenerator)(gen.Plugin),
+			}
+		}
+	}
+
+	if in.Templates != nil {
+		out.Templates = make([]GitOpsSetTemplate, len(in.Templates))
+		for i := range in.Templates {
+			out.Templates[i] = GitOpsSetTemplate(in.Templates[i])
+		}
+	}
+
+	if in.PruneProtection != nil {
+		out.PruneProtection = (*PruneProtection)(in.PruneProtection)
+	}
+
+	return out
+}
+
+func convertMatrixToHub(in *MatrixGenerator) *v1beta1.MatrixGenerator {
+	if in == nil {
+		return nil
+	}
+
+	out := &v1beta1.MatrixGenerator{SingleElement: in.SingleElement}
+	if in.Generators != nil {
+		out.Generators = make([]v1beta1.GitOpsSetNestedGenerator, len(in.Generators))
+		for i, gen := range in.Generators {
+			out.Generators[i] = v1beta1.GitOpsSetNestedGenerator{
+				Name:          gen.Name,
+				List:          (*v1beta1.ListGenerator)(gen.List),
+				GitRepository: convertGitRepositoryToHub(gen.GitRepository),
+				OCIRepository: convertOCIRepositoryToHub(gen.OCIRepository),
+				PullRequests:  convertPullRequestsToHub(gen.PullRequests),
+				Cluster:       (*v1beta1.ClusterGenerator)(gen.Cluster),
+				APIClient:     convertAPIClientToHub(gen.APIClient),
+				ImagePolicy:   (*v1beta1.ImagePolicyGenerator)(gen.ImagePolicy),
+				Config:        (*v1beta1.ConfigGenerator)(gen.Config),
+				Plugin:        (*v1beta1.PluginGenerator)(gen.Plugin),
+			}
+		}
+	}
+
+	return out
+}
+
+func convertMatrixFromHub(in *v1beta1.MatrixGenerator) *MatrixGenerator {
+	if in == nil {
+		return nil
+	}
+
+	out := &MatrixGenerator{SingleElement: in.SingleElement}
+	if in.Generators != nil {
+		out.Generators = make([]GitOpsSetNestedGenerator, len(in.Generators))
+		for i, gen := range in.Generators {
+			out.Generators[i] = GitOpsSetNestedGenerator{
+				Name:          gen.Name,
+				List:          (*ListGenerator)(gen.List),
+				GitRepository: convertGitRepositoryFromHub(gen.GitRepository),
+				OCIRepository: convertOCIRepositoryFromHub(gen.OCIRepository),
+				PullRequests:  convertPullRequestsFromHub(gen.PullRequests),
+				Cluster:       (*ClusterGenerator)(gen.Cluster),
+				APIClient:     convertAPIClientFromHub(gen.APIClient),
+				ImagePolicy:   (*ImagePolicyGenerator)(gen.ImagePolicy),
+				Config:        (*ConfigGenerator)(gen.Config),
+				Plugin:        (*PluginGenerator)(gen.Plugin),
+			}
+		}
+	}
+
+	return out
+}
+
+// convertPullRequestsToHub converts the Forks flag to the equivalent
+// ForkPolicy.
+func convertPullRequestsToHub(in *PullRequestGenerator) *v1beta1.PullRequestGenerator {
+	if in == nil {
+		return nil
+	}
+
+	out := &v1beta1.PullRequestGenerator{
+		Interval:   in.Interval,
+		Driver:     in.Driver,
+		ServerURL:  in.ServerURL,
+		Repo:       in.Repo,
+		SecretRef:  in.SecretRef,
+		Labels:     in.Labels,
+		ForkPolicy: v1beta1.ExcludeForkPolicy,
+	}
+	if in.Forks {
+		out.ForkPolicy = v1beta1.IncludeForkPolicy
+	}
+
+	return out
+}
+
+func convertPullRequestsFromHub(in *v1beta1.PullRequestGenerator) *PullRequestGenerator {
+	if in == nil {
+		return nil
+	}
+
+	return &PullRequestGenerator{
+		Interval:  in.Interval,
+		Driver:    in.Driver,
+		ServerURL: in.ServerURL,
+		Repo:      in.Repo,
+		SecretRef: in.SecretRef,
+		Labels:    in.Labels,
+		Forks:     in.ForkPolicy == v1beta1.IncludeForkPolicy,
+	}
+}
+
+// convertAPIClientToHub converts the CA SecretRef to the equivalent TLS
+// configuration.
+func convertAPIClientToHub(in *APIClientGenerator) *v1beta1.APIClientGenerator {
+	if in == nil {
+		return nil
+	}
+
+	out := &v1beta1.APIClientGenerator{
+		Interval:      in.Interval,
+		Endpoint:      in.Endpoint,
+		Method:        in.Method,
+		JSONPath:      in.JSONPath,
+		HeadersRef:    (*v1beta1.HeadersReference)(in.HeadersRef),
+		Body:          in.Body,
+		SingleElement: in.SingleElement,
+	}
+	if in.SecretRef != nil {
+		out.TLS = &v1beta1.APIClientTLS{CASecretRef: *in.SecretRef}
+	}
+
+	return out
+}
+
+func convertAPIClientFromHub(in *v1beta1.APIClientGenerator) *APIClientGenerator {
+	if in == nil {
+		return nil
+	}
+
+	out := &APIClientGenerator{
+		Interval:      in.Interval,
+		Endpoint:      in.Endpoint,
+		Method:        in.Method,
+		JSONPath:      in.JSONPath,
+		HeadersRef:    (*HeadersReference)(in.HeadersRef),
+		Body:          in.Body,
+		SingleElement: in.SingleElement,
+	}
+	if in.TLS != nil {
+		ref := corev1.LocalObjectReference(in.TLS.CASecretRef)
+		out.SecretRef = &ref
+	}
+
+	return out
+}
+
+func convertGitRepositoryToHub(in *GitRepositoryGenerator) *v1beta1.GitRepositoryGenerator {
+	if in == nil {
+		return nil
+	}
+
+	return &v1beta1.GitRepositoryGenerator{
+		RepositoryRef: in.RepositoryRef,
+		Namespace:     in.Namespace,
+		Files:         convertFileItemsToHub(in.Files),
+		Directories:   convertDirectoryItemsToHub(in.Directories),
+	}
+}
+
+func convertGitRepositoryFromHub(in *v1beta1.GitRepositoryGenerator) *GitRepositoryGenerator {
+	if in == nil {
+		return nil
+	}
+
+	return &GitRepositoryGenerator{
+		RepositoryRef: in.RepositoryRef,
+		Namespace:     in.Namespace,
+		Files:         convertFileItemsFromHub(in.Files),
+		Directories:   convertDirectoryItemsFromHub(in.Directories),
+	}
+}
+
+func convertOCIRepositoryToHub(in *OCIRepositoryGenerator) *v1beta1.OCIRepositoryGenerator {
+	if in == nil {
+		return nil
+	}
+
+	return &v1beta1.OCIRepositoryGenerator{
+		RepositoryRef: in.RepositoryRef,
+		Namespace:     in.Namespace,
+		Files:         convertFileItemsToHub(in.Files),
+		Directories:   convertDirectoryItemsToHub(in.Directories),
+	}
+}
+
+func convertOCIRepositoryFromHub(in *v1beta1.OCIRepositoryGenerator) *OCIRepositoryGenerator {
+	if in == nil {
+		return nil
+	}
+
+	return &OCIRepositoryGenerator{
+		RepositoryRef: in.RepositoryRef,
+		Namespace:     in.Namespace,
+		Files:         convertFileItemsFromHub(in.Files),
+		Directories:   convertDirectoryItemsFromHub(in.Directories),
+	}
+}
+
+func convertFileItemsToHub(in []RepositoryGeneratorFileItem) []v1beta1.RepositoryGeneratorFileItem {
+	if in == nil {
+		return nil
+	}
+
+	out := make([]v1beta1.RepositoryGeneratorFileItem, len(in))
+	for i := range in {
+		out[i] = v1beta1.RepositoryGeneratorFileItem(in[i])
+	}
+
+	return out
+}
+
+func convertFileItemsFromHub(in []v1beta1.RepositoryGeneratorFileItem) []RepositoryGeneratorFileItem {
+	if in == nil {
+		return nil
+	}
+
+	out := make([]RepositoryGeneratorFileItem, len(in))
+	for i := range in {
+		out[i] = RepositoryGeneratorFileItem(in[i])
+	}
+
+	return out
+}
+
+func convertDirectoryItemsToHub(in []RepositoryGeneratorDirectoryItem) []v1beta1.RepositoryGeneratorDirectoryItem {
+	if in == nil {
+		return nil
+	}
+
+	out := make([]v1beta1.RepositoryGeneratorDirectoryItem, len(in))
+	for i := range in {
+		out[i] = v1beta1.RepositoryGeneratorDirectoryItem(in[i])
+	}
+
+	return out
+}
+
+func convertDirectoryItemsFromHub(in []v1beta1.RepositoryGeneratorDirectoryItem) []RepositoryGeneratorDirectoryItem {
+	if in == nil {
+		return nil
+	}
+
+	out := make([]RepositoryGeneratorDirectoryItem, len(in))
+	for i := range in {
+		out[i] = RepositoryGeneratorDirectoryItem(in[i])
+	}
+
+	return out
+}
+
+func convertStatusToHub(in GitOpsSetStatus) v1beta1.GitOpsSetStatus {
+	out := v1beta1.GitOpsSetStatus{
+		ReconcileRequestStatus: in.ReconcileRequestStatus,
+		ObservedGeneration:     in.ObservedGeneration,
+		Conditions:             in.Conditions,
+	}
+
+	if in.Inventory != nil {
+		out.Inventory = &v1beta1.ResourceInventory{
+			Entries:    convertResourceRefsToHub(in.Inventory.Entries),
+			ConfigMaps: in.Inventory.ConfigMaps,
+			Digest:     in.Inventory.Digest,
+		}
+	}
+
+	if in.Plan != nil {
+		out.Plan = &v1beta1.GitOpsSetPlan{
+			Create: convertResourceRefsToHub(in.Plan.Create),
+			Update: convertResourceRefsToHub(in.Plan.Update),
+			Delete: convertResourceRefsToHub(in.Plan.Delete),
+		}
+	}
+
+	if in.LastApplied != nil {
+		out.LastApplied = &v1beta1.AppliedState{
+			Digest:           in.LastApplied.Digest,
+			ReconcileRequest: in.LastApplied.ReconcileRequest,
+		}
+		if in.LastApplied.Revisions != nil {
+			out.LastApplied.Revisions = make([]v1beta1.SourceRevision, len(in.LastApplied.Revisions))
+			for i := range in.LastApplied.Revisions {
+				out.LastApplied.Revisions[i] = v1beta1.SourceRevision(in.LastApplied.Revisions[i])
+			}
+		}
+	}
+
+	if in.Generators != nil {
+		out.Generators = make([]v1beta1.GeneratorStatus, len(in.Generators))
+		for i := range in.Generators {
+			out.Generators[i] = v1beta1.GeneratorStatus(in.Generators[i])
+		}
+	}
+
+	if in.Wave != nil {
+		wave := v1beta1.WaveStatus(*in.Wave)
+		out.Wave = &wave
+	}
+
+	return out
+}
+
+func convertStatusFromHub(in v1beta1.GitOpsSetStatus) GitOpsSetStatus {
+	out := GitOpsSetStatus{
+		ReconcileRequestStatus: in.ReconcileRequestStatus,
+		ObservedGeneration:     in.ObservedGeneration,
+		Conditions:             in.Conditions,
+	}
+
+	if in.Inventory != nil {
+		out.Inventory = &ResourceInventory{
+			Entries:    convertResourceRefsFromHub(in.Inventory.Entries),
+			ConfigMaps: in.Inventory.ConfigMaps,
+			Digest:     in.Inventory.Digest,
+		}
+	}
+
+	if in.Plan != nil {
+		out.Plan = &GitOpsSetPlan{
+			Create: convertResourceRefsFromHub(in.Plan.Create),
+			Update: convertResourceRefsFromHub(in.Plan.Update),
+			Delete: convertResourceRefsFromHub(in.Plan.Delete),
+		}
+	}
+
+	if in.LastApplied != nil {
+		out.LastApplied = &AppliedState{
+			Digest:           in.LastApplied.Digest,
+			ReconcileRequest: in.LastApplied.ReconcileRequest,
+		}
+		if in.LastApplied.Revisions != nil {
+			out.LastApplied.Revisions = make([]SourceRevision, len(in.LastApplied.Revisions))
+			for i := range in.LastApplied.Revisions {
+				out.LastApplied.Revisions[i] = SourceRevision(in.LastApplied.Revisions[i])
+			}
+		}
+	}
+
+	if in.Generators != nil {
+		out.Generators = make([]GeneratorStatus, len(in.Generators))
+		for i := range in.Generators {
+			out.Generators[i] = GeneratorStatus(in.Generators[i])
+		}
+	}
+
+	if in.Wave != nil {
+		wave := WaveStatus(*in.Wave)
+		out.Wave = &wave
+	}
+
+	return out
+}
+
+func convertResourceRefsToHub(in []ResourceRef) []v1beta1.ResourceRef {
+	if in == nil {
+		return nil
+	}
+
+	out := make([]v1beta1.ResourceRef, len(in))
+	for i := range in {
+		out[i] = v1beta1.ResourceRef(in[i])
+	}
+
+	return out
+}
+
+func convertResourceRefsFromHub(in []v1beta1.ResourceRef) []ResourceRef {
+	if in == nil {
+		return nil
+	}
+
+	out := make([]ResourceRef, len(in))
+	for i := range in {
+		out[i] = ResourceRef(in[i])
+	}
+
+	return out
+}
@@ -0,0 +1,146 @@
+package v1alpha1
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	fuzz "github.com/google/gofuzz"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/weaveworks/gitopssets-controller/api/v1beta1"
+)
+
+const fuzzIterations = 1000
+
+func TestFuzzyConversion(t *testing.T) {
+	t.Run("v1alpha1 to v1beta1 and back", func(t *testing.T) {
+		f := newFuzzer()
+		for i := 0; i < fuzzIterations; i++ {
+			spoke := &GitOpsSet{}
+			f.Fuzz(spoke)
+
+			hub := &v1beta1.GitOpsSet{}
+			if err := spoke.ConvertTo(hub); err != nil {
+				t.Fatal(err)
+			}
+			converted := &GitOpsSet{}
+			if err := converted.ConvertFrom(hub); err != nil {
+				t.Fatal(err)
+			}
+
+			if diff := cmp.Diff(spoke, converted); diff != "" {
+				t.Fatalf("failed to round-trip conversion:\n%s", diff)
+			}
+		}
+	})
+
+	t.Run("v1beta1 to v1alpha1 and back", func(t *testing.T) {
+		f := newFuzzer()
+		for i := 0; i < fuzzIterations; i++ {
+			hub := &v1beta1.GitOpsSet{}
+			f.Fuzz(hub)
+
+			spoke := &GitOpsSet{}
+			if err := spoke.ConvertFrom(hub); err != nil {
+				t.Fatal(err)
+			}
+			converted := &v1beta1.GitOpsSet{}
+			if err := spoke.ConvertTo(converted); err != nil {
+				t.Fatal(err)
+			}
+
+			if diff := cmp.Diff(hub, converted); diff != "" {
+				t.Fatalf("failed to round-trip conversion:\n%s", diff)
+			}
+		}
+	})
+}
+
+func TestConvertTo(t *testing.T) {
+	spoke := &GitOpsSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "demo-set", Namespace: "default"},
+		Spec: GitOpsSetSpec{
+			Generators: []GitOpsSetGenerator{
+				{
+					PullRequests: &PullRequestGenerator{Driver: "github", Repo: "test-org/test-repo", Forks: true},
+				},
+				{
+					Matrix: &MatrixGenerator{
+						Generators: []GitOpsSetNestedGenerator{
+							{
+								PullRequests: &PullRequestGenerator{Driver: "github", Repo: "test-org/test-repo"},
+							},
+							{
+								APIClient: &APIClientGenerator{
+									Endpoint:  "https://example.com/api",
+									SecretRef: &corev1.LocalObjectReference{Name: "test-ca"},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	hub := &v1beta1.GitOpsSet{}
+	if err := spoke.ConvertTo(hub); err != nil {
+		t.Fatal(err)
+	}
+
+	want := &v1beta1.GitOpsSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "demo-set", Namespace: "default"},
+		Spec: v1beta1.GitOpsSetSpec{
+			Generators: []v1beta1.GitOpsSetGenerator{
+				{
+					PullRequests: &v1beta1.PullRequestGenerator{Driver: "github", Repo: "test-org/test-repo", ForkPolicy: v1beta1.IncludeForkPolicy},
+				},
+				{
+					Matrix: &v1beta1.MatrixGenerator{
+						Generators: []v1beta1.GitOpsSetNestedGenerator{
+							{
+								PullRequests: &v1beta1.PullRequestGenerator{Driver: "github", Repo: "test-org/test-repo", ForkPolicy: v1beta1.ExcludeForkPolicy},
+							},
+							{
+								APIClient: &v1beta1.APIClientGenerator{
+									Endpoint: "https://example.com/api",
+									TLS:      &v1beta1.APIClientTLS{CASecretRef: corev1.LocalObjectReference{Name: "test-ca"}},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	if diff := cmp.Diff(want, hub); diff != "" {
+		t.Fatalf("failed to convert:\n%s", diff)
+	}
+}
+
+func newFuzzer() *fuzz.Fuzzer {
+	return fuzz.New().NilChance(0.3).NumElements(0, 3).Funcs(
+		// The ForkPolicy is defaulted and validated by the API server, so
+		// only valid values are converted.
+		func(p *v1beta1.ForkPolicy, c fuzz.Continue) {
+			*p = v1beta1.ExcludeForkPolicy
+			if c.RandBool() {
+				*p = v1beta1.IncludeForkPolicy
+			}
+		},
+		// The TypeMeta is set by the conversion webhook, not the conversion
+		// functions.
+		func(tm *metav1.TypeMeta, c fuzz.Continue) {},
+		// The fuzzer can't fill in the Object interface.
+		func(raw *runtime.RawExtension, c fuzz.Continue) {
+			raw.Raw = []byte(fmt.Sprintf(`{"value": %q}`, c.RandString()))
+		},
+		// The fuzzer can't create times with locations that compare equal.
+		func(tm *metav1.Time, c fuzz.Continue) {
+			*tm = metav1.Unix(c.Int63n(1<<32), 0)
+		},
+	)
+}
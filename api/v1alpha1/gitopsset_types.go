@@ -1,6 +1,8 @@
 package v1alpha1
 
 import (
+	"time"
+
 	"github.com/fluxcd/pkg/apis/meta"
 	corev1 "k8s.io/api/core/v1"
 	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
@@ -12,6 +14,10 @@ import (
 // up resources.
 const GitOpsSetFinalizer = "finalizers.templates.weave.works"
 
+// DefaultHealthCheckTimeout is the timeout for the health checks of the
+// generated resources if no timeout is configured.
+const DefaultHealthCheckTimeout = 5 * time.Minute
+
 // GitOpsSetTemplate describes a resource to create
 type GitOpsSetTemplate struct {
 	// Repeat is a JSONPath string defining that the template content should be
@@ -20,6 +26,22 @@ type GitOpsSetTemplate struct {
 	Repeat string `json:"repeat,omitempty"`
 	// Content is the YAML to be templated and generated.
 	Content runtime.RawExtension `json:"content"`
+
+	// Wave is used to order the application of the generated resources.
+	//
+	// Resources in lower waves are applied first, and the resources in a wave
+	// must be healthy before the resources in the next wave are applied.
+	// +optional
+	Wave int32 `json:"wave,omitempty"`
+
+	// TargetCluster is the GitopsCluster that the generated resources are
+	// applied to, in the format "namespace/name", or "name" for a
+	// GitopsCluster in the namespace of the GitOpsSet.
+	//
+	// This is templated with the same parameters as the content, so that the
+	// resources can be applied to the clusters from the Cluster generator.
+	// +optional
+	TargetCluster string `json:"targetCluster,omitempty"`
 }
 
 // ClusterGenerator defines a generator that queries the cluster API for
@@ -43,6 +65,10 @@ type ConfigGenerator struct {
 	// Name of the referent.
 	// +required
 	Name string `json:"name"`
+
+	// Namespace of the referent, defaults to the namespace of the GitOpsSet.
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
 }
 
 // ListGenerator generates from a hard-coded list.
@@ -150,6 +176,24 @@ type HeadersReference struct {
 	Name string `json:"name"`
 }
 
+// PluginGenerator defines a generator that requests the elements from an
+// external plugin service.
+type PluginGenerator struct {
+	// ConfigMapRef references a ConfigMap in the same namespace that describes
+	// the plugin service.
+	ConfigMapRef corev1.LocalObjectReference `json:"configMapRef"`
+
+	// Input is passed to the plugin in each request.
+	// +optional
+	Input *apiextensionsv1.JSON `json:"input,omitempty"`
+
+	// The interval at which to request the elements from the plugin.
+	//
+	// The plugin can request an earlier regeneration in the response.
+	// +optional
+	Interval metav1.Duration `json:"interval,omitempty"`
+}
+
 // RepositoryGeneratorFileItem defines a path to a file to be parsed when generating.
 type RepositoryGeneratorFileItem struct {
 	// Path is the name of a file to read and generate from can be JSON or YAML.
@@ -168,6 +212,11 @@ type GitRepositoryGenerator struct {
 	// RepositoryRef is the name of a GitRepository resource to be generated from.
 	RepositoryRef string `json:"repositoryRef,omitempty"`
 
+	// Namespace of the GitRepository, defaults to the namespace of the
+	// GitOpsSet.
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+
 	// Files is a set of rules for identifying files to be parsed.
 	Files []RepositoryGeneratorFileItem `json:"files,omitempty"`
 
@@ -181,6 +230,11 @@ type OCIRepositoryGenerator struct {
 	// RepositoryRef is the name of a OCIRepository resource to be generated from.
 	RepositoryRef string `json:"repositoryRef,omitempty"`
 
+	// Namespace of the OCIRepository, defaults to the namespace of the
+	// GitOpsSet.
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+
 	// Files is a set of rules for identifying files to be parsed.
 	Files []RepositoryGeneratorFileItem `json:"files,omitempty"`
 
@@ -223,12 +277,18 @@ type GitOpsSetNestedGenerator struct {
 	APIClient     *APIClientGenerator     `json:"apiClient,omitempty"`
 	ImagePolicy   *ImagePolicyGenerator   `json:"imagePolicy,omitempty"`
 	Config        *ConfigGenerator        `json:"config,omitempty"`
+	Plugin        *PluginGenerator        `json:"plugin,omitempty"`
 }
 
 // ImagePolicyGenerator generates from the ImagePolicy.
 type ImagePolicyGenerator struct {
 	// PolicyRef is the name of a ImagePolicy resource to be generated from.
 	PolicyRef string `json:"policyRef,omitempty"`
+
+	// Namespace of the ImagePolicy, defaults to the namespace of the
+	// GitOpsSet.
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
 }
 
 // GitOpsSetGenerator is the top-level set of generators for this GitOpsSet.
@@ -242,6 +302,7 @@ type GitOpsSetGenerator struct {
 	APIClient     *APIClientGenerator     `json:"apiClient,omitempty"`
 	ImagePolicy   *ImagePolicyGenerator   `json:"imagePolicy,omitempty"`
 	Config        *ConfigGenerator        `json:"config,omitempty"`
+	Plugin        *PluginGenerator        `json:"plugin,omitempty"`
 }
 
 // GitOpsSetSpec defines the desired state of GitOpsSet
@@ -262,8 +323,203 @@ type GitOpsSetSpec struct {
 	// when reconciling this Kustomization.
 	// +optional
 	ServiceAccountName string `json:"serviceAccountName,omitempty"`
+
+	// ForceConflicts tells the controller to take ownership of fields in the
+	// generated resources that are managed by other field managers when
+	// applying the resources.
+	// +optional
+	ForceConflicts bool `json:"forceConflicts,omitempty"`
+
+	// DriftDetection configures how the controller responds when the generated
+	// resources are changed or deleted in the cluster.
+	//
+	// When enabled, drifted resources are re-applied, when set to warn, the
+	// drift is reported in the DriftDetected condition and an event, but the
+	// resources are not changed.
+	//
+	// Defaults to disabled.
+	// +kubebuilder:validation:Enum=enabled;warn;disabled
+	// +optional
+	DriftDetection DriftDetectionMode `json:"driftDetection,omitempty"`
+
+	// Wait instructs the controller to check the health of all the generated
+	// resources after they are applied, the result is recorded in the Healthy
+	// condition.
+	// +optional
+	Wait bool `json:"wait,omitempty"`
+
+	// Timeout for the health checks of the generated resources.
+	//
+	// Defaults to 5m.
+	// +kubebuilder:validation:Type=string
+	// +kubebuilder:validation:Pattern="^([0-9]+(\\.[0-9]+)?(ms|s|m|h))+$"
+	// +optional
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+
+	// Mode controls whether the generated resources are applied.
+	//
+	// In Plan mode, the changes that would be made to the generated resources
+	// are recorded in the status, but not applied.
+	//
+	// Defaults to Apply.
+	// +kubebuilder:validation:Enum=Apply;Plan
+	// +optional
+	Mode GitOpsSetMode `json:"mode,omitempty"`
+
+	// PruneProtection limits the number of generated resources that can be
+	// deleted in a single reconciliation.
+	// +optional
+	PruneProtection *PruneProtection `json:"pruneProtection,omitempty"`
+
+	// DeletionPolicy controls what happens to the generated resources when the
+	// GitOpsSet is deleted.
+	//
+	// With Delete, the generated resources are deleted, with Orphan, the
+	// generated resources are left in the cluster.
+	//
+	// Defaults to Delete.
+	// +kubebuilder:validation:Enum=Delete;Orphan
+	// +optional
+	DeletionPolicy DeletionPolicy `json:"deletionPolicy,omitempty"`
+
+	// AdoptionPolicy controls whether generated resources that already exist
+	// in the cluster, but are not in the inventory, are adopted.
+	//
+	// With Never, the reconciliation fails, with IfUnowned, resources are
+	// adopted unless they were generated by another GitOpsSet, and with Always,
+	// resources are adopted even if they were generated by another GitOpsSet.
+	//
+	// Defaults to Never.
+	// +kubebuilder:validation:Enum=Never;IfUnowned;Always
+	// +optional
+	AdoptionPolicy AdoptionPolicy `json:"adoptionPolicy,omitempty"`
+
+	// GeneratorErrorPolicy controls what happens when a generator fails.
+	//
+	// With Fail, nothing is applied until all the generators succeed, with
+	// KeepLast, the elements that the generator last generated are used, and
+	// with Skip, the generator's elements are left out, the resources that were
+	// generated from them are removed.
+	//
+	// GitOpsSets are marked as Degraded when they're reconciled with failed
+	// generators.
+	//
+	// Defaults to Fail.
+	// +kubebuilder:validation:Enum=Fail;KeepLast;Skip
+	// +optional
+	GeneratorErrorPolicy GeneratorErrorPolicy `json:"generatorErrorPolicy,omitempty"`
+
+	// MaxRenderedResources is the maximum number of resources that can be
+	// rendered, when more are rendered the reconciliation fails before
+	// anything is applied.
+	//
+	// This overrides the limit configured for the controller, zero means that
+	// the number of resources is not limited.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MaxRenderedResources *int32 `json:"maxRenderedResources,omitempty"`
+
+	// RetryInterval is the interval after which reconciliations that failed
+	// with transient errors are retried, for example, network failures and
+	// server errors from APIs, and GitOpsSets waiting for the artifact of a
+	// source are reconciled again.
+	//
+	// Some jitter is added to the interval, if it's not provided, failures
+	// are retried with an exponential backoff.
+	// +kubebuilder:validation:Type=string
+	// +kubebuilder:validation:Pattern="^([0-9]+(\\.[0-9]+)?(ms|s|m|h))+$"
+	// +optional
+	RetryInterval *metav1.Duration `json:"retryInterval,omitempty"`
+}
+
+// GeneratorErrorPolicy controls what happens when a generator fails.
+type GeneratorErrorPolicy string
+
+const (
+	// FailGeneratorErrorPolicy fails the reconciliation.
+	FailGeneratorErrorPolicy GeneratorErrorPolicy = "Fail"
+
+	// KeepLastGeneratorErrorPolicy uses the elements that the generator last
+	// generated.
+	KeepLastGeneratorErrorPolicy GeneratorErrorPolicy = "KeepLast"
+
+	// SkipGeneratorErrorPolicy leaves out the elements from the generator.
+	SkipGeneratorErrorPolicy GeneratorErrorPolicy = "Skip"
+)
+
+// AdoptionPolicy controls whether existing resources are adopted.
+type AdoptionPolicy string
+
+const (
+	// NeverAdoptionPolicy never adopts existing resources.
+	NeverAdoptionPolicy AdoptionPolicy = "Never"
+
+	// IfUnownedAdoptionPolicy adopts existing resources that were not
+	// generated by another GitOpsSet.
+	IfUnownedAdoptionPolicy AdoptionPolicy = "IfUnowned"
+
+	// AlwaysAdoptionPolicy adopts existing resources.
+	AlwaysAdoptionPolicy AdoptionPolicy = "Always"
+)
+
+// DeletionPolicy controls what happens to the generated resources when the
+// GitOpsSet is deleted.
+type DeletionPolicy string
+
+const (
+	// DeleteDeletionPolicy deletes the generated resources.
+	DeleteDeletionPolicy DeletionPolicy = "Delete"
+
+	// OrphanDeletionPolicy leaves the generated resources in the cluster.
+	OrphanDeletionPolicy DeletionPolicy = "Orphan"
+)
+
+// PruneProtection limits the number of generated resources that can be deleted
+// in a single reconciliation.
+//
+// If either limit would be exceeded, the reconciliation is blocked until the
+// deletions are approved.
+type PruneProtection struct {
+	// MaxDeletions is the maximum number of resources that can be deleted.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MaxDeletions *int32 `json:"maxDeletions,omitempty"`
+
+	// MaxDeletionPercent is the maximum percentage of the resources in the
+	// inventory that can be deleted.
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=100
+	// +optional
+	MaxDeletionPercent *int32 `json:"maxDeletionPercent,omitempty"`
 }
 
+// GitOpsSetMode controls whether the generated resources are applied.
+type GitOpsSetMode string
+
+const (
+	// ApplyMode applies the generated resources.
+	ApplyMode GitOpsSetMode = "Apply"
+
+	// PlanMode records the changes to the generated resources without applying
+	// them.
+	PlanMode GitOpsSetMode = "Plan"
+)
+
+// DriftDetectionMode is the mode for detecting changes to generated resources.
+type DriftDetectionMode string
+
+const (
+	// DriftDetectionEnabled detects and corrects drift in generated resources.
+	DriftDetectionEnabled DriftDetectionMode = "enabled"
+
+	// DriftDetectionWarn detects and reports drift in generated resources
+	// without correcting it.
+	DriftDetectionWarn DriftDetectionMode = "warn"
+
+	// DriftDetectionDisabled disables drift detection.
+	DriftDetectionDisabled DriftDetectionMode = "disabled"
+)
+
 // GitOpsSetStatus defines the observed state of GitOpsSet
 type GitOpsSetStatus struct {
 	meta.ReconcileRequestStatus `json:",inline"`
@@ -281,6 +537,128 @@ type GitOpsSetStatus struct {
 	// have been successfully applied
 	// +optional
 	Inventory *ResourceInventory `json:"inventory,omitempty"`
+
+	// Plan contains the changes that would be made to the generated resources
+	// when the GitOpsSet is in Plan mode.
+	// +optional
+	Plan *GitOpsSetPlan `json:"plan,omitempty"`
+
+	// LastApplied records the rendered resources and the source revisions
+	// that were last applied, and is used to skip applying the resources when
+	// nothing has changed.
+	// +optional
+	LastApplied *AppliedState `json:"lastApplied,omitempty"`
+
+	// Generators contains the status of each of the generators, and the named
+	// generators within Matrix generators.
+	// +optional
+	Generators []GeneratorStatus `json:"generators,omitempty"`
+
+	// Wave records the wave of generated resources that was last applied,
+	// while the resources in the wave are not healthy, the later waves are
+	// applied once they are healthy.
+	// +optional
+	Wave *WaveStatus `json:"wave,omitempty"`
+}
+
+// WaveStatus records a wave of generated resources that was applied.
+type WaveStatus struct {
+	// Number is the number of the wave.
+	Number int `json:"number"`
+
+	// AppliedTime is the time that the wave was applied, the reconciliation
+	// fails if the resources in the wave are not healthy within the timeout.
+	AppliedTime metav1.Time `json:"appliedTime"`
+}
+
+// GeneratorStatus is the status of a generator.
+type GeneratorStatus struct {
+	// Index is the index of the generator in the generators of the GitOpsSet.
+	Index int `json:"index"`
+
+	// Name is the name of a generator within a Matrix generator.
+	// +optional
+	Name string `json:"name,omitempty"`
+
+	// Type is the type of the generator e.g. GitRepository.
+	Type string `json:"type"`
+
+	// Elements is the number of elements generated by the generator.
+	Elements int `json:"elements"`
+
+	// LastGenerated is the time that the generator last successfully
+	// generated elements.
+	// +optional
+	LastGenerated *metav1.Time `json:"lastGenerated,omitempty"`
+
+	// Revision is the revision of the source, or the ETag of the response,
+	// that was consumed by the generator.
+	// +optional
+	Revision string `json:"revision,omitempty"`
+
+	// Error is the error from the last generation if it failed.
+	// +optional
+	Error string `json:"error,omitempty"`
+}
+
+// AppliedState records the state of the last successful apply.
+type AppliedState struct {
+	// Digest is the digest of the rendered resources.
+	Digest string `json:"digest"`
+
+	// Revisions contains the revisions of the sources referenced by the
+	// generators.
+	// +optional
+	Revisions []SourceRevision `json:"revisions,omitempty"`
+
+	// ReconcileRequest is the value of the reconcile request annotation when
+	// the resources were applied.
+	// +optional
+	ReconcileRequest string `json:"reconcileRequest,omitempty"`
+}
+
+// SourceRevision is the revision of a source referenced by a generator.
+type SourceRevision struct {
+	// Kind is the kind of the source e.g. GitRepository.
+	Kind string `json:"kind"`
+
+	// Name is the name of the source.
+	Name string `json:"name"`
+
+	// Namespace is the namespace of the source.
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+
+	// Revision is the revision of the artifact for GitRepository and
+	// OCIRepository sources, and the latest image for ImagePolicy sources.
+	// +optional
+	Revision string `json:"revision,omitempty"`
+
+	// Digest is the digest of the artifact for GitRepository and
+	// OCIRepository sources.
+	// +optional
+	Digest string `json:"digest,omitempty"`
+}
+
+// GitOpsSetPlan contains the changes that would be made to the generated
+// resources if they were applied.
+type GitOpsSetPlan struct {
+	// Create contains the resources that would be created.
+	// +optional
+	Create []ResourceRef `json:"create,omitempty"`
+
+	// Update contains the resources that would be changed.
+	// +optional
+	Update []ResourceRef `json:"update,omitempty"`
+
+	// Delete contains the resources that would be deleted.
+	// +optional
+	Delete []ResourceRef `json:"delete,omitempty"`
+}
+
+// HasChanges returns true if applying the plan would change any resources.
+func (in *GitOpsSetPlan) HasChanges() bool {
+	return len(in.Create) > 0 || len(in.Update) > 0 || len(in.Delete) > 0
 }
 
 //+genclient
@@ -291,6 +669,7 @@ type GitOpsSetStatus struct {
 //+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp",description=""
 //+kubebuilder:printcolumn:name="Ready",type="string",JSONPath=".status.conditions[?(@.type==\"Ready\")].status",description=""
 //+kubebuilder:printcolumn:name="Status",type="string",JSONPath=".status.conditions[?(@.type==\"Ready\")].message",description=""
+//+kubebuilder:printcolumn:name="Elements",type="string",JSONPath=".status.generators[*].elements",description="",priority=1
 
 // GitOpsSet is the Schema for the gitopssets API
 type GitOpsSet struct {
@@ -301,6 +680,16 @@ type GitOpsSet struct {
 	Status GitOpsSetStatus `json:"status,omitempty"`
 }
 
+// GetTimeout returns the timeout for the health checks of the generated
+// resources.
+func (in GitOpsSet) GetTimeout() time.Duration {
+	if in.Spec.Timeout == nil {
+		return DefaultHealthCheckTimeout
+	}
+
+	return in.Spec.Timeout.Duration
+}
+
 // GetConditions returns the status conditions of the object.
 func (in GitOpsSet) GetConditions() []metav1.Condition {
 	return in.Status.Conditions
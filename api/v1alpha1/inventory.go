@@ -11,6 +11,15 @@ import (
 type ResourceInventory struct {
 	// Entries of Kubernetes resource object references.
 	Entries []ResourceRef `json:"entries,omitempty"`
+
+	// ConfigMaps contains the names of the ConfigMaps that the entries are
+	// stored in when there are too many entries to store in the status.
+	// +optional
+	ConfigMaps []string `json:"configMaps,omitempty"`
+
+	// Digest is the digest of the entries stored in the ConfigMaps.
+	// +optional
+	Digest string `json:"digest,omitempty"`
 }
 
 // ResourceRef contains the information necessary to locate a resource within a cluster.
@@ -21,6 +30,12 @@ type ResourceRef struct {
 
 	// Version is the API version of the Kubernetes resource object's kind.
 	Version string `json:"v"`
+
+	// Cluster is the GitopsCluster that the resource was applied to, in the
+	// format "namespace/name", this is empty for resources in the cluster that
+	// the GitOpsSet is in.
+	// +optional
+	Cluster string `json:"cluster,omitempty"`
 }
 
 // ResourceRefFromObject returns a ResourceRef from a runtime.Object.
@@ -47,6 +47,26 @@ func (in *APIClientGenerator) DeepCopy() *APIClientGenerator {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AppliedState) DeepCopyInto(out *AppliedState) {
+	*out = *in
+	if in.Revisions != nil {
+		in, out := &in.Revisions, &out.Revisions
+		*out = make([]SourceRevision, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AppliedState.
+func (in *AppliedState) DeepCopy() *AppliedState {
+	if in == nil {
+		return nil
+	}
+	out := new(AppliedState)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ClusterGenerator) DeepCopyInto(out *ClusterGenerator) {
 	*out = *in
@@ -78,6 +98,25 @@ func (in *ConfigGenerator) DeepCopy() *ConfigGenerator {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GeneratorStatus) DeepCopyInto(out *GeneratorStatus) {
+	*out = *in
+	if in.LastGenerated != nil {
+		in, out := &in.LastGenerated, &out.LastGenerated
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GeneratorStatus.
+func (in *GeneratorStatus) DeepCopy() *GeneratorStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(GeneratorStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *GitOpsSet) DeepCopyInto(out *GitOpsSet) {
 	*out = *in
@@ -153,6 +192,11 @@ func (in *GitOpsSetGenerator) DeepCopyInto(out *GitOpsSetGenerator) {
 		*out = new(ConfigGenerator)
 		**out = **in
 	}
+	if in.Plugin != nil {
+		in, out := &in.Plugin, &out.Plugin
+		*out = new(PluginGenerator)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GitOpsSetGenerator.
@@ -240,6 +284,11 @@ func (in *GitOpsSetNestedGenerator) DeepCopyInto(out *GitOpsSetNestedGenerator)
 		*out = new(ConfigGenerator)
 		**out = **in
 	}
+	if in.Plugin != nil {
+		in, out := &in.Plugin, &out.Plugin
+		*out = new(PluginGenerator)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GitOpsSetNestedGenerator.
@@ -252,6 +301,36 @@ func (in *GitOpsSetNestedGenerator) DeepCopy() *GitOpsSetNestedGenerator {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GitOpsSetPlan) DeepCopyInto(out *GitOpsSetPlan) {
+	*out = *in
+	if in.Create != nil {
+		in, out := &in.Create, &out.Create
+		*out = make([]ResourceRef, len(*in))
+		copy(*out, *in)
+	}
+	if in.Update != nil {
+		in, out := &in.Update, &out.Update
+		*out = make([]ResourceRef, len(*in))
+		copy(*out, *in)
+	}
+	if in.Delete != nil {
+		in, out := &in.Delete, &out.Delete
+		*out = make([]ResourceRef, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GitOpsSetPlan.
+func (in *GitOpsSetPlan) DeepCopy() *GitOpsSetPlan {
+	if in == nil {
+		return nil
+	}
+	out := new(GitOpsSetPlan)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *GitOpsSetSpec) DeepCopyInto(out *GitOpsSetSpec) {
 	*out = *in
@@ -269,6 +348,26 @@ func (in *GitOpsSetSpec) DeepCopyInto(out *GitOpsSetSpec) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.Timeout != nil {
+		in, out := &in.Timeout, &out.Timeout
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.PruneProtection != nil {
+		in, out := &in.PruneProtection, &out.PruneProtection
+		*out = new(PruneProtection)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.MaxRenderedResources != nil {
+		in, out := &in.MaxRenderedResources, &out.MaxRenderedResources
+		*out = new(int32)
+		**out = **in
+	}
+	if in.RetryInterval != nil {
+		in, out := &in.RetryInterval, &out.RetryInterval
+		*out = new(metav1.Duration)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GitOpsSetSpec.
@@ -297,6 +396,28 @@ func (in *GitOpsSetStatus) DeepCopyInto(out *GitOpsSetStatus) {
 		*out = new(ResourceInventory)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.Plan != nil {
+		in, out := &in.Plan, &out.Plan
+		*out = new(GitOpsSetPlan)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.LastApplied != nil {
+		in, out := &in.LastApplied, &out.LastApplied
+		*out = new(AppliedState)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Generators != nil {
+		in, out := &in.Generators, &out.Generators
+		*out = make([]GeneratorStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Wave != nil {
+		in, out := &in.Wave, &out.Wave
+		*out = new(WaveStatus)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GitOpsSetStatus.
@@ -449,6 +570,53 @@ func (in *OCIRepositoryGenerator) DeepCopy() *OCIRepositoryGenerator {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PluginGenerator) DeepCopyInto(out *PluginGenerator) {
+	*out = *in
+	out.ConfigMapRef = in.ConfigMapRef
+	if in.Input != nil {
+		in, out := &in.Input, &out.Input
+		*out = new(v1.JSON)
+		(*in).DeepCopyInto(*out)
+	}
+	out.Interval = in.Interval
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PluginGenerator.
+func (in *PluginGenerator) DeepCopy() *PluginGenerator {
+	if in == nil {
+		return nil
+	}
+	out := new(PluginGenerator)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PruneProtection) DeepCopyInto(out *PruneProtection) {
+	*out = *in
+	if in.MaxDeletions != nil {
+		in, out := &in.MaxDeletions, &out.MaxDeletions
+		*out = new(int32)
+		**out = **in
+	}
+	if in.MaxDeletionPercent != nil {
+		in, out := &in.MaxDeletionPercent, &out.MaxDeletionPercent
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PruneProtection.
+func (in *PruneProtection) DeepCopy() *PruneProtection {
+	if in == nil {
+		return nil
+	}
+	out := new(PruneProtection)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PullRequestGenerator) DeepCopyInto(out *PullRequestGenerator) {
 	*out = *in
@@ -513,6 +681,11 @@ func (in *ResourceInventory) DeepCopyInto(out *ResourceInventory) {
 		*out = make([]ResourceRef, len(*in))
 		copy(*out, *in)
 	}
+	if in.ConfigMaps != nil {
+		in, out := &in.ConfigMaps, &out.ConfigMaps
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResourceInventory.
@@ -539,3 +712,34 @@ func (in *ResourceRef) DeepCopy() *ResourceRef {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SourceRevision) DeepCopyInto(out *SourceRevision) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SourceRevision.
+func (in *SourceRevision) DeepCopy() *SourceRevision {
+	if in == nil {
+		return nil
+	}
+	out := new(SourceRevision)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WaveStatus) DeepCopyInto(out *WaveStatus) {
+	*out = *in
+	in.AppliedTime.DeepCopyInto(&out.AppliedTime)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WaveStatus.
+func (in *WaveStatus) DeepCopy() *WaveStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(WaveStatus)
+	in.DeepCopyInto(out)
+	return out
+}
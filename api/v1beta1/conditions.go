@@ -0,0 +1,226 @@
+package v1beta1
+
+import (
+	"github.com/fluxcd/pkg/apis/meta"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// ReconciliationFailedReason represents the fact that
+	// the reconciliation failed.
+	ReconciliationFailedReason string = "ReconciliationFailed"
+
+	// ReconciliationSucceededReason represents the fact that
+	// the reconciliation succeeded.
+	ReconciliationSucceededReason string = "ReconciliationSucceeded"
+
+	// DriftDetectedReason represents the fact that generated resources have
+	// been changed or deleted in the cluster.
+	DriftDetectedReason string = "DriftDetected"
+
+	// HealthCheckFailedReason represents the fact that
+	// the generated resources are not healthy.
+	HealthCheckFailedReason string = "HealthCheckFailed"
+
+	// HealthCheckSucceededReason represents the fact that
+	// the generated resources are healthy.
+	HealthCheckSucceededReason string = "HealthCheckSucceeded"
+
+	// PlanPendingReason represents the fact that the GitOpsSet is in Plan
+	// mode and there are changes that have not been applied.
+	PlanPendingReason string = "PlanPending"
+
+	// PruneBlockedReason represents the fact that the reconciliation would
+	// delete more resources than the prune protection allows.
+	PruneBlockedReason string = "PruneBlocked"
+
+	// OwnershipConflictReason represents the fact that a generated resource
+	// already exists and is owned by another GitOpsSet.
+	OwnershipConflictReason string = "OwnershipConflict"
+
+	// ArtifactFailedReason represents the fact that the artifact of a source
+	// that a generator reads is not available.
+	ArtifactFailedReason string = "ArtifactFailed"
+
+	// RenderFailedReason represents the fact that the generators or the
+	// templates failed to produce the resources.
+	RenderFailedReason string = "RenderFailed"
+
+	// ApplyFailedReason represents the fact that the generated resources
+	// could not be applied.
+	ApplyFailedReason string = "ApplyFailed"
+
+	// PruneFailedReason represents the fact that resources that are no
+	// longer generated could not be removed.
+	PruneFailedReason string = "PruneFailed"
+
+	// GeneratorFailedReason represents the fact that generators failed, and
+	// the GitOpsSet was reconciled without them because of the generator
+	// error policy.
+	GeneratorFailedReason string = "GeneratorFailed"
+
+	// RenderLimitExceededReason represents the fact that more resources were
+	// rendered than the limit allows.
+	RenderLimitExceededReason string = "RenderLimitExceeded"
+
+	// AccessDeniedReason represents the fact that a generator references an
+	// object in another namespace that it's not allowed to access.
+	AccessDeniedReason string = "AccessDenied"
+
+	// PolicyViolationReason represents the fact that rendered resources are
+	// not allowed by a GitOpsSetPolicy.
+	PolicyViolationReason string = "PolicyViolation"
+)
+
+const (
+	// DriftDetectedCondition indicates that generated resources differ from the
+	// resources that were rendered from the templates.
+	DriftDetectedCondition string = "DriftDetected"
+
+	// HealthyCondition indicates the health of the generated resources.
+	HealthyCondition string = "Healthy"
+
+	// DegradedCondition indicates that the GitOpsSet was reconciled without
+	// the current elements from all of its generators.
+	DegradedCondition string = "Degraded"
+)
+
+// SetGitOpsSetReadiness sets the ready condition with the given status, reason and message.
+//
+// When the status is True the reconciliation is complete, the Reconciling and
+// Stalled conditions are removed and the generation is observed. Otherwise
+// the Reconciling condition records that the reconciliation will be retried.
+func SetGitOpsSetReadiness(set *GitOpsSet, inventory *ResourceInventory, status metav1.ConditionStatus, reason, message string) {
+	setInventory(set, inventory)
+
+	newCondition := metav1.Condition{
+		Type:    meta.ReadyCondition,
+		Status:  status,
+		Reason:  reason,
+		Message: message,
+	}
+	apimeta.SetStatusCondition(&set.Status.Conditions, newCondition)
+
+	if status == metav1.ConditionTrue {
+		apimeta.RemoveStatusCondition(&set.Status.Conditions, meta.StalledCondition)
+		ClearGitOpsSetReconciling(set)
+		return
+	}
+
+	if reconciling := apimeta.FindStatusCondition(set.Status.Conditions, meta.ReconcilingCondition); reconciling != nil {
+		apimeta.SetStatusCondition(&set.Status.Conditions, metav1.Condition{
+			Type:    meta.ReconcilingCondition,
+			Status:  metav1.ConditionTrue,
+			Reason:  meta.ProgressingWithRetryReason,
+			Message: reconciling.Message,
+		})
+	}
+}
+
+// SetGitOpsSetReconciling sets the Reconciling condition with the given reason
+// and message.
+func SetGitOpsSetReconciling(set *GitOpsSet, reason, message string) {
+	apimeta.SetStatusCondition(&set.Status.Conditions, metav1.Condition{
+		Type:    meta.ReconcilingCondition,
+		Status:  metav1.ConditionTrue,
+		Reason:  reason,
+		Message: message,
+	})
+}
+
+// ClearGitOpsSetReconciling removes the Reconciling condition and records the
+// generation as observed.
+//
+// This completes reconciliations that leave the GitOpsSet not ready without
+// failing, for example when changes are waiting to be applied.
+func ClearGitOpsSetReconciling(set *GitOpsSet) {
+	apimeta.RemoveStatusCondition(&set.Status.Conditions, meta.ReconcilingCondition)
+	set.Status.ObservedGeneration = set.ObjectMeta.Generation
+}
+
+// SetGitOpsSetStalled marks the GitOpsSet as not ready and stalled with the
+// given reason and message.
+//
+// Stalled GitOpsSets can't be reconciled until they're changed, so the
+// generation is observed.
+func SetGitOpsSetStalled(set *GitOpsSet, inventory *ResourceInventory, reason, message string) {
+	setInventory(set, inventory)
+
+	apimeta.SetStatusCondition(&set.Status.Conditions, metav1.Condition{
+		Type:    meta.ReadyCondition,
+		Status:  metav1.ConditionFalse,
+		Reason:  reason,
+		Message: message,
+	})
+	apimeta.SetStatusCondition(&set.Status.Conditions, metav1.Condition{
+		Type:    meta.StalledCondition,
+		Status:  metav1.ConditionTrue,
+		Reason:  reason,
+		Message: message,
+	})
+	ClearGitOpsSetReconciling(set)
+}
+
+func setInventory(set *GitOpsSet, inventory *ResourceInventory) {
+	if inventory == nil {
+		return
+	}
+
+	set.Status.Inventory = inventory
+	if len(inventory.Entries) == 0 && len(inventory.ConfigMaps) == 0 {
+		set.Status.Inventory = nil
+	}
+}
+
+// GetGitOpsSetReadiness returns the readiness condition of the GitOpsSet.
+func GetGitOpsSetReadiness(set *GitOpsSet) metav1.ConditionStatus {
+	return apimeta.FindStatusCondition(set.Status.Conditions, meta.ReadyCondition).Status
+}
+
+// SetGitOpsSetDriftDetected sets the DriftDetected condition with the given
+// message.
+func SetGitOpsSetDriftDetected(set *GitOpsSet, message string) {
+	apimeta.SetStatusCondition(&set.Status.Conditions, metav1.Condition{
+		Type:    DriftDetectedCondition,
+		Status:  metav1.ConditionTrue,
+		Reason:  DriftDetectedReason,
+		Message: message,
+	})
+}
+
+// ClearGitOpsSetDriftDetected removes the DriftDetected condition.
+func ClearGitOpsSetDriftDetected(set *GitOpsSet) {
+	apimeta.RemoveStatusCondition(&set.Status.Conditions, DriftDetectedCondition)
+}
+
+// SetGitOpsSetDegraded sets the Degraded condition with the given message.
+func SetGitOpsSetDegraded(set *GitOpsSet, message string) {
+	apimeta.SetStatusCondition(&set.Status.Conditions, metav1.Condition{
+		Type:    DegradedCondition,
+		Status:  metav1.ConditionTrue,
+		Reason:  GeneratorFailedReason,
+		Message: message,
+	})
+}
+
+// ClearGitOpsSetDegraded removes the Degraded condition.
+func ClearGitOpsSetDegraded(set *GitOpsSet) {
+	apimeta.RemoveStatusCondition(&set.Status.Conditions, DegradedCondition)
+}
+
+// SetGitOpsSetHealthiness sets the Healthy condition with the given status,
+// reason and message.
+func SetGitOpsSetHealthiness(set *GitOpsSet, status metav1.ConditionStatus, reason, message string) {
+	apimeta.SetStatusCondition(&set.Status.Conditions, metav1.Condition{
+		Type:    HealthyCondition,
+		Status:  status,
+		Reason:  reason,
+		Message: message,
+	})
+}
+
+// ClearGitOpsSetHealthiness removes the Healthy condition.
+func ClearGitOpsSetHealthiness(set *GitOpsSet) {
+	apimeta.RemoveStatusCondition(&set.Status.Conditions, HealthyCondition)
+}
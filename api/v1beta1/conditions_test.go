@@ -0,0 +1,75 @@
+package v1beta1
+
+import (
+	"testing"
+
+	"github.com/fluxcd/pkg/apis/meta"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestSetGitOpsSetReadiness(t *testing.T) {
+	t.Run("ready", func(t *testing.T) {
+		set := newReconcilingGitOpsSet()
+		set.Status.Conditions = append(set.Status.Conditions, metav1.Condition{Type: meta.StalledCondition, Status: metav1.ConditionTrue})
+
+		SetGitOpsSetReadiness(set, nil, metav1.ConditionTrue, ReconciliationSucceededReason, "1 resources created")
+
+		assertConditions(t, set, []metav1.Condition{
+			{Type: meta.ReadyCondition, Status: metav1.ConditionTrue, Reason: ReconciliationSucceededReason, Message: "1 resources created"},
+		})
+		assertObservedGeneration(t, set, 2)
+	})
+
+	t.Run("not ready", func(t *testing.T) {
+		set := newReconcilingGitOpsSet()
+
+		SetGitOpsSetReadiness(set, nil, metav1.ConditionFalse, ApplyFailedReason, "failed to apply")
+
+		assertConditions(t, set, []metav1.Condition{
+			{Type: meta.ReconcilingCondition, Status: metav1.ConditionTrue, Reason: meta.ProgressingWithRetryReason, Message: "Reconciliation in progress"},
+			{Type: meta.ReadyCondition, Status: metav1.ConditionFalse, Reason: ApplyFailedReason, Message: "failed to apply"},
+		})
+		assertObservedGeneration(t, set, 1)
+	})
+}
+
+func TestSetGitOpsSetStalled(t *testing.T) {
+	set := newReconcilingGitOpsSet()
+
+	SetGitOpsSetStalled(set, &ResourceInventory{}, RenderFailedReason, "invalid template")
+
+	assertConditions(t, set, []metav1.Condition{
+		{Type: meta.ReadyCondition, Status: metav1.ConditionFalse, Reason: RenderFailedReason, Message: "invalid template"},
+		{Type: meta.StalledCondition, Status: metav1.ConditionTrue, Reason: RenderFailedReason, Message: "invalid template"},
+	})
+	assertObservedGeneration(t, set, 2)
+	if set.Status.Inventory != nil {
+		t.Fatalf("got inventory %#v, want nil for an empty inventory", set.Status.Inventory)
+	}
+}
+
+func newReconcilingGitOpsSet() *GitOpsSet {
+	set := &GitOpsSet{
+		ObjectMeta: metav1.ObjectMeta{Generation: 2},
+		Status:     GitOpsSetStatus{ObservedGeneration: 1},
+	}
+	SetGitOpsSetReconciling(set, meta.ProgressingReason, "Reconciliation in progress")
+
+	return set
+}
+
+func assertConditions(t *testing.T, set *GitOpsSet, want []metav1.Condition) {
+	t.Helper()
+	if diff := cmp.Diff(want, set.Status.Conditions, cmpopts.IgnoreFields(metav1.Condition{}, "LastTransitionTime")); diff != "" {
+		t.Fatalf("failed to set conditions:\n%s", diff)
+	}
+}
+
+func assertObservedGeneration(t *testing.T, set *GitOpsSet, want int64) {
+	t.Helper()
+	if set.Status.ObservedGeneration != want {
+		t.Fatalf("got observed generation %d, want %d", set.Status.ObservedGeneration, want)
+	}
+}
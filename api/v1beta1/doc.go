@@ -0,0 +1,3 @@
+// Package v1beta1 contains API Schema definitions for the gitopssets v1beta1 API group
+// +groupName=templates.weave.works
+package v1beta1
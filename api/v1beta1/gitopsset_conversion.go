@@ -0,0 +1,5 @@
+package v1beta1
+
+// Hub marks this type as a conversion hub, the other versions of GitOpsSet are
+// converted to and from this version.
+func (*GitOpsSet) Hub() {}
@@ -0,0 +1,751 @@
+package v1beta1
+
+import (
+	"time"
+
+	"github.com/fluxcd/pkg/apis/meta"
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	runtime "k8s.io/apimachinery/pkg/runtime"
+)
+
+// GitOpsSetFinalizer is the finalizer added to GitOpsSets to allow us to clean
+// up resources.
+const GitOpsSetFinalizer = "finalizers.templates.weave.works"
+
+// DefaultHealthCheckTimeout is the timeout for the health checks of the
+// generated resources if no timeout is configured.
+const DefaultHealthCheckTimeout = 5 * time.Minute
+
+// GitOpsSetTemplate describes a resource to create
+type GitOpsSetTemplate struct {
+	// Repeat is a JSONPath string defining that the template content should be
+	// repeated for each of the matching elements in the JSONPath expression.
+	// https://kubernetes.io/docs/reference/kubectl/jsonpath/
+	Repeat string `json:"repeat,omitempty"`
+	// Content is the YAML to be templated and generated.
+	Content runtime.RawExtension `json:"content"`
+
+	// Wave is used to order the application of the generated resources.
+	//
+	// Resources in lower waves are applied first, and the resources in a wave
+	// must be healthy before the resources in the next wave are applied.
+	// +optional
+	Wave int32 `json:"wave,omitempty"`
+
+	// TargetCluster is the GitopsCluster that the generated resources are
+	// applied to, in the format "namespace/name", or "name" for a
+	// GitopsCluster in the namespace of the GitOpsSet.
+	//
+	// This is templated with the same parameters as the content, so that the
+	// resources can be applied to the clusters from the Cluster generator.
+	// +optional
+	TargetCluster string `json:"targetCluster,omitempty"`
+}
+
+// ClusterGenerator defines a generator that queries the cluster API for
+// relevant clusters.
+type ClusterGenerator struct {
+	// Selector is used to filter the clusters that you want to target.
+	//
+	// If no selector is provided, no clusters will be matched.
+	// +optional
+	Selector metav1.LabelSelector `json:"selector,omitempty"`
+}
+
+// ConfigGenerator loads a referenced ConfigMap or
+// Secret from the Cluster and makes it available as a resource.
+type ConfigGenerator struct {
+	// Kind of the referent.
+	// +kubebuilder:validation:Enum=ConfigMap;Secret
+	// +required
+	Kind string `json:"kind"`
+
+	// Name of the referent.
+	// +required
+	Name string `json:"name"`
+
+	// Namespace of the referent, defaults to the namespace of the GitOpsSet.
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// ListGenerator generates from a hard-coded list.
+type ListGenerator struct {
+	Elements []apiextensionsv1.JSON `json:"elements,omitempty"`
+}
+
+// PullRequestGenerator defines a generator that queries a Git hosting service
+// for relevant PRs.
+type PullRequestGenerator struct {
+	// The interval at which to check for repository updates.
+	// +required
+	Interval metav1.Duration `json:"interval"`
+	// TODO: Fill this out with the rest of the elements from
+	// https://github.com/jenkins-x/go-scm/blob/main/scm/factory/factory.go
+
+	// Determines which git-api protocol to use.
+	// +kubebuilder:validation:Enum=github;gitlab;bitbucketserver
+	Driver string `json:"driver"`
+	// This is the API endpoint to use.
+	// +kubebuilder:validation:Pattern="^https://"
+	// +optional
+	ServerURL string `json:"serverURL,omitempty"`
+	// This should be the Repo you want to query.
+	// e.g. my-org/my-repo
+	// +required
+	Repo string `json:"repo"`
+
+	// Reference to Secret in same namespace with a field "password" which is an
+	// auth token that can query the Git Provider API.
+	SecretRef *corev1.LocalObjectReference `json:"secretRef,omitempty"`
+
+	// Labels is used to filter the PRs that you want to target.
+	// This may be applied on the server.
+	// +optional
+	Labels []string `json:"labels,omitempty"`
+
+	// ForkPolicy controls whether PRs from forks of the repository are
+	// included.
+	//
+	// Defaults to Exclude.
+	// +kubebuilder:default=Exclude
+	// +kubebuilder:validation:Enum=Include;Exclude
+	// +optional
+	ForkPolicy ForkPolicy `json:"forkPolicy,omitempty"`
+}
+
+// ForkPolicy controls whether PRs from forks are included.
+type ForkPolicy string
+
+const (
+	// IncludeForkPolicy includes PRs from forks.
+	IncludeForkPolicy ForkPolicy = "Include"
+
+	// ExcludeForkPolicy excludes PRs from forks.
+	ExcludeForkPolicy ForkPolicy = "Exclude"
+)
+
+// APIClientGenerator defines a generator that queries an API endpoint and uses
+// that to generate data.
+type APIClientGenerator struct {
+	// The interval at which to poll the API endpoint.
+	// +required
+	Interval metav1.Duration `json:"interval"`
+
+	// This is the API endpoint to use.
+	// +kubebuilder:validation:Pattern="^(http|https)://"
+	// +optional
+	Endpoint string `json:"endpoint,omitempty"`
+
+	// Method defines the HTTP method to use to talk to the endpoint.
+	// +kubebuilder:default="GET"
+	// +kubebuilder:validation:Enum=GET;POST
+	Method string `json:"method,omitempty"`
+
+	// JSONPath is string that is used to modify the result of the API
+	// call.
+	//
+	// This can be used to extract a repeating element from a response.
+	// https://kubernetes.io/docs/reference/kubectl/jsonpath/
+	JSONPath string `json:"jsonPath,omitempty"`
+
+	// HeadersRef allows optional configuration of a Secret or ConfigMap to add
+	// additional headers to an outgoing request.
+	//
+	// For example, a Secret with a key Authorization: Bearer abc123 could be
+	// used to configure an authorization header.
+	//
+	// +optional
+	HeadersRef *HeadersReference `json:"headersRef,omitempty"`
+
+	// Body is set as the body in a POST request.
+	//
+	// If set, this will configure the Method to be POST automatically.
+	// +optional
+	Body *apiextensionsv1.JSON `json:"body,omitempty"`
+
+	// SingleElement means generate a single element with the result of the API
+	// call.
+	//
+	// When true, the response must be a JSON object and will be returned as a
+	// single element, i.e. only one element will be generated containing the
+	// entire object.
+	//
+	// +optional
+	SingleElement bool `json:"singleElement,omitempty"`
+
+	// TLS configures the TLS connection to the API endpoint.
+	// +optional
+	TLS *APIClientTLS `json:"tls,omitempty"`
+}
+
+// APIClientTLS configures the TLS connection to an API endpoint.
+type APIClientTLS struct {
+	// CASecretRef references a Secret in the same namespace with a field
+	// "caFile" which provides the Certificate Authority to trust when making
+	// API calls.
+	CASecretRef corev1.LocalObjectReference `json:"caSecretRef"`
+}
+
+// HeadersReference references either a Secret or ConfigMap to be used for
+// additional request headers.
+type HeadersReference struct {
+	// The resource kind to get headers from.
+	// +kubebuilder:validation:Enum=Secret;ConfigMap
+	Kind string `json:"kind"`
+	// Name of the resource in the same namespace to apply headers from.
+	Name string `json:"name"`
+}
+
+// PluginGenerator defines a generator that requests the elements from an
+// external plugin service.
+type PluginGenerator struct {
+	// ConfigMapRef references a ConfigMap in the same namespace that describes
+	// the plugin service.
+	ConfigMapRef corev1.LocalObjectReference `json:"configMapRef"`
+
+	// Input is passed to the plugin in each request.
+	// +optional
+	Input *apiextensionsv1.JSON `json:"input,omitempty"`
+
+	// The interval at which to request the elements from the plugin.
+	//
+	// The plugin can request an earlier regeneration in the response.
+	// +optional
+	Interval metav1.Duration `json:"interval,omitempty"`
+}
+
+// RepositoryGeneratorFileItem defines a path to a file to be parsed when generating.
+type RepositoryGeneratorFileItem struct {
+	// Path is the name of a file to read and generate from can be JSON or YAML.
+	Path string `json:"path"`
+}
+
+// RepositoryGeneratorDirectoryItem stores the information about a specific
+// directory to be generated from.
+type RepositoryGeneratorDirectoryItem struct {
+	Path    string `json:"path"`
+	Exclude bool   `json:"exclude,omitempty"`
+}
+
+// GitRepositoryGenerator generates from files in a Flux GitRepository resource.
+type GitRepositoryGenerator struct {
+	// RepositoryRef is the name of a GitRepository resource to be generated from.
+	RepositoryRef string `json:"repositoryRef,omitempty"`
+
+	// Namespace of the GitRepository, defaults to the namespace of the
+	// GitOpsSet.
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+
+	// Files is a set of rules for identifying files to be parsed.
+	Files []RepositoryGeneratorFileItem `json:"files,omitempty"`
+
+	// Directories is a set of rules for identifying directories to be
+	// generated.
+	Directories []RepositoryGeneratorDirectoryItem `json:"directories,omitempty"`
+}
+
+// OCIRepositoryGenerator generates from files in a Flux OCIRepository resource.
+type OCIRepositoryGenerator struct {
+	// RepositoryRef is the name of a OCIRepository resource to be generated from.
+	RepositoryRef string `json:"repositoryRef,omitempty"`
+
+	// Namespace of the OCIRepository, defaults to the namespace of the
+	// GitOpsSet.
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+
+	// Files is a set of rules for identifying files to be parsed.
+	Files []RepositoryGeneratorFileItem `json:"files,omitempty"`
+
+	// Directories is a set of rules for identifying directories to be
+	// generated.
+	Directories []RepositoryGeneratorDirectoryItem `json:"directories,omitempty"`
+}
+
+// MatrixGenerator defines a matrix that combines generators.
+// The matrix is a cartesian product of the generators.
+type MatrixGenerator struct {
+	// Generators is a list of generators to be combined.
+	Generators []GitOpsSetNestedGenerator `json:"generators,omitempty"`
+
+	// SingleElement means generate a single element with the result of the
+	// merged generator elements.
+	//
+	// When true, the matrix elements will be merged to a single element, with
+	// whatever prefixes they have.
+	// It's recommended that you use the Name field to separate out elements.
+	//
+	// +optional
+	SingleElement bool `json:"singleElement,omitempty"`
+}
+
+// GitOpsSetNestedGenerator describes the generators usable by the MatrixGenerator.
+// This is a subset of the generators allowed by the GitOpsSetGenerator because the CRD format doesn't support recursive declarations.
+type GitOpsSetNestedGenerator struct {
+	// Name is an optional field that will be used to prefix the values generated
+	// by the nested generators, this allows multiple generators of the same
+	// type in a single Matrix generator.
+	// +optional
+	Name string `json:"name,omitempty"`
+
+	List          *ListGenerator          `json:"list,omitempty"`
+	GitRepository *GitRepositoryGenerator `json:"gitRepository,omitempty"`
+	OCIRepository *OCIRepositoryGenerator `json:"ociRepository,omitempty"`
+	PullRequests  *PullRequestGenerator   `json:"pullRequests,omitempty"`
+	Cluster       *ClusterGenerator       `json:"cluster,omitempty"`
+	APIClient     *APIClientGenerator     `json:"apiClient,omitempty"`
+	ImagePolicy   *ImagePolicyGenerator   `json:"imagePolicy,omitempty"`
+	Config        *ConfigGenerator        `json:"config,omitempty"`
+	Plugin        *PluginGenerator        `json:"plugin,omitempty"`
+}
+
+// ImagePolicyGenerator generates from the ImagePolicy.
+type ImagePolicyGenerator struct {
+	// PolicyRef is the name of a ImagePolicy resource to be generated from.
+	PolicyRef string `json:"policyRef,omitempty"`
+
+	// Namespace of the ImagePolicy, defaults to the namespace of the
+	// GitOpsSet.
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// GitOpsSetGenerator is the top-level set of generators for this GitOpsSet.
+type GitOpsSetGenerator struct {
+	List          *ListGenerator          `json:"list,omitempty"`
+	PullRequests  *PullRequestGenerator   `json:"pullRequests,omitempty"`
+	GitRepository *GitRepositoryGenerator `json:"gitRepository,omitempty"`
+	OCIRepository *OCIRepositoryGenerator `json:"ociRepository,omitempty"`
+	Matrix        *MatrixGenerator        `json:"matrix,omitempty"`
+	Cluster       *ClusterGenerator       `json:"cluster,omitempty"`
+	APIClient     *APIClientGenerator     `json:"apiClient,omitempty"`
+	ImagePolicy   *ImagePolicyGenerator   `json:"imagePolicy,omitempty"`
+	Config        *ConfigGenerator        `json:"config,omitempty"`
+	Plugin        *PluginGenerator        `json:"plugin,omitempty"`
+}
+
+// GitOpsSetSpec defines the desired state of GitOpsSet
+type GitOpsSetSpec struct {
+	// Suspend tells the controller to suspend the reconciliation of this
+	// GitOpsSet.
+	// +optional
+	Suspend bool `json:"suspend,omitempty"`
+
+	// Generators generate the data to be inserted into the provided templates.
+	Generators []GitOpsSetGenerator `json:"generators,omitempty"`
+
+	// Templates are a set of YAML templates that are rendered into resources
+	// from the data supplied by the generators.
+	Templates []GitOpsSetTemplate `json:"templates,omitempty"`
+
+	// The name of the Kubernetes service account to impersonate
+	// when reconciling this Kustomization.
+	// +optional
+	ServiceAccountName string `json:"serviceAccountName,omitempty"`
+
+	// ForceConflicts tells the controller to take ownership of fields in the
+	// generated resources that are managed by other field managers when
+	// applying the resources.
+	// +optional
+	ForceConflicts bool `json:"forceConflicts,omitempty"`
+
+	// DriftDetection configures how the controller responds when the generated
+	// resources are changed or deleted in the cluster.
+	//
+	// When enabled, drifted resources are re-applied, when set to warn, the
+	// drift is reported in the DriftDetected condition and an event, but the
+	// resources are not changed.
+	//
+	// Defaults to disabled.
+	// +kubebuilder:validation:Enum=enabled;warn;disabled
+	// +optional
+	DriftDetection DriftDetectionMode `json:"driftDetection,omitempty"`
+
+	// Wait instructs the controller to check the health of all the generated
+	// resources after they are applied, the result is recorded in the Healthy
+	// condition.
+	// +optional
+	Wait bool `json:"wait,omitempty"`
+
+	// Timeout for the health checks of the generated resources.
+	//
+	// Defaults to 5m.
+	// +kubebuilder:validation:Type=string
+	// +kubebuilder:validation:Pattern="^([0-9]+(\\.[0-9]+)?(ms|s|m|h))+$"
+	// +optional
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+
+	// Mode controls whether the generated resources are applied.
+	//
+	// In Plan mode, the changes that would be made to the generated resources
+	// are recorded in the status, but not applied.
+	//
+	// Defaults to Apply.
+	// +kubebuilder:validation:Enum=Apply;Plan
+	// +optional
+	Mode GitOpsSetMode `json:"mode,omitempty"`
+
+	// PruneProtection limits the number of generated resources that can be
+	// deleted in a single reconciliation.
+	// +optional
+	PruneProtection *PruneProtection `json:"pruneProtection,omitempty"`
+
+	// DeletionPolicy controls what happens to the generated resources when the
+	// GitOpsSet is deleted.
+	//
+	// With Delete, the generated resources are deleted, with Orphan, the
+	// generated resources are left in the cluster.
+	//
+	// Defaults to Delete.
+	// +kubebuilder:validation:Enum=Delete;Orphan
+	// +optional
+	DeletionPolicy DeletionPolicy `json:"deletionPolicy,omitempty"`
+
+	// AdoptionPolicy controls whether generated resources that already exist
+	// in the cluster, but are not in the inventory, are adopted.
+	//
+	// With Never, the reconciliation fails, with IfUnowned, resources are
+	// adopted unless they were generated by another GitOpsSet, and with Always,
+	// resources are adopted even if they were generated by another GitOpsSet.
+	//
+	// Defaults to Never.
+	// +kubebuilder:validation:Enum=Never;IfUnowned;Always
+	// +optional
+	AdoptionPolicy AdoptionPolicy `json:"adoptionPolicy,omitempty"`
+
+	// GeneratorErrorPolicy controls what happens when a generator fails.
+	//
+	// With Fail, nothing is applied until all the generators succeed, with
+	// KeepLast, the elements that the generator last generated are used, and
+	// with Skip, the generator's elements are left out, the resources that were
+	// generated from them are removed.
+	//
+	// GitOpsSets are marked as Degraded when they're reconciled with failed
+	// generators.
+	//
+	// Defaults to Fail.
+	// +kubebuilder:validation:Enum=Fail;KeepLast;Skip
+	// +optional
+	GeneratorErrorPolicy GeneratorErrorPolicy `json:"generatorErrorPolicy,omitempty"`
+
+	// MaxRenderedResources is the maximum number of resources that can be
+	// rendered, when more are rendered the reconciliation fails before
+	// anything is applied.
+	//
+	// This overrides the limit configured for the controller, zero means that
+	// the number of resources is not limited.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MaxRenderedResources *int32 `json:"maxRenderedResources,omitempty"`
+
+	// RetryInterval is the interval after which reconciliations that failed
+	// with transient errors are retried, for example, network failures and
+	// server errors from APIs, and GitOpsSets waiting for the artifact of a
+	// source are reconciled again.
+	//
+	// Some jitter is added to the interval, if it's not provided, failures
+	// are retried with an exponential backoff.
+	// +kubebuilder:validation:Type=string
+	// +kubebuilder:validation:Pattern="^([0-9]+(\\.[0-9]+)?(ms|s|m|h))+$"
+	// +optional
+	RetryInterval *metav1.Duration `json:"retryInterval,omitempty"`
+}
+
+// GeneratorErrorPolicy controls what happens when a generator fails.
+type GeneratorErrorPolicy string
+
+const (
+	// FailGeneratorErrorPolicy fails the reconciliation.
+	FailGeneratorErrorPolicy GeneratorErrorPolicy = "Fail"
+
+	// KeepLastGeneratorErrorPolicy uses the elements that the generator last
+	// generated.
+	KeepLastGeneratorErrorPolicy GeneratorErrorPolicy = "KeepLast"
+
+	// SkipGeneratorErrorPolicy leaves out the elements from the generator.
+	SkipGeneratorErrorPolicy GeneratorErrorPolicy = "Skip"
+)
+
+// AdoptionPolicy controls whether existing resources are adopted.
+type AdoptionPolicy string
+
+const (
+	// NeverAdoptionPolicy never adopts existing resources.
+	NeverAdoptionPolicy AdoptionPolicy = "Never"
+
+	// IfUnownedAdoptionPolicy adopts existing resources that were not
+	// generated by another GitOpsSet.
+	IfUnownedAdoptionPolicy AdoptionPolicy = "IfUnowned"
+
+	// AlwaysAdoptionPolicy adopts existing resources.
+	AlwaysAdoptionPolicy AdoptionPolicy = "Always"
+)
+
+// DeletionPolicy controls what happens to the generated resources when the
+// GitOpsSet is deleted.
+type DeletionPolicy string
+
+const (
+	// DeleteDeletionPolicy deletes the generated resources.
+	DeleteDeletionPolicy DeletionPolicy = "Delete"
+
+	// OrphanDeletionPolicy leaves the generated resources in the cluster.
+	OrphanDeletionPolicy DeletionPolicy = "Orphan"
+)
+
+// PruneProtection limits the number of generated resources that can be deleted
+// in a single reconciliation.
+//
+// If either limit would be exceeded, the reconciliation is blocked until the
+// deletions are approved.
+type PruneProtection struct {
+	// MaxDeletions is the maximum number of resources that can be deleted.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MaxDeletions *int32 `json:"maxDeletions,omitempty"`
+
+	// MaxDeletionPercent is the maximum percentage of the resources in the
+	// inventory that can be deleted.
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=100
+	// +optional
+	MaxDeletionPercent *int32 `json:"maxDeletionPercent,omitempty"`
+}
+
+// GitOpsSetMode controls whether the generated resources are applied.
+type GitOpsSetMode string
+
+const (
+	// ApplyMode applies the generated resources.
+	ApplyMode GitOpsSetMode = "Apply"
+
+	// PlanMode records the changes to the generated resources without applying
+	// them.
+	PlanMode GitOpsSetMode = "Plan"
+)
+
+// PlanAnnotation can be added to a GitOpsSet with the value "true" to plan the
+// changes to the generated resources without applying them.
+//
+// This is equivalent to setting the mode to Plan.
+const PlanAnnotation = "templates.weave.works/plan"
+
+// DriftDetectionMode is the mode for detecting changes to generated resources.
+type DriftDetectionMode string
+
+const (
+	// DriftDetectionEnabled detects and corrects drift in generated resources.
+	DriftDetectionEnabled DriftDetectionMode = "enabled"
+
+	// DriftDetectionWarn detects and reports drift in generated resources
+	// without correcting it.
+	DriftDetectionWarn DriftDetectionMode = "warn"
+
+	// DriftDetectionDisabled disables drift detection.
+	DriftDetectionDisabled DriftDetectionMode = "disabled"
+)
+
+// GitOpsSetStatus defines the observed state of GitOpsSet
+type GitOpsSetStatus struct {
+	meta.ReconcileRequestStatus `json:",inline"`
+
+	// ObservedGeneration is the last observed generation of the HelmRepository
+	// object.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Conditions holds the conditions for the GitOpsSet
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// Inventory contains the list of Kubernetes resource object references that
+	// have been successfully applied
+	// +optional
+	Inventory *ResourceInventory `json:"inventory,omitempty"`
+
+	// Plan contains the changes that would be made to the generated resources
+	// when the GitOpsSet is in Plan mode.
+	// +optional
+	Plan *GitOpsSetPlan `json:"plan,omitempty"`
+
+	// LastApplied records the rendered resources and the source revisions
+	// that were last applied, and is used to skip applying the resources when
+	// nothing has changed.
+	// +optional
+	LastApplied *AppliedState `json:"lastApplied,omitempty"`
+
+	// Generators contains the status of each of the generators, and the named
+	// generators within Matrix generators.
+	// +optional
+	Generators []GeneratorStatus `json:"generators,omitempty"`
+
+	// Wave records the wave of generated resources that was last applied,
+	// while the resources in the wave are not healthy, the later waves are
+	// applied once they are healthy.
+	// +optional
+	Wave *WaveStatus `json:"wave,omitempty"`
+}
+
+// WaveStatus records a wave of generated resources that was applied.
+type WaveStatus struct {
+	// Number is the number of the wave.
+	Number int `json:"number"`
+
+	// AppliedTime is the time that the wave was applied, the reconciliation
+	// fails if the resources in the wave are not healthy within the timeout.
+	AppliedTime metav1.Time `json:"appliedTime"`
+}
+
+// GeneratorStatus is the status of a generator.
+type GeneratorStatus struct {
+	// Index is the index of the generator in the generators of the GitOpsSet.
+	Index int `json:"index"`
+
+	// Name is the name of a generator within a Matrix generator.
+	// +optional
+	Name string `json:"name,omitempty"`
+
+	// Type is the type of the generator e.g. GitRepository.
+	Type string `json:"type"`
+
+	// Elements is the number of elements generated by the generator.
+	Elements int `json:"elements"`
+
+	// LastGenerated is the time that the generator last successfully
+	// generated elements.
+	// +optional
+	LastGenerated *metav1.Time `json:"lastGenerated,omitempty"`
+
+	// Revision is the revision of the source, or the ETag of the response,
+	// that was consumed by the generator.
+	// +optional
+	Revision string `json:"revision,omitempty"`
+
+	// Error is the error from the last generation if it failed.
+	// +optional
+	Error string `json:"error,omitempty"`
+}
+
+// AppliedState records the state of the last successful apply.
+type AppliedState struct {
+	// Digest is the digest of the rendered resources.
+	Digest string `json:"digest"`
+
+	// Revisions contains the revisions of the sources referenced by the
+	// generators.
+	// +optional
+	Revisions []SourceRevision `json:"revisions,omitempty"`
+
+	// ReconcileRequest is the value of the reconcile request annotation when
+	// the resources were applied.
+	// +optional
+	ReconcileRequest string `json:"reconcileRequest,omitempty"`
+}
+
+// SourceRevision is the revision of a source referenced by a generator.
+type SourceRevision struct {
+	// Kind is the kind of the source e.g. GitRepository.
+	Kind string `json:"kind"`
+
+	// Name is the name of the source.
+	Name string `json:"name"`
+
+	// Namespace is the namespace of the source.
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+
+	// Revision is the revision of the artifact for GitRepository and
+	// OCIRepository sources, and the latest image for ImagePolicy sources.
+	// +optional
+	Revision string `json:"revision,omitempty"`
+
+	// Digest is the digest of the artifact for GitRepository and
+	// OCIRepository sources.
+	// +optional
+	Digest string `json:"digest,omitempty"`
+}
+
+// GitOpsSetPlan contains the changes that would be made to the generated
+// resources if they were applied.
+type GitOpsSetPlan struct {
+	// Create contains the resources that would be created.
+	// +optional
+	Create []ResourceRef `json:"create,omitempty"`
+
+	// Update contains the resources that would be changed.
+	// +optional
+	Update []ResourceRef `json:"update,omitempty"`
+
+	// Delete contains the resources that would be deleted.
+	// +optional
+	Delete []ResourceRef `json:"delete,omitempty"`
+}
+
+// HasChanges returns true if applying the plan would change any resources.
+func (in *GitOpsSetPlan) HasChanges() bool {
+	return len(in.Create) > 0 || len(in.Update) > 0 || len(in.Delete) > 0
+}
+
+//+genclient
+//+genclient:Namespaced
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:storageversion
+//+kubebuilder:resource:shortName="gs"
+//+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp",description=""
+//+kubebuilder:printcolumn:name="Ready",type="string",JSONPath=".status.conditions[?(@.type==\"Ready\")].status",description=""
+//+kubebuilder:printcolumn:name="Status",type="string",JSONPath=".status.conditions[?(@.type==\"Ready\")].message",description=""
+//+kubebuilder:printcolumn:name="Elements",type="string",JSONPath=".status.generators[*].elements",description="",priority=1
+
+// GitOpsSet is the Schema for the gitopssets API
+type GitOpsSet struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   GitOpsSetSpec   `json:"spec,omitempty"`
+	Status GitOpsSetStatus `json:"status,omitempty"`
+}
+
+// GetTimeout returns the timeout for the health checks of the generated
+// resources.
+func (in GitOpsSet) GetTimeout() time.Duration {
+	if in.Spec.Timeout == nil {
+		return DefaultHealthCheckTimeout
+	}
+
+	return in.Spec.Timeout.Duration
+}
+
+// PlanEnabled returns true if the changes to the generated resources are
+// planned without applying them, either with the Plan mode or the
+// PlanAnnotation.
+func (in GitOpsSet) PlanEnabled() bool {
+	return in.Spec.Mode == PlanMode || in.GetAnnotations()[PlanAnnotation] == "true"
+}
+
+// GetConditions returns the status conditions of the object.
+func (in GitOpsSet) GetConditions() []metav1.Condition {
+	return in.Status.Conditions
+}
+
+// SetConditions sets the status conditions on the object.
+func (in *GitOpsSet) SetConditions(conditions []metav1.Condition) {
+	in.Status.Conditions = conditions
+}
+
+//+kubebuilder:object:root=true
+
+// GitOpsSetList contains a list of GitOpsSet
+type GitOpsSetList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []GitOpsSet `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&GitOpsSet{}, &GitOpsSetList{})
+}
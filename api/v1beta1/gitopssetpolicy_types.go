@@ -0,0 +1,74 @@
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// GitOpsSetPolicySpec defines the resources that GitOpsSets can generate.
+type GitOpsSetPolicySpec struct {
+	// SourceNamespaces are the namespaces of the GitOpsSets that the policy
+	// applies to, if none are provided the policy applies to GitOpsSets in all
+	// namespaces.
+	// +optional
+	SourceNamespaces []string `json:"sourceNamespaces,omitempty"`
+
+	// AllowedKinds are the kinds of resources that can be generated, if none
+	// are provided all kinds can be generated.
+	// +optional
+	AllowedKinds []GroupKind `json:"allowedKinds,omitempty"`
+
+	// DeniedKinds are the kinds of resources that can't be generated, these
+	// take precedence over the AllowedKinds.
+	// +optional
+	DeniedKinds []GroupKind `json:"deniedKinds,omitempty"`
+
+	// TargetNamespaces are the namespaces that namespaced resources can be
+	// generated in, in addition to the namespace of the GitOpsSet.
+	//
+	// "*" allows all namespaces, if none are provided resources can only be
+	// generated in the namespace of the GitOpsSet.
+	// +optional
+	TargetNamespaces []string `json:"targetNamespaces,omitempty"`
+}
+
+// GroupKind identifies a kind of resource.
+type GroupKind struct {
+	// Group is the API group of the kind, this is empty for the core API
+	// group.
+	// +optional
+	Group string `json:"group,omitempty"`
+
+	// Kind of the resource, "*" matches all the kinds in the group.
+	// +required
+	Kind string `json:"kind"`
+}
+
+//+genclient
+//+genclient:nonNamespaced
+//+kubebuilder:object:root=true
+//+kubebuilder:resource:scope=Cluster
+//+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp",description=""
+
+// GitOpsSetPolicy limits the resources that GitOpsSets can generate.
+//
+// Resources generated by a GitOpsSet must be allowed by all the policies that
+// apply to the namespace of the GitOpsSet.
+type GitOpsSetPolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec GitOpsSetPolicySpec `json:"spec,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// GitOpsSetPolicyList contains a list of GitOpsSetPolicy
+type GitOpsSetPolicyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []GitOpsSetPolicy `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&GitOpsSetPolicy{}, &GitOpsSetPolicyList{})
+}
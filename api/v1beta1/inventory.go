@@ -0,0 +1,52 @@
+package v1beta1
+
+import (
+	"fmt"
+
+	runtime "k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/cli-utils/pkg/object"
+)
+
+// ResourceInventory contains a list of Kubernetes resource object references that have been applied by a Kustomization.
+type ResourceInventory struct {
+	// Entries of Kubernetes resource object references.
+	Entries []ResourceRef `json:"entries,omitempty"`
+
+	// ConfigMaps contains the names of the ConfigMaps that the entries are
+	// stored in when there are too many entries to store in the status.
+	// +optional
+	ConfigMaps []string `json:"configMaps,omitempty"`
+
+	// Digest is the digest of the entries stored in the ConfigMaps.
+	// +optional
+	Digest string `json:"digest,omitempty"`
+}
+
+// ResourceRef contains the information necessary to locate a resource within a cluster.
+type ResourceRef struct {
+	// ID is the string representation of the Kubernetes resource object's metadata,
+	// in the format '<namespace>_<name>_<group>_<kind>'.
+	ID string `json:"id"`
+
+	// Version is the API version of the Kubernetes resource object's kind.
+	Version string `json:"v"`
+
+	// Cluster is the GitopsCluster that the resource was applied to, in the
+	// format "namespace/name", this is empty for resources in the cluster that
+	// the GitOpsSet is in.
+	// +optional
+	Cluster string `json:"cluster,omitempty"`
+}
+
+// ResourceRefFromObject returns a ResourceRef from a runtime.Object.
+func ResourceRefFromObject(obj runtime.Object) (ResourceRef, error) {
+	objMeta, err := object.RuntimeToObjMeta(obj)
+	if err != nil {
+		return ResourceRef{}, fmt.Errorf("failed to parse object Metadata: %w", err)
+	}
+
+	return ResourceRef{
+		ID:      objMeta.String(),
+		Version: obj.GetObjectKind().GroupVersionKind().Version,
+	}, nil
+}
@@ -0,0 +1,90 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/fluxcd/pkg/runtime/acl"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+
+	templatesv1 "github.com/weaveworks/gitopssets-controller/api/v1beta1"
+	"github.com/weaveworks/gitopssets-controller/controllers/templates/generators"
+)
+
+// AllowedNamespacesAnnotation is set on objects that are referenced by
+// generators to allow GitOpsSets in other namespaces to reference them when
+// cross-namespace references are disabled.
+//
+// The value is a comma separated list of namespaces, or "*" to allow all
+// namespaces.
+const AllowedNamespacesAnnotation = "templates.weave.works/allowed-namespaces"
+
+// checkCrossNamespaceRefs returns an acl.AccessDeniedError if the generators
+// reference objects in other namespaces that don't allow references from the
+// namespace of the GitOpsSet.
+//
+// All references are allowed unless cross-namespace references are disabled.
+func (r *GitOpsSetReconciler) checkCrossNamespaceRefs(ctx context.Context, gitOpsSet *templatesv1.GitOpsSet, instantiatedGenerators map[string]generators.Generator) error {
+	if !r.NoCrossNamespaceRefs {
+		return nil
+	}
+
+	kinds := map[string]client.Object{}
+	for _, obj := range generators.FindDependencyKinds(instantiatedGenerators) {
+		gvk, err := apiutil.GVKForObject(obj, r.Scheme)
+		if err != nil {
+			return fmt.Errorf("failed to get kind of generator dependency: %w", err)
+		}
+		kinds[gvk.Kind] = obj
+	}
+
+	for i := range gitOpsSet.Spec.Generators {
+		for _, dependency := range generators.FindDependencies(&gitOpsSet.Spec.Generators[i], gitOpsSet, instantiatedGenerators) {
+			if dependency.Namespace == gitOpsSet.GetNamespace() {
+				continue
+			}
+
+			kind, ok := kinds[dependency.Kind]
+			if !ok {
+				return acl.AccessDeniedError(fmt.Sprintf("%s %s can't be accessed, cross-namespace references are not allowed",
+					dependency.Kind, dependency.ObjectKey))
+			}
+
+			obj := kind.DeepCopyObject().(client.Object)
+			if err := r.Client.Get(ctx, dependency.ObjectKey, obj); err != nil {
+				// The generator reports the missing object.
+				if apierrors.IsNotFound(err) {
+					continue
+				}
+
+				return fmt.Errorf("failed to get %s %s: %w", dependency.Kind, dependency.ObjectKey, err)
+			}
+
+			if !namespaceAllowed(obj.GetAnnotations()[AllowedNamespacesAnnotation], gitOpsSet.GetNamespace()) {
+				return acl.AccessDeniedError(fmt.Sprintf("%s %s can't be accessed, the %s annotation does not allow references from namespace %s",
+					dependency.Kind, dependency.ObjectKey, AllowedNamespacesAnnotation, gitOpsSet.GetNamespace()))
+			}
+		}
+	}
+
+	return nil
+}
+
+func namespaceAllowed(allowed, namespace string) bool {
+	for _, v := range strings.Split(allowed, ",") {
+		if v = strings.TrimSpace(v); v == "*" || v == namespace {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isAccessDenied(err error) bool {
+	var denied acl.AccessDeniedError
+	return errors.As(err, &denied)
+}
@@ -0,0 +1,129 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	sourcev1 "github.com/fluxcd/source-controller/api/v1beta2"
+	"github.com/go-logr/logr"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	templatesv1 "github.com/weaveworks/gitopssets-controller/api/v1beta1"
+	"github.com/weaveworks/gitopssets-controller/controllers/templates/generators"
+	"github.com/weaveworks/gitopssets-controller/controllers/templates/generators/gitrepository"
+	"github.com/weaveworks/gitopssets-controller/test"
+)
+
+func TestCheckCrossNamespaceRefs(t *testing.T) {
+	accessTests := []struct {
+		name                 string
+		noCrossNamespaceRefs bool
+		namespace            string
+		annotations          map[string]string
+		wantErr              string
+	}{
+		{
+			name:                 "same namespace",
+			noCrossNamespaceRefs: true,
+			namespace:            "default",
+		},
+		{
+			name:      "cross-namespace references enabled",
+			namespace: "flux-system",
+		},
+		{
+			name:                 "cross-namespace reference without annotation",
+			noCrossNamespaceRefs: true,
+			namespace:            "flux-system",
+			wantErr:              "GitRepository flux-system/test-repository can't be accessed, the templates.weave.works/allowed-namespaces annotation does not allow references from namespace default",
+		},
+		{
+			name:                 "cross-namespace reference from another namespace",
+			noCrossNamespaceRefs: true,
+			namespace:            "flux-system",
+			annotations:          map[string]string{AllowedNamespacesAnnotation: "staging"},
+			wantErr:              "annotation does not allow references from namespace default",
+		},
+		{
+			name:                 "cross-namespace reference from an allowed namespace",
+			noCrossNamespaceRefs: true,
+			namespace:            "flux-system",
+			annotations:          map[string]string{AllowedNamespacesAnnotation: "staging, default"},
+		},
+		{
+			name:                 "cross-namespace reference from all namespaces",
+			noCrossNamespaceRefs: true,
+			namespace:            "flux-system",
+			annotations:          map[string]string{AllowedNamespacesAnnotation: "*"},
+		},
+	}
+
+	for _, tt := range accessTests {
+		t.Run(tt.name, func(t *testing.T) {
+			scheme := runtime.NewScheme()
+			test.AssertNoError(t, clientgoscheme.AddToScheme(scheme))
+			test.AssertNoError(t, sourcev1.AddToScheme(scheme))
+
+			repo := test.NewGitRepository(func(gr *sourcev1.GitRepository) {
+				gr.SetNamespace("flux-system")
+				gr.SetAnnotations(tt.annotations)
+			})
+			r := &GitOpsSetReconciler{
+				Client:               fake.NewClientBuilder().WithScheme(scheme).WithRuntimeObjects(repo).Build(),
+				Scheme:               scheme,
+				NoCrossNamespaceRefs: tt.noCrossNamespaceRefs,
+			}
+			gs := &templatesv1.GitOpsSet{
+				ObjectMeta: metav1.ObjectMeta{Name: "demo-set", Namespace: "default"},
+				Spec: templatesv1.GitOpsSetSpec{
+					Generators: []templatesv1.GitOpsSetGenerator{
+						{
+							GitRepository: &templatesv1.GitRepositoryGenerator{RepositoryRef: "test-repository", Namespace: tt.namespace},
+						},
+					},
+				},
+			}
+
+			err := r.checkCrossNamespaceRefs(context.TODO(), gs, map[string]generators.Generator{
+				"GitRepository": gitrepository.NewGenerator(logr.Discard(), r.Client, nil),
+			})
+			if tt.wantErr == "" {
+				test.AssertNoError(t, err)
+				return
+			}
+			test.AssertErrorMatch(t, tt.wantErr, err)
+			if reason := failureReason(err); reason != templatesv1.AccessDeniedReason {
+				t.Fatalf("got reason %q, want %q", reason, templatesv1.AccessDeniedReason)
+			}
+		})
+	}
+}
+
+func TestCheckCrossNamespaceRefs_missing_object(t *testing.T) {
+	scheme := runtime.NewScheme()
+	test.AssertNoError(t, sourcev1.AddToScheme(scheme))
+
+	r := &GitOpsSetReconciler{
+		Client:               fake.NewClientBuilder().WithScheme(scheme).Build(),
+		Scheme:               scheme,
+		NoCrossNamespaceRefs: true,
+	}
+	gs := &templatesv1.GitOpsSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "demo-set", Namespace: "default"},
+		Spec: templatesv1.GitOpsSetSpec{
+			Generators: []templatesv1.GitOpsSetGenerator{
+				{
+					GitRepository: &templatesv1.GitRepositoryGenerator{RepositoryRef: "test-repository", Namespace: "flux-system"},
+				},
+			},
+		},
+	}
+
+	err := r.checkCrossNamespaceRefs(context.TODO(), gs, map[string]generators.Generator{
+		"GitRepository": gitrepository.NewGenerator(logr.Discard(), r.Client, nil),
+	})
+	test.AssertNoError(t, err)
+}
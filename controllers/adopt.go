@@ -0,0 +1,60 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	templatesv1 "github.com/weaveworks/gitopssets-controller/api/v1beta1"
+	"github.com/weaveworks/gitopssets-controller/controllers/templates"
+)
+
+// OwnershipConflictError is returned when a generated resource already exists
+// and was generated by another GitOpsSet.
+type OwnershipConflictError struct {
+	ID    string
+	Owner client.ObjectKey
+}
+
+func (e OwnershipConflictError) Error() string {
+	return fmt.Sprintf("resource %s is owned by GitOpsSet %s", e.ID, e.Owner)
+}
+
+// adoptResource applies the resource over an existing resource in the cluster
+// if the adoption policy allows it.
+//
+// The resource is applied with forced ownership, the rendered labels associate
+// the resource with the GitOpsSet.
+func adoptResource(ctx context.Context, k8sClient client.Client, gitOpsSet *templatesv1.GitOpsSet, obj *unstructured.Unstructured, ref templatesv1.ResourceRef) error {
+	existing := &unstructured.Unstructured{}
+	existing.SetGroupVersionKind(obj.GroupVersionKind())
+	if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(obj), existing); err != nil {
+		return fmt.Errorf("failed to get existing Resource: %w", err)
+	}
+
+	owner, owned := generatedBy(existing)
+	if owned && owner != client.ObjectKeyFromObject(gitOpsSet) && gitOpsSet.Spec.AdoptionPolicy != templatesv1.AlwaysAdoptionPolicy {
+		return OwnershipConflictError{ID: ref.ID, Owner: owner}
+	}
+
+	return applyResource(ctx, k8sClient, obj, true)
+}
+
+// generatedBy returns the key of the GitOpsSet that generated the resource,
+// from the labels on the resource.
+func generatedBy(obj client.Object) (client.ObjectKey, bool) {
+	labels := obj.GetLabels()
+	name, ok := labels[templates.NameLabel]
+	if !ok {
+		return client.ObjectKey{}, false
+	}
+
+	return client.ObjectKey{Name: name, Namespace: labels[templates.NamespaceLabel]}, true
+}
+
+func adoptionEnabled(gitOpsSet *templatesv1.GitOpsSet) bool {
+	return gitOpsSet.Spec.AdoptionPolicy == templatesv1.IfUnownedAdoptionPolicy ||
+		gitOpsSet.Spec.AdoptionPolicy == templatesv1.AlwaysAdoptionPolicy
+}
@@ -0,0 +1,221 @@
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	imagev1 "github.com/fluxcd/image-reflector-controller/api/v1beta2"
+	fluxMeta "github.com/fluxcd/pkg/apis/meta"
+	sourcev1 "github.com/fluxcd/source-controller/api/v1beta2"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	templatesv1 "github.com/weaveworks/gitopssets-controller/api/v1beta1"
+	"github.com/weaveworks/gitopssets-controller/controllers/templates/generators"
+)
+
+// appliedState returns the state to be recorded when the rendered resources
+// are applied, the revisions are those of the sources that the resources were
+// rendered from.
+func appliedState(gitOpsSet *templatesv1.GitOpsSet, resources []*unstructured.Unstructured, revisions []templatesv1.SourceRevision) (*templatesv1.AppliedState, error) {
+	b, err := json.Marshal(resources)
+	if err != nil {
+		return nil, fmt.Errorf("failed to calculate digest of rendered resources: %w", err)
+	}
+
+	reconcileRequest, _ := fluxMeta.ReconcileAnnotationValue(gitOpsSet.GetAnnotations())
+
+	return &templatesv1.AppliedState{
+		Digest:           fmt.Sprintf("sha256:%x", sha256.Sum256(b)),
+		Revisions:        revisions,
+		ReconcileRequest: reconcileRequest,
+	}, nil
+}
+
+// applyUnchanged returns true if the resources were successfully applied with
+// the same state, and the GitOpsSet hasn't changed since.
+//
+// Resources are always applied when drift detection is enabled, because the
+// apply corrects the drift.
+func applyUnchanged(gitOpsSet *templatesv1.GitOpsSet, state *templatesv1.AppliedState) bool {
+	last := gitOpsSet.Status.LastApplied
+	if last == nil || driftDetectionEnabled(gitOpsSet) {
+		return false
+	}
+
+	if gitOpsSet.Generation != gitOpsSet.Status.ObservedGeneration {
+		return false
+	}
+
+	if !apimeta.IsStatusConditionTrue(gitOpsSet.Status.Conditions, fluxMeta.ReadyCondition) {
+		return false
+	}
+
+	if last.Digest != state.Digest || last.ReconcileRequest != state.ReconcileRequest || len(last.Revisions) != len(state.Revisions) {
+		return false
+	}
+
+	for i := range last.Revisions {
+		if last.Revisions[i] != state.Revisions[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// inventoryExists returns true if all the resources in the inventory exist in
+// the cluster.
+//
+// Only the metadata of the resources is fetched.
+func inventoryExists(ctx context.Context, clients *clusterClients, inventory *templatesv1.ResourceInventory) (bool, error) {
+	if inventory == nil {
+		return true, nil
+	}
+
+	for _, ref := range inventory.Entries {
+		u, err := unstructuredFromResourceRef(ref)
+		if err != nil {
+			return false, err
+		}
+
+		k8sClient, err := clients.forResource(ctx, ref)
+		if err != nil {
+			return false, err
+		}
+
+		obj := &metav1.PartialObjectMetadata{}
+		obj.SetGroupVersionKind(u.GroupVersionKind())
+		if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(u), obj); err != nil {
+			if apierrors.IsNotFound(err) {
+				return false, nil
+			}
+
+			return false, fmt.Errorf("failed to get %s: %w", ref.ID, err)
+		}
+	}
+
+	return true, nil
+}
+
+// sourceRevisions returns the revisions of the GitRepository, OCIRepository
+// and ImagePolicy sources referenced by the generators, sorted by kind,
+// namespace and name.
+func sourceRevisions(ctx context.Context, c client.Reader, gitOpsSet *templatesv1.GitOpsSet) ([]templatesv1.SourceRevision, error) {
+	seen := map[templatesv1.SourceRevision]bool{}
+	var revisions []templatesv1.SourceRevision
+	addRevision := func(obj client.Object, name, namespace string) error {
+		key := client.ObjectKey{Name: name, Namespace: generators.ReferenceNamespace(namespace, gitOpsSet)}
+		if err := c.Get(ctx, key, obj); err != nil {
+			return fmt.Errorf("failed to get source revision: %w", err)
+		}
+
+		revision := sourceRevision(obj)
+		if !seen[revision] {
+			seen[revision] = true
+			revisions = append(revisions, revision)
+		}
+
+		return nil
+	}
+
+	for _, gen := range gitOpsSet.Spec.Generators {
+		if err := addGeneratorRevisions(gen.GitRepository, gen.OCIRepository, gen.ImagePolicy, addRevision); err != nil {
+			return nil, err
+		}
+
+		if gen.Matrix != nil {
+			for _, matrixGen := range gen.Matrix.Generators {
+				if err := addGeneratorRevisions(matrixGen.GitRepository, matrixGen.OCIRepository, matrixGen.ImagePolicy, addRevision); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	sort.Slice(revisions, func(i, j int) bool {
+		if revisions[i].Kind != revisions[j].Kind {
+			return revisions[i].Kind < revisions[j].Kind
+		}
+
+		if revisions[i].Namespace != revisions[j].Namespace {
+			return revisions[i].Namespace < revisions[j].Namespace
+		}
+
+		return revisions[i].Name < revisions[j].Name
+	})
+
+	return revisions, nil
+}
+
+func addGeneratorRevisions(gitRepository *templatesv1.GitRepositoryGenerator, ociRepository *templatesv1.OCIRepositoryGenerator, imagePolicy *templatesv1.ImagePolicyGenerator, addRevision func(client.Object, string, string) error) error {
+	if gitRepository != nil {
+		if err := addRevision(&sourcev1.GitRepository{}, gitRepository.RepositoryRef, gitRepository.Namespace); err != nil {
+			return err
+		}
+	}
+
+	if ociRepository != nil {
+		if err := addRevision(&sourcev1.OCIRepository{}, ociRepository.RepositoryRef, ociRepository.Namespace); err != nil {
+			return err
+		}
+	}
+
+	if imagePolicy != nil {
+		if err := addRevision(&imagev1.ImagePolicy{}, imagePolicy.PolicyRef, imagePolicy.Namespace); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// sourceRevision returns the current revision of a source.
+func sourceRevision(obj client.Object) templatesv1.SourceRevision {
+	revision := templatesv1.SourceRevision{Name: obj.GetName(), Namespace: obj.GetNamespace()}
+	switch v := obj.(type) {
+	case *sourcev1.GitRepository:
+		revision.Kind = sourcev1.GitRepositoryKind
+		if v.Status.Artifact != nil {
+			revision.Revision = v.Status.Artifact.Revision
+			revision.Digest = v.Status.Artifact.Digest
+		}
+	case *sourcev1.OCIRepository:
+		revision.Kind = sourcev1.OCIRepositoryKind
+		if v.Status.Artifact != nil {
+			revision.Revision = v.Status.Artifact.Revision
+			revision.Digest = v.Status.Artifact.Digest
+		}
+	case *imagev1.ImagePolicy:
+		revision.Kind = imagev1.ImagePolicyKind
+		revision.Revision = v.Status.LatestImage
+	}
+
+	return revision
+}
+
+// sourceRevisionChanged returns a filter for GitOpsSets that excludes the
+// GitOpsSets that last applied the current revision of the source.
+func sourceRevisionChanged(obj client.Object) func(*templatesv1.GitOpsSet) bool {
+	current := sourceRevision(obj)
+
+	return func(gitOpsSet *templatesv1.GitOpsSet) bool {
+		if gitOpsSet.Status.LastApplied == nil {
+			return true
+		}
+
+		for _, applied := range gitOpsSet.Status.LastApplied.Revisions {
+			if applied.Kind == current.Kind && applied.Namespace == current.Namespace && applied.Name == current.Name {
+				return applied != current
+			}
+		}
+
+		return true
+	}
+}
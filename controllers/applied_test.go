@@ -0,0 +1,295 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/fluxcd/pkg/apis/meta"
+	sourcev1 "github.com/fluxcd/source-controller/api/v1"
+	sourcev1beta2 "github.com/fluxcd/source-controller/api/v1beta2"
+	"github.com/go-logr/logr"
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	templatesv1 "github.com/weaveworks/gitopssets-controller/api/v1beta1"
+	"github.com/weaveworks/gitopssets-controller/controllers/templates/generators"
+	"github.com/weaveworks/gitopssets-controller/test"
+)
+
+func TestApplyUnchanged(t *testing.T) {
+	state := &templatesv1.AppliedState{
+		Digest: "sha256:1234",
+		Revisions: []templatesv1.SourceRevision{
+			{Kind: "GitRepository", Name: "test-repo", Revision: "main@sha1:1234", Digest: "sha256:5678"},
+		},
+	}
+
+	unchangedTests := []struct {
+		name   string
+		opts   func(*templatesv1.GitOpsSet)
+		digest string
+		want   bool
+	}{
+		{
+			name: "unchanged",
+			want: true,
+		},
+		{
+			name: "not previously applied",
+			opts: func(gs *templatesv1.GitOpsSet) {
+				gs.Status.LastApplied = nil
+			},
+		},
+		{
+			name:   "rendered resources changed",
+			digest: "sha256:4321",
+		},
+		{
+			name: "source revision changed",
+			opts: func(gs *templatesv1.GitOpsSet) {
+				gs.Status.LastApplied.Revisions[0].Revision = "main@sha1:4321"
+			},
+		},
+		{
+			name: "GitOpsSet changed",
+			opts: func(gs *templatesv1.GitOpsSet) {
+				gs.Generation = 2
+			},
+		},
+		{
+			name: "not ready",
+			opts: func(gs *templatesv1.GitOpsSet) {
+				gs.Status.Conditions[0].Status = metav1.ConditionFalse
+			},
+		},
+		{
+			name: "reconciliation requested",
+			opts: func(gs *templatesv1.GitOpsSet) {
+				gs.Status.LastApplied.ReconcileRequest = "2023-01-01T00:00:00Z"
+			},
+		},
+		{
+			name: "drift detection enabled",
+			opts: func(gs *templatesv1.GitOpsSet) {
+				gs.Spec.DriftDetection = templatesv1.DriftDetectionEnabled
+			},
+		},
+	}
+
+	for _, tt := range unchangedTests {
+		t.Run(tt.name, func(t *testing.T) {
+			gs := &templatesv1.GitOpsSet{}
+			gs.Generation = 1
+			gs.Status.ObservedGeneration = 1
+			gs.Status.Conditions = []metav1.Condition{{Type: meta.ReadyCondition, Status: metav1.ConditionTrue}}
+			gs.Status.LastApplied = state.DeepCopy()
+			if tt.opts != nil {
+				tt.opts(gs)
+			}
+
+			current := state.DeepCopy()
+			if tt.digest != "" {
+				current.Digest = tt.digest
+			}
+
+			if got := applyUnchanged(gs, current); got != tt.want {
+				t.Fatalf("applyUnchanged() got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSourceRevisionChanged(t *testing.T) {
+	repo := &sourcev1beta2.GitRepository{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-repo", Namespace: "default"},
+		Status: sourcev1beta2.GitRepositoryStatus{
+			Artifact: &sourcev1.Artifact{Revision: "main@sha1:1234", Digest: "sha256:5678"},
+		},
+	}
+
+	changedTests := []struct {
+		name    string
+		applied *templatesv1.AppliedState
+		want    bool
+	}{
+		{
+			name: "not previously applied",
+			want: true,
+		},
+		{
+			name: "same revision",
+			applied: &templatesv1.AppliedState{
+				Revisions: []templatesv1.SourceRevision{
+					{Kind: "GitRepository", Name: "test-repo", Namespace: "default", Revision: "main@sha1:1234", Digest: "sha256:5678"},
+				},
+			},
+		},
+		{
+			name: "different revision",
+			applied: &templatesv1.AppliedState{
+				Revisions: []templatesv1.SourceRevision{
+					{Kind: "GitRepository", Name: "test-repo", Namespace: "default", Revision: "main@sha1:4321", Digest: "sha256:8765"},
+				},
+			},
+			want: true,
+		},
+		{
+			name: "different digest",
+			applied: &templatesv1.AppliedState{
+				Revisions: []templatesv1.SourceRevision{
+					{Kind: "GitRepository", Name: "test-repo", Namespace: "default", Revision: "main@sha1:1234", Digest: "sha256:8765"},
+				},
+			},
+			want: true,
+		},
+		{
+			name: "source with the same name in another namespace",
+			applied: &templatesv1.AppliedState{
+				Revisions: []templatesv1.SourceRevision{
+					{Kind: "GitRepository", Name: "test-repo", Namespace: "other", Revision: "main@sha1:4321", Digest: "sha256:8765"},
+					{Kind: "GitRepository", Name: "test-repo", Namespace: "default", Revision: "main@sha1:1234", Digest: "sha256:5678"},
+				},
+			},
+		},
+		{
+			name: "source not previously applied",
+			applied: &templatesv1.AppliedState{
+				Revisions: []templatesv1.SourceRevision{
+					{Kind: "OCIRepository", Name: "test-repo", Namespace: "default", Revision: "main@sha1:1234", Digest: "sha256:5678"},
+				},
+			},
+			want: true,
+		},
+	}
+
+	for _, tt := range changedTests {
+		t.Run(tt.name, func(t *testing.T) {
+			gs := &templatesv1.GitOpsSet{}
+			gs.Status.LastApplied = tt.applied
+
+			if got := sourceRevisionChanged(repo)(gs); got != tt.want {
+				t.Fatalf("sourceRevisionChanged() got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRender_sourceRevisions(t *testing.T) {
+	scheme := runtime.NewScheme()
+	test.AssertNoError(t, templatesv1.AddToScheme(scheme))
+	test.AssertNoError(t, sourcev1beta2.AddToScheme(scheme))
+
+	repo := test.NewGitRepository(func(gr *sourcev1beta2.GitRepository) {
+		gr.Status.Artifact = &sourcev1.Artifact{Revision: "main@sha1:1234", Digest: "sha256:5678"}
+	})
+	cl := fake.NewClientBuilder().WithScheme(scheme).WithObjects(repo).WithStatusSubresource(repo).Build()
+
+	gs := &templatesv1.GitOpsSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-set", Namespace: "default"},
+		Spec: templatesv1.GitOpsSetSpec{
+			Generators: []templatesv1.GitOpsSetGenerator{
+				{
+					GitRepository: &templatesv1.GitRepositoryGenerator{RepositoryRef: repo.GetName()},
+				},
+			},
+			Templates: []templatesv1.GitOpsSetTemplate{
+				{
+					Content: runtime.RawExtension{Raw: mustMarshalJSON(t, test.NewConfigMap())},
+				},
+			},
+		},
+	}
+
+	r := &GitOpsSetReconciler{Client: cl, Scheme: scheme}
+	resources, revisions, err := r.render(context.TODO(), logr.Discard(), gs, map[string]generators.Generator{
+		"GitRepository": &sourceUpdatingGenerator{client: cl, revision: "main@sha1:4321"},
+	})
+	test.AssertNoError(t, err)
+	if l := len(resources); l != 1 {
+		t.Fatalf("got %d resources, want 1", l)
+	}
+
+	// The revision is updated while the templates are rendered, the revision
+	// that was read before rendering is recorded.
+	want := []templatesv1.SourceRevision{
+		{Kind: "GitRepository", Name: "test-repository", Namespace: "default", Revision: "main@sha1:1234", Digest: "sha256:5678"},
+	}
+	if diff := cmp.Diff(want, revisions); diff != "" {
+		t.Fatalf("failed to record source revisions:\n%s", diff)
+	}
+
+	test.AssertNoError(t, cl.Get(context.TODO(), client.ObjectKeyFromObject(repo), repo))
+	if !sourceRevisionChanged(repo)(&templatesv1.GitOpsSet{Status: templatesv1.GitOpsSetStatus{
+		LastApplied: &templatesv1.AppliedState{Revisions: revisions}}}) {
+		t.Fatal("expected the update of the source to reconcile the GitOpsSet")
+	}
+}
+
+// sourceUpdatingGenerator updates the revision of the GitRepository that it
+// generates from, as if the source changed while the templates are rendered.
+type sourceUpdatingGenerator struct {
+	client   client.Client
+	revision string
+}
+
+func (g *sourceUpdatingGenerator) Generate(ctx context.Context, sg *templatesv1.GitOpsSetGenerator, gs *templatesv1.GitOpsSet) ([]map[string]any, error) {
+	var repo sourcev1beta2.GitRepository
+	if err := g.client.Get(ctx, client.ObjectKey{Name: sg.GitRepository.RepositoryRef, Namespace: gs.GetNamespace()}, &repo); err != nil {
+		return nil, err
+	}
+	repo.Status.Artifact.Revision = g.revision
+	if err := g.client.Status().Update(ctx, &repo); err != nil {
+		return nil, err
+	}
+
+	return []map[string]any{{}}, nil
+}
+
+func (g *sourceUpdatingGenerator) Interval(*templatesv1.GitOpsSetGenerator) time.Duration {
+	return generators.NoRequeueInterval
+}
+
+func TestSourceRevisions(t *testing.T) {
+	scheme := runtime.NewScheme()
+	test.AssertNoError(t, sourcev1beta2.AddToScheme(scheme))
+
+	newRepository := func(namespace, revision string) *sourcev1beta2.GitRepository {
+		return test.NewGitRepository(func(gr *sourcev1beta2.GitRepository) {
+			gr.Namespace = namespace
+			gr.Status.Artifact = &sourcev1.Artifact{Revision: revision}
+		})
+	}
+	cl := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		newRepository("default", "main@sha1:1234"),
+		newRepository("flux-system", "main@sha1:4321"),
+	).Build()
+
+	gs := &templatesv1.GitOpsSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-set", Namespace: "default"},
+		Spec: templatesv1.GitOpsSetSpec{
+			Generators: []templatesv1.GitOpsSetGenerator{
+				{
+					GitRepository: &templatesv1.GitRepositoryGenerator{RepositoryRef: "test-repository", Namespace: "flux-system"},
+				},
+				{
+					GitRepository: &templatesv1.GitRepositoryGenerator{RepositoryRef: "test-repository"},
+				},
+			},
+		},
+	}
+
+	revisions, err := sourceRevisions(context.TODO(), cl, gs)
+	test.AssertNoError(t, err)
+
+	want := []templatesv1.SourceRevision{
+		{Kind: "GitRepository", Name: "test-repository", Namespace: "default", Revision: "main@sha1:1234"},
+		{Kind: "GitRepository", Name: "test-repository", Namespace: "flux-system", Revision: "main@sha1:4321"},
+	}
+	if diff := cmp.Diff(want, revisions); diff != "" {
+		t.Fatalf("failed to get source revisions:\n%s", diff)
+	}
+}
@@ -0,0 +1,179 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/fluxcd/pkg/runtime/acl"
+	clustersv1 "github.com/weaveworks/cluster-controller/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	templatesv1 "github.com/weaveworks/gitopssets-controller/api/v1beta1"
+	"github.com/weaveworks/gitopssets-controller/controllers/templates"
+)
+
+// remoteClientCacheSize is the number of clients for remote clusters that are
+// cached.
+const remoteClientCacheSize = 100
+
+// kubeConfigKeys are the keys that the kubeconfig is read from in the Secret
+// referenced by a GitopsCluster, in order of preference.
+var kubeConfigKeys = []string{"value", "value.yaml"}
+
+// clusterClients provides the clients for the clusters that the generated
+// resources are applied to.
+//
+// Resources without a target cluster use the local client, and clients for
+// remote clusters are created when they are first needed.
+type clusterClients struct {
+	local   client.Client
+	remote  func(ctx context.Context, cluster client.ObjectKey) (client.Client, error)
+	clients map[string]client.Client
+}
+
+// clusterClients returns the clients for the clusters that the resources
+// generated by the GitOpsSet are applied to, the GitopsClusters and their
+// kubeconfig Secrets are read with the local client.
+func (r *GitOpsSetReconciler) clusterClients(gitOpsSet *templatesv1.GitOpsSet, k8sClient client.Client) *clusterClients {
+	return &clusterClients{
+		local: k8sClient,
+		remote: func(ctx context.Context, cluster client.ObjectKey) (client.Client, error) {
+			return r.makeClusterClient(ctx, k8sClient, gitOpsSet.GetNamespace(), cluster)
+		},
+		clients: map[string]client.Client{},
+	}
+}
+
+// forCluster returns the client for a cluster in the format "namespace/name",
+// or the local client if the cluster is empty.
+func (c *clusterClients) forCluster(ctx context.Context, cluster string) (client.Client, error) {
+	if cluster == "" {
+		return c.local, nil
+	}
+
+	if cl, ok := c.clients[cluster]; ok {
+		return cl, nil
+	}
+
+	namespace, name, ok := strings.Cut(cluster, "/")
+	if !ok || namespace == "" || name == "" {
+		return nil, fmt.Errorf("invalid target cluster %q, must be in the format namespace/name", cluster)
+	}
+
+	cl, err := c.remote(ctx, client.ObjectKey{Namespace: namespace, Name: name})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create client for cluster %s: %w", cluster, err)
+	}
+	c.clients[cluster] = cl
+
+	return cl, nil
+}
+
+// forResource returns the client for the cluster that a referenced resource is
+// in.
+func (c *clusterClients) forResource(ctx context.Context, ref templatesv1.ResourceRef) (client.Client, error) {
+	return c.forCluster(ctx, ref.Cluster)
+}
+
+// makeClusterClient creates a client from the kubeconfig Secret of a
+// GitopsCluster, the GitopsCluster and Secret are read with the client for the
+// GitOpsSet, so that they are subject to the same access as other references.
+//
+// GitopsClusters that reference a CAPI Cluster use the kubeconfig Secret that
+// CAPI creates for the cluster.
+//
+// The clients are cached by the resource version of the Secret, so a new
+// client is created when the kubeconfig changes.
+func (r *GitOpsSetReconciler) makeClusterClient(ctx context.Context, k8sClient client.Client, namespace string, key client.ObjectKey) (client.Client, error) {
+	// The GitopsCluster is fetched as an unstructured object, because the
+	// cluster-controller types are only registered when the Cluster generator
+	// is enabled.
+	u := &unstructured.Unstructured{}
+	u.SetGroupVersionKind(clustersv1.GroupVersion.WithKind("GitopsCluster"))
+	if err := k8sClient.Get(ctx, key, u); err != nil {
+		return nil, fmt.Errorf("failed to get GitopsCluster: %w", err)
+	}
+
+	if r.NoCrossNamespaceRefs && key.Namespace != namespace && !namespaceAllowed(u.GetAnnotations()[AllowedNamespacesAnnotation], namespace) {
+		return nil, acl.AccessDeniedError(fmt.Sprintf("GitopsCluster %s can't be accessed, cross-namespace references are not allowed", key))
+	}
+
+	var cluster clustersv1.GitopsCluster
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, &cluster); err != nil {
+		return nil, fmt.Errorf("failed to parse GitopsCluster: %w", err)
+	}
+
+	var secretName string
+	switch {
+	case cluster.Spec.SecretRef != nil:
+		secretName = cluster.Spec.SecretRef.Name
+	case cluster.Spec.CAPIClusterRef != nil:
+		secretName = cluster.Spec.CAPIClusterRef.Name + "-kubeconfig"
+	default:
+		return nil, fmt.Errorf("GitopsCluster %s has no kubeconfig Secret", key)
+	}
+
+	var secret corev1.Secret
+	if err := k8sClient.Get(ctx, client.ObjectKey{Namespace: key.Namespace, Name: secretName}, &secret); err != nil {
+		return nil, fmt.Errorf("failed to get kubeconfig Secret: %w", err)
+	}
+
+	cacheKey := fmt.Sprintf("%s/%s@%s", key, secretName, secret.GetResourceVersion())
+	if r.remoteClients != nil {
+		if cached, ok := r.remoteClients.Get(cacheKey); ok {
+			return cached.(client.Client), nil
+		}
+	}
+
+	var kubeConfig []byte
+	for _, k := range kubeConfigKeys {
+		if v, ok := secret.Data[k]; ok {
+			kubeConfig = v
+			break
+		}
+	}
+	if kubeConfig == nil {
+		return nil, fmt.Errorf("kubeconfig Secret %s/%s has no %s key", key.Namespace, secretName, strings.Join(kubeConfigKeys, " or "))
+	}
+
+	restConfig, err := clientcmd.RESTConfigFromKubeConfig(kubeConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse kubeconfig: %w", err)
+	}
+
+	cl, err := client.New(restConfig, client.Options{Scheme: r.Scheme})
+	if err != nil {
+		return nil, err
+	}
+	if r.remoteClients != nil {
+		r.remoteClients.Add(cacheKey, cl)
+	}
+
+	return cl, nil
+}
+
+// resourceRefFromObject returns the ResourceRef for a generated resource,
+// including the cluster that the resource is applied to.
+func resourceRefFromObject(obj *unstructured.Unstructured) (templatesv1.ResourceRef, error) {
+	ref, err := templatesv1.ResourceRefFromObject(obj)
+	if err != nil {
+		return ref, err
+	}
+	ref.Cluster = obj.GetAnnotations()[templates.TargetClusterAnnotation]
+
+	return ref, nil
+}
+
+// compareResourceRefs orders ResourceRefs by cluster and ID.
+func compareResourceRefs(x, y templatesv1.ResourceRef) bool {
+	if x.Cluster != y.Cluster {
+		return x.Cluster < y.Cluster
+	}
+
+	return x.ID < y.ID
+}
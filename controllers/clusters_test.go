@@ -0,0 +1,234 @@
+package controllers
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/fluxcd/pkg/apis/meta"
+	clustersv1 "github.com/weaveworks/cluster-controller/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/utils/lru"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/weaveworks/gitopssets-controller/test"
+)
+
+const testKubeConfig = `apiVersion: v1
+kind: Config
+clusters:
+- cluster:
+    server: https://remote.example.com:6443
+  name: remote
+contexts:
+- context:
+    cluster: remote
+    user: remote
+  name: remote
+current-context: remote
+users:
+- name: remote
+  user:
+    token: test-token
+`
+
+func TestClusterClients_forCluster(t *testing.T) {
+	local := fake.NewClientBuilder().Build()
+	remote := fake.NewClientBuilder().Build()
+	var created []client.ObjectKey
+	clients := &clusterClients{
+		local: local,
+		remote: func(ctx context.Context, key client.ObjectKey) (client.Client, error) {
+			created = append(created, key)
+			return remote, nil
+		},
+		clients: map[string]client.Client{},
+	}
+
+	cl, err := clients.forCluster(context.TODO(), "")
+	test.AssertNoError(t, err)
+	if cl != local {
+		t.Fatal("expected the local client for resources without a cluster")
+	}
+
+	for i := 0; i < 2; i++ {
+		cl, err = clients.forCluster(context.TODO(), "clusters/remote")
+		test.AssertNoError(t, err)
+		if cl != remote {
+			t.Fatal("expected the remote client for resources with a cluster")
+		}
+	}
+
+	if len(created) != 1 || created[0] != (client.ObjectKey{Namespace: "clusters", Name: "remote"}) {
+		t.Fatalf("expected the remote client to be created once, got %v", created)
+	}
+
+	_, err = clients.forCluster(context.TODO(), "remote")
+	test.AssertErrorMatch(t, `invalid target cluster "remote", must be in the format namespace/name`, err)
+}
+
+func TestMakeClusterClient(t *testing.T) {
+	clusterTests := []struct {
+		name    string
+		spec    clustersv1.GitopsClusterSpec
+		secret  *corev1.Secret
+		wantErr string
+	}{
+		{
+			name:   "cluster with a secret",
+			spec:   clustersv1.GitopsClusterSpec{SecretRef: &meta.LocalObjectReference{Name: "remote-kubeconfig"}},
+			secret: newKubeConfigSecret("remote-kubeconfig", "value"),
+		},
+		{
+			name:   "cluster with a CAPI cluster",
+			spec:   clustersv1.GitopsClusterSpec{CAPIClusterRef: &meta.LocalObjectReference{Name: "capi-cluster"}},
+			secret: newKubeConfigSecret("capi-cluster-kubeconfig", "value.yaml"),
+		},
+		{
+			name:    "missing secret",
+			spec:    clustersv1.GitopsClusterSpec{SecretRef: &meta.LocalObjectReference{Name: "remote-kubeconfig"}},
+			wantErr: `failed to get kubeconfig Secret: secrets "remote-kubeconfig" not found`,
+		},
+		{
+			name:    "secret without a kubeconfig",
+			spec:    clustersv1.GitopsClusterSpec{SecretRef: &meta.LocalObjectReference{Name: "remote-kubeconfig"}},
+			secret:  newKubeConfigSecret("remote-kubeconfig", "kubeconfig"),
+			wantErr: "kubeconfig Secret clusters/remote-kubeconfig has no value or value.yaml key",
+		},
+	}
+
+	for _, tt := range clusterTests {
+		t.Run(tt.name, func(t *testing.T) {
+			scheme := runtime.NewScheme()
+			test.AssertNoError(t, clientgoscheme.AddToScheme(scheme))
+			test.AssertNoError(t, clustersv1.AddToScheme(scheme))
+
+			objs := []runtime.Object{
+				&clustersv1.GitopsCluster{
+					ObjectMeta: metav1.ObjectMeta{Name: "remote", Namespace: "clusters"},
+					Spec:       tt.spec,
+				},
+			}
+			if tt.secret != nil {
+				objs = append(objs, tt.secret)
+			}
+
+			r := &GitOpsSetReconciler{
+				Client: fake.NewClientBuilder().WithScheme(scheme).Build(),
+				Scheme: scheme,
+			}
+
+			// The GitopsCluster and Secret are read with the client for the
+			// GitOpsSet.
+			k8sClient := fake.NewClientBuilder().WithScheme(scheme).WithRuntimeObjects(objs...).Build()
+			cl, err := r.makeClusterClient(context.TODO(), k8sClient, "clusters", client.ObjectKey{Namespace: "clusters", Name: "remote"})
+			if tt.wantErr != "" {
+				test.AssertErrorMatch(t, tt.wantErr, err)
+				return
+			}
+
+			test.AssertNoError(t, err)
+			if cl == nil {
+				t.Fatal("expected a client to be created")
+			}
+		})
+	}
+}
+
+func TestMakeClusterClient_cross_namespace(t *testing.T) {
+	crossNamespaceTests := []struct {
+		name        string
+		annotations map[string]string
+		noCrossNS   bool
+		wantErr     string
+	}{
+		{
+			name: "cross-namespace references allowed",
+		},
+		{
+			name:      "cross-namespace references not allowed",
+			noCrossNS: true,
+			wantErr:   "GitopsCluster clusters/remote can't be accessed, cross-namespace references are not allowed",
+		},
+		{
+			name:        "cross-namespace references allowed by the GitopsCluster",
+			annotations: map[string]string{AllowedNamespacesAnnotation: "default"},
+			noCrossNS:   true,
+		},
+		{
+			name:        "cross-namespace references allowed for other namespaces",
+			annotations: map[string]string{AllowedNamespacesAnnotation: "team-a"},
+			noCrossNS:   true,
+			wantErr:     "GitopsCluster clusters/remote can't be accessed, cross-namespace references are not allowed",
+		},
+	}
+
+	for _, tt := range crossNamespaceTests {
+		t.Run(tt.name, func(t *testing.T) {
+			k8sClient := newClusterClient(t, &clustersv1.GitopsCluster{
+				ObjectMeta: metav1.ObjectMeta{Name: "remote", Namespace: "clusters", Annotations: tt.annotations},
+				Spec:       clustersv1.GitopsClusterSpec{SecretRef: &meta.LocalObjectReference{Name: "remote-kubeconfig"}},
+			}, newKubeConfigSecret("remote-kubeconfig", "value"))
+			r := &GitOpsSetReconciler{Scheme: k8sClient.Scheme(), NoCrossNamespaceRefs: tt.noCrossNS}
+
+			_, err := r.makeClusterClient(context.TODO(), k8sClient, "default", client.ObjectKey{Namespace: "clusters", Name: "remote"})
+			if tt.wantErr != "" {
+				test.AssertErrorMatch(t, tt.wantErr, err)
+				if !isAccessDenied(err) {
+					t.Fatalf("expected an access denied error, got %v", err)
+				}
+				return
+			}
+			test.AssertNoError(t, err)
+		})
+	}
+}
+
+func TestMakeClusterClient_caching(t *testing.T) {
+	secret := newKubeConfigSecret("remote-kubeconfig", "value")
+	k8sClient := newClusterClient(t, &clustersv1.GitopsCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "remote", Namespace: "clusters"},
+		Spec:       clustersv1.GitopsClusterSpec{SecretRef: &meta.LocalObjectReference{Name: "remote-kubeconfig"}},
+	}, secret)
+	r := &GitOpsSetReconciler{Scheme: k8sClient.Scheme(), remoteClients: lru.New(10)}
+	key := client.ObjectKey{Namespace: "clusters", Name: "remote"}
+
+	cl1, err := r.makeClusterClient(context.TODO(), k8sClient, "clusters", key)
+	test.AssertNoError(t, err)
+	cl2, err := r.makeClusterClient(context.TODO(), k8sClient, "clusters", key)
+	test.AssertNoError(t, err)
+	if cl1 != cl2 {
+		t.Fatal("expected the cached client to be returned")
+	}
+
+	// A new client is created when the kubeconfig changes.
+	test.AssertNoError(t, k8sClient.Get(context.TODO(), client.ObjectKeyFromObject(secret), secret))
+	secret.Data["value"] = []byte(strings.ReplaceAll(testKubeConfig, "remote.example.com", "other.example.com"))
+	test.AssertNoError(t, k8sClient.Update(context.TODO(), secret))
+
+	cl3, err := r.makeClusterClient(context.TODO(), k8sClient, "clusters", key)
+	test.AssertNoError(t, err)
+	if cl1 == cl3 {
+		t.Fatal("expected a new client when the kubeconfig Secret changes")
+	}
+}
+
+func newClusterClient(t *testing.T, objs ...runtime.Object) client.Client {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	test.AssertNoError(t, clientgoscheme.AddToScheme(scheme))
+	test.AssertNoError(t, clustersv1.AddToScheme(scheme))
+
+	return fake.NewClientBuilder().WithScheme(scheme).WithRuntimeObjects(objs...).Build()
+}
+
+func newKubeConfigSecret(name, key string) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "clusters"},
+		Data:       map[string][]byte{key: []byte(testKubeConfig)},
+	}
+}
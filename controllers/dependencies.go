@@ -0,0 +1,58 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gitops-tools/pkg/sets"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	templatesv1 "github.com/weaveworks/gitopssets-controller/api/v1beta1"
+	"github.com/weaveworks/gitopssets-controller/controllers/templates/generators"
+)
+
+// dependencyIndexKey returns the key for the index of GitOpsSets by the
+// objects of a kind that their generators depend on.
+func dependencyIndexKey(kind string) string {
+	return ".metadata.dependencies." + kind
+}
+
+// dependencyToGitOpsSet returns a function that maps objects of a kind to the
+// GitOpsSets with generators that depend on them.
+//
+// GitOpsSets that last applied the current revision of a source are not
+// reconciled.
+func (r *GitOpsSetReconciler) dependencyToGitOpsSet(kind string) handler.MapFunc {
+	return func(ctx context.Context, obj client.Object) []reconcile.Request {
+		return r.queryIndexedGitOpsSets(ctx, dependencyIndexKey(kind), obj, sourceRevisionChanged(obj))
+	}
+}
+
+// indexDependencies returns an index function for GitOpsSets that returns the
+// objects of a kind that the generators depend on, including the generators
+// nested in Matrix generators.
+func indexDependencies(kind string, enabledGenerators map[string]generators.Generator) client.IndexerFunc {
+	return func(o client.Object) []string {
+		gs, ok := o.(*templatesv1.GitOpsSet)
+		if !ok {
+			panic(fmt.Sprintf("Expected a GitOpsSet, got %T", o))
+		}
+
+		referencedNames := sets.New[string]()
+		for i := range gs.Spec.Generators {
+			for _, dependency := range generators.FindDependencies(&gs.Spec.Generators[i], gs, enabledGenerators) {
+				if dependency.Kind == kind {
+					referencedNames.Insert(dependency.ObjectKey.String())
+				}
+			}
+		}
+
+		if referencedNames.Len() == 0 {
+			return nil
+		}
+
+		return referencedNames.SortedList(func(x, y string) bool { return x < y })
+	}
+}
@@ -0,0 +1,88 @@
+package controllers
+
+import (
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	templatesv1 "github.com/weaveworks/gitopssets-controller/api/v1beta1"
+	"github.com/weaveworks/gitopssets-controller/controllers/templates/generators"
+	"github.com/weaveworks/gitopssets-controller/controllers/templates/generators/apiclient"
+	"github.com/weaveworks/gitopssets-controller/controllers/templates/generators/config"
+	"github.com/weaveworks/gitopssets-controller/controllers/templates/generators/gitrepository"
+	"github.com/weaveworks/gitopssets-controller/controllers/templates/generators/matrix"
+	"github.com/weaveworks/gitopssets-controller/controllers/templates/generators/pullrequests"
+)
+
+func TestIndexDependencies(t *testing.T) {
+	nestedGenerators := map[string]generators.GeneratorFactory{
+		"GitRepository": gitrepository.GeneratorFactory(nil),
+		"PullRequests":  pullrequests.GeneratorFactory,
+	}
+	enabledGenerators := map[string]generators.Generator{
+		"GitRepository": gitrepository.GeneratorFactory(nil)(logr.Discard(), nil),
+		"APIClient":     apiclient.GeneratorFactory(apiclient.DefaultClientFactory)(logr.Discard(), nil),
+		"Config":        config.GeneratorFactory(logr.Discard(), nil),
+		"Matrix":        matrix.GeneratorFactory(nestedGenerators)(logr.Discard(), nil),
+	}
+
+	gs := &templatesv1.GitOpsSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "demo-set", Namespace: "default"},
+		Spec: templatesv1.GitOpsSetSpec{
+			Generators: []templatesv1.GitOpsSetGenerator{
+				{
+					GitRepository: &templatesv1.GitRepositoryGenerator{RepositoryRef: "top-level-repo"},
+				},
+				{
+					Config: &templatesv1.ConfigGenerator{Kind: "ConfigMap", Name: "test-config"},
+				},
+				{
+					APIClient: &templatesv1.APIClientGenerator{
+						Endpoint:   "https://example.com/api",
+						HeadersRef: &templatesv1.HeadersReference{Kind: "Secret", Name: "api-headers"},
+						TLS:        &templatesv1.APIClientTLS{CASecretRef: corev1.LocalObjectReference{Name: "api-tls"}},
+					},
+				},
+				{
+					Matrix: &templatesv1.MatrixGenerator{
+						Generators: []templatesv1.GitOpsSetNestedGenerator{
+							{
+								GitRepository: &templatesv1.GitRepositoryGenerator{RepositoryRef: "nested-repo", Namespace: "flux-system"},
+							},
+							{
+								PullRequests: &templatesv1.PullRequestGenerator{
+									Driver:    "fake",
+									ServerURL: "https://example.com",
+									Repo:      "test-org/my-repo",
+									SecretRef: &corev1.LocalObjectReference{Name: "pr-credentials"},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	indexTests := []struct {
+		kind string
+		want []string
+	}{
+		{kind: "GitRepository", want: []string{"default/top-level-repo", "flux-system/nested-repo"}},
+		{kind: "ConfigMap", want: []string{"default/test-config"}},
+		{kind: "Secret", want: []string{"default/api-headers", "default/api-tls", "default/pr-credentials"}},
+		{kind: "OCIRepository"},
+	}
+
+	for _, tt := range indexTests {
+		t.Run(tt.kind, func(t *testing.T) {
+			got := indexDependencies(tt.kind, enabledGenerators)(gs)
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Fatalf("failed to index dependencies:\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,250 @@
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/gitops-tools/pkg/sets"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/cli-utils/pkg/object"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	templatesv1 "github.com/weaveworks/gitopssets-controller/api/v1beta1"
+	"github.com/weaveworks/gitopssets-controller/controllers/templates"
+)
+
+// RenderedDigestAnnotation is added to generated resources when drift
+// detection is enabled and records a digest of the rendered resource.
+//
+// This is used to tell the difference between changes to the rendered
+// templates and changes made to the resources in the cluster.
+const RenderedDigestAnnotation = "templates.weave.works/rendered-digest"
+
+func driftDetectionEnabled(gitOpsSet *templatesv1.GitOpsSet) bool {
+	return gitOpsSet.Spec.DriftDetection == templatesv1.DriftDetectionEnabled ||
+		gitOpsSet.Spec.DriftDetection == templatesv1.DriftDetectionWarn
+}
+
+// addRenderedDigest records the digest of the rendered resource in an
+// annotation on the resource.
+func addRenderedDigest(obj *unstructured.Unstructured) error {
+	b, err := json.Marshal(obj.Object)
+	if err != nil {
+		return fmt.Errorf("failed to calculate digest of resource: %w", err)
+	}
+
+	annotations := obj.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[RenderedDigestAnnotation] = fmt.Sprintf("sha256:%x", sha256.Sum256(b))
+	obj.SetAnnotations(annotations)
+
+	return nil
+}
+
+// resourceDrifted returns true if the resource in the cluster has been changed
+// or deleted since it was applied.
+//
+// The desired resource must have been annotated with the digest of the
+// rendered resource, if the digest doesn't match the digest recorded on the
+// existing resource, then the templates have changed, and this is not
+// considered to be drift.
+func resourceDrifted(ctx context.Context, k8sClient client.Client, desired *unstructured.Unstructured) (bool, error) {
+	existing := &unstructured.Unstructured{}
+	existing.SetGroupVersionKind(desired.GroupVersionKind())
+	if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(desired), existing); err != nil {
+		if apierrors.IsNotFound(err) {
+			return true, nil
+		}
+
+		return false, err
+	}
+
+	if existing.GetAnnotations()[RenderedDigestAnnotation] != desired.GetAnnotations()[RenderedDigestAnnotation] {
+		return false, nil
+	}
+
+	// Fields that have been changed by other field managers would fail with a
+	// conflict unless ownership is forced.
+	return resourceChanged(ctx, k8sClient, existing, desired, true)
+}
+
+// recordDrift updates the DriftDetected condition and emits an event for the
+// drifted resources.
+func (r *GitOpsSetReconciler) recordDrift(gitOpsSet *templatesv1.GitOpsSet, drifted []string) {
+	if len(drifted) == 0 {
+		templatesv1.ClearGitOpsSetDriftDetected(gitOpsSet)
+		return
+	}
+
+	if gitOpsSet.Spec.DriftDetection == templatesv1.DriftDetectionWarn {
+		msg := fmt.Sprintf("%d resources have drifted: %s", len(drifted), strings.Join(drifted, ", "))
+		templatesv1.SetGitOpsSetDriftDetected(gitOpsSet, msg)
+		if r.EventRecorder != nil {
+			r.EventRecorder.Event(gitOpsSet, corev1.EventTypeWarning, templatesv1.DriftDetectedReason, msg)
+		}
+		return
+	}
+
+	templatesv1.ClearGitOpsSetDriftDetected(gitOpsSet)
+	if r.EventRecorder != nil {
+		msg := fmt.Sprintf("corrected drift in %d resources: %s", len(drifted), strings.Join(drifted, ", "))
+		r.EventRecorder.Event(gitOpsSet, corev1.EventTypeNormal, templatesv1.DriftDetectedReason, msg)
+	}
+}
+
+// watchInventory starts watching the kinds of the resources in the inventory
+// if they are not already being watched, and stops watching the kinds that are
+// no longer in the inventory of any GitOpsSet with drift detection enabled.
+//
+// The watches only cache the metadata of the resources, and resources in
+// remote clusters are not watched, their drift is corrected when the GitOpsSet
+// is next reconciled.
+//
+// The watches use the controller's ServiceAccount, which needs permission to
+// list and watch the generated kinds in all namespaces.
+func (r *GitOpsSetReconciler) watchInventory(ctx context.Context, gitOpsSet *templatesv1.GitOpsSet, inventory *templatesv1.ResourceInventory) error {
+	if r.controller == nil {
+		return nil
+	}
+
+	kinds := sets.New[schema.GroupVersionKind]()
+	if inventory != nil && driftDetectionEnabled(gitOpsSet) && gitOpsSet.DeletionTimestamp.IsZero() {
+		for _, ref := range inventory.Entries {
+			if ref.Cluster != "" {
+				continue
+			}
+
+			objMeta, err := object.ParseObjMetadata(ref.ID)
+			if err != nil {
+				return fmt.Errorf("failed to parse object ID %s: %w", ref.ID, err)
+			}
+			kinds.Insert(objMeta.GroupKind.WithVersion(ref.Version))
+		}
+	}
+
+	r.watchesMu.Lock()
+	defer r.watchesMu.Unlock()
+
+	if r.watchedKinds == nil {
+		r.watchedKinds = sets.New[schema.GroupVersionKind]()
+		r.inventoryKinds = map[client.ObjectKey]sets.Set[schema.GroupVersionKind]{}
+	}
+
+	key := client.ObjectKeyFromObject(gitOpsSet)
+	if kinds.Len() == 0 {
+		delete(r.inventoryKinds, key)
+	} else {
+		r.inventoryKinds[key] = kinds
+	}
+
+	logger := log.FromContext(ctx)
+	for _, gvk := range kinds.Difference(r.watchedKinds).List() {
+		obj := &metav1.PartialObjectMetadata{}
+		obj.SetGroupVersionKind(gvk)
+		if err := r.controller.Watch(
+			source.Kind(r.cache, obj),
+			handler.EnqueueRequestsFromMapFunc(r.generatedResourceToGitOpsSet),
+			generatedResourcePredicate()); err != nil {
+			return fmt.Errorf("failed to watch %s: %w", gvk, err)
+		}
+		logger.Info("watching generated resources", "gvk", gvk.String())
+		r.watchedKinds.Insert(gvk)
+	}
+
+	// The watches are stopped by removing the informers, the cache is only
+	// used for the generated resources, so this doesn't affect the other
+	// watches of the controller.
+	inUse := sets.New[schema.GroupVersionKind]()
+	for _, v := range r.inventoryKinds {
+		inUse = inUse.Union(v)
+	}
+	for _, gvk := range r.watchedKinds.Difference(inUse).List() {
+		obj := &metav1.PartialObjectMetadata{}
+		obj.SetGroupVersionKind(gvk)
+		if err := r.cache.RemoveInformer(ctx, obj); err != nil {
+			return fmt.Errorf("failed to stop watching %s: %w", gvk, err)
+		}
+		logger.Info("stopped watching generated resources", "gvk", gvk.String())
+		r.watchedKinds.Delete(gvk)
+	}
+
+	return nil
+}
+
+// generatedResourceToGitOpsSet maps a generated resource to the GitOpsSet that
+// generated it, if the GitOpsSet has drift detection enabled.
+func (r *GitOpsSetReconciler) generatedResourceToGitOpsSet(ctx context.Context, obj client.Object) []reconcile.Request {
+	labels := obj.GetLabels()
+	key := client.ObjectKey{Name: labels[templates.NameLabel], Namespace: labels[templates.NamespaceLabel]}
+	if key.Name == "" || key.Namespace == "" {
+		return nil
+	}
+
+	var gitOpsSet templatesv1.GitOpsSet
+	if err := r.Get(ctx, key, &gitOpsSet); err != nil {
+		return nil
+	}
+
+	if !driftDetectionEnabled(&gitOpsSet) {
+		return nil
+	}
+
+	return []reconcile.Request{{NamespacedName: key}}
+}
+
+// generatedResourcePredicate filters the events for generated resources to
+// those that can indicate that a resource has drifted.
+//
+// Creation events are ignored, these are sent when the watch is started, and
+// when the controller creates resources.
+//
+// Resources that don't have a generation e.g. ConfigMaps are compared by
+// resource version.
+func generatedResourcePredicate() predicate.Predicate {
+	return predicate.Funcs{
+		CreateFunc: func(e event.CreateEvent) bool {
+			return false
+		},
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			if e.ObjectOld == nil || e.ObjectNew == nil || !isGeneratedResource(e.ObjectNew) {
+				return false
+			}
+
+			if e.ObjectNew.GetGeneration() == 0 {
+				return e.ObjectNew.GetResourceVersion() != e.ObjectOld.GetResourceVersion()
+			}
+
+			return e.ObjectNew.GetGeneration() != e.ObjectOld.GetGeneration() ||
+				!reflect.DeepEqual(e.ObjectNew.GetLabels(), e.ObjectOld.GetLabels()) ||
+				!reflect.DeepEqual(e.ObjectNew.GetAnnotations(), e.ObjectOld.GetAnnotations())
+		},
+		DeleteFunc: func(e event.DeleteEvent) bool {
+			return isGeneratedResource(e.Object)
+		},
+		GenericFunc: func(e event.GenericEvent) bool {
+			return false
+		},
+	}
+}
+
+func isGeneratedResource(obj client.Object) bool {
+	_, ok := obj.GetLabels()[templates.NameLabel]
+
+	return ok
+}
@@ -0,0 +1,205 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gitops-tools/pkg/sets"
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	templatesv1 "github.com/weaveworks/gitopssets-controller/api/v1beta1"
+	"github.com/weaveworks/gitopssets-controller/controllers/templates"
+	"github.com/weaveworks/gitopssets-controller/test"
+)
+
+func TestGeneratedResourcePredicate(t *testing.T) {
+	generated := func(opts ...func(client.Object)) client.Object {
+		obj := &metav1.PartialObjectMetadata{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            "test-resource",
+				Namespace:       "default",
+				Generation:      1,
+				ResourceVersion: "1",
+				Labels: map[string]string{
+					templates.NameLabel:      "demo-set",
+					templates.NamespaceLabel: "default",
+				},
+			},
+		}
+		for _, o := range opts {
+			o(obj)
+		}
+
+		return obj
+	}
+
+	updateTests := []struct {
+		name   string
+		oldObj client.Object
+		newObj client.Object
+		want   bool
+	}{
+		{
+			name:   "status change",
+			oldObj: generated(),
+			newObj: generated(func(o client.Object) { o.SetResourceVersion("2") }),
+			want:   false,
+		},
+		{
+			name:   "generation change",
+			oldObj: generated(),
+			newObj: generated(func(o client.Object) { o.SetGeneration(2) }),
+			want:   true,
+		},
+		{
+			name:   "label change",
+			oldObj: generated(),
+			newObj: generated(func(o client.Object) {
+				o.SetLabels(map[string]string{
+					templates.NameLabel:      "demo-set",
+					templates.NamespaceLabel: "default",
+					"new-label":              "test",
+				})
+			}),
+			want: true,
+		},
+		{
+			name:   "annotation change",
+			oldObj: generated(),
+			newObj: generated(func(o client.Object) {
+				o.SetAnnotations(map[string]string{"new-annotation": "test"})
+			}),
+			want: true,
+		},
+		{
+			name:   "resource version change without generation",
+			oldObj: generated(func(o client.Object) { o.SetGeneration(0) }),
+			newObj: generated(func(o client.Object) {
+				o.SetGeneration(0)
+				o.SetResourceVersion("2")
+			}),
+			want: true,
+		},
+		{
+			name:   "not a generated resource",
+			oldObj: generated(func(o client.Object) { o.SetLabels(nil) }),
+			newObj: generated(func(o client.Object) {
+				o.SetLabels(nil)
+				o.SetGeneration(2)
+			}),
+			want: false,
+		},
+	}
+
+	for _, tt := range updateTests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := generatedResourcePredicate().Update(event.UpdateEvent{ObjectOld: tt.oldObj, ObjectNew: tt.newObj})
+			if got != tt.want {
+				t.Errorf("Update() got %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("creation", func(t *testing.T) {
+		if generatedResourcePredicate().Create(event.CreateEvent{Object: generated()}) {
+			t.Error("expected creation events to be ignored")
+		}
+	})
+
+	t.Run("deletion", func(t *testing.T) {
+		if !generatedResourcePredicate().Delete(event.DeleteEvent{Object: generated()}) {
+			t.Error("expected deletion events to be accepted")
+		}
+		if generatedResourcePredicate().Delete(event.DeleteEvent{Object: &corev1.ConfigMap{}}) {
+			t.Error("expected deletion events for resources without labels to be ignored")
+		}
+	})
+}
+
+func TestWatchInventory(t *testing.T) {
+	fc := &fakeController{}
+	fi := &fakeInformers{}
+	r := &GitOpsSetReconciler{controller: fc, cache: fi}
+
+	kustomizationRef := templatesv1.ResourceRef{ID: "default_test-kustomization_kustomize.toolkit.fluxcd.io_Kustomization", Version: "v1"}
+	configMapRef := templatesv1.ResourceRef{ID: "default_test-cm__ConfigMap", Version: "v1"}
+	kustomizationGVK := schema.GroupVersionKind{Group: "kustomize.toolkit.fluxcd.io", Version: "v1", Kind: "Kustomization"}
+	configMapGVK := schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}
+
+	gs1 := newDriftGitOpsSet("test-set-1", templatesv1.DriftDetectionEnabled)
+	gs2 := newDriftGitOpsSet("test-set-2", templatesv1.DriftDetectionWarn)
+
+	test.AssertNoError(t, r.watchInventory(context.TODO(), gs1, &templatesv1.ResourceInventory{
+		Entries: []templatesv1.ResourceRef{kustomizationRef, configMapRef}}))
+	test.AssertNoError(t, r.watchInventory(context.TODO(), gs2, &templatesv1.ResourceInventory{
+		Entries: []templatesv1.ResourceRef{configMapRef, {ID: "default_remote-cm__ConfigMap", Version: "v1", Cluster: "default/remote"}}}))
+	assertWatchedKinds(t, r, kustomizationGVK, configMapGVK)
+	if fc.watches != 2 {
+		t.Fatalf("got %d watches, want 2", fc.watches)
+	}
+
+	// The Kustomization is no longer generated.
+	test.AssertNoError(t, r.watchInventory(context.TODO(), gs1, &templatesv1.ResourceInventory{
+		Entries: []templatesv1.ResourceRef{configMapRef}}))
+	assertWatchedKinds(t, r, configMapGVK)
+
+	// The ConfigMaps are still generated by the first GitOpsSet.
+	gs2.Spec.DriftDetection = templatesv1.DriftDetectionDisabled
+	test.AssertNoError(t, r.watchInventory(context.TODO(), gs2, &templatesv1.ResourceInventory{
+		Entries: []templatesv1.ResourceRef{configMapRef}}))
+	assertWatchedKinds(t, r, configMapGVK)
+
+	// The GitOpsSet is deleted.
+	test.AssertNoError(t, r.watchInventory(context.TODO(), gs1, nil))
+	assertWatchedKinds(t, r)
+
+	if diff := cmp.Diff([]schema.GroupVersionKind{kustomizationGVK, configMapGVK}, fi.removed); diff != "" {
+		t.Fatalf("failed to stop watches:\n%s", diff)
+	}
+}
+
+func assertWatchedKinds(t *testing.T, r *GitOpsSetReconciler, want ...schema.GroupVersionKind) {
+	t.Helper()
+	if diff := cmp.Diff(sets.New(want...), r.watchedKinds); diff != "" {
+		t.Fatalf("failed to watch kinds:\n%s", diff)
+	}
+}
+
+func newDriftGitOpsSet(name string, mode templatesv1.DriftDetectionMode) *templatesv1.GitOpsSet {
+	return &templatesv1.GitOpsSet{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Spec:       templatesv1.GitOpsSetSpec{DriftDetection: mode},
+	}
+}
+
+type fakeController struct {
+	controller.Controller
+	watches int
+}
+
+func (c *fakeController) Watch(src source.Source, eventhandler handler.EventHandler, predicates ...predicate.Predicate) error {
+	c.watches++
+
+	return nil
+}
+
+type fakeInformers struct {
+	cache.Cache
+	removed []schema.GroupVersionKind
+}
+
+func (i *fakeInformers) RemoveInformer(ctx context.Context, obj client.Object) error {
+	i.removed = append(i.removed, obj.GetObjectKind().GroupVersionKind())
+
+	return nil
+}
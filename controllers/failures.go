@@ -0,0 +1,150 @@
+package controllers
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	templatesv1 "github.com/weaveworks/gitopssets-controller/api/v1beta1"
+	"github.com/weaveworks/gitopssets-controller/controllers/templates/generators"
+)
+
+// stageError records the stage of the reconciliation that failed, the reason
+// is recorded in the Ready condition.
+type stageError struct {
+	reason string
+	err    error
+}
+
+func (e stageError) Error() string {
+	return e.err.Error()
+}
+
+func (e stageError) Unwrap() error {
+	return e.err
+}
+
+// stalledError is returned when the GitOpsSet can't be reconciled until it's
+// changed, retrying the reconciliation would fail in the same way.
+type stalledError struct {
+	reason string
+	err    error
+}
+
+func (e stalledError) Error() string {
+	return e.err.Error()
+}
+
+func (e stalledError) Unwrap() error {
+	return e.err
+}
+
+// failedStage wraps the error with the reason for the stage that failed, nil
+// errors are not wrapped.
+func failedStage(reason string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return stageError{reason: reason, err: err}
+}
+
+// failureReason returns the reason for the Ready condition when the
+// reconciliation fails with the error.
+func failureReason(err error) string {
+	var stalled stalledError
+	if errors.As(err, &stalled) {
+		return stalled.reason
+	}
+
+	if errors.As(err, &generators.NoArtifactError{}) {
+		return templatesv1.ArtifactFailedReason
+	}
+
+	if isAccessDenied(err) {
+		return templatesv1.AccessDeniedReason
+	}
+
+	if errors.As(err, &OwnershipConflictError{}) {
+		return templatesv1.OwnershipConflictReason
+	}
+
+	var stage stageError
+	if errors.As(err, &stage) {
+		return stage.reason
+	}
+
+	return templatesv1.ReconciliationFailedReason
+}
+
+// retryJitter is the maximum fraction of the retry interval that is added to
+// it, so that GitOpsSets that fail at the same time are not all retried at the
+// same time.
+const retryJitter = 0.1
+
+// isTransient returns true if the error is expected to be resolved without
+// the GitOpsSet being changed, for example, network timeouts, refused and reset
+// connections, server errors and rate limits from APIs and conflicts when
+// updating resources.
+//
+// Other network failures, for example, certificates that can't be verified,
+// are not transient.
+func isTransient(err error) bool {
+	var statusErr generators.HTTPStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.Transient()
+	}
+
+	if apierrors.IsConflict(err) || apierrors.IsServerTimeout(err) || apierrors.IsTimeout(err) ||
+		apierrors.IsTooManyRequests(err) || apierrors.IsServiceUnavailable(err) || apierrors.IsInternalError(err) {
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	return errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET)
+}
+
+// isPermanent returns true if the error is expected to fail in the same way
+// every time the GitOpsSet is reconciled, for example, requests that APIs
+// reject as invalid and certificates that can't be verified.
+//
+// Other client errors, for example, unauthorized requests and missing
+// resources, can be resolved without the GitOpsSet being changed.
+func isPermanent(err error) bool {
+	var statusErr generators.HTTPStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode == http.StatusBadRequest || statusErr.StatusCode == http.StatusUnprocessableEntity
+	}
+
+	var verificationErr *tls.CertificateVerificationError
+	var unknownAuthorityErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var invalidErr x509.CertificateInvalidError
+
+	return errors.As(err, &verificationErr) || errors.As(err, &unknownAuthorityErr) ||
+		errors.As(err, &hostnameErr) || errors.As(err, &invalidErr)
+}
+
+// retryAfter returns the interval after which a reconciliation that failed
+// with a transient error is retried, with jitter added.
+//
+// False is returned if the error is not transient, or the GitOpsSet has no
+// retry interval, these are retried with the backoff of the controller.
+func retryAfter(gs *templatesv1.GitOpsSet, err error) (time.Duration, bool) {
+	if gs.Spec.RetryInterval == nil || !isTransient(err) {
+		return 0, false
+	}
+
+	return wait.Jitter(gs.Spec.RetryInterval.Duration, retryJitter), true
+}
@@ -0,0 +1,258 @@
+package controllers
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	templatesv1 "github.com/weaveworks/gitopssets-controller/api/v1beta1"
+	"github.com/weaveworks/gitopssets-controller/controllers/templates/generators"
+)
+
+func TestFailureReason(t *testing.T) {
+	reasonTests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{
+			name: "unknown error",
+			err:  errors.New("failed"),
+			want: templatesv1.ReconciliationFailedReason,
+		},
+		{
+			name: "failed stage",
+			err:  fmt.Errorf("wrapped: %w", failedStage(templatesv1.ApplyFailedReason, errors.New("failed"))),
+			want: templatesv1.ApplyFailedReason,
+		},
+		{
+			name: "stalled",
+			err:  stalledError{reason: templatesv1.RenderFailedReason, err: errors.New("failed")},
+			want: templatesv1.RenderFailedReason,
+		},
+		{
+			name: "missing artifact while rendering",
+			err:  failedStage(templatesv1.RenderFailedReason, generators.ArtifactError("GitRepository", client.ObjectKey{Name: "test"})),
+			want: templatesv1.ArtifactFailedReason,
+		},
+		{
+			name: "ownership conflict while applying",
+			err:  failedStage(templatesv1.ApplyFailedReason, OwnershipConflictError{ID: "test"}),
+			want: templatesv1.OwnershipConflictReason,
+		},
+		{
+			name: "apply and prune failures",
+			err: errors.Join(
+				failedStage(templatesv1.ApplyFailedReason, errors.New("failed to apply")),
+				failedStage(templatesv1.PruneFailedReason, errors.New("failed to prune"))),
+			want: templatesv1.ApplyFailedReason,
+		},
+		{
+			name: "prune failure",
+			err:  errors.Join(nil, failedStage(templatesv1.PruneFailedReason, errors.New("failed to prune"))),
+			want: templatesv1.PruneFailedReason,
+		},
+	}
+
+	for _, tt := range reasonTests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := failureReason(tt.err); got != tt.want {
+				t.Fatalf("got reason %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFailedStage(t *testing.T) {
+	if err := failedStage(templatesv1.ApplyFailedReason, nil); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+}
+
+func TestIsTransient(t *testing.T) {
+	configMaps := schema.GroupResource{Resource: "configmaps"}
+	transientTests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{
+			name: "unknown error",
+			err:  errors.New("failed"),
+		},
+		{
+			name: "connection refused",
+			err:  fmt.Errorf("failed to fetch: %w", newURLError(&net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)})),
+			want: true,
+		},
+		{
+			name: "connection reset",
+			err:  newURLError(&net.OpError{Op: "read", Net: "tcp", Err: os.NewSyscallError("read", syscall.ECONNRESET)}),
+			want: true,
+		},
+		{
+			name: "timeout",
+			err:  newURLError(&net.OpError{Op: "dial", Net: "tcp", Err: os.ErrDeadlineExceeded}),
+			want: true,
+		},
+		{
+			name: "certificate that can't be verified",
+			err:  newURLError(&tls.CertificateVerificationError{Err: x509.UnknownAuthorityError{}}),
+		},
+		{
+			name: "other network failure",
+			err:  newURLError(errors.New("no such host")),
+		},
+		{
+			name: "server error from an API",
+			err:  failedStage(templatesv1.RenderFailedReason, generators.HTTPStatusError{StatusCode: 503, Endpoint: "https://example.com"}),
+			want: true,
+		},
+		{
+			name: "rate limited by an API",
+			err:  generators.HTTPStatusError{StatusCode: 429, Endpoint: "https://example.com"},
+			want: true,
+		},
+		{
+			name: "client error from an API",
+			err:  generators.HTTPStatusError{StatusCode: 404, Endpoint: "https://example.com"},
+		},
+		{
+			name: "unauthorized by an APIClient endpoint",
+			err:  failedStage(templatesv1.RenderFailedReason, generators.HTTPStatusError{StatusCode: 401, Endpoint: "https://example.com/api"}),
+		},
+		{
+			name: "conflict updating a resource",
+			err:  failedStage(templatesv1.ApplyFailedReason, apierrors.NewConflict(configMaps, "test", errors.New("modified"))),
+			want: true,
+		},
+		{
+			name: "API server unavailable",
+			err:  apierrors.NewServiceUnavailable("unavailable"),
+			want: true,
+		},
+		{
+			name: "forbidden",
+			err:  apierrors.NewForbidden(configMaps, "test", errors.New("forbidden")),
+		},
+		{
+			name: "invalid templates",
+			err:  stalledError{reason: templatesv1.RenderFailedReason, err: errors.New("failed to parse")},
+		},
+	}
+
+	for _, tt := range transientTests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isTransient(tt.err); got != tt.want {
+				t.Fatalf("isTransient() got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsPermanent(t *testing.T) {
+	permanentTests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{
+			name: "unknown error",
+			err:  errors.New("failed"),
+		},
+		{
+			name: "bad request to an APIClient endpoint",
+			err:  failedStage(templatesv1.RenderFailedReason, generators.HTTPStatusError{StatusCode: 400, Endpoint: "https://example.com/api"}),
+			want: true,
+		},
+		{
+			name: "unprocessable request to an API",
+			err:  generators.HTTPStatusError{StatusCode: 422, Endpoint: "https://example.com"},
+			want: true,
+		},
+		{
+			name: "unauthorized request to an API",
+			err:  generators.HTTPStatusError{StatusCode: 401, Endpoint: "https://example.com"},
+		},
+		{
+			name: "forbidden request to an API",
+			err:  generators.HTTPStatusError{StatusCode: 403, Endpoint: "https://example.com"},
+		},
+		{
+			name: "missing API endpoint",
+			err:  generators.HTTPStatusError{StatusCode: 404, Endpoint: "https://example.com"},
+		},
+		{
+			name: "request timeout from an API",
+			err:  generators.HTTPStatusError{StatusCode: 408, Endpoint: "https://example.com"},
+		},
+		{
+			name: "rate limited by an API",
+			err:  generators.HTTPStatusError{StatusCode: 429, Endpoint: "https://example.com"},
+		},
+		{
+			name: "server error from an API",
+			err:  generators.HTTPStatusError{StatusCode: 503, Endpoint: "https://example.com"},
+		},
+		{
+			name: "certificate that can't be verified",
+			err:  newURLError(&tls.CertificateVerificationError{Err: x509.UnknownAuthorityError{}}),
+			want: true,
+		},
+		{
+			name: "certificate for another host",
+			err:  newURLError(x509.HostnameError{Host: "example.com"}),
+			want: true,
+		},
+		{
+			name: "connection refused",
+			err:  newURLError(&net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}),
+		},
+	}
+
+	for _, tt := range permanentTests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isPermanent(tt.err); got != tt.want {
+				t.Fatalf("isPermanent() got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	transientErr := apierrors.NewServiceUnavailable("unavailable")
+	gs := &templatesv1.GitOpsSet{}
+
+	if _, ok := retryAfter(gs, transientErr); ok {
+		t.Fatal("expected no retry without a retry interval")
+	}
+
+	gs.Spec.RetryInterval = &metav1.Duration{Duration: time.Minute}
+	if _, ok := retryAfter(gs, errors.New("failed")); ok {
+		t.Fatal("expected no retry for an error that is not transient")
+	}
+
+	retry, ok := retryAfter(gs, transientErr)
+	if !ok {
+		t.Fatal("expected a retry for a transient error")
+	}
+	if retry < time.Minute || retry > time.Minute+6*time.Second {
+		t.Fatalf("got retry after %s, want between 1m and 1m6s", retry)
+	}
+}
+
+func newURLError(err error) error {
+	return &url.Error{Op: "Get", URL: "https://example.com", Err: err}
+}
@@ -5,50 +5,58 @@ import (
 	"errors"
 	"fmt"
 	"sort"
+	"sync"
 	"time"
 
-	imagev1 "github.com/fluxcd/image-reflector-controller/api/v1beta2"
 	eventv1 "github.com/fluxcd/pkg/apis/event/v1beta1"
 	fluxMeta "github.com/fluxcd/pkg/apis/meta"
 	"github.com/fluxcd/pkg/runtime/conditions"
 	runtimeCtrl "github.com/fluxcd/pkg/runtime/controller"
 	"github.com/fluxcd/pkg/runtime/predicates"
-	sourcev1 "github.com/fluxcd/source-controller/api/v1beta2"
 	"github.com/gitops-tools/pkg/sets"
 	"github.com/go-logr/logr"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/utils/lru"
 	"sigs.k8s.io/cli-utils/pkg/object"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/ratelimiter"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
-	clustersv1 "github.com/weaveworks/cluster-controller/api/v1alpha1"
-	templatesv1 "github.com/weaveworks/gitopssets-controller/api/v1alpha1"
+	templatesv1 "github.com/weaveworks/gitopssets-controller/api/v1beta1"
 	"github.com/weaveworks/gitopssets-controller/controllers/templates"
 	"github.com/weaveworks/gitopssets-controller/controllers/templates/generators"
+	"github.com/weaveworks/gitopssets-controller/pkg/elements"
+	"github.com/weaveworks/gitopssets-controller/pkg/impersonation"
+	"github.com/weaveworks/gitopssets-controller/pkg/inventory"
+	"github.com/weaveworks/gitopssets-controller/pkg/registry"
 )
 
 var accessor = meta.NewAccessor()
 
-const (
-	gitRepositoryIndexKey string = ".metadata.gitRepository"
-	ociRepositoryIndexKey string = ".metadata.ociRepository"
-	imagePolicyIndexKey   string = ".metadata.imagePolicy"
-	configMapIndexKey     string = ".metadata.configMap"
-	secretIndexKey        string = ".metadata.secret"
-)
+// fieldManager is the name of the field manager used when applying generated
+// resources with server-side apply.
+const fieldManager = "gitopssets-controller"
 
 type eventRecorder interface {
 	Event(object runtime.Object, eventType, reason, message string)
@@ -64,8 +72,56 @@ type GitOpsSetReconciler struct {
 
 	Generators map[string]generators.GeneratorFactory
 
+	// Watches are the additional kinds that are watched for the enabled
+	// generators.
+	Watches []registry.Watch
+
+	// InventoryThreshold is the number of inventory entries above which the
+	// inventory is stored in ConfigMaps rather than in the status.
+	InventoryThreshold int
+
+	// MaxRenderedResources is the maximum number of resources that can be
+	// rendered for a GitOpsSet, unless the GitOpsSet overrides it, zero means
+	// that the number is not limited.
+	MaxRenderedResources int
+
+	// NoCrossNamespaceRefs disables references from generators to objects in
+	// other namespaces, unless the objects allow them with the
+	// AllowedNamespacesAnnotation.
+	NoCrossNamespaceRefs bool
+
+	// MaxConcurrentReconciles is the number of GitOpsSets that are reconciled
+	// concurrently, each GitOpsSet is only reconciled by one worker at a time.
+	MaxConcurrentReconciles int
+
+	// RateLimiter limits the rate at which failed reconciliations are
+	// retried, the controller-runtime default is used if it's nil.
+	RateLimiter ratelimiter.RateLimiter
+
+	// RequeueDependency is the interval after which GitOpsSets that are
+	// waiting for the artifact of a source are reconciled again, zero waits
+	// for the source to change.
+	RequeueDependency time.Duration
+
+	// WatchLabelSelector limits the GitOpsSets that are reconciled to those
+	// with matching labels, so that GitOpsSets can be sharded across
+	// controllers.
+	WatchLabelSelector labels.Selector
+
+	// ImpersonationCacheSize is the number of clients for impersonated
+	// ServiceAccounts that are cached, zero uses the default size.
+	ImpersonationCacheSize int
+
 	Scheme *runtime.Scheme
 	Mapper meta.RESTMapper
+
+	impersonationClients *impersonation.Cache
+	remoteClients        *lru.Cache
+	controller           controller.Controller
+	cache                cache.Cache
+	watchesMu            sync.Mutex
+	watchedKinds         sets.Set[schema.GroupVersionKind]
+	inventoryKinds       map[client.ObjectKey]sets.Set[schema.GroupVersionKind]
 }
 
 // event emits a Kubernetes event using EventRecorder
@@ -86,11 +142,12 @@ func (r *GitOpsSetReconciler) event(obj *templatesv1.GitOpsSet, severity, msg st
 //+kubebuilder:rbac:groups=templates.weave.works,resources=gitopssets,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=templates.weave.works,resources=gitopssets/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=templates.weave.works,resources=gitopssets/finalizers,verbs=update
+//+kubebuilder:rbac:groups=templates.weave.works,resources=gitopssetpolicies,verbs=get;list;watch
 //+kubebuilder:rbac:groups=source.toolkit.fluxcd.io,resources=gitrepositories,verbs=get;list;watch
 //+kubebuilder:rbac:groups=source.toolkit.fluxcd.io,resources=ocirepositories,verbs=get;list;watch
-//+kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch
-//+kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch
-//+kubebuilder:rbac:groups="",resources=serviceaccounts,verbs=impersonate
+//+kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;create;update
+//+kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups="",resources=serviceaccounts,verbs=get;list;watch;impersonate
 //+kubebuilder:rbac:groups=gitops.weave.works,resources=gitopsclusters,verbs=get;list;watch
 //+kubebuilder:rbac:groups=image.toolkit.fluxcd.io,resources=imagepolicies,verbs=get;list;watch
 //+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
@@ -111,6 +168,20 @@ func (r *GitOpsSetReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 
 	logger.Info("GitOpsSet loaded")
 
+	loadedInventory, err := inventory.Load(ctx, r.Client, &gitOpsSet)
+	if err != nil {
+		if gitOpsSet.ObjectMeta.DeletionTimestamp.IsZero() {
+			return ctrl.Result{}, fmt.Errorf("failed to load inventory: %w", err)
+		}
+
+		// The GitOpsSet would never be deleted if the inventory can't be
+		// loaded, so the generated resources are left in place.
+		logger.Error(err, "failed to load inventory, generated resources will not be removed")
+		r.event(&gitOpsSet, eventv1.EventSeverityError,
+			fmt.Sprintf("failed to load inventory, generated resources will not be removed: %s", err))
+	}
+	gitOpsSet.Status.Inventory = loadedInventory
+
 	// Add finalizer first if it doesn't exist to avoid the race condition
 	// between init and delete.
 	if !controllerutil.ContainsFinalizer(&gitOpsSet, templatesv1.GitOpsSetFinalizer) {
@@ -137,9 +208,10 @@ func (r *GitOpsSetReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 		}
 		k8sClient = c
 	}
+	clients := r.clusterClients(&gitOpsSet, k8sClient)
 
 	if !gitOpsSet.ObjectMeta.DeletionTimestamp.IsZero() {
-		return r.finalize(ctx, &gitOpsSet, k8sClient)
+		return r.finalize(ctx, &gitOpsSet, clients)
 	}
 
 	// Set the value of the reconciliation request in status.
@@ -147,9 +219,16 @@ func (r *GitOpsSetReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 		gitOpsSet.Status.LastHandledReconcileAt = v
 	}
 
+	templatesv1.SetGitOpsSetReconciling(&gitOpsSet, fluxMeta.ProgressingReason, "Reconciliation in progress")
+	if err := r.patchStatus(ctx, req, gitOpsSet.Status); err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to mark GitOpsSet as reconciling: %w", err)
+	}
+
 	defer func() {
 		// Record Prometheus metrics.
 		r.Metrics.RecordReadiness(ctx, &gitOpsSet)
+		r.Metrics.RecordReconciling(ctx, &gitOpsSet)
+		r.Metrics.RecordStalled(ctx, &gitOpsSet)
 		r.Metrics.RecordDuration(ctx, &gitOpsSet, reconcileStart)
 		r.Metrics.RecordSuspend(ctx, &gitOpsSet, gitOpsSet.Spec.Suspend)
 
@@ -161,31 +240,128 @@ func (r *GitOpsSetReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 		}
 	}()
 
-	inventory, requeue, err := r.reconcileResources(ctx, k8sClient, &gitOpsSet)
+	inventory, requeue, err := r.reconcileResources(ctx, clients, &gitOpsSet)
 
 	if err != nil {
 		// We can return here because when the resource artifact is updated, this
-		// will trigger a reconciliation.
+		// will trigger a reconciliation, the GitOpsSet is also checked again
+		// after the retry interval, or the RequeueDependency interval, in case
+		// the change is missed.
 
 		if errors.As(err, &generators.NoArtifactError{}) {
-			templatesv1.SetGitOpsSetReadiness(&gitOpsSet, inventory, metav1.ConditionFalse, templatesv1.ReconciliationFailedReason, "waiting for artifact")
+			templatesv1.SetGitOpsSetReadiness(&gitOpsSet, inventory, metav1.ConditionFalse, templatesv1.ArtifactFailedReason, "waiting for artifact")
+			if err := r.patchStatus(ctx, req, gitOpsSet.Status); err != nil {
+				logger.Error(err, "failed to reconcile")
+			}
+			requeue := r.RequeueDependency
+			if gitOpsSet.Spec.RetryInterval != nil {
+				requeue = gitOpsSet.Spec.RetryInterval.Duration
+			}
+			return ctrl.Result{RequeueAfter: wait.Jitter(requeue, retryJitter)}, nil
+		}
+
+		// The later waves are applied once the resources in the wave are
+		// healthy, the health of the wave is checked after the interval.
+		var waveErr waveNotHealthyError
+		if errors.As(err, &waveErr) {
+			reason := fluxMeta.ProgressingReason
+			if waveErr.timeout > 0 {
+				reason = templatesv1.HealthCheckFailedReason
+				r.event(&gitOpsSet, eventv1.EventSeverityError, err.Error())
+			}
+			templatesv1.SetGitOpsSetReadiness(&gitOpsSet, inventory, metav1.ConditionFalse, reason, err.Error())
 			if err := r.patchStatus(ctx, req, gitOpsSet.Status); err != nil {
 				logger.Error(err, "failed to reconcile")
 			}
+			return ctrl.Result{RequeueAfter: healthCheckInterval}, nil
+		}
+
+		// The reconciliation is blocked until the deletions are approved with
+		// an annotation, which will trigger a reconciliation.
+		if errors.As(err, &PruneBlockedError{}) {
+			templatesv1.SetGitOpsSetStalled(&gitOpsSet, inventory, templatesv1.PruneBlockedReason, err.Error())
+			if err := r.patchStatus(ctx, req, gitOpsSet.Status); err != nil {
+				logger.Error(err, "failed to reconcile")
+			}
+			r.event(&gitOpsSet, eventv1.EventSeverityError, err.Error())
 			return ctrl.Result{}, nil
 		}
 
-		templatesv1.SetGitOpsSetReadiness(&gitOpsSet, inventory, metav1.ConditionFalse, templatesv1.ReconciliationFailedReason, err.Error())
+		// Retrying won't help until the GitOpsSet is changed, which will
+		// trigger a reconciliation, the GitOpsSet is still checked after the
+		// interval of the generators in case the failure is resolved.
+		if errors.As(err, &stalledError{}) || isPermanent(err) {
+			templatesv1.SetGitOpsSetStalled(&gitOpsSet, inventory, failureReason(err), err.Error())
+			if err := r.patchStatus(ctx, req, gitOpsSet.Status); err != nil {
+				logger.Error(err, "failed to reconcile")
+			}
+			r.event(&gitOpsSet, eventv1.EventSeverityError, err.Error())
+			return ctrl.Result{RequeueAfter: requeue}, nil
+		}
+
+		templatesv1.SetGitOpsSetReadiness(&gitOpsSet, inventory, metav1.ConditionFalse, failureReason(err), err.Error())
 		if err := r.patchStatus(ctx, req, gitOpsSet.Status); err != nil {
 			logger.Error(err, "failed to reconcile")
 		}
 		msg := fmt.Sprintf("Reconciliation failed after %s", time.Since(reconcileStart).String())
 		r.event(&gitOpsSet, eventv1.EventSeverityError, msg)
 
+		// Transient failures are retried after the retry interval of the
+		// GitOpsSet, rather than with the backoff of the controller.
+		if retry, ok := retryAfter(&gitOpsSet, err); ok {
+			logger.Error(err, "reconciliation failed, retrying", "retryAfter", retry)
+			return ctrl.Result{RequeueAfter: retry}, nil
+		}
+
 		return ctrl.Result{}, err
 	}
 
+	if gitOpsSet.PlanEnabled() {
+		msg := planSummary(gitOpsSet.Status.Plan)
+		if gitOpsSet.Status.Plan.HasChanges() {
+			templatesv1.SetGitOpsSetReadiness(&gitOpsSet, nil, metav1.ConditionFalse, templatesv1.PlanPendingReason, msg)
+			templatesv1.ClearGitOpsSetReconciling(&gitOpsSet)
+		} else {
+			templatesv1.SetGitOpsSetReadiness(&gitOpsSet, nil, metav1.ConditionTrue, templatesv1.ReconciliationSucceededReason, msg)
+		}
+
+		if err := r.patchStatus(ctx, req, gitOpsSet.Status); err != nil {
+			logger.Error(err, "failed to reconcile")
+			return ctrl.Result{}, fmt.Errorf("failed to update status with plan: %w", err)
+		}
+		if gitOpsSet.Status.Plan.HasChanges() {
+			r.event(&gitOpsSet, eventv1.EventSeverityInfo, msg)
+		}
+
+		return ctrl.Result{RequeueAfter: requeue}, nil
+	}
+
 	if inventory != nil {
+		if err := r.checkHealth(ctx, clients, &gitOpsSet, inventory); err != nil {
+			// Resources that are not healthy are checked again after the
+			// interval, rather than with the backoff of the controller.
+			var healthErr resourcesNotHealthyError
+			notHealthy := errors.As(err, &healthErr)
+			reason := templatesv1.HealthCheckFailedReason
+			if notHealthy && healthErr.timeout == 0 {
+				reason = fluxMeta.ProgressingReason
+			}
+
+			templatesv1.SetGitOpsSetReadiness(&gitOpsSet, inventory, metav1.ConditionFalse, reason, err.Error())
+			if err := r.patchStatus(ctx, req, gitOpsSet.Status); err != nil {
+				logger.Error(err, "failed to reconcile")
+			}
+			if reason == templatesv1.HealthCheckFailedReason {
+				r.event(&gitOpsSet, eventv1.EventSeverityError, err.Error())
+			}
+
+			if notHealthy {
+				return ctrl.Result{RequeueAfter: healthCheckInterval}, nil
+			}
+
+			return ctrl.Result{}, err
+		}
+
 		templatesv1.SetGitOpsSetReadiness(&gitOpsSet, inventory, metav1.ConditionTrue, templatesv1.ReconciliationSucceededReason,
 			fmt.Sprintf("%d resources created", len(inventory.Entries)))
 
@@ -197,37 +373,150 @@ func (r *GitOpsSetReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 
 			return ctrl.Result{}, fmt.Errorf("failed to update status and inventory: %w", err)
 		}
+
+		if err := r.watchInventory(ctx, &gitOpsSet, inventory); err != nil {
+			logger.Error(err, "failed to watch generated resources")
+		}
 	}
 
 	return ctrl.Result{RequeueAfter: requeue}, nil
 }
 
-func (r *GitOpsSetReconciler) reconcileResources(ctx context.Context, k8sClient client.Client, gitOpsSet *templatesv1.GitOpsSet) (*templatesv1.ResourceInventory, time.Duration, error) {
+func (r *GitOpsSetReconciler) reconcileResources(ctx context.Context, clients *clusterClients, gitOpsSet *templatesv1.GitOpsSet) (*templatesv1.ResourceInventory, time.Duration, error) {
 	logger := log.FromContext(ctx)
 	instantiatedGenerators := map[string]generators.Generator{}
 	for k, factory := range r.Generators {
 		instantiatedGenerators[k] = factory(log.FromContext(ctx), r.Client)
 	}
 
-	inventory, err := r.renderAndReconcile(ctx, logger, k8sClient, gitOpsSet, instantiatedGenerators)
-	if err != nil {
-		return inventory, generators.NoRequeueInterval, err
+	// The interval is also returned when the reconciliation fails, so that
+	// stalled GitOpsSets are still checked after the generator interval.
+	requeueAfter, intervalErr := calculateInterval(gitOpsSet, instantiatedGenerators)
+	if intervalErr != nil {
+		requeueAfter = generators.NoRequeueInterval
 	}
 
-	requeueAfter, err := calculateInterval(gitOpsSet, instantiatedGenerators)
+	// Invalid templates and disabled generators fail in the same way every
+	// time the GitOpsSet is reconciled.
+	if errs := templates.Validate(gitOpsSet, instantiatedGenerators); len(errs) > 0 {
+		return nil, requeueAfter, stalledError{reason: templatesv1.RenderFailedReason, err: errs.ToAggregate()}
+	}
+
+	if err := r.checkCrossNamespaceRefs(ctx, gitOpsSet, instantiatedGenerators); err != nil {
+		return nil, requeueAfter, err
+	}
+
+	inventory, err := r.renderAndReconcile(ctx, logger, clients, gitOpsSet, instantiatedGenerators)
 	if err != nil {
-		return inventory, generators.NoRequeueInterval, fmt.Errorf("failed to calculate requeue interval: %w", err)
+		return inventory, requeueAfter, err
+	}
+
+	if intervalErr != nil {
+		return inventory, generators.NoRequeueInterval, fmt.Errorf("failed to calculate requeue interval: %w", intervalErr)
 	}
 
 	return inventory, requeueAfter, nil
 }
 
-func (r *GitOpsSetReconciler) renderAndReconcile(ctx context.Context, logger logr.Logger, k8sClient client.Client, gitOpsSet *templatesv1.GitOpsSet, instantiatedGenerators map[string]generators.Generator) (*templatesv1.ResourceInventory, error) {
-	resources, err := templates.Render(ctx, gitOpsSet, instantiatedGenerators)
+// render renders the resources for the GitOpsSet, applying the generator error
+// policy, and returns the revisions of the sources that the generators read.
+//
+// With the KeepLast and Skip policies the elements are saved after each render,
+// so that they can be used in place of the elements from generators that fail
+// later, or to keep the ElementIndex of the other elements when they're
+// skipped.
+//
+// The revisions are read before the templates are rendered, if a source
+// changes while rendering, the older revision is recorded, and the event for
+// the newer revision reconciles the GitOpsSet again rather than being ignored.
+func (r *GitOpsSetReconciler) render(ctx context.Context, logger logr.Logger, gitOpsSet *templatesv1.GitOpsSet, instantiatedGenerators map[string]generators.Generator) ([]*unstructured.Unstructured, []templatesv1.SourceRevision, error) {
+	storeElements := gitOpsSet.Spec.GeneratorErrorPolicy == templatesv1.KeepLastGeneratorErrorPolicy ||
+		gitOpsSet.Spec.GeneratorErrorPolicy == templatesv1.SkipGeneratorErrorPolicy
+	store := elements.NewStore(r.Client)
+
+	var last templates.GeneratedElements
+	if storeElements {
+		var err error
+		last, err = store.Load(ctx, gitOpsSet)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// Missing sources are reported by the generators.
+	revisions, revisionsErr := sourceRevisions(ctx, r.Client, gitOpsSet)
+
+	result, err := templates.RenderWithElements(ctx, gitOpsSet, instantiatedGenerators, last)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+	if revisionsErr != nil {
+		return nil, nil, revisionsErr
+	}
+
+	if storeElements {
+		if err := store.Save(ctx, gitOpsSet, result.Elements); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if result.Degraded != nil {
+		logger.Error(result.Degraded, "generators failed, reconciling without their current elements", "policy", gitOpsSet.Spec.GeneratorErrorPolicy)
+		templatesv1.SetGitOpsSetDegraded(gitOpsSet, result.Degraded.Error())
+	} else {
+		templatesv1.ClearGitOpsSetDegraded(gitOpsSet)
+	}
+
+	return result.Resources, revisions, nil
+}
+
+func (r *GitOpsSetReconciler) renderAndReconcile(ctx context.Context, logger logr.Logger, clients *clusterClients, gitOpsSet *templatesv1.GitOpsSet, instantiatedGenerators map[string]generators.Generator) (*templatesv1.ResourceInventory, error) {
+	resources, revisions, err := r.render(ctx, logger, gitOpsSet, instantiatedGenerators)
+	if err != nil {
+		return nil, failedStage(templatesv1.RenderFailedReason, err)
 	}
 	logger.Info("rendered templates", "resourceCount", len(resources))
+	recordRenderedResources(gitOpsSet, len(resources))
+
+	if err := checkRenderLimit(gitOpsSet, r.MaxRenderedResources, len(resources)); err != nil {
+		return nil, err
+	}
+
+	if err := r.checkPolicies(ctx, gitOpsSet, resources); err != nil {
+		return nil, err
+	}
+
+	if gitOpsSet.PlanEnabled() {
+		plan, err := planResources(ctx, clients, gitOpsSet, resources)
+		gitOpsSet.Status.Plan = plan
+		logger.Info("planned changes", "create", len(plan.Create), "update", len(plan.Update), "delete", len(plan.Delete))
+
+		// Nothing is applied, so the inventory is unchanged.
+		return nil, err
+	}
+	gitOpsSet.Status.Plan = nil
+
+	state, err := appliedState(gitOpsSet, resources, revisions)
+	if err != nil {
+		return nil, err
+	}
+
+	if applyUnchanged(gitOpsSet, state) {
+		exists, err := inventoryExists(ctx, clients, gitOpsSet.Status.Inventory)
+		if err != nil {
+			return nil, err
+		}
+
+		if exists {
+			logger.Info("rendered resources and source revisions are unchanged, skipping apply")
+			if gitOpsSet.Status.Inventory == nil {
+				return &templatesv1.ResourceInventory{}, nil
+			}
+
+			return gitOpsSet.Status.Inventory, nil
+		}
+	}
+	gitOpsSet.Status.LastApplied = nil
 
 	var inventoryErr error
 
@@ -236,69 +525,160 @@ func (r *GitOpsSetReconciler) renderAndReconcile(ctx context.Context, logger log
 		existingEntries.Insert(gitOpsSet.Status.Inventory.Entries...)
 	}
 
-	entries := sets.New[templatesv1.ResourceRef]()
+	// Check the deletions before applying anything, so that a blocked
+	// reconciliation doesn't leave the resources partially updated.
+	renderedEntries := sets.New[templatesv1.ResourceRef]()
 	for _, newResource := range resources {
-		ref, err := templatesv1.ResourceRefFromObject(newResource)
-		if err != nil {
-			inventoryErr = errors.Join(inventoryErr, fmt.Errorf("failed to update inventory: %w", err))
-			continue
+		if ref, err := resourceRefFromObject(newResource); err == nil {
+			renderedEntries.Insert(ref)
 		}
+	}
+	deletions := existingEntries.Difference(renderedEntries).SortedList(compareResourceRefs)
+	if err := checkPruneProtection(gitOpsSet, existingEntries, deletions); err != nil {
+		return nil, err
+	}
 
-		if existingEntries.Has(ref) {
-			existing, err := unstructuredFromResourceRef(ref)
+	waves, err := sortIntoWaves(resources)
+	if err != nil {
+		return nil, err
+	}
+
+	var drifted []string
+	entries := sets.New[templatesv1.ResourceRef]()
+	for i, w := range waves {
+		for _, newResource := range w.resources {
+			ref, err := resourceRefFromObject(newResource)
 			if err != nil {
-				inventoryErr = errors.Join(inventoryErr, fmt.Errorf("failed to convert resource for update: %w", err))
+				inventoryErr = errors.Join(inventoryErr, fmt.Errorf("failed to update inventory: %w", err))
 				continue
 			}
-			// We can add the entry because we know it exists
-			entries.Insert(ref)
-			err = k8sClient.Get(ctx, client.ObjectKeyFromObject(newResource), existing)
-			if err == nil {
-				newResource = copyUnstructuredContent(existing, newResource)
-				if err := k8sClient.Patch(ctx, newResource, client.MergeFrom(existing)); err != nil {
+
+			k8sClient, err := clients.forResource(ctx, ref)
+			if err != nil {
+				inventoryErr = errors.Join(inventoryErr, err)
+				continue
+			}
+
+			if driftDetectionEnabled(gitOpsSet) {
+				if err := addRenderedDigest(newResource); err != nil {
+					inventoryErr = errors.Join(inventoryErr, err)
+					continue
+				}
+			}
+
+			if existingEntries.Has(ref) {
+				// We can add the entry because we know it exists
+				entries.Insert(ref)
+
+				force := gitOpsSet.Spec.ForceConflicts
+				if driftDetectionEnabled(gitOpsSet) {
+					isDrifted, err := resourceDrifted(ctx, k8sClient, newResource)
+					if err != nil {
+						inventoryErr = errors.Join(inventoryErr, fmt.Errorf("failed to check Resource for drift: %w", err))
+						continue
+					}
+
+					if isDrifted {
+						if err := logResourceMessage(logger, "resource has drifted", newResource); err != nil {
+							inventoryErr = errors.Join(inventoryErr, err)
+						}
+						drifted = append(drifted, ref.ID)
+
+						// Drift is only reported in warn mode.
+						if gitOpsSet.Spec.DriftDetection == templatesv1.DriftDetectionWarn {
+							continue
+						}
+
+						// Fields that were changed in the cluster are now owned by
+						// other field managers and need to be taken back.
+						force = true
+					}
+				}
+
+				desired := newResource.DeepCopy()
+				if err := applyResource(ctx, k8sClient, newResource, force); err != nil {
 					inventoryErr = errors.Join(inventoryErr, fmt.Errorf("failed to update Resource: %w", err))
+					continue
+				}
+
+				if err := upgradeManagedFields(ctx, k8sClient, desired, newResource, force); err != nil {
+					inventoryErr = errors.Join(inventoryErr, err)
 				}
 				continue
 			}
 
-			if !apierrors.IsNotFound(err) {
-				inventoryErr = errors.Join(inventoryErr, fmt.Errorf("failed to load existing Resource: %w", err))
+			if err := logResourceMessage(logger, "creating new resource", newResource); err != nil {
+				inventoryErr = errors.Join(inventoryErr, err)
 				continue
 			}
-		}
 
-		if err := logResourceMessage(logger, "creating new resource", newResource); err != nil {
-			inventoryErr = errors.Join(inventoryErr, err)
-			continue
-		}
+			// Resources that are not in the inventory must not already exist in the
+			// cluster, the dry-run create fails if they do.
+			if err := k8sClient.Create(ctx, newResource.DeepCopy(), client.DryRunAll, client.FieldOwner(fieldManager)); err != nil {
+				if apierrors.IsAlreadyExists(err) && adoptionEnabled(gitOpsSet) {
+					if err := adoptResource(ctx, k8sClient, gitOpsSet, newResource, ref); err != nil {
+						inventoryErr = errors.Join(inventoryErr, fmt.Errorf("failed to adopt Resource: %w", err))
+						continue
+					}
+					if err := logResourceMessage(logger, "adopted resource", newResource); err != nil {
+						inventoryErr = errors.Join(inventoryErr, err)
+					}
+					entries.Insert(ref)
+					continue
+				}
 
-		if err := k8sClient.Create(ctx, newResource); err != nil {
-			inventoryErr = errors.Join(inventoryErr, fmt.Errorf("failed to create Resource: %w", err))
-			if apierrors.IsAlreadyExists(err) {
-				if err := logResourceMessage(logger, "resource already exists", newResource); err != nil {
-					inventoryErr = errors.Join(inventoryErr, err)
+				inventoryErr = errors.Join(inventoryErr, fmt.Errorf("failed to create Resource: %w", err))
+				if apierrors.IsAlreadyExists(err) {
+					if err := logResourceMessage(logger, "resource already exists", newResource); err != nil {
+						inventoryErr = errors.Join(inventoryErr, err)
+					}
 				}
+				continue
 			}
-			continue
+
+			if err := applyResource(ctx, k8sClient, newResource, gitOpsSet.Spec.ForceConflicts); err != nil {
+				inventoryErr = errors.Join(inventoryErr, fmt.Errorf("failed to create Resource: %w", err))
+				continue
+			}
+
+			entries.Insert(ref)
 		}
 
-		entries.Insert(ref)
+		if i == len(waves)-1 {
+			break
+		}
+
+		// The next wave is only applied once this wave is applied and healthy.
+		// Resources in later waves are kept in the inventory so that they are
+		// not removed.
+		if inventoryErr == nil {
+			inventoryErr = checkWave(ctx, clients, gitOpsSet, w)
+		}
+		if inventoryErr != nil {
+			r.recordDrift(gitOpsSet, drifted)
+			return &templatesv1.ResourceInventory{Entries: entries.Union(existingEntries).SortedList(compareResourceRefs)}, failedStage(templatesv1.ApplyFailedReason, inventoryErr)
+		}
+		logger.Info("wave is healthy", "wave", w.number)
+	}
+
+	r.recordDrift(gitOpsSet, drifted)
+	gitOpsSet.Status.Wave = nil
+
+	if inventoryErr == nil {
+		gitOpsSet.Status.LastApplied = state
 	}
 
+	inventoryErr = failedStage(templatesv1.ApplyFailedReason, inventoryErr)
 	if gitOpsSet.Status.Inventory == nil {
-		return &templatesv1.ResourceInventory{Entries: entries.SortedList(func(x, y templatesv1.ResourceRef) bool {
-			return x.ID < y.ID
-		})}, inventoryErr
+		return &templatesv1.ResourceInventory{Entries: entries.SortedList(compareResourceRefs)}, inventoryErr
 
 	}
 	objectsToRemove := existingEntries.Difference(entries)
-	if err := r.removeResourceRefs(ctx, k8sClient, objectsToRemove.List()); err != nil {
-		inventoryErr = errors.Join(inventoryErr, err)
+	if err := r.removeResourceRefs(ctx, clients, objectsToRemove.List(), false); err != nil {
+		inventoryErr = errors.Join(inventoryErr, failedStage(templatesv1.PruneFailedReason, err))
 	}
 
-	return &templatesv1.ResourceInventory{Entries: entries.SortedList(func(x, y templatesv1.ResourceRef) bool {
-		return x.ID < y.ID
-	})}, inventoryErr
+	return &templatesv1.ResourceInventory{Entries: entries.SortedList(compareResourceRefs)}, inventoryErr
 }
 
 func (r *GitOpsSetReconciler) patchStatus(ctx context.Context, req ctrl.Request, newStatus templatesv1.GitOpsSetStatus) error {
@@ -307,19 +687,66 @@ func (r *GitOpsSetReconciler) patchStatus(ctx context.Context, req ctrl.Request,
 		return err
 	}
 
+	// The inventory is stored outside of the status when it's too large.
+	store := inventory.NewStore(r.Client, r.InventoryThreshold)
+	storedInventory, err := store.Save(ctx, &set, newStatus.Inventory)
+	if err != nil {
+		return fmt.Errorf("failed to store inventory: %w", err)
+	}
+	newStatus.Inventory = storedInventory
+
 	patch := client.MergeFrom(set.DeepCopy())
 	set.Status = newStatus
 
-	return r.Status().Patch(ctx, &set, patch)
+	if err := r.Status().Patch(ctx, &set, patch); err != nil {
+		return err
+	}
+
+	// The previous inventory ConfigMaps are only removed once the status
+	// refers to the new ones, if this fails they are removed when the status
+	// is next patched.
+	if err := store.Prune(ctx, &set, storedInventory); err != nil {
+		log.FromContext(ctx).Error(err, "failed to remove unused inventory ConfigMaps")
+	}
+
+	return nil
 }
 
-func (r *GitOpsSetReconciler) removeResourceRefs(ctx context.Context, k8sClient client.Client, deletions []templatesv1.ResourceRef) error {
+// removeResourceRefs deletes the referenced resources.
+//
+// If orphan is true, or the resource has pruning disabled, the resource is
+// orphaned instead of being deleted.
+func (r *GitOpsSetReconciler) removeResourceRefs(ctx context.Context, clients *clusterClients, deletions []templatesv1.ResourceRef, orphan bool) error {
 	logger := log.FromContext(ctx)
 	for _, v := range deletions {
 		u, err := unstructuredFromResourceRef(v)
 		if err != nil {
 			return err
 		}
+
+		k8sClient, err := clients.forResource(ctx, v)
+		if err != nil {
+			return err
+		}
+
+		if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(u), u); err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			return fmt.Errorf("failed to get %v: %w", v.ID, err)
+		}
+
+		if orphan || u.GetAnnotations()[templates.PruneAnnotation] == "disabled" {
+			if err := logResourceMessage(logger, "orphaning resource", u); err != nil {
+				return err
+			}
+
+			if err := orphanResource(ctx, k8sClient, u); err != nil {
+				return fmt.Errorf("failed to orphan %v: %w", v.ID, err)
+			}
+			continue
+		}
+
 		if err := logResourceMessage(logger, "deleting resource", u); err != nil {
 			return err
 		}
@@ -332,106 +759,110 @@ func (r *GitOpsSetReconciler) removeResourceRefs(ctx context.Context, k8sClient
 	return nil
 }
 
-// SetupWithManager sets up the controller with the Manager.
-func (r *GitOpsSetReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	// Index the GitOpsSets by the GitRepository references they (may) point at.
-	if err := mgr.GetCache().IndexField(
-		context.TODO(), &templatesv1.GitOpsSet{}, gitRepositoryIndexKey, indexGitRepositories); err != nil {
-		return fmt.Errorf("failed setting index field for GitRepository: %w", err)
-	}
-
-	if err := mgr.GetCache().IndexField(
-		context.TODO(), &templatesv1.GitOpsSet{}, configMapIndexKey, indexConfig("ConfigMap")); err != nil {
-		return fmt.Errorf("failed setting index field for ConfigMap: %w", err)
-	}
+// orphanResource removes the labels that associate the resource with the
+// GitOpsSet that generated it.
+func orphanResource(ctx context.Context, k8sClient client.Client, obj *unstructured.Unstructured) error {
+	patch := client.MergeFrom(obj.DeepCopy())
+	labels := obj.GetLabels()
+	delete(labels, templates.NameLabel)
+	delete(labels, templates.NamespaceLabel)
+	obj.SetLabels(labels)
 
-	if err := mgr.GetCache().IndexField(
-		context.TODO(), &templatesv1.GitOpsSet{}, secretIndexKey, indexConfig("Secret")); err != nil {
-		return fmt.Errorf("failed setting index field for Secret: %w", err)
-	}
+	return k8sClient.Patch(ctx, obj, patch)
+}
 
-	if err := mgr.GetCache().IndexField(
-		context.TODO(), &templatesv1.GitOpsSet{}, ociRepositoryIndexKey, indexOCIRepositories); err != nil {
-		return fmt.Errorf("failed setting index field for OCIRepository: %w", err)
+// SetupWithManager sets up the controller with the Manager.
+func (r *GitOpsSetReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	enabledGenerators := map[string]generators.Generator{}
+	for name, factory := range r.Generators {
+		enabledGenerators[name] = factory(mgr.GetLogger(), mgr.GetClient())
 	}
 
 	builder := ctrl.NewControllerManagedBy(mgr).
 		For(&templatesv1.GitOpsSet{}, builder.WithPredicates(
-			predicate.Or(predicate.GenerationChangedPredicate{}, predicates.ReconcileRequestedPredicate{}))).
-		Watches(
-			&sourcev1.GitRepository{},
-			handler.EnqueueRequestsFromMapFunc(r.gitRepositoryToGitOpsSet),
-		).
-		Watches(
-			&corev1.Secret{},
-			handler.EnqueueRequestsFromMapFunc(r.secretToGitOpsSet),
-		)
-
-	if r.Generators["Config"] != nil {
-		builder.Watches(
-			&corev1.ConfigMap{},
-			handler.EnqueueRequestsFromMapFunc(r.configMapToGitOpsSet),
-		)
-	}
+			r.shardPredicate(),
+			predicate.Or(predicate.GenerationChangedPredicate{}, predicate.AnnotationChangedPredicate{}, predicates.ReconcileRequestedPredicate{}))).
+		WithOptions(controller.Options{
+			MaxConcurrentReconciles: r.MaxConcurrentReconciles,
+			RateLimiter:             r.RateLimiter,
+		})
+
+	// Index the GitOpsSets by the objects that their generators depend on, and
+	// watch each kind of object that the enabled generators can depend on.
+	watchedKinds := sets.New[string]()
+	for _, obj := range generators.FindDependencyKinds(enabledGenerators) {
+		gvk, err := apiutil.GVKForObject(obj, mgr.GetScheme())
+		if err != nil {
+			return fmt.Errorf("failed to get kind of generator dependency: %w", err)
+		}
+		if watchedKinds.Has(gvk.Kind) {
+			continue
+		}
+		watchedKinds.Insert(gvk.Kind)
 
-	if r.Generators["OCIRepository"] != nil {
-		builder.Watches(
-			&sourcev1.OCIRepository{},
-			handler.EnqueueRequestsFromMapFunc(r.ociRepositoryToGitOpsSet),
-		)
-	}
+		if err := mgr.GetCache().IndexField(
+			context.TODO(), &templatesv1.GitOpsSet{}, dependencyIndexKey(gvk.Kind), indexDependencies(gvk.Kind, enabledGenerators)); err != nil {
+			return fmt.Errorf("failed setting index field for %s: %w", gvk.Kind, err)
+		}
 
-	// Only watch for GitopsCluster objects if the Cluster generator is enabled.
-	if r.Generators["Cluster"] != nil {
 		builder.Watches(
-			&clustersv1.GitopsCluster{},
-			handler.EnqueueRequestsFromMapFunc(r.gitOpsClusterToGitOpsSet),
+			obj,
+			handler.EnqueueRequestsFromMapFunc(r.dependencyToGitOpsSet(gvk.Kind)),
 		)
 	}
 
-	// Only watch for ImagePolicy objects if the ImagePolicy generator is enabled.
-	if r.Generators["ImagePolicy"] != nil {
-		// Index the GitOpsSets by the ImageRepository references they (may) point at.
-		if err := mgr.GetCache().IndexField(
-			context.TODO(), &templatesv1.GitOpsSet{}, imagePolicyIndexKey, indexImagePolicies); err != nil {
-			return fmt.Errorf("failed setting index fields: %w", err)
-		}
+	builder.Watches(
+		&templatesv1.GitOpsSetPolicy{},
+		handler.EnqueueRequestsFromMapFunc(r.policyToGitOpsSets),
+	)
 
+	for _, watch := range r.Watches {
 		builder.Watches(
-			&imagev1.ImagePolicy{},
-			handler.EnqueueRequestsFromMapFunc(r.imagePolicyToGitOpsSet),
+			watch.Object,
+			handler.EnqueueRequestsFromMapFunc(watch.MapFunc(r.inShard(mgr.GetClient()))),
 		)
 	}
 
-	return builder.Complete(r)
-}
-
-// gitOpsClusterToGitOpsSet maps a GitopsCluster object to its related GitOpsSet objects
-// and returns a list of reconcile requests for the GitOpsSets.
-func (r *GitOpsSetReconciler) gitOpsClusterToGitOpsSet(ctx context.Context, o client.Object) []reconcile.Request {
-	gitOpsCluster, ok := o.(*clustersv1.GitopsCluster)
-	if !ok {
-		return nil
+	// The impersonation clients share the manager's connections and
+	// discovery, and the client for a ServiceAccount is removed when it's
+	// deleted.
+	mapper := r.Mapper
+	if mapper == nil {
+		mapper = mgr.GetRESTMapper()
 	}
-
-	list := &templatesv1.GitOpsSetList{}
-
-	err := r.List(ctx, list, &client.ListOptions{})
+	r.impersonationClients = impersonation.NewCache(r.Config, mgr.GetHTTPClient(), r.Scheme, mapper, r.ImpersonationCacheSize)
+	r.remoteClients = lru.New(remoteClientCacheSize)
+	builder.WatchesMetadata(
+		&corev1.ServiceAccount{},
+		handler.Funcs{DeleteFunc: r.serviceAccountDeleted},
+	)
+
+	c, err := builder.Build(r)
 	if err != nil {
-		return nil
+		return err
 	}
 
-	var result []reconcile.Request
-	for _, v := range list.Items {
-		if matchCluster(gitOpsCluster, &v) {
-			result = append(result, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&v)})
-		}
+	// The controller and a separate cache are kept to watch the kinds of the
+	// generated resources when drift detection is enabled, the informers in
+	// this cache are removed when the kinds are no longer generated.
+	driftCache, err := cache.New(mgr.GetConfig(), cache.Options{
+		HTTPClient: mgr.GetHTTPClient(),
+		Scheme:     mgr.GetScheme(),
+		Mapper:     mgr.GetRESTMapper(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create cache for generated resources: %w", err)
 	}
+	if err := mgr.Add(driftCache); err != nil {
+		return fmt.Errorf("failed to add cache for generated resources: %w", err)
+	}
+	r.controller = c
+	r.cache = driftCache
 
-	return result
+	return nil
 }
 
-func (r *GitOpsSetReconciler) finalize(ctx context.Context, gs *templatesv1.GitOpsSet, k8sClient client.Client) (ctrl.Result, error) {
+func (r *GitOpsSetReconciler) finalize(ctx context.Context, gs *templatesv1.GitOpsSet, clients *clusterClients) (ctrl.Result, error) {
 	logger := ctrl.LoggerFrom(ctx)
 	logger.Info("finalizing resources")
 
@@ -439,245 +870,57 @@ func (r *GitOpsSetReconciler) finalize(ctx context.Context, gs *templatesv1.GitO
 		gs.Status.Inventory != nil &&
 		gs.Status.Inventory.Entries != nil {
 
-		if err := r.removeResourceRefs(ctx, k8sClient, gs.Status.Inventory.Entries); err != nil {
+		if err := r.removeResourceRefs(ctx, clients, gs.Status.Inventory.Entries, gs.Spec.DeletionPolicy == templatesv1.OrphanDeletionPolicy); err != nil {
 			return ctrl.Result{}, err
 		}
 
 		logger.Info("cleaned resources")
 	}
 
+	deleteRenderedResources(gs)
+
+	if err := r.watchInventory(ctx, gs, nil); err != nil {
+		logger.Error(err, "failed to stop watching generated resources")
+	}
+
 	logger.Info("removing the finalizer")
 	// Remove our finalizer from the list and update it
 	controllerutil.RemoveFinalizer(gs, templatesv1.GitOpsSetFinalizer)
 	return ctrl.Result{}, r.Update(ctx, gs)
 }
 
-func matchCluster(gitOpsCluster *clustersv1.GitopsCluster, gitOpsSet *templatesv1.GitOpsSet) bool {
-	for _, generator := range gitOpsSet.Spec.Generators {
-		for _, selector := range getClusterSelectors(generator) {
-			if selectorMatchesCluster(selector, gitOpsCluster) {
-				return true
-			}
-		}
-	}
-
-	return false
-}
-
-func getClusterSelectors(generator templatesv1.GitOpsSetGenerator) []metav1.LabelSelector {
-	selectors := []metav1.LabelSelector{}
-
-	if generator.Cluster != nil {
-		selectors = append(selectors, generator.Cluster.Selector)
-	}
-
-	if generator.Matrix != nil && generator.Matrix.Generators != nil {
-		for _, matrixGenerator := range generator.Matrix.Generators {
-			if matrixGenerator.Cluster != nil {
-				selectors = append(selectors, matrixGenerator.Cluster.Selector)
-			}
-		}
-	}
-
-	return selectors
-}
-
-func selectorMatchesCluster(labelSelector metav1.LabelSelector, cluster *clustersv1.GitopsCluster) bool {
-	selector, err := metav1.LabelSelectorAsSelector(&labelSelector)
-	if err != nil {
-		return false
-	}
-
-	// If the selector is empty, then we don't match anything.
-	// We want to be cautious here, so we don't accidentally match
-	// all clusters.
-	if selector.Empty() {
-		return false
-	}
-
-	labelSet := labels.Set(cluster.GetLabels())
-
-	return selector.Matches(labelSet)
-}
-
-func (r *GitOpsSetReconciler) gitRepositoryToGitOpsSet(ctx context.Context, obj client.Object) []reconcile.Request {
-	// TODO: Store the applied version of GitRepositories in the Status, and don't
-	// retrigger if the commit-id isn't different.
-	return r.queryIndexedGitOpsSets(ctx, gitRepositoryIndexKey, obj)
-}
-
-func (r *GitOpsSetReconciler) ociRepositoryToGitOpsSet(ctx context.Context, obj client.Object) []reconcile.Request {
-	return r.queryIndexedGitOpsSets(ctx, ociRepositoryIndexKey, obj)
-}
-
-func (r *GitOpsSetReconciler) imagePolicyToGitOpsSet(ctx context.Context, obj client.Object) []reconcile.Request {
-	return r.queryIndexedGitOpsSets(ctx, imagePolicyIndexKey, obj)
-}
-
-func (r *GitOpsSetReconciler) queryIndexedGitOpsSets(ctx context.Context, key string, obj client.Object) []reconcile.Request {
+func (r *GitOpsSetReconciler) queryIndexedGitOpsSets(ctx context.Context, key string, obj client.Object, filters ...func(*templatesv1.GitOpsSet) bool) []reconcile.Request {
 	var list templatesv1.GitOpsSetList
 
-	if err := r.List(ctx, &list,
-		client.MatchingFields{key: client.ObjectKeyFromObject(obj).String()},
-		client.InNamespace(obj.GetNamespace())); err != nil {
+	// GitOpsSets in any namespace can reference the object, the indexed keys
+	// include the namespace.
+	if err := r.inShard(r.Client).List(ctx, &list,
+		client.MatchingFields{key: client.ObjectKeyFromObject(obj).String()}); err != nil {
 		return nil
 	}
 
 	result := []reconcile.Request{}
+items:
 	for i := range list.Items {
+		for _, filter := range filters {
+			if !filter(&list.Items[i]) {
+				continue items
+			}
+		}
 		result = append(result, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&list.Items[i])})
 	}
 
 	return result
 }
 
-func (r *GitOpsSetReconciler) configMapToGitOpsSet(ctx context.Context, obj client.Object) []reconcile.Request {
-	return r.queryIndexedGitOpsSets(ctx, configMapIndexKey, obj)
-}
-
-func (r *GitOpsSetReconciler) secretToGitOpsSet(ctx context.Context, obj client.Object) []reconcile.Request {
-	return r.queryIndexedGitOpsSets(ctx, secretIndexKey, obj)
-}
-
 func (r *GitOpsSetReconciler) makeImpersonationClient(namespace, serviceAccountName string) (client.Client, error) {
-	copyCfg := rest.CopyConfig(r.Config)
-
-	copyCfg.Impersonate = rest.ImpersonationConfig{
-		UserName: fmt.Sprintf("system:serviceaccount:%s:%s", namespace, serviceAccountName),
-	}
-
-	return client.New(copyCfg, client.Options{Scheme: r.Scheme, Mapper: r.Mapper})
+	return r.impersonationClients.Client(namespace, serviceAccountName)
 }
 
-func indexGitRepositories(o client.Object) []string {
-	ks, ok := o.(*templatesv1.GitOpsSet)
-	if !ok {
-		panic(fmt.Sprintf("Expected a GitOpsSet, got %T", o))
-	}
-
-	referencedRepositories := []*templatesv1.GitRepositoryGenerator{}
-	for _, gen := range ks.Spec.Generators {
-		if gen.GitRepository != nil {
-			referencedRepositories = append(referencedRepositories, gen.GitRepository)
-		}
-		if gen.Matrix != nil && gen.Matrix.Generators != nil {
-			for _, matrixGen := range gen.Matrix.Generators {
-				if matrixGen.GitRepository != nil {
-					referencedRepositories = append(referencedRepositories, matrixGen.GitRepository)
-				}
-			}
-		}
-	}
-
-	if len(referencedRepositories) == 0 {
-		return nil
-	}
-
-	referencedNames := []string{}
-	for _, grg := range referencedRepositories {
-		referencedNames = append(referencedNames, fmt.Sprintf("%s/%s", ks.GetNamespace(), grg.RepositoryRef))
-	}
-
-	return referencedNames
-}
-
-func indexOCIRepositories(o client.Object) []string {
-	ks, ok := o.(*templatesv1.GitOpsSet)
-	if !ok {
-		panic(fmt.Sprintf("Expected a GitOpsSet, got %T", o))
-	}
-
-	referencedRepositories := []*templatesv1.OCIRepositoryGenerator{}
-	for _, gen := range ks.Spec.Generators {
-		if gen.OCIRepository != nil {
-			referencedRepositories = append(referencedRepositories, gen.OCIRepository)
-		}
-		if gen.Matrix != nil && gen.Matrix.Generators != nil {
-			for _, matrixGen := range gen.Matrix.Generators {
-				if matrixGen.OCIRepository != nil {
-					referencedRepositories = append(referencedRepositories, matrixGen.OCIRepository)
-				}
-			}
-		}
-	}
-
-	if len(referencedRepositories) == 0 {
-		return nil
-	}
-
-	referencedNames := []string{}
-	for _, org := range referencedRepositories {
-		referencedNames = append(referencedNames, fmt.Sprintf("%s/%s", ks.GetNamespace(), org.RepositoryRef))
-	}
-
-	return referencedNames
-}
-
-func indexConfig(kind string) func(o client.Object) []string {
-	return func(o client.Object) []string {
-		ks, ok := o.(*templatesv1.GitOpsSet)
-		if !ok {
-			panic(fmt.Sprintf("Expected a GitOpsSet, got %T", o))
-		}
-
-		referencedResources := []*templatesv1.ConfigGenerator{}
-		for _, gen := range ks.Spec.Generators {
-			if gen.Config != nil && gen.Config.Kind == kind {
-				referencedResources = append(referencedResources, gen.Config)
-			}
-			if gen.Matrix != nil && gen.Matrix.Generators != nil {
-				for _, matrixGen := range gen.Matrix.Generators {
-					if matrixGen.Config != nil && matrixGen.Config.Kind == kind {
-						referencedResources = append(referencedResources, matrixGen.Config)
-					}
-				}
-			}
-		}
-
-		if len(referencedResources) == 0 {
-			return nil
-		}
-
-		referencedNames := []string{}
-		for _, grg := range referencedResources {
-			referencedNames = append(referencedNames, fmt.Sprintf("%s/%s", ks.GetNamespace(), grg.Name))
-		}
-
-		return referencedNames
-	}
-}
-
-func indexImagePolicies(o client.Object) []string {
-	ks, ok := o.(*templatesv1.GitOpsSet)
-	if !ok {
-		panic(fmt.Sprintf("Expected a GitOpsSet, got %T", o))
-	}
-
-	referencedPolicies := []*templatesv1.ImagePolicyGenerator{}
-	for _, gen := range ks.Spec.Generators {
-		if gen.ImagePolicy != nil {
-			referencedPolicies = append(referencedPolicies, gen.ImagePolicy)
-			continue
-		}
-		if gen.Matrix != nil && gen.Matrix.Generators != nil {
-			for _, matrixGen := range gen.Matrix.Generators {
-				if matrixGen.ImagePolicy != nil {
-					referencedPolicies = append(referencedPolicies, matrixGen.ImagePolicy)
-				}
-			}
-		}
-	}
-
-	if len(referencedPolicies) == 0 {
-		return nil
-	}
-
-	referencedNames := []string{}
-	for _, ip := range referencedPolicies {
-		referencedNames = append(referencedNames, fmt.Sprintf("%s/%s", ks.GetNamespace(), ip.PolicyRef))
-	}
-
-	return referencedNames
+// serviceAccountDeleted removes the cached client for a ServiceAccount when
+// it's deleted, nothing is queued for reconciliation.
+func (r *GitOpsSetReconciler) serviceAccountDeleted(ctx context.Context, e event.DeleteEvent, q workqueue.RateLimitingInterface) {
+	r.impersonationClients.Remove(e.Object.GetNamespace(), e.Object.GetName())
 }
 
 func unstructuredFromResourceRef(ref templatesv1.ResourceRef) (*unstructured.Unstructured, error) {
@@ -693,22 +936,45 @@ func unstructuredFromResourceRef(ref templatesv1.ResourceRef) (*unstructured.Uns
 	return &u, nil
 }
 
-func copyUnstructuredContent(existing, newValue *unstructured.Unstructured) *unstructured.Unstructured {
-	result := unstructured.Unstructured{}
-	existing.DeepCopyInto(&result)
+// applyResource uses server-side apply to create or update the resource in the
+// cluster.
+//
+// The fields in the resource are owned by the gitopssets-controller field
+// manager, fields that are no longer rendered are removed from the resource.
+func applyResource(ctx context.Context, k8sClient client.Client, obj *unstructured.Unstructured, force bool) error {
+	opts := []client.PatchOption{client.FieldOwner(fieldManager)}
+	if force {
+		opts = append(opts, client.ForceOwnership)
+	}
 
-	disallowedKeys := sets.New("status", "metadata", "kind", "apiVersion")
+	return k8sClient.Patch(ctx, obj, client.Apply, opts...)
+}
 
-	for k, v := range newValue.Object {
-		if !disallowedKeys.Has(k) {
-			result.Object[k] = v
-		}
+// resourceChanged uses a server-side dry-run to check whether applying the
+// desired resource would change the existing resource.
+func resourceChanged(ctx context.Context, k8sClient client.Client, existing, desired *unstructured.Unstructured, force bool) (bool, error) {
+	applied := desired.DeepCopy()
+	opts := []client.PatchOption{client.FieldOwner(fieldManager), client.DryRunAll}
+	if force {
+		opts = append(opts, client.ForceOwnership)
+	}
+	if err := k8sClient.Patch(ctx, applied, client.Apply, opts...); err != nil {
+		return false, err
 	}
 
-	result.SetAnnotations(newValue.GetAnnotations())
-	result.SetLabels(newValue.GetLabels())
+	return !equality.Semantic.DeepEqual(comparableContent(existing), comparableContent(applied)), nil
+}
+
+// comparableContent removes the fields that are updated by the API server from
+// the resource.
+func comparableContent(obj *unstructured.Unstructured) map[string]any {
+	c := obj.DeepCopy()
+	unstructured.RemoveNestedField(c.Object, "metadata", "managedFields")
+	unstructured.RemoveNestedField(c.Object, "metadata", "resourceVersion")
+	unstructured.RemoveNestedField(c.Object, "metadata", "generation")
+	unstructured.RemoveNestedField(c.Object, "status")
 
-	return &result
+	return c.Object
 }
 
 func logResourceMessage(logger logr.Logger, msg string, obj runtime.Object) error {
@@ -733,7 +999,7 @@ func logResourceMessage(logger logr.Logger, msg string, obj runtime.Object) erro
 func calculateInterval(gs *templatesv1.GitOpsSet, configuredGenerators map[string]generators.Generator) (time.Duration, error) {
 	res := []time.Duration{}
 	for _, mg := range gs.Spec.Generators {
-		relevantGenerators, err := generators.FindRelevantGenerators(mg, configuredGenerators)
+		relevantGenerators, err := registry.Default.FindRelevantGenerators(&mg, configuredGenerators)
 		if err != nil {
 			return generators.NoRequeueInterval, err
 		}
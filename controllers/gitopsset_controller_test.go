@@ -3,8 +3,10 @@ package controllers
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"path/filepath"
 	"sort"
+	"strings"
 	"testing"
 	"time"
 
@@ -27,14 +29,16 @@ import (
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/envtest"
 
-	clustersv1 "github.com/weaveworks/cluster-controller/api/v1alpha1"
-	templatesv1 "github.com/weaveworks/gitopssets-controller/api/v1alpha1"
+	templatesv1 "github.com/weaveworks/gitopssets-controller/api/v1beta1"
 	"github.com/weaveworks/gitopssets-controller/controllers/templates/generators"
 	"github.com/weaveworks/gitopssets-controller/controllers/templates/generators/gitrepository"
 	"github.com/weaveworks/gitopssets-controller/controllers/templates/generators/list"
+	"github.com/weaveworks/gitopssets-controller/pkg/inventory"
 	"github.com/weaveworks/gitopssets-controller/test"
 )
 
@@ -119,6 +123,12 @@ func TestReconciliation(t *testing.T) {
 		test.AssertInventoryHasItems(t, updated, want...)
 		assertGitOpsSetCondition(t, updated, meta.ReadyCondition, "3 resources created")
 		assertKustomizationsExist(t, k8sClient, "default", "engineering-dev-demo", "engineering-prod-demo", "engineering-preprod-demo")
+		if apimeta.FindStatusCondition(updated.Status.Conditions, meta.ReconcilingCondition) != nil {
+			t.Fatal("Reconciling condition not removed after a successful reconciliation")
+		}
+		if updated.Status.ObservedGeneration != updated.Generation {
+			t.Fatalf("got observed generation %d, want %d", updated.Status.ObservedGeneration, updated.Generation)
+		}
 	})
 
 	t.Run("reconciling creation of resources in different namespaces", func(t *testing.T) {
@@ -176,6 +186,71 @@ func TestReconciliation(t *testing.T) {
 		assertNoKustomizationsExistInNamespace(t, k8sClient, "default")
 	})
 
+	t.Run("reconciling cleanup when deleted with orphan deletion policy", func(t *testing.T) {
+		ctx := context.TODO()
+		gs := createAndReconcileToFinalizedState(t, k8sClient, reconciler, makeTestGitOpsSet(t, func(gs *templatesv1.GitOpsSet) {
+			gs.Spec.DeletionPolicy = templatesv1.OrphanDeletionPolicy
+		}))
+		defer deleteAllKustomizations(t, k8sClient)
+
+		_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(gs)})
+		test.AssertNoError(t, err)
+
+		deleteGitOpsSetAndFinalize(t, k8sClient, reconciler, gs)
+		assertKustomizationsExist(t, k8sClient, "default", "engineering-dev-demo", "engineering-prod-demo", "engineering-preprod-demo")
+		assertKustomizationIsOrphaned(t, k8sClient, nsn("default", "engineering-dev-demo"))
+	})
+
+	t.Run("reconciling removal of resources with pruning disabled", func(t *testing.T) {
+		ctx := context.TODO()
+		gs := makeTestGitOpsSet(t, func(gs *templatesv1.GitOpsSet) {
+			gs.Spec.Templates = []templatesv1.GitOpsSetTemplate{
+				{
+					Content: runtime.RawExtension{
+						Raw: mustMarshalJSON(t, test.MakeTestKustomization(nsn("default", "{{ .Element.cluster }}-demo"), func(ks *kustomizev1.Kustomization) {
+							ks.Annotations = map[string]string{
+								"templates.weave.works/prune": "{{ .Element.prune }}",
+							}
+						})),
+					},
+				},
+			}
+			gs.Spec.Generators = []templatesv1.GitOpsSetGenerator{
+				{
+					List: &templatesv1.ListGenerator{
+						Elements: []apiextensionsv1.JSON{
+							{Raw: []byte(`{"cluster": "engineering-dev", "prune": "enabled"}`)},
+							{Raw: []byte(`{"cluster": "engineering-prod", "prune": "disabled"}`)},
+							{Raw: []byte(`{"cluster": "engineering-preprod", "prune": "enabled"}`)},
+						},
+					},
+				},
+			}
+		})
+		gs = createAndReconcileToFinalizedState(t, k8sClient, reconciler, gs)
+		defer deleteAllKustomizations(t, k8sClient)
+
+		_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(gs)})
+		test.AssertNoError(t, err)
+
+		test.AssertNoError(t, k8sClient.Get(ctx, client.ObjectKeyFromObject(gs), gs))
+		gs.Spec.Generators[0].List.Elements = []apiextensionsv1.JSON{
+			{Raw: []byte(`{"cluster": "engineering-dev", "prune": "enabled"}`)},
+		}
+		test.AssertNoError(t, k8sClient.Update(ctx, gs))
+
+		_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(gs)})
+		test.AssertNoError(t, err)
+
+		test.AssertNoError(t, k8sClient.Get(ctx, client.ObjectKeyFromObject(gs), gs))
+		test.AssertInventoryHasItems(t, gs, test.MakeTestKustomization(nsn("default", "engineering-dev-demo")))
+		assertKustomizationsExist(t, k8sClient, "default", "engineering-dev-demo", "engineering-prod-demo")
+		assertKustomizationIsOrphaned(t, k8sClient, nsn("default", "engineering-prod-demo"))
+
+		deleteGitOpsSetAndFinalize(t, k8sClient, reconciler, gs)
+		assertKustomizationsExist(t, k8sClient, "default", "engineering-prod-demo")
+	})
+
 	t.Run("error conditions", func(t *testing.T) {
 		ctx := context.TODO()
 		gs := makeTestGitOpsSet(t, func(gs *templatesv1.GitOpsSet) {
@@ -355,6 +430,563 @@ func TestReconciliation(t *testing.T) {
 		}
 	})
 
+	t.Run("reconciling update of resources with fields managed by other field managers", func(t *testing.T) {
+		ctx := context.TODO()
+		gs := makeTestGitOpsSet(t, func(gs *templatesv1.GitOpsSet) {
+			gs.Spec.Generators = []templatesv1.GitOpsSetGenerator{
+				{
+					List: &templatesv1.ListGenerator{
+						Elements: []apiextensionsv1.JSON{
+							{Raw: []byte(`{"cluster": "engineering-dev"}`)},
+						},
+					},
+				},
+			}
+		})
+		gs = createAndReconcileToFinalizedState(t, k8sClient, reconciler, gs)
+		defer deleteGitOpsSetAndFinalize(t, k8sClient, reconciler, gs)
+
+		_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(gs)})
+		test.AssertNoError(t, err)
+
+		// Another field manager takes ownership of a field that is not rendered
+		// and a field that is rendered from the template.
+		kustomization := &unstructured.Unstructured{}
+		kustomization.SetGroupVersionKind(kustomizationGVK)
+		kustomization.SetName("engineering-dev-demo")
+		kustomization.SetNamespace("default")
+		test.AssertNoError(t, unstructured.SetNestedField(kustomization.Object, true, "spec", "suspend"))
+		test.AssertNoError(t, unstructured.SetNestedField(kustomization.Object, "./other/path", "spec", "path"))
+		test.AssertNoError(t, k8sClient.Patch(ctx, kustomization, client.Apply, client.FieldOwner("other-manager"), client.ForceOwnership))
+		requestReconciliation(t, k8sClient, gs)
+
+		_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(gs)})
+		test.AssertErrorMatch(t, `failed to update Resource:.*conflict`, err)
+
+		test.AssertNoError(t, k8sClient.Get(ctx, client.ObjectKeyFromObject(gs), gs))
+		gs.Spec.ForceConflicts = true
+		test.AssertNoError(t, k8sClient.Update(ctx, gs))
+
+		_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(gs)})
+		test.AssertNoError(t, err)
+
+		updated := &unstructured.Unstructured{}
+		updated.SetGroupVersionKind(kustomizationGVK)
+		test.AssertNoError(t, k8sClient.Get(ctx, client.ObjectKeyFromObject(kustomization), updated))
+
+		path, _, err := unstructured.NestedString(updated.Object, "spec", "path")
+		test.AssertNoError(t, err)
+		if path != "./clusters/engineering-dev/" {
+			t.Errorf("got path %q, want %q", path, "./clusters/engineering-dev/")
+		}
+		suspended, _, err := unstructured.NestedBool(updated.Object, "spec", "suspend")
+		test.AssertNoError(t, err)
+		if !suspended {
+			t.Error("expected the field owned by another manager to be retained")
+		}
+	})
+
+	t.Run("reconciling update of resources created before server-side apply", func(t *testing.T) {
+		ctx := context.TODO()
+		withAnnotations := func(annotations map[string]string) []templatesv1.GitOpsSetTemplate {
+			return []templatesv1.GitOpsSetTemplate{
+				{
+					Content: runtime.RawExtension{
+						Raw: mustMarshalJSON(t, test.MakeTestKustomization(nsn("", "unused"), func(ks *kustomizev1.Kustomization) {
+							ks.Name = "{{ .Element.cluster }}-demo"
+							ks.Annotations = annotations
+						})),
+					},
+				},
+			}
+		}
+		gs := makeTestGitOpsSet(t, func(gs *templatesv1.GitOpsSet) {
+			gs.Spec.Templates = withAnnotations(map[string]string{
+				"example.com/cluster": "{{ .Element.cluster }}",
+				"testing":             "oldVersion",
+			})
+			gs.Spec.Generators = []templatesv1.GitOpsSetGenerator{
+				{
+					List: &templatesv1.ListGenerator{
+						Elements: []apiextensionsv1.JSON{
+							{Raw: []byte(`{"cluster": "engineering-dev"}`)},
+						},
+					},
+				},
+			}
+		})
+		gs = createAndReconcileToFinalizedState(t, k8sClient, reconciler, gs)
+		defer deleteGitOpsSetAndFinalize(t, k8sClient, reconciler, gs)
+
+		_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(gs)})
+		test.AssertNoError(t, err)
+
+		// Recreate the Kustomization in the way that earlier versions of the
+		// controller created it, without server-side apply.
+		kustomization := &unstructured.Unstructured{}
+		kustomization.SetGroupVersionKind(kustomizationGVK)
+		test.AssertNoError(t, k8sClient.Get(ctx, client.ObjectKey{Name: "engineering-dev-demo", Namespace: "default"}, kustomization))
+		deleteAllKustomizations(t, k8sClient)
+		kustomization.SetResourceVersion("")
+		kustomization.SetUID("")
+		kustomization.SetCreationTimestamp(metav1.Time{})
+		kustomization.SetManagedFields(nil)
+		test.AssertNoError(t, k8sClient.Create(ctx, kustomization, client.FieldOwner("manager")))
+
+		test.AssertNoError(t, k8sClient.Get(ctx, client.ObjectKeyFromObject(gs), gs))
+		gs.Spec.Templates = withAnnotations(map[string]string{
+			"example.com/cluster": "{{ .Element.cluster }}",
+		})
+		test.AssertNoError(t, k8sClient.Update(ctx, gs))
+
+		_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(gs)})
+		test.AssertNoError(t, err)
+
+		updated := &unstructured.Unstructured{}
+		updated.SetGroupVersionKind(kustomizationGVK)
+		test.AssertNoError(t, k8sClient.Get(ctx, client.ObjectKeyFromObject(kustomization), updated))
+		want := map[string]string{"example.com/cluster": "engineering-dev"}
+		if diff := cmp.Diff(want, updated.GetAnnotations()); diff != "" {
+			t.Fatalf("failed to remove field from resource created without server-side apply:\n%s", diff)
+		}
+	})
+
+	t.Run("reconciling drifted resources with drift detection in warn mode", func(t *testing.T) {
+		ctx := context.TODO()
+		gs := makeTestGitOpsSet(t, func(gs *templatesv1.GitOpsSet) {
+			gs.Spec.DriftDetection = templatesv1.DriftDetectionWarn
+			gs.Spec.Generators = []templatesv1.GitOpsSetGenerator{
+				{
+					List: &templatesv1.ListGenerator{
+						Elements: []apiextensionsv1.JSON{
+							{Raw: []byte(`{"cluster": "engineering-dev"}`)},
+						},
+					},
+				},
+			}
+		})
+		gs = createAndReconcileToFinalizedState(t, k8sClient, reconciler, gs)
+		defer deleteGitOpsSetAndFinalize(t, k8sClient, reconciler, gs)
+		defer eventRecorder.Reset()
+
+		_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(gs)})
+		test.AssertNoError(t, err)
+
+		test.AssertNoError(t, k8sClient.Get(ctx, client.ObjectKeyFromObject(gs), gs))
+		if cond := apimeta.FindStatusCondition(gs.Status.Conditions, templatesv1.DriftDetectedCondition); cond != nil {
+			t.Fatalf("expected no DriftDetected condition, got %#v", cond)
+		}
+
+		setKustomizationPath(t, k8sClient, nsn("default", "engineering-dev-demo"), "./changed/path")
+		eventRecorder.Reset()
+
+		_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(gs)})
+		test.AssertNoError(t, err)
+
+		test.AssertNoError(t, k8sClient.Get(ctx, client.ObjectKeyFromObject(gs), gs))
+		assertGitOpsSetCondition(t, gs, templatesv1.DriftDetectedCondition,
+			"1 resources have drifted: default_engineering-dev-demo_kustomize.toolkit.fluxcd.io_Kustomization")
+		assertKustomizationPath(t, k8sClient, nsn("default", "engineering-dev-demo"), "./changed/path")
+
+		want := []*test.EventData{
+			{
+				EventType: corev1.EventTypeWarning,
+				Reason:    templatesv1.DriftDetectedReason,
+				Message:   "1 resources have drifted: default_engineering-dev-demo_kustomize.toolkit.fluxcd.io_Kustomization",
+			},
+		}
+		if diff := cmp.Diff(want, eventRecorder.Events[:1]); diff != "" {
+			t.Fatalf("failed to record drift event:\n%s", diff)
+		}
+
+		// Changes to the templates are applied to drifted resources, the path
+		// is now owned by another field manager.
+		test.AssertNoError(t, k8sClient.Get(ctx, client.ObjectKeyFromObject(gs), gs))
+		gs.Spec.ForceConflicts = true
+		gs.Spec.Generators[0].List.Elements = []apiextensionsv1.JSON{
+			{Raw: []byte(`{"cluster": "engineering-dev", "team": "engineering"}`)},
+		}
+		gs.Spec.Templates[0].Content.Raw = mustMarshalJSON(t, test.MakeTestKustomization(nsn("", "unused"), func(ks *kustomizev1.Kustomization) {
+			ks.Name = "{{ .Element.cluster }}-demo"
+			ks.Spec.Path = "./{{ .Element.team }}/clusters/{{ .Element.cluster }}/"
+		}))
+		test.AssertNoError(t, k8sClient.Update(ctx, gs))
+
+		_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(gs)})
+		test.AssertNoError(t, err)
+
+		test.AssertNoError(t, k8sClient.Get(ctx, client.ObjectKeyFromObject(gs), gs))
+		if cond := apimeta.FindStatusCondition(gs.Status.Conditions, templatesv1.DriftDetectedCondition); cond != nil {
+			t.Fatalf("expected no DriftDetected condition, got %#v", cond)
+		}
+		assertKustomizationPath(t, k8sClient, nsn("default", "engineering-dev-demo"), "./engineering/clusters/engineering-dev/")
+	})
+
+	t.Run("reconciling drifted resources with drift detection enabled", func(t *testing.T) {
+		ctx := context.TODO()
+		gs := makeTestGitOpsSet(t, func(gs *templatesv1.GitOpsSet) {
+			gs.Spec.DriftDetection = templatesv1.DriftDetectionEnabled
+			gs.Spec.Generators = []templatesv1.GitOpsSetGenerator{
+				{
+					List: &templatesv1.ListGenerator{
+						Elements: []apiextensionsv1.JSON{
+							{Raw: []byte(`{"cluster": "engineering-dev"}`)},
+						},
+					},
+				},
+			}
+		})
+		gs = createAndReconcileToFinalizedState(t, k8sClient, reconciler, gs)
+		defer deleteGitOpsSetAndFinalize(t, k8sClient, reconciler, gs)
+		defer eventRecorder.Reset()
+
+		_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(gs)})
+		test.AssertNoError(t, err)
+
+		setKustomizationPath(t, k8sClient, nsn("default", "engineering-dev-demo"), "./changed/path")
+
+		_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(gs)})
+		test.AssertNoError(t, err)
+		assertKustomizationPath(t, k8sClient, nsn("default", "engineering-dev-demo"), "./clusters/engineering-dev/")
+
+		// Deleted resources are recreated.
+		deleteAllKustomizations(t, k8sClient)
+		eventRecorder.Reset()
+
+		_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(gs)})
+		test.AssertNoError(t, err)
+		assertKustomizationsExist(t, k8sClient, "default", "engineering-dev-demo")
+
+		test.AssertNoError(t, k8sClient.Get(ctx, client.ObjectKeyFromObject(gs), gs))
+		if cond := apimeta.FindStatusCondition(gs.Status.Conditions, templatesv1.DriftDetectedCondition); cond != nil {
+			t.Fatalf("expected no DriftDetected condition, got %#v", cond)
+		}
+		want := []*test.EventData{
+			{
+				EventType: corev1.EventTypeNormal,
+				Reason:    templatesv1.DriftDetectedReason,
+				Message:   "corrected drift in 1 resources: default_engineering-dev-demo_kustomize.toolkit.fluxcd.io_Kustomization",
+			},
+		}
+		if diff := cmp.Diff(want, eventRecorder.Events[:1]); diff != "" {
+			t.Fatalf("failed to record drift event:\n%s", diff)
+		}
+	})
+
+	t.Run("reconciling with health checks", func(t *testing.T) {
+		ctx := context.TODO()
+		gs := makeTestGitOpsSet(t, func(gs *templatesv1.GitOpsSet) {
+			gs.Spec.Wait = true
+			gs.Spec.Timeout = &metav1.Duration{Duration: time.Second}
+			gs.Spec.Generators = []templatesv1.GitOpsSetGenerator{
+				{
+					List: &templatesv1.ListGenerator{
+						Elements: []apiextensionsv1.JSON{
+							{Raw: []byte(`{"cluster": "engineering-dev"}`)},
+						},
+					},
+				},
+			}
+		})
+		gs = createAndReconcileToFinalizedState(t, k8sClient, reconciler, gs)
+		defer deleteGitOpsSetAndFinalize(t, k8sClient, reconciler, gs)
+
+		_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(gs)})
+		test.AssertNoError(t, err)
+
+		test.AssertNoError(t, k8sClient.Get(ctx, client.ObjectKeyFromObject(gs), gs))
+		assertGitOpsSetCondition(t, gs, templatesv1.HealthyCondition, "1 resources are healthy")
+		assertGitOpsSetCondition(t, gs, meta.ReadyCondition, "1 resources created")
+
+		kustomization := &unstructured.Unstructured{}
+		kustomization.SetGroupVersionKind(kustomizationGVK)
+		test.AssertNoError(t, k8sClient.Get(ctx, nsn("default", "engineering-dev-demo"), kustomization))
+		test.AssertNoError(t, unstructured.SetNestedField(kustomization.Object, kustomization.GetGeneration(), "status", "observedGeneration"))
+		test.AssertNoError(t, unstructured.SetNestedSlice(kustomization.Object, []any{
+			map[string]any{
+				"type":               "Ready",
+				"status":             "False",
+				"reason":             "BuildFailed",
+				"message":            "kustomization path not found",
+				"lastTransitionTime": "2023-01-01T00:00:00Z",
+			},
+		}, "status", "conditions"))
+		test.AssertNoError(t, k8sClient.Status().Update(ctx, kustomization))
+
+		// The health is checked again after the interval.
+		result, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(gs)})
+		test.AssertNoError(t, err)
+		if result.RequeueAfter != healthCheckInterval {
+			t.Fatalf("got RequeueAfter %v, want %v", result.RequeueAfter, healthCheckInterval)
+		}
+
+		test.AssertNoError(t, k8sClient.Get(ctx, client.ObjectKeyFromObject(gs), gs))
+		assertGitOpsSetCondition(t, gs, templatesv1.HealthyCondition,
+			"1 resources are not healthy: default_engineering-dev-demo_kustomize.toolkit.fluxcd.io_Kustomization (InProgress)")
+		assertGitOpsSetCondition(t, gs, meta.ReadyCondition,
+			"waiting for resources to become healthy: 1 resources are not healthy: default_engineering-dev-demo_kustomize.toolkit.fluxcd.io_Kustomization (InProgress)")
+		if reason := apimeta.FindStatusCondition(gs.Status.Conditions, meta.ReadyCondition).Reason; reason != meta.ProgressingReason {
+			t.Fatalf("got reason %s, want %s", reason, meta.ProgressingReason)
+		}
+		test.AssertInventoryHasItems(t, gs, test.MakeTestKustomization(nsn("default", "engineering-dev-demo")))
+
+		// The health check fails once the resources are not healthy within
+		// the timeout.
+		time.Sleep(2 * time.Second)
+		_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(gs)})
+		test.AssertNoError(t, err)
+
+		test.AssertNoError(t, k8sClient.Get(ctx, client.ObjectKeyFromObject(gs), gs))
+		assertGitOpsSetCondition(t, gs, meta.ReadyCondition,
+			"health check failed after 1s: 1 resources are not healthy: default_engineering-dev-demo_kustomize.toolkit.fluxcd.io_Kustomization (InProgress)")
+		if reason := apimeta.FindStatusCondition(gs.Status.Conditions, meta.ReadyCondition).Reason; reason != templatesv1.HealthCheckFailedReason {
+			t.Fatalf("got reason %s, want %s", reason, templatesv1.HealthCheckFailedReason)
+		}
+	})
+
+	t.Run("reconciling resources in waves", func(t *testing.T) {
+		ctx := context.TODO()
+		configMapTemplate := func(value string) templatesv1.GitOpsSetTemplate {
+			return templatesv1.GitOpsSetTemplate{
+				Wave: 1,
+				Content: runtime.RawExtension{
+					Raw: mustMarshalJSON(t, test.NewConfigMap(func(c *corev1.ConfigMap) {
+						c.Name = "{{ .Element.cluster }}-config"
+						c.Data = map[string]string{"value": value}
+					})),
+				},
+			}
+		}
+		gs := makeTestGitOpsSet(t, func(gs *templatesv1.GitOpsSet) {
+			gs.Spec.Timeout = &metav1.Duration{Duration: time.Second}
+			gs.Spec.Templates = append(gs.Spec.Templates, configMapTemplate("first"))
+			gs.Spec.Generators = []templatesv1.GitOpsSetGenerator{
+				{
+					List: &templatesv1.ListGenerator{
+						Elements: []apiextensionsv1.JSON{
+							{Raw: []byte(`{"cluster": "engineering-dev"}`)},
+						},
+					},
+				},
+			}
+		})
+		gs = createAndReconcileToFinalizedState(t, k8sClient, reconciler, gs)
+		defer deleteGitOpsSetAndFinalize(t, k8sClient, reconciler, gs)
+
+		_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(gs)})
+		test.AssertNoError(t, err)
+
+		test.AssertNoError(t, k8sClient.Get(ctx, client.ObjectKeyFromObject(gs), gs))
+		assertGitOpsSetCondition(t, gs, meta.ReadyCondition, "2 resources created")
+
+		kustomization := &unstructured.Unstructured{}
+		kustomization.SetGroupVersionKind(kustomizationGVK)
+		test.AssertNoError(t, k8sClient.Get(ctx, nsn("default", "engineering-dev-demo"), kustomization))
+		test.AssertNoError(t, unstructured.SetNestedField(kustomization.Object, kustomization.GetGeneration(), "status", "observedGeneration"))
+		test.AssertNoError(t, unstructured.SetNestedSlice(kustomization.Object, []any{
+			map[string]any{
+				"type":               "Ready",
+				"status":             "False",
+				"reason":             "BuildFailed",
+				"message":            "kustomization path not found",
+				"lastTransitionTime": "2023-01-01T00:00:00Z",
+			},
+		}, "status", "conditions"))
+		test.AssertNoError(t, k8sClient.Status().Update(ctx, kustomization))
+
+		gs.Spec.Templates[1] = configMapTemplate("second")
+		test.AssertNoError(t, k8sClient.Update(ctx, gs))
+
+		// The reconciliation is requeued until the wave is healthy.
+		result, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(gs)})
+		test.AssertNoError(t, err)
+		if result.RequeueAfter != healthCheckInterval {
+			t.Fatalf("got RequeueAfter %v, want %v", result.RequeueAfter, healthCheckInterval)
+		}
+
+		cm := &corev1.ConfigMap{}
+		test.AssertNoError(t, k8sClient.Get(ctx, nsn("default", "engineering-dev-config"), cm))
+		if v := cm.Data["value"]; v != "first" {
+			t.Fatalf("got value %q, want %q", v, "first")
+		}
+
+		test.AssertNoError(t, k8sClient.Get(ctx, client.ObjectKeyFromObject(gs), gs))
+		assertGitOpsSetCondition(t, gs, meta.ReadyCondition,
+			"waiting for wave 0 to become healthy: 1 resources are not healthy: default_engineering-dev-demo_kustomize.toolkit.fluxcd.io_Kustomization (InProgress)")
+		if gs.Status.Wave == nil || gs.Status.Wave.Number != 0 {
+			t.Fatalf("got wave %v, want wave 0 to be recorded", gs.Status.Wave)
+		}
+		test.AssertInventoryHasItems(t, gs,
+			test.MakeTestKustomization(nsn("default", "engineering-dev-demo")),
+			test.NewConfigMap(func(c *corev1.ConfigMap) {
+				c.Name = "engineering-dev-config"
+			}))
+
+		// The wave fails once it's not healthy within the timeout.
+		time.Sleep(2 * time.Second)
+		_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(gs)})
+		test.AssertNoError(t, err)
+		test.AssertNoError(t, k8sClient.Get(ctx, client.ObjectKeyFromObject(gs), gs))
+		assertGitOpsSetCondition(t, gs, meta.ReadyCondition,
+			"wave 0 is not healthy after 1s: 1 resources are not healthy: default_engineering-dev-demo_kustomize.toolkit.fluxcd.io_Kustomization (InProgress)")
+
+		// The next wave is applied once the wave is healthy.
+		test.AssertNoError(t, k8sClient.Get(ctx, nsn("default", "engineering-dev-demo"), kustomization))
+		test.AssertNoError(t, unstructured.SetNestedSlice(kustomization.Object, []any{}, "status", "conditions"))
+		test.AssertNoError(t, k8sClient.Status().Update(ctx, kustomization))
+
+		_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(gs)})
+		test.AssertNoError(t, err)
+		test.AssertNoError(t, k8sClient.Get(ctx, nsn("default", "engineering-dev-config"), cm))
+		if v := cm.Data["value"]; v != "second" {
+			t.Fatalf("got value %q, want %q", v, "second")
+		}
+		test.AssertNoError(t, k8sClient.Get(ctx, client.ObjectKeyFromObject(gs), gs))
+		if gs.Status.Wave != nil {
+			t.Fatalf("got wave %v recorded after all waves were applied", gs.Status.Wave)
+		}
+	})
+
+	t.Run("reconciling in plan mode", func(t *testing.T) {
+		ctx := context.TODO()
+		gs := makeTestGitOpsSet(t, func(gs *templatesv1.GitOpsSet) {
+			gs.Spec.Mode = templatesv1.PlanMode
+			gs.Spec.Generators = []templatesv1.GitOpsSetGenerator{
+				{
+					List: &templatesv1.ListGenerator{
+						Elements: []apiextensionsv1.JSON{
+							{Raw: []byte(`{"cluster": "engineering-dev"}`)},
+							{Raw: []byte(`{"cluster": "engineering-prod"}`)},
+						},
+					},
+				},
+			}
+		})
+		gs = createAndReconcileToFinalizedState(t, k8sClient, reconciler, gs)
+		defer deleteGitOpsSetAndFinalize(t, k8sClient, reconciler, gs)
+
+		_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(gs)})
+		test.AssertNoError(t, err)
+
+		test.AssertNoError(t, k8sClient.Get(ctx, client.ObjectKeyFromObject(gs), gs))
+		assertNoKustomizationsExistInNamespace(t, k8sClient, "default")
+		assertInventoryHasNoItems(t, gs)
+		assertGitOpsSetCondition(t, gs, meta.ReadyCondition, "plan has 2 resources to create, 0 to update and 0 to delete")
+		wantPlan := &templatesv1.GitOpsSetPlan{
+			Create: []templatesv1.ResourceRef{
+				{ID: "default_engineering-dev-demo_kustomize.toolkit.fluxcd.io_Kustomization", Version: "v1beta2"},
+				{ID: "default_engineering-prod-demo_kustomize.toolkit.fluxcd.io_Kustomization", Version: "v1beta2"},
+			},
+		}
+		if diff := cmp.Diff(wantPlan, gs.Status.Plan, cmpopts.EquateEmpty()); diff != "" {
+			t.Fatalf("failed to plan changes:\n%s", diff)
+		}
+
+		// Switching to Apply mode applies the changes.
+		gs.Spec.Mode = templatesv1.ApplyMode
+		test.AssertNoError(t, k8sClient.Update(ctx, gs))
+
+		_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(gs)})
+		test.AssertNoError(t, err)
+
+		test.AssertNoError(t, k8sClient.Get(ctx, client.ObjectKeyFromObject(gs), gs))
+		assertKustomizationsExist(t, k8sClient, "default", "engineering-dev-demo", "engineering-prod-demo")
+		assertGitOpsSetCondition(t, gs, meta.ReadyCondition, "2 resources created")
+		if gs.Status.Plan != nil {
+			t.Fatalf("expected the plan to be removed, got %#v", gs.Status.Plan)
+		}
+
+		// The annotation enables Plan mode.
+		gs.SetAnnotations(map[string]string{templatesv1.PlanAnnotation: "true"})
+		gs.Spec.Generators[0].List.Elements = []apiextensionsv1.JSON{
+			{Raw: []byte(`{"cluster": "engineering-dev"}`)},
+			{Raw: []byte(`{"cluster": "engineering-preprod"}`)},
+		}
+		gs.Spec.Templates[0].Content.Raw = mustMarshalJSON(t, test.MakeTestKustomization(nsn("default", "{{ .Element.cluster }}-demo"), func(ks *kustomizev1.Kustomization) {
+			ks.Spec.Path = "./templated/clusters/{{ .Element.cluster }}/"
+		}))
+		test.AssertNoError(t, k8sClient.Update(ctx, gs))
+
+		_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(gs)})
+		test.AssertNoError(t, err)
+
+		test.AssertNoError(t, k8sClient.Get(ctx, client.ObjectKeyFromObject(gs), gs))
+		assertKustomizationsExist(t, k8sClient, "default", "engineering-dev-demo", "engineering-prod-demo")
+		assertKustomizationPath(t, k8sClient, nsn("default", "engineering-dev-demo"), "./clusters/engineering-dev/")
+		test.AssertInventoryHasItems(t, gs,
+			test.MakeTestKustomization(nsn("default", "engineering-dev-demo")),
+			test.MakeTestKustomization(nsn("default", "engineering-prod-demo")))
+		wantPlan = &templatesv1.GitOpsSetPlan{
+			Create: []templatesv1.ResourceRef{
+				{ID: "default_engineering-preprod-demo_kustomize.toolkit.fluxcd.io_Kustomization", Version: "v1beta2"},
+			},
+			Update: []templatesv1.ResourceRef{
+				{ID: "default_engineering-dev-demo_kustomize.toolkit.fluxcd.io_Kustomization", Version: "v1beta2"},
+			},
+			Delete: []templatesv1.ResourceRef{
+				{ID: "default_engineering-prod-demo_kustomize.toolkit.fluxcd.io_Kustomization", Version: "v1beta2"},
+			},
+		}
+		if diff := cmp.Diff(wantPlan, gs.Status.Plan, cmpopts.EquateEmpty()); diff != "" {
+			t.Fatalf("failed to plan changes:\n%s", diff)
+		}
+		if readiness := templatesv1.GetGitOpsSetReadiness(gs); readiness != metav1.ConditionFalse {
+			t.Fatalf("got readiness %s, want %s", readiness, metav1.ConditionFalse)
+		}
+	})
+
+	t.Run("reconciling removal of resources with prune protection", func(t *testing.T) {
+		ctx := context.TODO()
+		gs := makeTestGitOpsSet(t, func(gs *templatesv1.GitOpsSet) {
+			gs.Spec.PruneProtection = &templatesv1.PruneProtection{
+				MaxDeletions: int32Ptr(1),
+			}
+		})
+		gs = createAndReconcileToFinalizedState(t, k8sClient, reconciler, gs)
+		defer deleteGitOpsSetAndFinalize(t, k8sClient, reconciler, gs)
+		defer eventRecorder.Reset()
+
+		_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(gs)})
+		test.AssertNoError(t, err)
+		assertKustomizationsExist(t, k8sClient, "default", "engineering-dev-demo", "engineering-prod-demo", "engineering-preprod-demo")
+
+		test.AssertNoError(t, k8sClient.Get(ctx, client.ObjectKeyFromObject(gs), gs))
+		gs.Spec.Generators[0].List.Elements = []apiextensionsv1.JSON{
+			{Raw: []byte(`{"cluster": "engineering-dev"}`)},
+		}
+		test.AssertNoError(t, k8sClient.Update(ctx, gs))
+
+		_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(gs)})
+		test.AssertNoError(t, err)
+
+		assertKustomizationsExist(t, k8sClient, "default", "engineering-dev-demo", "engineering-prod-demo", "engineering-preprod-demo")
+		test.AssertNoError(t, k8sClient.Get(ctx, client.ObjectKeyFromObject(gs), gs))
+		cond := apimeta.FindStatusCondition(gs.Status.Conditions, meta.ReadyCondition)
+		if cond.Status != metav1.ConditionFalse || cond.Reason != templatesv1.PruneBlockedReason {
+			t.Fatalf("expected reconciliation to be blocked, got %#v", cond)
+		}
+
+		token := deletionsToken([]templatesv1.ResourceRef{
+			{ID: "default_engineering-preprod-demo_kustomize.toolkit.fluxcd.io_Kustomization", Version: "v1beta2"},
+			{ID: "default_engineering-prod-demo_kustomize.toolkit.fluxcd.io_Kustomization", Version: "v1beta2"},
+		})
+		want := "reconciliation would delete 2 of 3 resources which exceeds the prune protection limits, annotate with templates.weave.works/approve-deletions=" + token + " to approve the deletions"
+		assertGitOpsSetCondition(t, gs, meta.ReadyCondition, want)
+		if diff := cmp.Diff([]*test.EventData{{EventType: corev1.EventTypeWarning, Reason: templatesv1.PruneBlockedReason, Message: want}}, eventRecorder.Events[len(eventRecorder.Events)-1:]); diff != "" {
+			t.Fatalf("failed to record prune blocked event:\n%s", diff)
+		}
+
+		gs.SetAnnotations(map[string]string{ApproveDeletionsAnnotation: token})
+		test.AssertNoError(t, k8sClient.Update(ctx, gs))
+
+		_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(gs)})
+		test.AssertNoError(t, err)
+
+		assertKustomizationsExist(t, k8sClient, "default", "engineering-dev-demo")
+		test.AssertNoError(t, k8sClient.Get(ctx, client.ObjectKeyFromObject(gs), gs))
+		assertGitOpsSetCondition(t, gs, meta.ReadyCondition, "1 resources created")
+	})
+
 	t.Run("reconciling update of configmaps", func(t *testing.T) {
 		ctx := context.TODO()
 		gs := makeTestGitOpsSet(t, func(gs *templatesv1.GitOpsSet) {
@@ -719,6 +1351,143 @@ func TestReconciliation(t *testing.T) {
 		}
 	})
 
+	t.Run("reconciling unchanged resources", func(t *testing.T) {
+		ctx := context.TODO()
+		gs := createAndReconcileToFinalizedState(t, k8sClient, reconciler, makeTestGitOpsSet(t, func(gs *templatesv1.GitOpsSet) {
+			// The path is changed by another field manager.
+			gs.Spec.ForceConflicts = true
+		}))
+		defer deleteGitOpsSetAndFinalize(t, k8sClient, reconciler, gs)
+
+		_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(gs)})
+		test.AssertNoError(t, err)
+
+		test.AssertNoError(t, k8sClient.Get(ctx, client.ObjectKeyFromObject(gs), gs))
+		if gs.Status.LastApplied == nil || gs.Status.LastApplied.Digest == "" {
+			t.Fatalf("expected the applied state to be recorded, got %#v", gs.Status.LastApplied)
+		}
+
+		// The resources are not applied again because nothing has changed.
+		setKustomizationPath(t, k8sClient, nsn("default", "engineering-dev-demo"), "./changed/path")
+		_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(gs)})
+		test.AssertNoError(t, err)
+		assertKustomizationPath(t, k8sClient, nsn("default", "engineering-dev-demo"), "./changed/path")
+
+		test.AssertNoError(t, k8sClient.Get(ctx, client.ObjectKeyFromObject(gs), gs))
+		assertGitOpsSetCondition(t, gs, meta.ReadyCondition, "3 resources created")
+
+		// Requesting a reconciliation applies the resources.
+		requestReconciliation(t, k8sClient, gs)
+		_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(gs)})
+		test.AssertNoError(t, err)
+		assertKustomizationPath(t, k8sClient, nsn("default", "engineering-dev-demo"), "./clusters/engineering-dev/")
+
+		// Missing resources are recreated.
+		deleteAllKustomizations(t, k8sClient)
+		_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(gs)})
+		test.AssertNoError(t, err)
+		assertKustomizationsExist(t, k8sClient, "default", "engineering-dev-demo", "engineering-prod-demo", "engineering-preprod-demo")
+	})
+
+	t.Run("reconciling large inventories", func(t *testing.T) {
+		ctx := context.TODO()
+		reconciler.InventoryThreshold = 2
+		defer func() {
+			reconciler.InventoryThreshold = 0
+		}()
+
+		gs := createAndReconcileToFinalizedState(t, k8sClient, reconciler, makeTestGitOpsSet(t))
+
+		test.AssertNoError(t, k8sClient.Get(ctx, client.ObjectKeyFromObject(gs), gs))
+		want := []string{"demo-set-inventory-" + strings.TrimPrefix(gs.Status.Inventory.Digest, "sha256:")[:10] + "-0"}
+		if diff := cmp.Diff(want, gs.Status.Inventory.ConfigMaps); diff != "" {
+			t.Fatalf("failed to store inventory in ConfigMaps:\n%s", diff)
+		}
+		assertGitOpsSetCondition(t, gs, meta.ReadyCondition, "3 resources created")
+
+		loaded, err := inventory.Load(ctx, k8sClient, gs)
+		test.AssertNoError(t, err)
+		if l := len(loaded.Entries); l != 3 {
+			t.Fatalf("got %d inventory entries, want 3", l)
+		}
+
+		// The resources are deleted using the inventory in the ConfigMaps.
+		deleteGitOpsSetAndFinalize(t, k8sClient, reconciler, gs)
+		assertNoKustomizationsExistInNamespace(t, k8sClient, "default")
+	})
+
+	t.Run("reconciling deletion with a missing inventory ConfigMap", func(t *testing.T) {
+		ctx := context.TODO()
+		reconciler.InventoryThreshold = 2
+		defer func() {
+			reconciler.InventoryThreshold = 0
+		}()
+		defer eventRecorder.Reset()
+		defer deleteAllKustomizations(t, k8sClient)
+
+		gs := createAndReconcileToFinalizedState(t, k8sClient, reconciler, makeTestGitOpsSet(t))
+		test.AssertNoError(t, k8sClient.Get(ctx, client.ObjectKeyFromObject(gs), gs))
+		configMapName := gs.Status.Inventory.ConfigMaps[0]
+		test.AssertNoError(t, k8sClient.Delete(ctx, &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: configMapName, Namespace: "default"},
+		}))
+		eventRecorder.Reset()
+
+		// The GitOpsSet is deleted, and the resources are left in place.
+		deleteGitOpsSetAndFinalize(t, k8sClient, reconciler, gs)
+		assertKustomizationsExist(t, k8sClient, "default", "engineering-dev-demo", "engineering-prod-demo", "engineering-preprod-demo")
+
+		if l := len(eventRecorder.Events); l != 1 {
+			t.Fatalf("got %d events, want 1", l)
+		}
+		want := "failed to load inventory, generated resources will not be removed: failed to get inventory ConfigMap " + configMapName
+		if msg := eventRecorder.Events[0].Message; !strings.HasPrefix(msg, want) {
+			t.Fatalf("got event %q, want %q", msg, want)
+		}
+	})
+
+	t.Run("reconciling resources in remote clusters", func(t *testing.T) {
+		ctx := context.TODO()
+		// The remote cluster is the test cluster, accessed with a kubeconfig.
+		user, err := testEnv.AddUser(envtest.User{Name: "remote-admin", Groups: []string{"system:masters"}}, nil)
+		test.AssertNoError(t, err)
+		kubeConfig, err := user.KubeConfig()
+		test.AssertNoError(t, err)
+
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "remote-kubeconfig", Namespace: "default"},
+			Data:       map[string][]byte{"value": kubeConfig},
+		}
+		test.AssertNoError(t, k8sClient.Create(ctx, secret))
+		defer deleteObject(t, k8sClient, secret)
+
+		cluster := &unstructured.Unstructured{}
+		cluster.SetAPIVersion("gitops.weave.works/v1alpha1")
+		cluster.SetKind("GitopsCluster")
+		cluster.SetName("remote")
+		cluster.SetNamespace("default")
+		test.AssertNoError(t, unstructured.SetNestedField(cluster.Object, "remote-kubeconfig", "spec", "secretRef", "name"))
+		test.AssertNoError(t, k8sClient.Create(ctx, cluster))
+		defer deleteObject(t, k8sClient, cluster)
+
+		gs := createAndReconcileToFinalizedState(t, k8sClient, reconciler, makeTestGitOpsSet(t, func(gs *templatesv1.GitOpsSet) {
+			gs.Spec.Templates[0].TargetCluster = "remote"
+		}))
+
+		test.AssertNoError(t, k8sClient.Get(ctx, client.ObjectKeyFromObject(gs), gs))
+		assertGitOpsSetCondition(t, gs, meta.ReadyCondition, "3 resources created")
+		for _, ref := range gs.Status.Inventory.Entries {
+			if ref.Cluster != "default/remote" {
+				t.Errorf("got cluster %q for %s, want %q", ref.Cluster, ref.ID, "default/remote")
+			}
+		}
+		assertKustomizationsExist(t, k8sClient, "default", "engineering-dev-demo", "engineering-prod-demo", "engineering-preprod-demo")
+
+		// The resources are deleted from the remote cluster.
+		deleteGitOpsSetAndFinalize(t, k8sClient, reconciler, gs)
+		assertNoKustomizationsExistInNamespace(t, k8sClient, "default")
+	})
+
 	t.Run("reconciling creation when suspended", func(t *testing.T) {
 		ctx := context.TODO()
 		gs := createAndReconcileToFinalizedState(t, k8sClient, reconciler, makeTestGitOpsSet(t, func(gs *templatesv1.GitOpsSet) {
@@ -765,6 +1534,63 @@ func TestReconciliation(t *testing.T) {
 		assertGitOpsSetCondition(t, gs, meta.ReadyCondition, "waiting for artifact")
 	})
 
+	t.Run("reconciling with a template that doesn't parse", func(t *testing.T) {
+		ctx := context.TODO()
+		gs := createAndReconcileToFinalizedState(t, k8sClient, reconciler, makeTestGitOpsSet(t, func(gs *templatesv1.GitOpsSet) {
+			gs.Spec.Templates = []templatesv1.GitOpsSetTemplate{
+				{
+					Content: runtime.RawExtension{Raw: []byte(`"{{ .Element.cluster | tested }}"`)},
+				},
+			}
+		}))
+		defer deleteGitOpsSetAndFinalize(t, k8sClient, reconciler, gs)
+
+		_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(gs)})
+		test.AssertNoError(t, err)
+
+		test.AssertNoError(t, k8sClient.Get(ctx, client.ObjectKeyFromObject(gs), gs))
+		for _, condType := range []string{meta.ReadyCondition, meta.StalledCondition} {
+			cond := apimeta.FindStatusCondition(gs.Status.Conditions, condType)
+			if cond == nil || cond.Reason != templatesv1.RenderFailedReason {
+				t.Fatalf("got %s condition %#v, want reason %s", condType, cond, templatesv1.RenderFailedReason)
+			}
+		}
+		if apimeta.FindStatusCondition(gs.Status.Conditions, meta.ReconcilingCondition) != nil {
+			t.Fatal("Reconciling condition not removed from a stalled GitOpsSet")
+		}
+		assertNoKustomizationsExistInNamespace(t, k8sClient, "default")
+	})
+
+	t.Run("reconciling with resources not allowed by a policy", func(t *testing.T) {
+		ctx := context.TODO()
+		policy := &templatesv1.GitOpsSetPolicy{
+			ObjectMeta: metav1.ObjectMeta{Name: "deny-kustomizations"},
+			Spec: templatesv1.GitOpsSetPolicySpec{
+				SourceNamespaces: []string{"default"},
+				DeniedKinds:      []templatesv1.GroupKind{{Group: "kustomize.toolkit.fluxcd.io", Kind: "Kustomization"}},
+			},
+		}
+		test.AssertNoError(t, k8sClient.Create(ctx, policy))
+		defer deleteObject(t, k8sClient, policy)
+
+		gs := createAndReconcileToFinalizedState(t, k8sClient, reconciler, makeTestGitOpsSet(t))
+		defer deleteGitOpsSetAndFinalize(t, k8sClient, reconciler, gs)
+
+		_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(gs)})
+		test.AssertErrorMatch(t, "rendered resources are not allowed by policy", err)
+
+		test.AssertNoError(t, k8sClient.Get(ctx, client.ObjectKeyFromObject(gs), gs))
+		cond := apimeta.FindStatusCondition(gs.Status.Conditions, meta.ReadyCondition)
+		if cond == nil || cond.Reason != templatesv1.PolicyViolationReason {
+			t.Fatalf("got Ready condition %#v, want reason %s", cond, templatesv1.PolicyViolationReason)
+		}
+		want := "Kustomization default/engineering-dev-demo: kind Kustomization.kustomize.toolkit.fluxcd.io is not allowed by GitOpsSetPolicy deny-kustomizations"
+		if !strings.Contains(cond.Message, want) {
+			t.Fatalf("got message %q, want it to contain %q", cond.Message, want)
+		}
+		assertNoKustomizationsExistInNamespace(t, k8sClient, "default")
+	})
+
 	t.Run("error conditions - existing resource", func(t *testing.T) {
 		ctx := context.TODO()
 		gs := makeTestGitOpsSet(t, func(gs *templatesv1.GitOpsSet) {
@@ -802,258 +1628,120 @@ func TestReconciliation(t *testing.T) {
 		updated := &templatesv1.GitOpsSet{}
 		test.AssertNoError(t, k8sClient.Get(ctx, client.ObjectKeyFromObject(gs), updated))
 		assertGitOpsSetCondition(t, updated, meta.ReadyCondition, "failed to create Resource: kustomizations.kustomize.toolkit.fluxcd.io \"engineering-dev-demo\" already exists")
+		if cond := apimeta.FindStatusCondition(updated.Status.Conditions, meta.ReadyCondition); cond.Reason != templatesv1.ApplyFailedReason {
+			t.Fatalf("got reason %s, want %s", cond.Reason, templatesv1.ApplyFailedReason)
+		}
+		cond := apimeta.FindStatusCondition(updated.Status.Conditions, meta.ReconcilingCondition)
+		if cond == nil || cond.Reason != meta.ProgressingWithRetryReason {
+			t.Fatalf("got Reconciling condition %#v, want reason %s", cond, meta.ProgressingWithRetryReason)
+		}
 	})
-}
 
-func TestGetClusterSelectors(t *testing.T) {
-	testCases := []struct {
-		name      string
-		generator templatesv1.GitOpsSetGenerator
-		want      []metav1.LabelSelector
-	}{
-		{
-			name: "with cluster",
-			generator: templatesv1.GitOpsSetGenerator{
-				Cluster: &templatesv1.ClusterGenerator{
-					Selector: metav1.LabelSelector{
-						MatchLabels: map[string]string{
-							"app": "myapp",
-						},
-					},
-				},
-			},
-			want: []metav1.LabelSelector{
-				{
-					MatchLabels: map[string]string{
-						"app": "myapp",
-					},
-				},
-			},
-		},
-		{
-			name: "with matrix",
-			generator: templatesv1.GitOpsSetGenerator{
-				Matrix: &templatesv1.MatrixGenerator{
-					Generators: []templatesv1.GitOpsSetNestedGenerator{
-						{
-							Cluster: &templatesv1.ClusterGenerator{
-								Selector: metav1.LabelSelector{
-									MatchLabels: map[string]string{
-										"env": "prod",
-									},
-								},
-							},
-						},
-						{
-							Cluster: &templatesv1.ClusterGenerator{
-								Selector: metav1.LabelSelector{
-									MatchLabels: map[string]string{
-										"env": "staging",
-									},
-								},
-							},
-						},
-					},
-				},
-			},
-			want: []metav1.LabelSelector{
-				{
-					MatchLabels: map[string]string{
-						"env": "prod",
-					},
-				},
-				{
-					MatchLabels: map[string]string{
-						"env": "staging",
-					},
-				},
-			},
-		},
-		{
-			name:      "without cluster or matrix",
-			generator: templatesv1.GitOpsSetGenerator{},
-			want:      []metav1.LabelSelector{},
-		},
-	}
+	t.Run("adopting existing resources", func(t *testing.T) {
+		ctx := context.TODO()
+		defer deleteAllKustomizations(t, k8sClient)
 
-	for _, tc := range testCases {
-		t.Run(tc.name, func(t *testing.T) {
-			got := getClusterSelectors(tc.generator)
-			if diff := cmp.Diff(tc.want, got); diff != "" {
-				t.Fatalf("failed to get selectors:\n%s", diff)
+		devKS := test.MakeTestKustomization(nsn("default", "engineering-dev-demo"))
+		test.AssertNoError(t, k8sClient.Create(ctx, test.ToUnstructured(t, devKS)))
+		prodKS := test.MakeTestKustomization(nsn("default", "engineering-prod-demo"), func(k *kustomizev1.Kustomization) {
+			k.ObjectMeta.Labels = map[string]string{
+				"templates.weave.works/name":      "other-set",
+				"templates.weave.works/namespace": "default",
 			}
 		})
-	}
+		test.AssertNoError(t, k8sClient.Create(ctx, test.ToUnstructured(t, prodKS)))
+
+		gs := createAndReconcileToFinalizedState(t, k8sClient, reconciler, makeTestGitOpsSet(t, func(gs *templatesv1.GitOpsSet) {
+			gs.Spec.AdoptionPolicy = templatesv1.IfUnownedAdoptionPolicy
+		}))
+		defer deleteGitOpsSetAndFinalize(t, k8sClient, reconciler, gs)
+
+		_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(gs)})
+		test.AssertErrorMatch(t, "failed to adopt Resource: resource default_engineering-prod-demo_kustomize.toolkit.fluxcd.io_Kustomization is owned by GitOpsSet default/other-set", err)
+
+		test.AssertNoError(t, k8sClient.Get(ctx, client.ObjectKeyFromObject(gs), gs))
+		cond := apimeta.FindStatusCondition(gs.Status.Conditions, meta.ReadyCondition)
+		if cond.Reason != templatesv1.OwnershipConflictReason {
+			t.Fatalf("got reason %s, want %s", cond.Reason, templatesv1.OwnershipConflictReason)
+		}
+		assertKustomizationPath(t, k8sClient, nsn("default", "engineering-dev-demo"), "./clusters/engineering-dev/")
+		assertKustomizationPath(t, k8sClient, nsn("default", "engineering-prod-demo"), "./examples/kustomize/environments/dev")
+
+		gs.Spec.AdoptionPolicy = templatesv1.AlwaysAdoptionPolicy
+		test.AssertNoError(t, k8sClient.Update(ctx, gs))
+
+		_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(gs)})
+		test.AssertNoError(t, err)
+
+		test.AssertNoError(t, k8sClient.Get(ctx, client.ObjectKeyFromObject(gs), gs))
+		test.AssertInventoryHasItems(t, gs,
+			test.MakeTestKustomization(nsn("default", "engineering-dev-demo")),
+			test.MakeTestKustomization(nsn("default", "engineering-prod-demo")),
+			test.MakeTestKustomization(nsn("default", "engineering-preprod-demo")))
+		assertKustomizationPath(t, k8sClient, nsn("default", "engineering-prod-demo"), "./clusters/engineering-prod/")
+	})
 }
 
-func TestMatchCluster(t *testing.T) {
-	gitopsCluster := &clustersv1.GitopsCluster{
-		ObjectMeta: metav1.ObjectMeta{
-			Labels: map[string]string{
-				"app": "myapp",
-				"env": "prod",
-			},
-		},
-	}
+func TestPatchStatus_failed_status_patch(t *testing.T) {
+	ctx := context.TODO()
+	scheme := runtime.NewScheme()
+	test.AssertNoError(t, clientgoscheme.AddToScheme(scheme))
+	test.AssertNoError(t, templatesv1.AddToScheme(scheme))
 
-	clusterGen := &templatesv1.ClusterGenerator{
-		Selector: metav1.LabelSelector{
-			MatchLabels: map[string]string{
-				"app": "myapp",
-			},
-		},
+	gs := &templatesv1.GitOpsSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "demo-set", Namespace: "default", UID: "d5b4b3b6-3b4e-4a8a-9d2c-6b7c0b0e5e4f"},
 	}
-
-	testCases := []struct {
-		name      string
-		cluster   *clustersv1.GitopsCluster
-		gitopsSet *templatesv1.GitOpsSet
-		want      bool
-	}{
-		{
-			name:    "matching cluster",
-			cluster: gitopsCluster,
-			gitopsSet: &templatesv1.GitOpsSet{
-				Spec: templatesv1.GitOpsSetSpec{
-					Generators: []templatesv1.GitOpsSetGenerator{
-						{
-							Cluster: clusterGen,
-						},
-					},
-				},
-			},
-			want: true,
-		},
-		{
-			name:    "non-matching cluster",
-			cluster: gitopsCluster,
-			gitopsSet: &templatesv1.GitOpsSet{
-				Spec: templatesv1.GitOpsSetSpec{
-					Generators: []templatesv1.GitOpsSetGenerator{
-						{
-							Cluster: &templatesv1.ClusterGenerator{
-								Selector: metav1.LabelSelector{
-									MatchLabels: map[string]string{
-										"app": "myapp",
-										"env": "staging",
-									},
-								},
-							},
-						},
-					},
-				},
-			},
-			want: false,
-		},
-		{
-			name:    "matching cluster in matrix generator",
-			cluster: gitopsCluster,
-			gitopsSet: &templatesv1.GitOpsSet{
-				Spec: templatesv1.GitOpsSetSpec{
-					Generators: []templatesv1.GitOpsSetGenerator{
-						{
-							Matrix: &templatesv1.MatrixGenerator{
-								Generators: []templatesv1.GitOpsSetNestedGenerator{
-									{
-										Cluster: clusterGen,
-									},
-								},
-							},
-						},
-					},
-				},
-			},
-			want: true,
-		},
-		{
-			name:    "list generator should not match",
-			cluster: gitopsCluster,
-			gitopsSet: &templatesv1.GitOpsSet{
-				Spec: templatesv1.GitOpsSetSpec{
-					Generators: []templatesv1.GitOpsSetGenerator{
-						{
-							List: &templatesv1.ListGenerator{},
-						},
-					},
-				},
+	failPatch := false
+	cl := fake.NewClientBuilder().WithScheme(scheme).WithObjects(gs).WithStatusSubresource(gs).
+		WithInterceptorFuncs(interceptor.Funcs{
+			SubResourcePatch: func(ctx context.Context, c client.Client, subResourceName string, obj client.Object, patch client.Patch, opts ...client.SubResourcePatchOption) error {
+				if failPatch {
+					return errors.New("failed to patch status")
+				}
+				return c.SubResource(subResourceName).Patch(ctx, obj, patch, opts...)
 			},
-			want: false,
-		},
+		}).Build()
+	r := &GitOpsSetReconciler{Client: cl, Scheme: scheme, InventoryThreshold: 1}
+	req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(gs)}
+
+	makeInventory := func(names ...string) *templatesv1.ResourceInventory {
+		inv := &templatesv1.ResourceInventory{}
+		for _, name := range names {
+			inv.Entries = append(inv.Entries, templatesv1.ResourceRef{ID: "default_" + name + "__ConfigMap", Version: "v1"})
+		}
+		return inv
+	}
+	loadInventory := func() *templatesv1.ResourceInventory {
+		t.Helper()
+		var updated templatesv1.GitOpsSet
+		test.AssertNoError(t, cl.Get(ctx, req.NamespacedName, &updated))
+		loaded, err := inventory.Load(ctx, cl, &updated)
+		test.AssertNoError(t, err)
+		return loaded
 	}
 
-	for _, tc := range testCases {
-		t.Run(tc.name, func(t *testing.T) {
-			got := matchCluster(tc.cluster, tc.gitopsSet)
-			if diff := cmp.Diff(tc.want, got); diff != "" {
-				t.Fatalf("failed to match cluster:\n%s", diff)
-			}
-		})
+	first := makeInventory("test-cm-1", "test-cm-2")
+	test.AssertNoError(t, r.patchStatus(ctx, req, templatesv1.GitOpsSetStatus{Inventory: first}))
+
+	failPatch = true
+	second := makeInventory("test-cm-1", "test-cm-2", "test-cm-3")
+	test.AssertErrorMatch(t, "failed to patch status", r.patchStatus(ctx, req, templatesv1.GitOpsSetStatus{Inventory: second}))
+
+	// The inventory recorded in the status can still be loaded.
+	if diff := cmp.Diff(first, loadInventory()); diff != "" {
+		t.Fatalf("failed to load inventory after a failed status patch:\n%s", diff)
 	}
-}
 
-func TestSelectorMatchesCluster(t *testing.T) {
-	testCases := []struct {
-		name          string
-		cluster       *clustersv1.GitopsCluster
-		labelSelector metav1.LabelSelector
-		want          bool
-	}{
-		{
-			name: "matching selector",
-			cluster: &clustersv1.GitopsCluster{
-				ObjectMeta: metav1.ObjectMeta{
-					Labels: map[string]string{
-						"app": "myapp",
-						"env": "prod",
-					},
-				},
-			},
-			labelSelector: metav1.LabelSelector{
-				MatchLabels: map[string]string{
-					"app": "myapp",
-				},
-			},
-			want: true,
-		},
-		{
-			name: "non-matching selector",
-			cluster: &clustersv1.GitopsCluster{
-				ObjectMeta: metav1.ObjectMeta{
-					Labels: map[string]string{
-						"app": "myapp",
-						"env": "prod",
-					},
-				},
-			},
-			labelSelector: metav1.LabelSelector{
-				MatchLabels: map[string]string{
-					"app": "otherapp",
-				},
-			},
-			want: false,
-		},
-		{
-			name: "empty selector",
-			cluster: &clustersv1.GitopsCluster{
-				ObjectMeta: metav1.ObjectMeta{
-					Labels: map[string]string{
-						"app": "myapp",
-						"env": "prod",
-					},
-				},
-			},
-			labelSelector: metav1.LabelSelector{},
-			want:          false,
-		},
+	failPatch = false
+	test.AssertNoError(t, r.patchStatus(ctx, req, templatesv1.GitOpsSetStatus{Inventory: second}))
+	if diff := cmp.Diff(second, loadInventory()); diff != "" {
+		t.Fatalf("failed to load inventory:\n%s", diff)
 	}
 
-	for _, tc := range testCases {
-		t.Run(tc.name, func(t *testing.T) {
-			got := selectorMatchesCluster(tc.labelSelector, tc.cluster)
-			if diff := cmp.Diff(tc.want, got); diff != "" {
-				t.Errorf("selectorMatchesCluster(%v, %v) mismatch (-want +got):\n%s", tc.labelSelector, tc.cluster, diff)
-			}
-		})
+	var configMaps corev1.ConfigMapList
+	test.AssertNoError(t, cl.List(ctx, &configMaps, client.InNamespace("default")))
+	if l := len(configMaps.Items); l != 1 {
+		t.Fatalf("got %d inventory ConfigMaps, want 1", l)
 	}
 }
 
@@ -1068,6 +1756,55 @@ func deleteAllKustomizations(t *testing.T, cl client.Client) {
 	}
 }
 
+// requestReconciliation annotates the GitOpsSet to request a reconciliation,
+// which applies the resources even if they are unchanged.
+func requestReconciliation(t *testing.T, cl client.Client, gs *templatesv1.GitOpsSet) {
+	t.Helper()
+	test.AssertNoError(t, cl.Get(context.TODO(), client.ObjectKeyFromObject(gs), gs))
+	annotations := gs.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[fluxMeta.ReconcileRequestAnnotation] = time.Now().Format(time.RFC3339Nano)
+	gs.SetAnnotations(annotations)
+	test.AssertNoError(t, cl.Update(context.TODO(), gs))
+}
+
+func setKustomizationPath(t *testing.T, cl client.Client, name types.NamespacedName, path string) {
+	t.Helper()
+	kustomization := &unstructured.Unstructured{}
+	kustomization.SetGroupVersionKind(kustomizationGVK)
+	test.AssertNoError(t, cl.Get(context.TODO(), name, kustomization))
+	test.AssertNoError(t, unstructured.SetNestedField(kustomization.Object, path, "spec", "path"))
+	test.AssertNoError(t, cl.Update(context.TODO(), kustomization))
+}
+
+func assertKustomizationPath(t *testing.T, cl client.Client, name types.NamespacedName, want string) {
+	t.Helper()
+	kustomization := &unstructured.Unstructured{}
+	kustomization.SetGroupVersionKind(kustomizationGVK)
+	test.AssertNoError(t, cl.Get(context.TODO(), name, kustomization))
+
+	path, _, err := unstructured.NestedString(kustomization.Object, "spec", "path")
+	test.AssertNoError(t, err)
+	if path != want {
+		t.Fatalf("got path %q, want %q", path, want)
+	}
+}
+
+func assertKustomizationIsOrphaned(t *testing.T, cl client.Client, name types.NamespacedName) {
+	t.Helper()
+	kustomization := &unstructured.Unstructured{}
+	kustomization.SetGroupVersionKind(kustomizationGVK)
+	test.AssertNoError(t, cl.Get(context.TODO(), name, kustomization))
+
+	for _, label := range []string{"templates.weave.works/name", "templates.weave.works/namespace"} {
+		if _, ok := kustomization.GetLabels()[label]; ok {
+			t.Fatalf("expected label %s to be removed from orphaned resource, got %v", label, kustomization.GetLabels())
+		}
+	}
+}
+
 func assertResourceDoesNotExist(t *testing.T, cl client.Client, gs *kustomizev1.Kustomization) {
 	t.Helper()
 	check := &unstructured.Unstructured{}
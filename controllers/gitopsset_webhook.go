@@ -0,0 +1,92 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	templatesv1 "github.com/weaveworks/gitopssets-controller/api/v1beta1"
+	"github.com/weaveworks/gitopssets-controller/controllers/templates"
+	"github.com/weaveworks/gitopssets-controller/controllers/templates/generators"
+)
+
+//+kubebuilder:webhook:path=/validate-templates-weave-works-v1beta1-gitopsset,mutating=false,failurePolicy=fail,sideEffects=None,groups=templates.weave.works,resources=gitopssets,verbs=create;update,versions=v1beta1,name=vgitopsset.templates.weave.works,admissionReviewVersions=v1
+
+// GitOpsSetValidator validates GitOpsSets on admission, rejecting GitOpsSets
+// that would fail to render when they're reconciled.
+type GitOpsSetValidator struct {
+	Generators map[string]generators.GeneratorFactory
+
+	enabledGenerators map[string]generators.Generator
+}
+
+var _ admission.CustomValidator = (*GitOpsSetValidator)(nil)
+
+// SetupWebhookWithManager registers the validating webhook with the Manager.
+//
+// The conversion webhook for the earlier versions of the API is registered
+// with it, if they're in the Manager's scheme.
+func (v *GitOpsSetValidator) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	v.enableGenerators(mgr.GetLogger(), mgr.GetClient())
+
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(&templatesv1.GitOpsSet{}).
+		WithValidator(v).
+		Complete()
+}
+
+// ValidateCreate is an implementation of the admission.CustomValidator
+// interface.
+func (v *GitOpsSetValidator) ValidateCreate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	gs, ok := obj.(*templatesv1.GitOpsSet)
+	if !ok {
+		return nil, fmt.Errorf("expected a GitOpsSet but got a %T", obj)
+	}
+
+	return nil, v.validate(gs)
+}
+
+// ValidateUpdate is an implementation of the admission.CustomValidator
+// interface.
+//
+// GitOpsSets that are being deleted are not validated so that finalizers can
+// be removed.
+func (v *GitOpsSetValidator) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+	gs, ok := newObj.(*templatesv1.GitOpsSet)
+	if !ok {
+		return nil, fmt.Errorf("expected a GitOpsSet but got a %T", newObj)
+	}
+
+	if !gs.GetDeletionTimestamp().IsZero() {
+		return nil, nil
+	}
+
+	return nil, v.validate(gs)
+}
+
+// ValidateDelete is an implementation of the admission.CustomValidator
+// interface.
+func (v *GitOpsSetValidator) ValidateDelete(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+func (v *GitOpsSetValidator) validate(gs *templatesv1.GitOpsSet) error {
+	if errs := templates.Validate(gs, v.enabledGenerators); len(errs) > 0 {
+		return apierrors.NewInvalid(templatesv1.GroupVersion.WithKind("GitOpsSet").GroupKind(), gs.GetName(), errs)
+	}
+
+	return nil
+}
+
+func (v *GitOpsSetValidator) enableGenerators(logger logr.Logger, c client.Reader) {
+	v.enabledGenerators = map[string]generators.Generator{}
+	for name, factory := range v.Generators {
+		v.enabledGenerators[name] = factory(logger, c)
+	}
+}
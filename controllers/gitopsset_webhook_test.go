@@ -0,0 +1,65 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-logr/logr"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	templatesv1 "github.com/weaveworks/gitopssets-controller/api/v1beta1"
+	"github.com/weaveworks/gitopssets-controller/controllers/templates/generators"
+	"github.com/weaveworks/gitopssets-controller/controllers/templates/generators/list"
+	"github.com/weaveworks/gitopssets-controller/test"
+)
+
+func TestGitOpsSetValidator(t *testing.T) {
+	v := &GitOpsSetValidator{
+		Generators: map[string]generators.GeneratorFactory{
+			"List": list.GeneratorFactory,
+		},
+	}
+	v.enableGenerators(logr.Discard(), nil)
+
+	valid := newValidationGitOpsSet(templatesv1.GitOpsSetGenerator{List: &templatesv1.ListGenerator{}})
+	invalid := newValidationGitOpsSet(templatesv1.GitOpsSetGenerator{
+		GitRepository: &templatesv1.GitRepositoryGenerator{RepositoryRef: "test-repo"},
+	})
+
+	_, err := v.ValidateCreate(context.TODO(), valid)
+	test.AssertNoError(t, err)
+
+	_, err = v.ValidateCreate(context.TODO(), invalid)
+	if !apierrors.IsInvalid(err) {
+		t.Fatalf("got error %v, want an invalid error", err)
+	}
+	test.AssertErrorMatch(t, `GitOpsSet.templates.weave.works "demo-set" is invalid: spec.generators\[0\]: Invalid value: "GitRepository": generator GitRepository not enabled`, err)
+
+	_, err = v.ValidateUpdate(context.TODO(), valid, invalid)
+	if !apierrors.IsInvalid(err) {
+		t.Fatalf("got error %v, want an invalid error", err)
+	}
+
+	deleting := invalid.DeepCopy()
+	now := metav1.Now()
+	deleting.SetDeletionTimestamp(&now)
+	_, err = v.ValidateUpdate(context.TODO(), invalid, deleting)
+	test.AssertNoError(t, err)
+
+	_, err = v.ValidateDelete(context.TODO(), invalid)
+	test.AssertNoError(t, err)
+}
+
+func newValidationGitOpsSet(gen templatesv1.GitOpsSetGenerator) *templatesv1.GitOpsSet {
+	return &templatesv1.GitOpsSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "demo-set", Namespace: "default"},
+		Spec: templatesv1.GitOpsSetSpec{
+			Generators: []templatesv1.GitOpsSetGenerator{gen},
+			Templates: []templatesv1.GitOpsSetTemplate{
+				{Content: runtime.RawExtension{Raw: []byte(`{"kind": "ConfigMap", "metadata": {"name": "{{ .Element.env }}"}}`)}},
+			},
+		},
+	}
+}
@@ -0,0 +1,144 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	fluxMeta "github.com/fluxcd/pkg/apis/meta"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/status"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	templatesv1 "github.com/weaveworks/gitopssets-controller/api/v1beta1"
+)
+
+// healthCheckInterval is how often the health of the generated resources is
+// checked while waiting for them to become healthy.
+var healthCheckInterval = 10 * time.Second
+
+// resourcesNotHealthyError is returned when the generated resources are not
+// healthy, the GitOpsSet is requeued to check them again.
+type resourcesNotHealthyError struct {
+	unhealthy []string
+	// timeout is set when the resources have not been healthy for longer
+	// than the timeout.
+	timeout time.Duration
+}
+
+func (e resourcesNotHealthyError) Error() string {
+	msg := fmt.Sprintf("%d resources are not healthy: %s", len(e.unhealthy), strings.Join(e.unhealthy, ", "))
+	if e.timeout > 0 {
+		return fmt.Sprintf("health check failed after %s: %s", e.timeout, msg)
+	}
+
+	return "waiting for resources to become healthy: " + msg
+}
+
+// checkHealth checks the health of the resources in the inventory and updates
+// the Healthy condition.
+//
+// A resourcesNotHealthyError is returned if the resources are not healthy, the
+// timeout starts when the resources are first found to be not healthy.
+func (r *GitOpsSetReconciler) checkHealth(ctx context.Context, clients *clusterClients, gitOpsSet *templatesv1.GitOpsSet, inventory *templatesv1.ResourceInventory) error {
+	if !gitOpsSet.Spec.Wait {
+		templatesv1.ClearGitOpsSetHealthiness(gitOpsSet)
+		return nil
+	}
+
+	logger := log.FromContext(ctx)
+	logger.Info("checking the health of resources", "timeout", gitOpsSet.GetTimeout())
+
+	unhealthy, err := unhealthyResources(ctx, clients, inventory)
+	if err != nil {
+		templatesv1.SetGitOpsSetHealthiness(gitOpsSet, metav1.ConditionFalse, templatesv1.HealthCheckFailedReason, err.Error())
+		return fmt.Errorf("failed to check health of resources: %w", err)
+	}
+
+	if len(unhealthy) > 0 {
+		healthErr := resourcesNotHealthyError{unhealthy: unhealthy}
+		reason := fluxMeta.ProgressingReason
+		if healthy := apimeta.FindStatusCondition(gitOpsSet.Status.Conditions, templatesv1.HealthyCondition); healthy != nil &&
+			healthy.Status == metav1.ConditionFalse && time.Since(healthy.LastTransitionTime.Time) > gitOpsSet.GetTimeout() {
+			healthErr.timeout = gitOpsSet.GetTimeout()
+			reason = templatesv1.HealthCheckFailedReason
+		}
+
+		templatesv1.SetGitOpsSetHealthiness(gitOpsSet, metav1.ConditionFalse, reason,
+			fmt.Sprintf("%d resources are not healthy: %s", len(unhealthy), strings.Join(unhealthy, ", ")))
+		return healthErr
+	}
+
+	templatesv1.SetGitOpsSetHealthiness(gitOpsSet, metav1.ConditionTrue, templatesv1.HealthCheckSucceededReason,
+		fmt.Sprintf("%d resources are healthy", len(inventory.Entries)))
+
+	return nil
+}
+
+// isReady returns false if the resource has a Ready condition that is not
+// True.
+//
+// kstatus only considers the Reconciling and Stalled conditions for custom
+// resources, and Flux resources can fail to reconcile without either.
+func isReady(u *unstructured.Unstructured) bool {
+	obj, err := status.GetObjectWithConditions(u.Object)
+	if err != nil {
+		return true
+	}
+
+	for _, cond := range obj.Status.Conditions {
+		if cond.Type == fluxMeta.ReadyCondition {
+			return cond.Status == corev1.ConditionTrue
+		}
+	}
+
+	return true
+}
+
+// unhealthyResources uses kstatus to compute the status of the resources in the
+// inventory, and returns the resources that are not current, along with their
+// status.
+func unhealthyResources(ctx context.Context, clients *clusterClients, inventory *templatesv1.ResourceInventory) ([]string, error) {
+	var unhealthy []string
+	for _, ref := range inventory.Entries {
+		u, err := unstructuredFromResourceRef(ref)
+		if err != nil {
+			return nil, err
+		}
+
+		k8sClient, err := clients.forResource(ctx, ref)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(u), u); err != nil {
+			if apierrors.IsNotFound(err) {
+				unhealthy = append(unhealthy, fmt.Sprintf("%s (%s)", ref.ID, status.NotFoundStatus))
+				continue
+			}
+
+			return nil, fmt.Errorf("failed to get %s: %w", ref.ID, err)
+		}
+
+		result, err := status.Compute(u)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compute status of %s: %w", ref.ID, err)
+		}
+
+		if result.Status == status.CurrentStatus && !isReady(u) {
+			result.Status = status.InProgressStatus
+		}
+
+		if result.Status != status.CurrentStatus {
+			unhealthy = append(unhealthy, fmt.Sprintf("%s (%s)", ref.ID, result.Status))
+		}
+	}
+
+	return unhealthy, nil
+}
@@ -0,0 +1,62 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	fluxMeta "github.com/fluxcd/pkg/apis/meta"
+	appsv1 "k8s.io/api/apps/v1"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	templatesv1 "github.com/weaveworks/gitopssets-controller/api/v1beta1"
+	"github.com/weaveworks/gitopssets-controller/test"
+)
+
+func TestCheckHealth(t *testing.T) {
+	unhealthy := &appsv1.Deployment{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "apps/v1", Kind: "Deployment"},
+		ObjectMeta: metav1.ObjectMeta{Name: "demo-deploy", Namespace: "default"},
+		Spec:       appsv1.DeploymentSpec{Replicas: ptr.To[int32](1)},
+	}
+	clients := &clusterClients{local: fake.NewClientBuilder().WithObjects(test.NewConfigMap(), unhealthy).Build()}
+	gs := &templatesv1.GitOpsSet{
+		Spec: templatesv1.GitOpsSetSpec{Wait: true, Timeout: &metav1.Duration{Duration: time.Minute}},
+	}
+	inventory := &templatesv1.ResourceInventory{
+		Entries: []templatesv1.ResourceRef{
+			mustResourceRef(t, test.NewConfigMap()),
+			mustResourceRef(t, unhealthy),
+		},
+	}
+	r := &GitOpsSetReconciler{}
+
+	err := r.checkHealth(context.TODO(), clients, gs, inventory)
+	test.AssertErrorMatch(t, `waiting for resources to become healthy: 1 resources are not healthy: default_demo-deploy_apps_Deployment \(InProgress\)`, err)
+	healthy := apimeta.FindStatusCondition(gs.Status.Conditions, templatesv1.HealthyCondition)
+	if healthy.Reason != fluxMeta.ProgressingReason {
+		t.Fatalf("got reason %s, want %s", healthy.Reason, fluxMeta.ProgressingReason)
+	}
+
+	// The health check fails once the resources have not been healthy for
+	// the timeout.
+	healthy.LastTransitionTime = metav1.NewTime(time.Now().Add(-2 * time.Minute))
+	err = r.checkHealth(context.TODO(), clients, gs, inventory)
+	test.AssertErrorMatch(t, `health check failed after 1m0s: 1 resources are not healthy`, err)
+	healthy = apimeta.FindStatusCondition(gs.Status.Conditions, templatesv1.HealthyCondition)
+	if healthy.Reason != templatesv1.HealthCheckFailedReason {
+		t.Fatalf("got reason %s, want %s", healthy.Reason, templatesv1.HealthCheckFailedReason)
+	}
+}
+
+func mustResourceRef(t *testing.T, obj runtime.Object) templatesv1.ResourceRef {
+	t.Helper()
+	ref, err := templatesv1.ResourceRefFromObject(obj)
+	test.AssertNoError(t, err)
+
+	return ref
+}
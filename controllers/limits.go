@@ -0,0 +1,53 @@
+package controllers
+
+import (
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	templatesv1 "github.com/weaveworks/gitopssets-controller/api/v1beta1"
+)
+
+// renderedResourcesGauge records the number of resources rendered for each
+// GitOpsSet, so that alerts can be raised before the limit is reached.
+var renderedResourcesGauge = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "gitopsset_rendered_resources",
+		Help: "The number of resources rendered by the last reconciliation of the GitOpsSet.",
+	},
+	[]string{"name", "namespace"},
+)
+
+func init() {
+	metrics.Registry.MustRegister(renderedResourcesGauge)
+}
+
+// recordRenderedResources records the number of resources rendered for the
+// GitOpsSet.
+func recordRenderedResources(gs *templatesv1.GitOpsSet, count int) {
+	renderedResourcesGauge.WithLabelValues(gs.GetName(), gs.GetNamespace()).Set(float64(count))
+}
+
+// deleteRenderedResources removes the metric for a GitOpsSet that is deleted.
+func deleteRenderedResources(gs *templatesv1.GitOpsSet) {
+	renderedResourcesGauge.DeleteLabelValues(gs.GetName(), gs.GetNamespace())
+}
+
+// checkRenderLimit returns an error if more resources were rendered than the
+// limit, the limit in the GitOpsSet overrides the limit for the controller.
+//
+// A limit of zero means that the number of resources is not limited.
+func checkRenderLimit(gs *templatesv1.GitOpsSet, controllerLimit, count int) error {
+	limit := controllerLimit
+	if gs.Spec.MaxRenderedResources != nil {
+		limit = int(*gs.Spec.MaxRenderedResources)
+	}
+
+	if limit == 0 || count <= limit {
+		return nil
+	}
+
+	return failedStage(templatesv1.RenderLimitExceededReason,
+		fmt.Errorf("rendered %d resources, which exceeds the limit of %d", count, limit))
+}
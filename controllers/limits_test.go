@@ -0,0 +1,90 @@
+package controllers
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	templatesv1 "github.com/weaveworks/gitopssets-controller/api/v1beta1"
+	"github.com/weaveworks/gitopssets-controller/test"
+)
+
+func TestCheckRenderLimit(t *testing.T) {
+	limitTests := []struct {
+		name            string
+		setLimit        *int32
+		controllerLimit int
+		count           int
+		wantErr         string
+	}{
+		{
+			name:  "no limits",
+			count: 10000,
+		},
+		{
+			name:            "below the controller limit",
+			controllerLimit: 10,
+			count:           10,
+		},
+		{
+			name:            "above the controller limit",
+			controllerLimit: 10,
+			count:           11,
+			wantErr:         "rendered 11 resources, which exceeds the limit of 10",
+		},
+		{
+			name:            "set limit overrides the controller limit",
+			setLimit:        int32Ptr(20),
+			controllerLimit: 10,
+			count:           15,
+		},
+		{
+			name:            "above the set limit",
+			setLimit:        int32Ptr(5),
+			controllerLimit: 10,
+			count:           6,
+			wantErr:         "rendered 6 resources, which exceeds the limit of 5",
+		},
+		{
+			name:            "set disables the limit",
+			setLimit:        int32Ptr(0),
+			controllerLimit: 10,
+			count:           100,
+		},
+	}
+
+	for _, tt := range limitTests {
+		t.Run(tt.name, func(t *testing.T) {
+			gs := &templatesv1.GitOpsSet{
+				Spec: templatesv1.GitOpsSetSpec{MaxRenderedResources: tt.setLimit},
+			}
+
+			err := checkRenderLimit(gs, tt.controllerLimit, tt.count)
+			if tt.wantErr == "" {
+				test.AssertNoError(t, err)
+				return
+			}
+			test.AssertErrorMatch(t, tt.wantErr, err)
+			if reason := failureReason(err); reason != templatesv1.RenderLimitExceededReason {
+				t.Fatalf("got reason %q, want %q", reason, templatesv1.RenderLimitExceededReason)
+			}
+		})
+	}
+}
+
+func TestRecordRenderedResources(t *testing.T) {
+	gs := &templatesv1.GitOpsSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "demo-set", Namespace: "default"},
+	}
+
+	recordRenderedResources(gs, 25)
+	if v := testutil.ToFloat64(renderedResourcesGauge.WithLabelValues("demo-set", "default")); v != 25 {
+		t.Fatalf("got %v rendered resources, want 25", v)
+	}
+
+	deleteRenderedResources(gs)
+	if n := testutil.CollectAndCount(renderedResourcesGauge); n != 0 {
+		t.Fatalf("got %d metrics after deletion, want 0", n)
+	}
+}
@@ -0,0 +1,49 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/util/csaupgrade"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// csaFieldManagers are the field managers whose client-side updates of
+// generated resources are upgraded to server-side apply.
+//
+// Only the controller's own field manager is upgraded, other controllers are
+// also called manager, and their fields must not be taken over.
+var csaFieldManagers = sets.New(fieldManager)
+
+// upgradeManagedFields moves the fields owned by the client-side updates of the
+// controller to its server-side apply field manager, and applies the desired
+// resource again.
+//
+// Without this, fields that are removed from a template would still be owned
+// by the client-side updates, and would not be removed from the resource.
+//
+// The applied resource is the resource returned when the desired resource was
+// applied, so resources that only have apply entries for the controller are
+// not read again.
+func upgradeManagedFields(ctx context.Context, k8sClient client.Client, desired, applied *unstructured.Unstructured, force bool) error {
+	patch, err := csaupgrade.UpgradeManagedFieldsPatch(applied, csaFieldManagers, fieldManager)
+	if err != nil {
+		return fmt.Errorf("failed to calculate managed fields upgrade: %w", err)
+	}
+	if patch == nil {
+		return nil
+	}
+
+	if err := k8sClient.Patch(ctx, applied, client.RawPatch(types.JSONPatchType, patch)); err != nil {
+		return fmt.Errorf("failed to upgrade managed fields: %w", err)
+	}
+
+	if err := applyResource(ctx, k8sClient, desired, force); err != nil {
+		return fmt.Errorf("failed to apply Resource with upgraded managed fields: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,101 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	"github.com/weaveworks/gitopssets-controller/test"
+)
+
+func TestUpgradeManagedFields(t *testing.T) {
+	upgradeTests := []struct {
+		name          string
+		managedFields []metav1.ManagedFieldsEntry
+		wantPatches   []types.PatchType
+	}{
+		{
+			name:          "only applied by the controller",
+			managedFields: []metav1.ManagedFieldsEntry{newManagedFieldsEntry(fieldManager, metav1.ManagedFieldsOperationApply)},
+		},
+		{
+			name: "updated by the controller",
+			managedFields: []metav1.ManagedFieldsEntry{
+				newManagedFieldsEntry(fieldManager, metav1.ManagedFieldsOperationApply),
+				newManagedFieldsEntry(fieldManager, metav1.ManagedFieldsOperationUpdate),
+			},
+			wantPatches: []types.PatchType{types.JSONPatchType, types.ApplyPatchType},
+		},
+		{
+			name: "updated by another controller",
+			managedFields: []metav1.ManagedFieldsEntry{
+				newManagedFieldsEntry(fieldManager, metav1.ManagedFieldsOperationApply),
+				newManagedFieldsEntry("manager", metav1.ManagedFieldsOperationUpdate),
+			},
+		},
+	}
+
+	for _, tt := range upgradeTests {
+		t.Run(tt.name, func(t *testing.T) {
+			cm := test.NewConfigMap()
+			cm.SetManagedFields(tt.managedFields)
+			cl, patches := newPatchRecordingClient(t, cm)
+
+			applied, err := runtime.DefaultUnstructuredConverter.ToUnstructured(cm)
+			test.AssertNoError(t, err)
+			desired := &unstructured.Unstructured{Object: map[string]any{
+				"apiVersion": "v1",
+				"kind":       "ConfigMap",
+				"metadata":   map[string]any{"name": cm.GetName(), "namespace": cm.GetNamespace()},
+			}}
+
+			test.AssertNoError(t, upgradeManagedFields(context.TODO(), cl, desired, &unstructured.Unstructured{Object: applied}, false))
+
+			if diff := cmp.Diff(tt.wantPatches, *patches); diff != "" {
+				t.Fatalf("failed to upgrade managed fields:\n%s", diff)
+			}
+		})
+	}
+}
+
+// newPatchRecordingClient returns a client that records the types of the
+// patches, apply patches are not supported by the fake client, and are only
+// recorded.
+func newPatchRecordingClient(t *testing.T, objs ...client.Object) (client.Client, *[]types.PatchType) {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	test.AssertNoError(t, clientgoscheme.AddToScheme(scheme))
+
+	var patches []types.PatchType
+	cl := fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).
+		WithInterceptorFuncs(interceptor.Funcs{
+			Patch: func(ctx context.Context, c client.WithWatch, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+				patches = append(patches, patch.Type())
+				if patch.Type() == types.ApplyPatchType {
+					return nil
+				}
+				return c.Patch(ctx, obj, patch, opts...)
+			},
+		}).Build()
+
+	return cl, &patches
+}
+
+func newManagedFieldsEntry(manager string, operation metav1.ManagedFieldsOperationType) metav1.ManagedFieldsEntry {
+	return metav1.ManagedFieldsEntry{
+		Manager:    manager,
+		Operation:  operation,
+		APIVersion: "v1",
+		FieldsType: "FieldsV1",
+		FieldsV1:   &metav1.FieldsV1{Raw: []byte(`{"f:data":{"f:testing":{}}}`)},
+	}
+}
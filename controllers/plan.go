@@ -0,0 +1,89 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/gitops-tools/pkg/sets"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	templatesv1 "github.com/weaveworks/gitopssets-controller/api/v1beta1"
+)
+
+// planResources calculates the changes that applying the rendered resources
+// would make to the resources in the inventory, without changing anything.
+func planResources(ctx context.Context, clients *clusterClients, gitOpsSet *templatesv1.GitOpsSet, resources []*unstructured.Unstructured) (*templatesv1.GitOpsSetPlan, error) {
+	existingEntries := sets.New[templatesv1.ResourceRef]()
+	if gitOpsSet.Status.Inventory != nil {
+		existingEntries.Insert(gitOpsSet.Status.Inventory.Entries...)
+	}
+
+	var planErr error
+	creates := sets.New[templatesv1.ResourceRef]()
+	updates := sets.New[templatesv1.ResourceRef]()
+	entries := sets.New[templatesv1.ResourceRef]()
+	for _, newResource := range resources {
+		ref, err := resourceRefFromObject(newResource)
+		if err != nil {
+			planErr = errors.Join(planErr, fmt.Errorf("failed to update inventory: %w", err))
+			continue
+		}
+		entries.Insert(ref)
+
+		k8sClient, err := clients.forResource(ctx, ref)
+		if err != nil {
+			planErr = errors.Join(planErr, err)
+			continue
+		}
+
+		if driftDetectionEnabled(gitOpsSet) {
+			if err := addRenderedDigest(newResource); err != nil {
+				planErr = errors.Join(planErr, err)
+				continue
+			}
+		}
+
+		if !existingEntries.Has(ref) {
+			if err := k8sClient.Create(ctx, newResource.DeepCopy(), client.DryRunAll, client.FieldOwner(fieldManager)); err != nil {
+				planErr = errors.Join(planErr, fmt.Errorf("failed to plan creation of Resource: %w", err))
+				continue
+			}
+			creates.Insert(ref)
+			continue
+		}
+
+		existing := &unstructured.Unstructured{}
+		existing.SetGroupVersionKind(newResource.GroupVersionKind())
+		if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(newResource), existing); err != nil {
+			if apierrors.IsNotFound(err) {
+				creates.Insert(ref)
+				continue
+			}
+			planErr = errors.Join(planErr, fmt.Errorf("failed to plan update of Resource: %w", err))
+			continue
+		}
+
+		changed, err := resourceChanged(ctx, k8sClient, existing, newResource, gitOpsSet.Spec.ForceConflicts)
+		if err != nil {
+			planErr = errors.Join(planErr, fmt.Errorf("failed to plan update of Resource: %w", err))
+			continue
+		}
+		if changed {
+			updates.Insert(ref)
+		}
+	}
+
+	return &templatesv1.GitOpsSetPlan{
+		Create: creates.SortedList(compareResourceRefs),
+		Update: updates.SortedList(compareResourceRefs),
+		Delete: existingEntries.Difference(entries).SortedList(compareResourceRefs),
+	}, planErr
+}
+
+func planSummary(plan *templatesv1.GitOpsSetPlan) string {
+	return fmt.Sprintf("plan has %d resources to create, %d to update and %d to delete",
+		len(plan.Create), len(plan.Update), len(plan.Delete))
+}
@@ -0,0 +1,119 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"golang.org/x/exp/slices"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	templatesv1 "github.com/weaveworks/gitopssets-controller/api/v1beta1"
+)
+
+// checkPolicies returns an error listing each of the rendered resources that
+// is not allowed by the GitOpsSetPolicies that apply to the GitOpsSet.
+func (r *GitOpsSetReconciler) checkPolicies(ctx context.Context, gitOpsSet *templatesv1.GitOpsSet, resources []*unstructured.Unstructured) error {
+	var policies templatesv1.GitOpsSetPolicyList
+	if err := r.List(ctx, &policies); err != nil {
+		return fmt.Errorf("failed to list GitOpsSetPolicies: %w", err)
+	}
+
+	violations := policyViolations(policies.Items, gitOpsSet, resources)
+	if len(violations) == 0 {
+		return nil
+	}
+
+	return failedStage(templatesv1.PolicyViolationReason,
+		fmt.Errorf("rendered resources are not allowed by policy: %s", strings.Join(violations, "; ")))
+}
+
+// policyToGitOpsSets maps a GitOpsSetPolicy to the GitOpsSets in the
+// namespaces that it applies to.
+func (r *GitOpsSetReconciler) policyToGitOpsSets(ctx context.Context, obj client.Object) []reconcile.Request {
+	policy, ok := obj.(*templatesv1.GitOpsSetPolicy)
+	if !ok {
+		return nil
+	}
+
+	var list templatesv1.GitOpsSetList
+	if err := r.inShard(r.Client).List(ctx, &list); err != nil {
+		return nil
+	}
+
+	result := []reconcile.Request{}
+	for i := range list.Items {
+		if policyApplies(policy, list.Items[i].GetNamespace()) {
+			result = append(result, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&list.Items[i])})
+		}
+	}
+
+	return result
+}
+
+// policyViolations returns a description of each rendered resource that is
+// not allowed by a policy that applies to the GitOpsSet.
+func policyViolations(policies []templatesv1.GitOpsSetPolicy, gitOpsSet *templatesv1.GitOpsSet, resources []*unstructured.Unstructured) []string {
+	var violations []string
+	for i := range policies {
+		policy := &policies[i]
+		if !policyApplies(policy, gitOpsSet.GetNamespace()) {
+			continue
+		}
+
+		for _, res := range resources {
+			gk := res.GroupVersionKind().GroupKind()
+			id := gk.Kind + " " + res.GetName()
+			if ns := res.GetNamespace(); ns != "" {
+				id = gk.Kind + " " + ns + "/" + res.GetName()
+			}
+
+			if !kindAllowed(policy.Spec, gk) {
+				violations = append(violations, fmt.Sprintf("%s: kind %s is not allowed by GitOpsSetPolicy %s", id, gk, policy.GetName()))
+				continue
+			}
+
+			if !targetNamespaceAllowed(policy.Spec, res.GetNamespace(), gitOpsSet.GetNamespace()) {
+				violations = append(violations, fmt.Sprintf("%s: namespace %s is not allowed by GitOpsSetPolicy %s", id, res.GetNamespace(), policy.GetName()))
+			}
+		}
+	}
+
+	return violations
+}
+
+func policyApplies(policy *templatesv1.GitOpsSetPolicy, namespace string) bool {
+	return len(policy.Spec.SourceNamespaces) == 0 || slices.Contains(policy.Spec.SourceNamespaces, namespace)
+}
+
+func kindAllowed(spec templatesv1.GitOpsSetPolicySpec, gk schema.GroupKind) bool {
+	if kindMatches(spec.DeniedKinds, gk) {
+		return false
+	}
+
+	return len(spec.AllowedKinds) == 0 || kindMatches(spec.AllowedKinds, gk)
+}
+
+func kindMatches(kinds []templatesv1.GroupKind, gk schema.GroupKind) bool {
+	for _, kind := range kinds {
+		if kind.Group == gk.Group && (kind.Kind == "*" || kind.Kind == gk.Kind) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// targetNamespaceAllowed returns true if resources can be generated in the
+// namespace, cluster-scoped resources have no namespace and are only limited
+// by their kind.
+func targetNamespaceAllowed(spec templatesv1.GitOpsSetPolicySpec, namespace, sourceNamespace string) bool {
+	if namespace == "" || namespace == sourceNamespace {
+		return true
+	}
+
+	return slices.Contains(spec.TargetNamespaces, "*") || slices.Contains(spec.TargetNamespaces, namespace)
+}
@@ -0,0 +1,144 @@
+package controllers
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	templatesv1 "github.com/weaveworks/gitopssets-controller/api/v1beta1"
+)
+
+func TestPolicyViolations(t *testing.T) {
+	configMap := makeTestResource("v1", "ConfigMap", "default", "test-cm")
+	remoteConfigMap := makeTestResource("v1", "ConfigMap", "kube-system", "test-cm")
+	clusterRole := makeTestResource("rbac.authorization.k8s.io/v1", "ClusterRole", "", "admin")
+
+	policyTests := []struct {
+		name      string
+		policies  []templatesv1.GitOpsSetPolicySpec
+		resources []*unstructured.Unstructured
+		want      []string
+	}{
+		{
+			name:      "no policies",
+			resources: []*unstructured.Unstructured{configMap, remoteConfigMap, clusterRole},
+		},
+		{
+			name: "policy for another namespace",
+			policies: []templatesv1.GitOpsSetPolicySpec{
+				{SourceNamespaces: []string{"team-a"}, DeniedKinds: []templatesv1.GroupKind{{Kind: "ConfigMap"}}},
+			},
+			resources: []*unstructured.Unstructured{configMap},
+		},
+		{
+			name: "denied kind",
+			policies: []templatesv1.GitOpsSetPolicySpec{
+				{DeniedKinds: []templatesv1.GroupKind{{Group: "rbac.authorization.k8s.io", Kind: "ClusterRole"}}},
+			},
+			resources: []*unstructured.Unstructured{configMap, clusterRole},
+			want: []string{
+				"ClusterRole admin: kind ClusterRole.rbac.authorization.k8s.io is not allowed by GitOpsSetPolicy test-policy-0",
+			},
+		},
+		{
+			name: "all kinds in a group denied",
+			policies: []templatesv1.GitOpsSetPolicySpec{
+				{DeniedKinds: []templatesv1.GroupKind{{Group: "rbac.authorization.k8s.io", Kind: "*"}}},
+			},
+			resources: []*unstructured.Unstructured{clusterRole},
+			want: []string{
+				"ClusterRole admin: kind ClusterRole.rbac.authorization.k8s.io is not allowed by GitOpsSetPolicy test-policy-0",
+			},
+		},
+		{
+			name: "kind that isn't allowed",
+			policies: []templatesv1.GitOpsSetPolicySpec{
+				{AllowedKinds: []templatesv1.GroupKind{{Kind: "ConfigMap"}}},
+			},
+			resources: []*unstructured.Unstructured{configMap, clusterRole},
+			want: []string{
+				"ClusterRole admin: kind ClusterRole.rbac.authorization.k8s.io is not allowed by GitOpsSetPolicy test-policy-0",
+			},
+		},
+		{
+			name: "denied kinds take precedence",
+			policies: []templatesv1.GitOpsSetPolicySpec{
+				{
+					AllowedKinds: []templatesv1.GroupKind{{Kind: "*"}},
+					DeniedKinds:  []templatesv1.GroupKind{{Kind: "ConfigMap"}},
+				},
+			},
+			resources: []*unstructured.Unstructured{configMap},
+			want: []string{
+				"ConfigMap default/test-cm: kind ConfigMap is not allowed by GitOpsSetPolicy test-policy-0",
+			},
+		},
+		{
+			name: "namespace of the GitOpsSet",
+			policies: []templatesv1.GitOpsSetPolicySpec{
+				{SourceNamespaces: []string{"default"}},
+			},
+			resources: []*unstructured.Unstructured{configMap, remoteConfigMap, clusterRole},
+			want: []string{
+				"ConfigMap kube-system/test-cm: namespace kube-system is not allowed by GitOpsSetPolicy test-policy-0",
+			},
+		},
+		{
+			name: "allowed target namespace",
+			policies: []templatesv1.GitOpsSetPolicySpec{
+				{TargetNamespaces: []string{"kube-system"}},
+			},
+			resources: []*unstructured.Unstructured{configMap, remoteConfigMap},
+		},
+		{
+			name: "all target namespaces",
+			policies: []templatesv1.GitOpsSetPolicySpec{
+				{TargetNamespaces: []string{"*"}},
+			},
+			resources: []*unstructured.Unstructured{configMap, remoteConfigMap},
+		},
+		{
+			name: "all policies must allow resources",
+			policies: []templatesv1.GitOpsSetPolicySpec{
+				{TargetNamespaces: []string{"*"}},
+				{TargetNamespaces: []string{"*"}, DeniedKinds: []templatesv1.GroupKind{{Kind: "ConfigMap"}}},
+			},
+			resources: []*unstructured.Unstructured{remoteConfigMap},
+			want: []string{
+				"ConfigMap kube-system/test-cm: kind ConfigMap is not allowed by GitOpsSetPolicy test-policy-1",
+			},
+		},
+	}
+
+	for _, tt := range policyTests {
+		t.Run(tt.name, func(t *testing.T) {
+			var policies []templatesv1.GitOpsSetPolicy
+			for i, spec := range tt.policies {
+				policies = append(policies, templatesv1.GitOpsSetPolicy{
+					ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("test-policy-%d", i)},
+					Spec:       spec,
+				})
+			}
+			gs := &templatesv1.GitOpsSet{
+				ObjectMeta: metav1.ObjectMeta{Name: "demo-set", Namespace: "default"},
+			}
+
+			if diff := cmp.Diff(tt.want, policyViolations(policies, gs, tt.resources)); diff != "" {
+				t.Fatalf("failed to check policies:\n%s", diff)
+			}
+		})
+	}
+}
+
+func makeTestResource(apiVersion, kind, namespace, name string) *unstructured.Unstructured {
+	u := &unstructured.Unstructured{}
+	u.SetAPIVersion(apiVersion)
+	u.SetKind(kind)
+	u.SetNamespace(namespace)
+	u.SetName(name)
+
+	return u
+}
@@ -0,0 +1,66 @@
+package controllers
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"strings"
+
+	"github.com/gitops-tools/pkg/sets"
+
+	templatesv1 "github.com/weaveworks/gitopssets-controller/api/v1beta1"
+)
+
+// ApproveDeletionsAnnotation can be added to a GitOpsSet to approve deletions
+// that are blocked by the prune protection.
+//
+// The value must match the token in the PruneBlocked condition message, which
+// identifies the set of resources to be deleted.
+const ApproveDeletionsAnnotation = "templates.weave.works/approve-deletions"
+
+// PruneBlockedError is returned when a reconciliation would delete more
+// resources than the prune protection allows.
+type PruneBlockedError struct {
+	Deletions int
+	Total     int
+	Token     string
+}
+
+func (e PruneBlockedError) Error() string {
+	return fmt.Sprintf("reconciliation would delete %d of %d resources which exceeds the prune protection limits, annotate with %s=%s to approve the deletions",
+		e.Deletions, e.Total, ApproveDeletionsAnnotation, e.Token)
+}
+
+// checkPruneProtection returns a PruneBlockedError if deleting the resources
+// would exceed the prune protection limits on the GitOpsSet and the deletions
+// have not been approved.
+func checkPruneProtection(gitOpsSet *templatesv1.GitOpsSet, existing sets.Set[templatesv1.ResourceRef], deletions []templatesv1.ResourceRef) error {
+	protection := gitOpsSet.Spec.PruneProtection
+	if protection == nil || len(deletions) == 0 {
+		return nil
+	}
+
+	exceeded := protection.MaxDeletions != nil && len(deletions) > int(*protection.MaxDeletions)
+	if protection.MaxDeletionPercent != nil && existing.Len() > 0 {
+		exceeded = exceeded || len(deletions)*100 > int(*protection.MaxDeletionPercent)*existing.Len()
+	}
+	if !exceeded {
+		return nil
+	}
+
+	token := deletionsToken(deletions)
+	if gitOpsSet.GetAnnotations()[ApproveDeletionsAnnotation] == token {
+		return nil
+	}
+
+	return PruneBlockedError{Deletions: len(deletions), Total: existing.Len(), Token: token}
+}
+
+// deletionsToken returns a short digest that identifies the set of deletions.
+func deletionsToken(deletions []templatesv1.ResourceRef) string {
+	ids := make([]string, len(deletions))
+	for i := range deletions {
+		ids[i] = deletions[i].ID
+	}
+
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(strings.Join(ids, "\n"))))[:12]
+}
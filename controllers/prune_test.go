@@ -0,0 +1,92 @@
+package controllers
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/gitops-tools/pkg/sets"
+
+	templatesv1 "github.com/weaveworks/gitopssets-controller/api/v1beta1"
+	"github.com/weaveworks/gitopssets-controller/test"
+)
+
+func TestCheckPruneProtection(t *testing.T) {
+	existing := sets.New[templatesv1.ResourceRef]()
+	for i := 0; i < 10; i++ {
+		existing.Insert(templatesv1.ResourceRef{ID: fmt.Sprintf("default_test-%d__ConfigMap", i), Version: "v1"})
+	}
+	deletions := existing.SortedList(func(x, y templatesv1.ResourceRef) bool {
+		return x.ID < y.ID
+	})[:3]
+
+	checkTests := []struct {
+		name        string
+		protection  *templatesv1.PruneProtection
+		annotations map[string]string
+		deletions   []templatesv1.ResourceRef
+		wantErr     string
+	}{
+		{
+			name:      "no prune protection",
+			deletions: deletions,
+		},
+		{
+			name:       "no deletions",
+			protection: &templatesv1.PruneProtection{MaxDeletions: int32Ptr(0)},
+		},
+		{
+			name:       "within max deletions",
+			protection: &templatesv1.PruneProtection{MaxDeletions: int32Ptr(3)},
+			deletions:  deletions,
+		},
+		{
+			name:       "exceeds max deletions",
+			protection: &templatesv1.PruneProtection{MaxDeletions: int32Ptr(2)},
+			deletions:  deletions,
+			wantErr:    "would delete 3 of 10 resources",
+		},
+		{
+			name:       "within max deletion percent",
+			protection: &templatesv1.PruneProtection{MaxDeletionPercent: int32Ptr(30)},
+			deletions:  deletions,
+		},
+		{
+			name:       "exceeds max deletion percent",
+			protection: &templatesv1.PruneProtection{MaxDeletionPercent: int32Ptr(25)},
+			deletions:  deletions,
+			wantErr:    "would delete 3 of 10 resources",
+		},
+		{
+			name:        "exceeded and approved",
+			protection:  &templatesv1.PruneProtection{MaxDeletions: int32Ptr(2)},
+			annotations: map[string]string{ApproveDeletionsAnnotation: deletionsToken(deletions)},
+			deletions:   deletions,
+		},
+		{
+			name:        "exceeded and approved for different deletions",
+			protection:  &templatesv1.PruneProtection{MaxDeletions: int32Ptr(2)},
+			annotations: map[string]string{ApproveDeletionsAnnotation: deletionsToken(deletions[:2])},
+			deletions:   deletions,
+			wantErr:     "annotate with templates.weave.works/approve-deletions=" + deletionsToken(deletions),
+		},
+	}
+
+	for _, tt := range checkTests {
+		t.Run(tt.name, func(t *testing.T) {
+			gs := &templatesv1.GitOpsSet{}
+			gs.SetAnnotations(tt.annotations)
+			gs.Spec.PruneProtection = tt.protection
+
+			err := checkPruneProtection(gs, existing, tt.deletions)
+			if tt.wantErr == "" {
+				test.AssertNoError(t, err)
+				return
+			}
+			test.AssertErrorMatch(t, tt.wantErr, err)
+		})
+	}
+}
+
+func int32Ptr(i int32) *int32 {
+	return &i
+}
@@ -0,0 +1,44 @@
+package controllers
+
+import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	templatesv1 "github.com/weaveworks/gitopssets-controller/api/v1beta1"
+)
+
+// shardReader only lists the GitOpsSets that match the selector, so that the
+// objects that GitOpsSets depend on only queue the GitOpsSets in the shard.
+type shardReader struct {
+	client.Reader
+	selector labels.Selector
+}
+
+// List is an implementation of the client.Reader interface.
+func (r shardReader) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	if _, ok := list.(*templatesv1.GitOpsSetList); ok {
+		opts = append(opts, client.MatchingLabelsSelector{Selector: r.selector})
+	}
+
+	return r.Reader.List(ctx, list, opts...)
+}
+
+// inShard returns a reader that only lists the GitOpsSets in the shard that
+// the controller reconciles.
+func (r *GitOpsSetReconciler) inShard(c client.Reader) client.Reader {
+	if r.WatchLabelSelector == nil || r.WatchLabelSelector.Empty() {
+		return c
+	}
+
+	return shardReader{Reader: c, selector: r.WatchLabelSelector}
+}
+
+// shardPredicate filters out events for GitOpsSets that are not in the shard.
+func (r *GitOpsSetReconciler) shardPredicate() predicate.Predicate {
+	return predicate.NewPredicateFuncs(func(obj client.Object) bool {
+		return r.WatchLabelSelector == nil || r.WatchLabelSelector.Matches(labels.Set(obj.GetLabels()))
+	})
+}
@@ -0,0 +1,142 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	sourcev1 "github.com/fluxcd/source-controller/api/v1beta2"
+	"github.com/go-logr/logr"
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	templatesv1 "github.com/weaveworks/gitopssets-controller/api/v1beta1"
+	"github.com/weaveworks/gitopssets-controller/controllers/templates/generators"
+	"github.com/weaveworks/gitopssets-controller/controllers/templates/generators/gitrepository"
+	"github.com/weaveworks/gitopssets-controller/test"
+)
+
+const shardKey = "sharding.fluxcd.io/key"
+
+func TestDependencyToGitOpsSet_sharding(t *testing.T) {
+	shardingTests := []struct {
+		name     string
+		selector string
+		want     []string
+	}{
+		{
+			name: "no selector",
+			want: []string{"shard1-set", "shard2-set", "unsharded-set"},
+		},
+		{
+			name:     "shard",
+			selector: shardKey + "=shard1",
+			want:     []string{"shard1-set"},
+		},
+		{
+			name:     "unsharded GitOpsSets",
+			selector: "!" + shardKey,
+			want:     []string{"unsharded-set"},
+		},
+	}
+
+	for _, tt := range shardingTests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newShardedReconciler(t, tt.selector)
+
+			repo := test.NewGitRepository()
+			requests := r.dependencyToGitOpsSet("GitRepository")(context.TODO(), repo)
+
+			if diff := cmp.Diff(tt.want, requestNames(requests)); diff != "" {
+				t.Fatalf("failed to map GitRepository to GitOpsSets:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestPolicyToGitOpsSets_sharding(t *testing.T) {
+	r := newShardedReconciler(t, shardKey+"=shard2")
+
+	requests := r.policyToGitOpsSets(context.TODO(), &templatesv1.GitOpsSetPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-policy"},
+	})
+
+	if diff := cmp.Diff([]string{"shard2-set"}, requestNames(requests)); diff != "" {
+		t.Fatalf("failed to map GitOpsSetPolicy to GitOpsSets:\n%s", diff)
+	}
+}
+
+func TestShardPredicate(t *testing.T) {
+	r := newShardedReconciler(t, shardKey+"=shard1")
+	p := r.shardPredicate()
+
+	if !p.Create(event.CreateEvent{Object: newShardedGitOpsSet("test-set", "shard1")}) {
+		t.Error("expected GitOpsSet in the shard to be reconciled")
+	}
+	if p.Create(event.CreateEvent{Object: newShardedGitOpsSet("test-set", "shard2")}) {
+		t.Error("expected GitOpsSet in another shard to be ignored")
+	}
+	if p.Create(event.CreateEvent{Object: newShardedGitOpsSet("test-set", "")}) {
+		t.Error("expected unsharded GitOpsSet to be ignored")
+	}
+}
+
+func newShardedReconciler(t *testing.T, selector string) *GitOpsSetReconciler {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	test.AssertNoError(t, templatesv1.AddToScheme(scheme))
+	test.AssertNoError(t, sourcev1.AddToScheme(scheme))
+
+	enabledGenerators := map[string]generators.Generator{
+		"GitRepository": gitrepository.NewGenerator(logr.Discard(), nil, nil),
+	}
+	cl := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithIndex(&templatesv1.GitOpsSet{}, dependencyIndexKey("GitRepository"), indexDependencies("GitRepository", enabledGenerators)).
+		WithObjects(
+			newShardedGitOpsSet("shard1-set", "shard1"),
+			newShardedGitOpsSet("shard2-set", "shard2"),
+			newShardedGitOpsSet("unsharded-set", ""),
+		).
+		Build()
+
+	parsed, err := labels.Parse(selector)
+	test.AssertNoError(t, err)
+
+	return &GitOpsSetReconciler{
+		Client:             cl,
+		Scheme:             scheme,
+		WatchLabelSelector: parsed,
+	}
+}
+
+func newShardedGitOpsSet(name, shard string) *templatesv1.GitOpsSet {
+	gs := &templatesv1.GitOpsSet{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Spec: templatesv1.GitOpsSetSpec{
+			Generators: []templatesv1.GitOpsSetGenerator{
+				{
+					GitRepository: &templatesv1.GitRepositoryGenerator{RepositoryRef: "test-repository"},
+				},
+			},
+		},
+	}
+	if shard != "" {
+		gs.SetLabels(map[string]string{shardKey: shard})
+	}
+
+	return gs
+}
+
+func requestNames(requests []reconcile.Request) []string {
+	names := []string{}
+	for _, req := range requests {
+		names = append(names, req.Name)
+	}
+
+	return names
+}
@@ -0,0 +1,72 @@
+package templates
+
+import (
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	templatesv1 "github.com/weaveworks/gitopssets-controller/api/v1beta1"
+	"github.com/weaveworks/gitopssets-controller/controllers/templates/generators"
+	"github.com/weaveworks/gitopssets-controller/pkg/registry"
+)
+
+// generatorStatus returns the status of a generator.
+//
+// If the generator failed, the time it last successfully generated elements is
+// copied from the previous status of the generator.
+func generatorStatus(previous []templatesv1.GeneratorStatus, index int, name, generatorType string, elements int, revision string, err error) templatesv1.GeneratorStatus {
+	status := templatesv1.GeneratorStatus{
+		Index:    index,
+		Name:     name,
+		Type:     generatorType,
+		Elements: elements,
+		Revision: revision,
+	}
+
+	if err == nil {
+		now := metav1.Now()
+		status.LastGenerated = &now
+		return status
+	}
+
+	status.Error = err.Error()
+	for _, p := range previous {
+		if p.Index == index && p.Name == name && p.Type == generatorType {
+			status.LastGenerated = p.LastGenerated
+			break
+		}
+	}
+
+	return status
+}
+
+// previousReport returns a report for the generator at index with the
+// revisions recorded in the previous statuses.
+func previousReport(previous []templatesv1.GeneratorStatus, index int) *generators.GenerationReport {
+	report := &generators.GenerationReport{PreviousNested: map[string]string{}}
+	for _, p := range previous {
+		if p.Index != index {
+			continue
+		}
+		if p.Name == "" {
+			report.PreviousRevision = p.Revision
+			continue
+		}
+		report.PreviousNested[p.Name] = p.Revision
+	}
+
+	return report
+}
+
+func generatorType(gen templatesv1.GitOpsSetGenerator) string {
+	return strings.Join(registry.Default.GeneratorTypes(&gen), ",")
+}
+
+func countElements(generated [][]map[string]any) int {
+	count := 0
+	for _, params := range generated {
+		count += len(params)
+	}
+
+	return count
+}
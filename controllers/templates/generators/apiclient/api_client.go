@@ -12,7 +12,7 @@ import (
 	"time"
 
 	"github.com/go-logr/logr"
-	templatesv1 "github.com/weaveworks/gitopssets-controller/api/v1alpha1"
+	templatesv1 "github.com/weaveworks/gitopssets-controller/api/v1beta1"
 	"github.com/weaveworks/gitopssets-controller/controllers/templates/generators"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/client-go/util/jsonpath"
@@ -100,8 +100,9 @@ func (g *APIClientGenerator) Generate(ctx context.Context, sg *templatesv1.GitOp
 	// Anything 400+ is an error?
 	if resp.StatusCode >= http.StatusBadRequest {
 		g.Logger.Info("failed to fetch endpoint", "endpoint", sg.APIClient.Endpoint, "statusCode", resp.StatusCode, "response", string(body))
-		return nil, fmt.Errorf("got %d response from endpoint %s", resp.StatusCode, sg.APIClient.Endpoint)
+		return nil, generators.HTTPStatusError{StatusCode: resp.StatusCode, Endpoint: sg.APIClient.Endpoint}
 	}
+	generators.RecordRevision(ctx, resp.Header.Get("ETag"))
 
 	if sg.APIClient.JSONPath == "" {
 		if sg.APIClient.SingleElement {
@@ -121,6 +122,33 @@ func (g *APIClientGenerator) Interval(sg *templatesv1.GitOpsSetGenerator) time.D
 	return sg.APIClient.Interval.Duration
 }
 
+// DependencyKinds is an implementation of the generators.DependentGenerator
+// interface.
+func (g *APIClientGenerator) DependencyKinds() []client.Object {
+	return []client.Object{&corev1.ConfigMap{}, &corev1.Secret{}}
+}
+
+// Dependencies is an implementation of the generators.DependentGenerator
+// interface.
+//
+// Changes to the headers or the TLS Secret trigger a new request before the
+// next poll.
+func (g *APIClientGenerator) Dependencies(sg *templatesv1.GitOpsSetGenerator, gsg *templatesv1.GitOpsSet) []generators.Dependency {
+	if sg.APIClient == nil {
+		return nil
+	}
+
+	var dependencies []generators.Dependency
+	if ref := sg.APIClient.HeadersRef; ref != nil {
+		dependencies = append(dependencies, generators.Dependency{Kind: ref.Kind, ObjectKey: client.ObjectKey{Name: ref.Name, Namespace: gsg.GetNamespace()}})
+	}
+	if tlsConfig := sg.APIClient.TLS; tlsConfig != nil {
+		dependencies = append(dependencies, generators.Dependency{Kind: "Secret", ObjectKey: client.ObjectKey{Name: tlsConfig.CASecretRef.Name, Namespace: gsg.GetNamespace()}})
+	}
+
+	return dependencies
+}
+
 func (g *APIClientGenerator) createRequest(ctx context.Context, ac *templatesv1.APIClientGenerator, namespace string) (*http.Request, error) {
 	method := ac.Method
 	if ac.Body != nil {
@@ -154,12 +182,12 @@ func (g *APIClientGenerator) createRequest(ctx context.Context, ac *templatesv1.
 }
 
 func (g *APIClientGenerator) createTLSConfig(ctx context.Context, ac *templatesv1.APIClientGenerator, namespace string) (*tls.Config, error) {
-	if ac.SecretRef == nil {
+	if ac.TLS == nil {
 		return nil, nil
 	}
 
 	var s corev1.Secret
-	name := client.ObjectKey{Name: ac.SecretRef.Name, Namespace: namespace}
+	name := client.ObjectKey{Name: ac.TLS.CASecretRef.Name, Namespace: namespace}
 	if err := g.Client.Get(ctx, name, &s); err != nil {
 		return nil, fmt.Errorf("failed to load Secret for API Client Generator %s: %w", name, err)
 	}
@@ -22,12 +22,13 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 
-	templatesv1 "github.com/weaveworks/gitopssets-controller/api/v1alpha1"
+	templatesv1 "github.com/weaveworks/gitopssets-controller/api/v1beta1"
 	"github.com/weaveworks/gitopssets-controller/controllers/templates/generators"
 	"github.com/weaveworks/gitopssets-controller/test"
 )
 
 var _ generators.Generator = (*APIClientGenerator)(nil)
+var _ generators.DependentGenerator = (*APIClientGenerator)(nil)
 
 func TestGenerate_with_no_generator(t *testing.T) {
 	gen := GeneratorFactory(DefaultClientFactory)(logr.Discard(), nil)
@@ -190,8 +191,10 @@ func TestGenerate(t *testing.T) {
 			apiClient: &templatesv1.APIClientGenerator{
 				Endpoint: ts.URL + "/api/get-testing",
 				Method:   http.MethodGet,
-				SecretRef: &corev1.LocalObjectReference{
-					Name: "https-ca-credentials",
+				TLS: &templatesv1.APIClientTLS{
+					CASecretRef: corev1.LocalObjectReference{
+						Name: "https-ca-credentials",
+					},
 				},
 			},
 			objs: []runtime.Object{newTestSecret(func(s *corev1.Secret) {
@@ -271,6 +274,13 @@ func TestGenerate_errors(t *testing.T) {
 			},
 			wantErr: fmt.Sprintf("got 404 response from endpoint %s", ts.URL+"/unknown"),
 		},
+		{
+			name: "endpoint returning 503",
+			apiClient: &templatesv1.APIClientGenerator{
+				Endpoint: ts.URL + "/api/unavailable",
+			},
+			wantErr: fmt.Sprintf("got 503 response from endpoint %s", ts.URL+"/api/unavailable"),
+		},
 		{
 			name: "invalid JSON response",
 			apiClient: &templatesv1.APIClientGenerator{
@@ -370,6 +380,41 @@ func TestGenerate_errors(t *testing.T) {
 	}
 }
 
+func TestGenerate_recordsETag(t *testing.T) {
+	ts := httptest.NewTLSServer(newTestMux(t))
+	defer ts.Close()
+
+	factory := func(_ *tls.Config) *http.Client {
+		return ts.Client()
+	}
+	gen := GeneratorFactory(factory)(logr.Discard(), newFakeClient(t))
+	gsg := templatesv1.GitOpsSetGenerator{
+		APIClient: &templatesv1.APIClientGenerator{
+			Endpoint: ts.URL + "/api/etag-testing",
+			Method:   http.MethodGet,
+		},
+	}
+
+	report := &generators.GenerationReport{}
+	_, err := gen.Generate(generators.WithReport(context.TODO(), report), &gsg,
+		&templatesv1.GitOpsSet{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "demo-set",
+				Namespace: "default",
+			},
+			Spec: templatesv1.GitOpsSetSpec{
+				Generators: []templatesv1.GitOpsSetGenerator{
+					gsg,
+				},
+			},
+		})
+	test.AssertNoError(t, err)
+
+	if report.Revision != `"test-etag"` {
+		t.Fatalf("got revision %q, want %q", report.Revision, `"test-etag"`)
+	}
+}
+
 func TestAPIClientGenerator_GetInterval(t *testing.T) {
 	interval := time.Minute * 10
 	gen := NewGenerator(logr.Discard(), fake.NewFakeClient(), DefaultClientFactory)
@@ -446,6 +491,11 @@ func newTestMux(t *testing.T) *http.ServeMux {
 		}
 	})
 
+	mux.HandleFunc("/api/etag-testing", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"test-etag"`)
+		writeResponse(w)
+	})
+
 	mux.HandleFunc("/api/get-testing", func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodGet {
 			http.Error(w, "wrong test endpoint", http.StatusMethodNotAllowed)
@@ -510,9 +560,60 @@ func newTestMux(t *testing.T) *http.ServeMux {
 		w.Write([]byte(`{`))
 	})
 
+	mux.HandleFunc("/api/unavailable", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	})
+
 	return mux
 }
 
+func TestAPIClientGenerator_Dependencies(t *testing.T) {
+	gen := NewGenerator(logr.Discard(), nil, DefaultClientFactory)
+	gs := &templatesv1.GitOpsSet{ObjectMeta: metav1.ObjectMeta{Name: "demo-set", Namespace: "default"}}
+
+	dependencyTests := []struct {
+		name      string
+		generator *templatesv1.APIClientGenerator
+		want      []generators.Dependency
+	}{
+		{
+			name:      "no references",
+			generator: &templatesv1.APIClientGenerator{Endpoint: "https://example.com/api"},
+		},
+		{
+			name: "headers from a ConfigMap and a TLS Secret",
+			generator: &templatesv1.APIClientGenerator{
+				Endpoint:   "https://example.com/api",
+				HeadersRef: &templatesv1.HeadersReference{Kind: "ConfigMap", Name: "test-headers"},
+				TLS:        &templatesv1.APIClientTLS{CASecretRef: corev1.LocalObjectReference{Name: "test-tls"}},
+			},
+			want: []generators.Dependency{
+				{Kind: "ConfigMap", ObjectKey: client.ObjectKey{Name: "test-headers", Namespace: "default"}},
+				{Kind: "Secret", ObjectKey: client.ObjectKey{Name: "test-tls", Namespace: "default"}},
+			},
+		},
+		{
+			name: "headers from a Secret",
+			generator: &templatesv1.APIClientGenerator{
+				Endpoint:   "https://example.com/api",
+				HeadersRef: &templatesv1.HeadersReference{Kind: "Secret", Name: "test-headers"},
+			},
+			want: []generators.Dependency{
+				{Kind: "Secret", ObjectKey: client.ObjectKey{Name: "test-headers", Namespace: "default"}},
+			},
+		},
+	}
+
+	for _, tt := range dependencyTests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := gen.Dependencies(&templatesv1.GitOpsSetGenerator{APIClient: tt.generator}, gs)
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Fatalf("failed to get dependencies:\n%s", diff)
+			}
+		})
+	}
+}
+
 func Test_addHeadersFromSecretToRequest(t *testing.T) {
 	secret := newTestSecret()
 	kc := newFakeClient(t, secret)
@@ -0,0 +1,25 @@
+package apiclient
+
+import (
+	templatesv1 "github.com/weaveworks/gitopssets-controller/api/v1beta1"
+	"github.com/weaveworks/gitopssets-controller/controllers/templates/generators"
+	"github.com/weaveworks/gitopssets-controller/pkg/registry"
+)
+
+func init() {
+	registry.Register(registry.Registration{
+		Name: "APIClient",
+		Configured: func(sg *templatesv1.GitOpsSetGenerator) bool {
+			return sg.APIClient != nil
+		},
+		Default:  true,
+		Nestable: true,
+		Factory: func(opts registry.Options) generators.GeneratorFactory {
+			if opts.HTTPClientFactory == nil {
+				return GeneratorFactory(DefaultClientFactory)
+			}
+
+			return GeneratorFactory(opts.HTTPClientFactory)
+		},
+	})
+}
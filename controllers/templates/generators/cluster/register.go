@@ -0,0 +1,26 @@
+package cluster
+
+import (
+	clustersv1 "github.com/weaveworks/cluster-controller/api/v1alpha1"
+
+	templatesv1 "github.com/weaveworks/gitopssets-controller/api/v1beta1"
+	"github.com/weaveworks/gitopssets-controller/controllers/templates/generators"
+	"github.com/weaveworks/gitopssets-controller/pkg/registry"
+)
+
+func init() {
+	registry.Register(registry.Registration{
+		Name: "Cluster",
+		Configured: func(sg *templatesv1.GitOpsSetGenerator) bool {
+			return sg.Cluster != nil
+		},
+		Nestable: true,
+		Factory: func(registry.Options) generators.GeneratorFactory {
+			return GeneratorFactory
+		},
+		AddToScheme: clustersv1.AddToScheme,
+		Watches: []registry.Watch{
+			{Object: &clustersv1.GitopsCluster{}, MapFunc: GitOpsSetsForCluster},
+		},
+	})
+}
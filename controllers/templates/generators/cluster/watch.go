@@ -0,0 +1,89 @@
+package cluster
+
+import (
+	"context"
+
+	clustersv1 "github.com/weaveworks/cluster-controller/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	templatesv1 "github.com/weaveworks/gitopssets-controller/api/v1beta1"
+)
+
+// GitOpsSetsForCluster returns a function that maps a GitopsCluster to the
+// GitOpsSets with Cluster generators that select it.
+func GitOpsSetsForCluster(c client.Reader) handler.MapFunc {
+	return func(ctx context.Context, o client.Object) []reconcile.Request {
+		gitOpsCluster, ok := o.(*clustersv1.GitopsCluster)
+		if !ok {
+			return nil
+		}
+
+		list := &templatesv1.GitOpsSetList{}
+
+		err := c.List(ctx, list, &client.ListOptions{})
+		if err != nil {
+			return nil
+		}
+
+		var result []reconcile.Request
+		for _, v := range list.Items {
+			if matchCluster(gitOpsCluster, &v) {
+				result = append(result, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&v)})
+			}
+		}
+
+		return result
+	}
+}
+
+func matchCluster(gitOpsCluster *clustersv1.GitopsCluster, gitOpsSet *templatesv1.GitOpsSet) bool {
+	for _, generator := range gitOpsSet.Spec.Generators {
+		for _, selector := range getClusterSelectors(generator) {
+			if selectorMatchesCluster(selector, gitOpsCluster) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func getClusterSelectors(generator templatesv1.GitOpsSetGenerator) []metav1.LabelSelector {
+	selectors := []metav1.LabelSelector{}
+
+	if generator.Cluster != nil {
+		selectors = append(selectors, generator.Cluster.Selector)
+	}
+
+	if generator.Matrix != nil && generator.Matrix.Generators != nil {
+		for _, matrixGenerator := range generator.Matrix.Generators {
+			if matrixGenerator.Cluster != nil {
+				selectors = append(selectors, matrixGenerator.Cluster.Selector)
+			}
+		}
+	}
+
+	return selectors
+}
+
+func selectorMatchesCluster(labelSelector metav1.LabelSelector, cluster *clustersv1.GitopsCluster) bool {
+	selector, err := metav1.LabelSelectorAsSelector(&labelSelector)
+	if err != nil {
+		return false
+	}
+
+	// If the selector is empty, then we don't match anything.
+	// We want to be cautious here, so we don't accidentally match
+	// all clusters.
+	if selector.Empty() {
+		return false
+	}
+
+	labelSet := labels.Set(cluster.GetLabels())
+
+	return selector.Matches(labelSet)
+}
@@ -6,7 +6,7 @@ import (
 	"time"
 
 	"github.com/go-logr/logr"
-	templatesv1 "github.com/weaveworks/gitopssets-controller/api/v1alpha1"
+	templatesv1 "github.com/weaveworks/gitopssets-controller/api/v1beta1"
 	"github.com/weaveworks/gitopssets-controller/controllers/templates/generators"
 	corev1 "k8s.io/api/core/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -47,14 +47,14 @@ func (g *ConfigGenerator) Generate(ctx context.Context, sg *templatesv1.GitOpsSe
 
 	switch sg.Config.Kind {
 	case "ConfigMap":
-		data, err := configMapToParams(ctx, g.Client, client.ObjectKey{Name: sg.Config.Name, Namespace: ks.GetNamespace()})
+		data, err := configMapToParams(ctx, g.Client, client.ObjectKey{Name: sg.Config.Name, Namespace: generators.ReferenceNamespace(sg.Config.Namespace, ks)})
 		if err != nil {
 			return nil, err
 		}
 		paramsList = append(paramsList, data)
 
 	case "Secret":
-		data, err := secretToParams(ctx, g.Client, client.ObjectKey{Name: sg.Config.Name, Namespace: ks.GetNamespace()})
+		data, err := secretToParams(ctx, g.Client, client.ObjectKey{Name: sg.Config.Name, Namespace: generators.ReferenceNamespace(sg.Config.Namespace, ks)})
 		if err != nil {
 			return nil, err
 		}
@@ -72,6 +72,24 @@ func (g *ConfigGenerator) Interval(sg *templatesv1.GitOpsSetGenerator) time.Dura
 	return generators.NoRequeueInterval
 }
 
+// DependencyKinds is an implementation of the generators.DependentGenerator
+// interface.
+func (g *ConfigGenerator) DependencyKinds() []client.Object {
+	return []client.Object{&corev1.ConfigMap{}, &corev1.Secret{}}
+}
+
+// Dependencies is an implementation of the generators.DependentGenerator
+// interface.
+func (g *ConfigGenerator) Dependencies(sg *templatesv1.GitOpsSetGenerator, ks *templatesv1.GitOpsSet) []generators.Dependency {
+	if sg.Config == nil {
+		return nil
+	}
+
+	return []generators.Dependency{
+		{Kind: sg.Config.Kind, ObjectKey: client.ObjectKey{Name: sg.Config.Name, Namespace: generators.ReferenceNamespace(sg.Config.Namespace, ks)}},
+	}
+}
+
 func configMapToParams(ctx context.Context, k8sClient client.Reader, key client.ObjectKey) (map[string]any, error) {
 	var configMap corev1.ConfigMap
 
@@ -14,7 +14,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 
-	templatesv1 "github.com/weaveworks/gitopssets-controller/api/v1alpha1"
+	templatesv1 "github.com/weaveworks/gitopssets-controller/api/v1beta1"
 	"github.com/weaveworks/gitopssets-controller/controllers/templates/generators"
 	"github.com/weaveworks/gitopssets-controller/test"
 )
@@ -44,6 +44,39 @@ func TestConfigGenerator_Interval(t *testing.T) {
 	}
 }
 
+func TestConfigGenerator_Dependencies(t *testing.T) {
+	gen := NewGenerator(logr.Discard(), nil)
+	gs := &templatesv1.GitOpsSet{ObjectMeta: metav1.ObjectMeta{Name: "demo-set", Namespace: "default"}}
+
+	for _, kind := range []string{"ConfigMap", "Secret"} {
+		t.Run(kind, func(t *testing.T) {
+			sg := &templatesv1.GitOpsSetGenerator{
+				Config: &templatesv1.ConfigGenerator{Kind: kind, Name: "test-config"},
+			}
+
+			want := []generators.Dependency{
+				{Kind: kind, ObjectKey: client.ObjectKey{Name: "test-config", Namespace: "default"}},
+			}
+			if diff := cmp.Diff(want, gen.Dependencies(sg, gs)); diff != "" {
+				t.Fatalf("failed to get dependencies:\n%s", diff)
+			}
+		})
+	}
+
+	t.Run("in another namespace", func(t *testing.T) {
+		sg := &templatesv1.GitOpsSetGenerator{
+			Config: &templatesv1.ConfigGenerator{Kind: "ConfigMap", Name: "test-config", Namespace: "shared"},
+		}
+
+		want := []generators.Dependency{
+			{Kind: "ConfigMap", ObjectKey: client.ObjectKey{Name: "test-config", Namespace: "shared"}},
+		}
+		if diff := cmp.Diff(want, gen.Dependencies(sg, gs)); diff != "" {
+			t.Fatalf("failed to get dependencies:\n%s", diff)
+		}
+	})
+}
+
 func TestConfigGenerator_Generate_with_errors(t *testing.T) {
 	tests := []struct {
 		name    string
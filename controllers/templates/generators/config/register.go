@@ -0,0 +1,21 @@
+package config
+
+import (
+	templatesv1 "github.com/weaveworks/gitopssets-controller/api/v1beta1"
+	"github.com/weaveworks/gitopssets-controller/controllers/templates/generators"
+	"github.com/weaveworks/gitopssets-controller/pkg/registry"
+)
+
+func init() {
+	registry.Register(registry.Registration{
+		Name: "Config",
+		Configured: func(sg *templatesv1.GitOpsSetGenerator) bool {
+			return sg.Config != nil
+		},
+		Default:  true,
+		Nestable: true,
+		Factory: func(registry.Options) generators.GeneratorFactory {
+			return GeneratorFactory
+		},
+	})
+}
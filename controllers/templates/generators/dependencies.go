@@ -0,0 +1,79 @@
+package generators
+
+import (
+	"sort"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	templatesv1 "github.com/weaveworks/gitopssets-controller/api/v1beta1"
+)
+
+// Dependency is an object in the cluster that a generator reads when
+// generating elements.
+type Dependency struct {
+	Kind string
+	client.ObjectKey
+}
+
+// DependentGenerator is implemented by generators that read objects from the
+// cluster, GitOpsSets are reconciled when the objects that their generators
+// depend on change.
+type DependentGenerator interface {
+	// DependencyKinds returns empty objects of the kinds that the generator
+	// can depend on, these are watched for changes.
+	DependencyKinds() []client.Object
+
+	// Dependencies returns the objects that the generator depends on, or nil
+	// if the generator is not configured in the GitOpsSetGenerator.
+	Dependencies(*templatesv1.GitOpsSetGenerator, *templatesv1.GitOpsSet) []Dependency
+}
+
+// FindDependencies returns the dependencies of the enabled generators that
+// are configured in a GitOpsSetGenerator, generators return no dependencies
+// when they are not configured.
+func FindDependencies(sg *templatesv1.GitOpsSetGenerator, gitOpsSet *templatesv1.GitOpsSet, enabledGenerators map[string]Generator) []Dependency {
+	var dependencies []Dependency
+	for _, name := range sortedNames(enabledGenerators) {
+		dependent, ok := enabledGenerators[name].(DependentGenerator)
+		if !ok {
+			continue
+		}
+		dependencies = append(dependencies, dependent.Dependencies(sg, gitOpsSet)...)
+	}
+
+	return dependencies
+}
+
+// FindDependencyKinds returns the kinds of objects that the enabled generators
+// can depend on, ordered by the names of the generators.
+func FindDependencyKinds(enabledGenerators map[string]Generator) []client.Object {
+	var kinds []client.Object
+	for _, name := range sortedNames(enabledGenerators) {
+		if dependent, ok := enabledGenerators[name].(DependentGenerator); ok {
+			kinds = append(kinds, dependent.DependencyKinds()...)
+		}
+	}
+
+	return kinds
+}
+
+// ReferenceNamespace returns the namespace of an object that a generator
+// references, references without a namespace are to objects in the namespace
+// of the GitOpsSet.
+func ReferenceNamespace(namespace string, gitOpsSet *templatesv1.GitOpsSet) string {
+	if namespace == "" {
+		return gitOpsSet.GetNamespace()
+	}
+
+	return namespace
+}
+
+func sortedNames(enabledGenerators map[string]Generator) []string {
+	names := make([]string, 0, len(enabledGenerators))
+	for name := range enabledGenerators {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
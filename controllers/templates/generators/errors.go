@@ -2,6 +2,7 @@ package generators
 
 import (
 	"fmt"
+	"net/http"
 
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
@@ -23,3 +24,29 @@ func ArtifactError(kind string, name client.ObjectKey) error {
 		Name: name,
 	}
 }
+
+// HTTPStatusError indicates that a generator got an error response from an
+// HTTP API.
+type HTTPStatusError struct {
+	StatusCode int
+	Endpoint   string
+	Err        error
+}
+
+func (e HTTPStatusError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("got %d response from endpoint %s: %s", e.StatusCode, e.Endpoint, e.Err)
+	}
+
+	return fmt.Sprintf("got %d response from endpoint %s", e.StatusCode, e.Endpoint)
+}
+
+func (e HTTPStatusError) Unwrap() error {
+	return e.Err
+}
+
+// Transient returns true if the request is expected to succeed when it's
+// retried, this is the case for server errors and rate limited requests.
+func (e HTTPStatusError) Transient() bool {
+	return e.StatusCode >= http.StatusInternalServerError || e.StatusCode == http.StatusTooManyRequests
+}
@@ -7,7 +7,7 @@ import (
 
 	sourcev1 "github.com/fluxcd/source-controller/api/v1beta2"
 	"github.com/go-logr/logr"
-	templatesv1 "github.com/weaveworks/gitopssets-controller/api/v1alpha1"
+	templatesv1 "github.com/weaveworks/gitopssets-controller/api/v1beta1"
 	"github.com/weaveworks/gitopssets-controller/controllers/templates/generators"
 	"github.com/weaveworks/gitopssets-controller/pkg/parser"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -98,8 +98,26 @@ func (g *GitRepositoryGenerator) Interval(sg *templatesv1.GitOpsSetGenerator) ti
 	return generators.NoRequeueInterval
 }
 
+// DependencyKinds is an implementation of the generators.DependentGenerator
+// interface.
+func (g *GitRepositoryGenerator) DependencyKinds() []client.Object {
+	return []client.Object{&sourcev1.GitRepository{}}
+}
+
+// Dependencies is an implementation of the generators.DependentGenerator
+// interface.
+func (g *GitRepositoryGenerator) Dependencies(sg *templatesv1.GitOpsSetGenerator, ks *templatesv1.GitOpsSet) []generators.Dependency {
+	if sg.GitRepository == nil {
+		return nil
+	}
+
+	return []generators.Dependency{
+		{Kind: sourcev1.GitRepositoryKind, ObjectKey: client.ObjectKey{Name: sg.GitRepository.RepositoryRef, Namespace: generators.ReferenceNamespace(sg.GitRepository.Namespace, ks)}},
+	}
+}
+
 func (g *GitRepositoryGenerator) loadGitRepository(ctx context.Context, gen *templatesv1.GitRepositoryGenerator, ks *templatesv1.GitOpsSet) (*sourcev1.GitRepository, error) {
-	repoName := client.ObjectKey{Name: gen.RepositoryRef, Namespace: ks.GetNamespace()}
+	repoName := client.ObjectKey{Name: gen.RepositoryRef, Namespace: generators.ReferenceNamespace(gen.Namespace, ks)}
 
 	var gr sourcev1.GitRepository
 	if err := g.Client.Get(ctx, repoName, &gr); err != nil {
@@ -111,6 +129,7 @@ func (g *GitRepositoryGenerator) loadGitRepository(ctx context.Context, gen *tem
 		g.Logger.Info("GitRepository does not have an artifact", "repository", repoName)
 		return nil, generators.ArtifactError("GitRepository", repoName)
 	}
+	generators.RecordRevision(ctx, gr.Status.Artifact.Revision)
 
 	return &gr, nil
 }
@@ -15,7 +15,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 
-	templatesv1 "github.com/weaveworks/gitopssets-controller/api/v1alpha1"
+	templatesv1 "github.com/weaveworks/gitopssets-controller/api/v1beta1"
 	"github.com/weaveworks/gitopssets-controller/controllers/templates/generators"
 	"github.com/weaveworks/gitopssets-controller/test"
 )
@@ -81,6 +81,25 @@ func TestGenerate(t *testing.T) {
 				{"Directory": "./applications/frontend", "Base": "frontend"},
 			},
 		},
+		{
+			"repository in another namespace",
+			&templatesv1.GitRepositoryGenerator{
+				RepositoryRef: "test-repository",
+				Namespace:     "flux-system",
+				Files: []templatesv1.RepositoryGeneratorFileItem{
+					{Path: "files/dev.yaml"},
+				},
+			},
+			[]runtime.Object{test.NewGitRepository(
+				withArchiveURLAndChecksum(srv.URL+"/files.tar.gz",
+					"sha256:f0a57ec1cdebda91cf00d89dfa298c6ac27791e7fdb0329990478061755eaca8"),
+				func(gr *sourcev1beta2.GitRepository) {
+					gr.SetNamespace("flux-system")
+				})},
+			[]map[string]any{
+				{"environment": "dev", "instances": 2.0},
+			},
+		},
 	}
 
 	for _, tt := range testCases {
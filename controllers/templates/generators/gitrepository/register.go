@@ -0,0 +1,54 @@
+package gitrepository
+
+import (
+	"context"
+	"path/filepath"
+
+	sourcev1 "github.com/fluxcd/source-controller/api/v1"
+	sourcev1beta2 "github.com/fluxcd/source-controller/api/v1beta2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	templatesv1 "github.com/weaveworks/gitopssets-controller/api/v1beta1"
+	"github.com/weaveworks/gitopssets-controller/controllers/templates/generators"
+	"github.com/weaveworks/gitopssets-controller/pkg/registry"
+)
+
+func init() {
+	registry.Register(registry.Registration{
+		Name: "GitRepository",
+		Configured: func(sg *templatesv1.GitOpsSetGenerator) bool {
+			return sg.GitRepository != nil
+		},
+		Default:  true,
+		Nestable: true,
+		Factory: func(opts registry.Options) generators.GeneratorFactory {
+			return GeneratorFactory(opts.Fetcher)
+		},
+		AddToScheme: sourcev1beta2.AddToScheme,
+		LocalReader: readLocalGitRepository,
+	})
+}
+
+// readLocalGitRepository provides GitRepositories with an artifact in the
+// directory with the name of the GitRepository in the repository root.
+func readLocalGitRepository(ctx context.Context, repositoryRoot string, key client.ObjectKey, obj client.Object) (bool, error) {
+	var artifact **sourcev1.Artifact
+	switch v := obj.(type) {
+	case *sourcev1beta2.GitRepository:
+		artifact = &v.Status.Artifact
+	case *sourcev1.GitRepository:
+		artifact = &v.Status.Artifact
+	default:
+		return false, nil
+	}
+
+	base, err := filepath.Abs(repositoryRoot)
+	if err != nil {
+		return true, err
+	}
+	*artifact = &sourcev1.Artifact{
+		URL: "file://" + filepath.Join(base, key.Name),
+	}
+
+	return true, nil
+}
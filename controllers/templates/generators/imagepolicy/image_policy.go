@@ -8,7 +8,7 @@ import (
 	imagev1 "github.com/fluxcd/image-reflector-controller/api/v1beta2"
 	"github.com/go-logr/logr"
 	"github.com/google/go-containerregistry/pkg/name"
-	templatesv1 "github.com/weaveworks/gitopssets-controller/api/v1alpha1"
+	templatesv1 "github.com/weaveworks/gitopssets-controller/api/v1beta1"
 	"github.com/weaveworks/gitopssets-controller/controllers/templates/generators"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -49,7 +49,7 @@ func (g *ImagePolicyGenerator) Generate(ctx context.Context, sg *templatesv1.Git
 	g.Logger.Info("generating params from ImagePolicy generator", "imagePolicy", sg.ImagePolicy.PolicyRef)
 
 	var imagePolicy imagev1.ImagePolicy
-	imagePolicyName := client.ObjectKey{Name: sg.ImagePolicy.PolicyRef, Namespace: ks.GetNamespace()}
+	imagePolicyName := client.ObjectKey{Name: sg.ImagePolicy.PolicyRef, Namespace: generators.ReferenceNamespace(sg.ImagePolicy.Namespace, ks)}
 	if err := g.Client.Get(ctx, imagePolicyName, &imagePolicy); err != nil {
 		return nil, fmt.Errorf("could not load ImagePolicy: %w", err)
 	}
@@ -64,6 +64,7 @@ func (g *ImagePolicyGenerator) Generate(ctx context.Context, sg *templatesv1.Git
 				Namespace: imagePolicy.GetNamespace(),
 			})
 	}
+	generators.RecordRevision(ctx, imagePolicy.Status.LatestImage)
 
 	latestTag, err := name.NewTag(imagePolicy.Status.LatestImage)
 	if err != nil {
@@ -104,3 +105,21 @@ func (g *ImagePolicyGenerator) Generate(ctx context.Context, sg *templatesv1.Git
 func (g *ImagePolicyGenerator) Interval(sg *templatesv1.GitOpsSetGenerator) time.Duration {
 	return generators.NoRequeueInterval
 }
+
+// DependencyKinds is an implementation of the generators.DependentGenerator
+// interface.
+func (g *ImagePolicyGenerator) DependencyKinds() []client.Object {
+	return []client.Object{&imagev1.ImagePolicy{}}
+}
+
+// Dependencies is an implementation of the generators.DependentGenerator
+// interface.
+func (g *ImagePolicyGenerator) Dependencies(sg *templatesv1.GitOpsSetGenerator, ks *templatesv1.GitOpsSet) []generators.Dependency {
+	if sg.ImagePolicy == nil {
+		return nil
+	}
+
+	return []generators.Dependency{
+		{Kind: imagev1.ImagePolicyKind, ObjectKey: client.ObjectKey{Name: sg.ImagePolicy.PolicyRef, Namespace: generators.ReferenceNamespace(sg.ImagePolicy.Namespace, ks)}},
+	}
+}
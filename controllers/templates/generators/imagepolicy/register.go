@@ -0,0 +1,23 @@
+package imagepolicy
+
+import (
+	imagev1 "github.com/fluxcd/image-reflector-controller/api/v1beta2"
+
+	templatesv1 "github.com/weaveworks/gitopssets-controller/api/v1beta1"
+	"github.com/weaveworks/gitopssets-controller/controllers/templates/generators"
+	"github.com/weaveworks/gitopssets-controller/pkg/registry"
+)
+
+func init() {
+	registry.Register(registry.Registration{
+		Name: "ImagePolicy",
+		Configured: func(sg *templatesv1.GitOpsSetGenerator) bool {
+			return sg.ImagePolicy != nil
+		},
+		Nestable: true,
+		Factory: func(registry.Options) generators.GeneratorFactory {
+			return GeneratorFactory
+		},
+		AddToScheme: imagev1.AddToScheme,
+	})
+}
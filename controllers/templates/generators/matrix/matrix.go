@@ -2,7 +2,6 @@ package matrix
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"reflect"
 	"sort"
@@ -10,8 +9,9 @@ import (
 
 	"dario.cat/mergo"
 	"github.com/go-logr/logr"
-	templatesv1 "github.com/weaveworks/gitopssets-controller/api/v1alpha1"
+	templatesv1 "github.com/weaveworks/gitopssets-controller/api/v1beta1"
 	"github.com/weaveworks/gitopssets-controller/controllers/templates/generators"
+	"github.com/weaveworks/gitopssets-controller/pkg/registry"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
@@ -49,14 +49,7 @@ func (mg *MatrixGenerator) Generate(ctx context.Context, sg *templatesv1.GitOpsS
 		return nil, nil
 	}
 
-	allGenerators := map[string]generators.Generator{}
-
-	for name, factory := range mg.generatorsMap {
-		g := factory(mg.Logger, mg.Client)
-		allGenerators[name] = g
-	}
-
-	generated, err := generate(ctx, *sg, allGenerators, ks)
+	generated, err := generate(ctx, *sg, mg.nestedGenerators(), ks)
 	if err != nil {
 		return nil, err
 	}
@@ -75,28 +68,23 @@ func (mg *MatrixGenerator) Generate(ctx context.Context, sg *templatesv1.GitOpsS
 
 // Interval is an implementation of the Generator interface.
 func (g *MatrixGenerator) Interval(sg *templatesv1.GitOpsSetGenerator) time.Duration {
-	allGenerators := map[string]generators.Generator{}
-
-	for name, factory := range g.generatorsMap {
-		g := factory(g.Logger, g.Client)
-		allGenerators[name] = g
-	}
+	allGenerators := g.nestedGenerators()
 
 	res := []time.Duration{}
 	for _, mg := range sg.Matrix.Generators {
-		relevantGenerators, err := generators.FindRelevantGenerators(mg, allGenerators)
+		gs, err := generators.MakeGitOpsSetGenerator(&mg)
+		if err != nil {
+			g.Logger.Error(err, "failed to calculate requeue interval, defaulting to no requeue")
+			return generators.NoRequeueInterval
+		}
+
+		relevantGenerators, err := registry.Default.FindRelevantGenerators(gs, allGenerators)
 		if err != nil {
 			g.Logger.Error(err, "failed to find relevant generators, defaulting to no requeue")
 			return generators.NoRequeueInterval
 		}
 
 		for _, rg := range relevantGenerators {
-			gs, err := makeGitOpsSetGenerator(&mg)
-			if err != nil {
-				g.Logger.Error(err, "failed to calculate requeue interval, defaulting to no requeue")
-				return generators.NoRequeueInterval
-			}
-
 			d := rg.Interval(gs)
 
 			if d > generators.NoRequeueInterval {
@@ -116,6 +104,47 @@ func (g *MatrixGenerator) Interval(sg *templatesv1.GitOpsSetGenerator) time.Dura
 	return res[0]
 }
 
+// DependencyKinds is an implementation of the generators.DependentGenerator
+// interface.
+//
+// The MatrixGenerator can depend on the kinds of all the generators that can
+// be nested within it.
+func (mg *MatrixGenerator) DependencyKinds() []client.Object {
+	return generators.FindDependencyKinds(mg.nestedGenerators())
+}
+
+// Dependencies is an implementation of the generators.DependentGenerator
+// interface.
+//
+// The dependencies are those of the nested generators.
+func (mg *MatrixGenerator) Dependencies(sg *templatesv1.GitOpsSetGenerator, ks *templatesv1.GitOpsSet) []generators.Dependency {
+	if sg.Matrix == nil {
+		return nil
+	}
+
+	allGenerators := mg.nestedGenerators()
+	var dependencies []generators.Dependency
+	for i := range sg.Matrix.Generators {
+		gs, err := generators.MakeGitOpsSetGenerator(&sg.Matrix.Generators[i])
+		if err != nil {
+			mg.Logger.Error(err, "failed to find the dependencies of the nested generator")
+			continue
+		}
+		dependencies = append(dependencies, generators.FindDependencies(gs, ks, allGenerators)...)
+	}
+
+	return dependencies
+}
+
+func (mg *MatrixGenerator) nestedGenerators() map[string]generators.Generator {
+	allGenerators := map[string]generators.Generator{}
+	for name, factory := range mg.generatorsMap {
+		allGenerators[name] = factory(mg.Logger, mg.Client)
+	}
+
+	return allGenerators
+}
+
 type generatedElements struct {
 	name     string
 	elements []map[string]any
@@ -125,19 +154,34 @@ type generatedElements struct {
 func generate(ctx context.Context, generator templatesv1.GitOpsSetGenerator, allGenerators map[string]generators.Generator, gitopsSet *templatesv1.GitOpsSet) ([]generatedElements, error) {
 	generated := []generatedElements{}
 
+	report := generators.ReportFromContext(ctx)
 	for _, mg := range generator.Matrix.Generators {
 		name := mg.Name
-		relevantGenerators, err := generators.FindRelevantGenerators(mg, allGenerators)
+		gs, err := generators.MakeGitOpsSetGenerator(&mg)
 		if err != nil {
 			return nil, err
 		}
-		for _, g := range relevantGenerators {
-			gs, err := makeGitOpsSetGenerator(&mg)
-			if err != nil {
-				return nil, err
-			}
 
-			res, err := g.Generate(ctx, gs, gitopsSet)
+		relevantGenerators, err := registry.Default.FindRelevantGenerators(gs, allGenerators)
+		if err != nil {
+			return nil, err
+		}
+		generatorTypes := registry.Default.GeneratorTypes(gs)
+		for i, g := range relevantGenerators {
+			nestedReport := &generators.GenerationReport{}
+			if report != nil {
+				nestedReport.PreviousRevision = report.PreviousNested[name]
+			}
+			res, err := g.Generate(generators.WithReport(ctx, nestedReport), gs, gitopsSet)
+			if report != nil && name != "" {
+				report.Nested = append(report.Nested, generators.NestedGenerationReport{
+					Name:     name,
+					Type:     generatorTypes[i],
+					Elements: len(res),
+					Revision: nestedReport.Revision,
+					Err:      err,
+				})
+			}
 			if err != nil {
 				return nil, err
 			}
@@ -151,24 +195,6 @@ func generate(ctx context.Context, generator templatesv1.GitOpsSetGenerator, all
 	return generated, nil
 }
 
-// makeGitOpsSetGenerator converts a GitOpsSetNestedGenerator struct to a GitOpsSetGenerator struct.
-// This is needed because MatrixGenerator includes GitOpsSetNestedGenerator struct,
-// but the Generate function of the Generator interface expects a GitOpsSetGenerator struct.
-func makeGitOpsSetGenerator(mg *templatesv1.GitOpsSetNestedGenerator) (*templatesv1.GitOpsSetGenerator, error) {
-	mgJSON, err := json.Marshal(mg)
-	if err != nil {
-		return nil, err
-	}
-
-	var gs templatesv1.GitOpsSetGenerator
-
-	if err = json.Unmarshal(mgJSON, &gs); err != nil {
-		return nil, err
-	}
-
-	return &gs, nil
-}
-
 // cartesian returns the cartesian product of a matrix with no
 // duplicates.
 func cartesian(generated []generatedElements) ([]map[string]any, error) {
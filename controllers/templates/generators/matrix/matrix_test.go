@@ -11,12 +11,13 @@ import (
 	sourcev1beta2 "github.com/fluxcd/source-controller/api/v1beta2"
 	"github.com/go-logr/logr"
 	"github.com/google/go-cmp/cmp"
-	templatesv1 "github.com/weaveworks/gitopssets-controller/api/v1alpha1"
+	templatesv1 "github.com/weaveworks/gitopssets-controller/api/v1beta1"
 	"github.com/weaveworks/gitopssets-controller/controllers/templates/generators"
 	"github.com/weaveworks/gitopssets-controller/controllers/templates/generators/gitrepository"
 	"github.com/weaveworks/gitopssets-controller/controllers/templates/generators/list"
 	"github.com/weaveworks/gitopssets-controller/controllers/templates/generators/pullrequests"
 	"github.com/weaveworks/gitopssets-controller/test"
+	corev1 "k8s.io/api/core/v1"
 	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -343,6 +344,93 @@ func TestMatrixGenerator_Generate(t *testing.T) {
 	}
 }
 
+func TestMatrixGenerator_Generate_report(t *testing.T) {
+	g := NewGenerator(logr.Discard(), newFakeClient(t), map[string]generators.GeneratorFactory{
+		"List": list.GeneratorFactory,
+	})
+	sg := &templatesv1.GitOpsSetGenerator{
+		Matrix: &templatesv1.MatrixGenerator{
+			Generators: []templatesv1.GitOpsSetNestedGenerator{
+				{
+					Name: "list1",
+					List: &templatesv1.ListGenerator{
+						Elements: []apiextensionsv1.JSON{
+							{Raw: []byte(`{"key1": "value1"}`)},
+							{Raw: []byte(`{"key2": "value2"}`)},
+						},
+					},
+				},
+				{
+					List: &templatesv1.ListGenerator{
+						Elements: []apiextensionsv1.JSON{
+							{Raw: []byte(`{"key3": "value3"}`)},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	report := &generators.GenerationReport{}
+	_, err := g.Generate(generators.WithReport(context.TODO(), report), sg, nil)
+	test.AssertNoError(t, err)
+
+	want := &generators.GenerationReport{
+		Nested: []generators.NestedGenerationReport{
+			{Name: "list1", Type: "List", Elements: 2},
+		},
+	}
+	if diff := cmp.Diff(want, report); diff != "" {
+		t.Fatalf("failed to report nested generators:\n%s", diff)
+	}
+}
+
+func TestMatrixGenerator_Generate_previousRevision(t *testing.T) {
+	var previous []string
+	g := NewGenerator(logr.Discard(), newFakeClient(t), map[string]generators.GeneratorFactory{
+		"List": func(l logr.Logger, c client.Reader) generators.Generator {
+			return previousRevisionGenerator{Generator: list.NewGenerator(l), previous: &previous}
+		},
+	})
+	sg := &templatesv1.GitOpsSetGenerator{
+		Matrix: &templatesv1.MatrixGenerator{
+			Generators: []templatesv1.GitOpsSetNestedGenerator{
+				{
+					Name: "list1",
+					List: &templatesv1.ListGenerator{
+						Elements: []apiextensionsv1.JSON{{Raw: []byte(`{"key1": "value1"}`)}},
+					},
+				},
+				{
+					Name: "list2",
+					List: &templatesv1.ListGenerator{
+						Elements: []apiextensionsv1.JSON{{Raw: []byte(`{"key2": "value2"}`)}},
+					},
+				},
+			},
+		},
+	}
+
+	report := &generators.GenerationReport{PreviousNested: map[string]string{"list1": "sha256:list1"}}
+	_, err := g.Generate(generators.WithReport(context.TODO(), report), sg, nil)
+	test.AssertNoError(t, err)
+
+	if diff := cmp.Diff([]string{"sha256:list1", ""}, previous); diff != "" {
+		t.Fatalf("failed to provide previous revisions:\n%s", diff)
+	}
+}
+
+type previousRevisionGenerator struct {
+	generators.Generator
+	previous *[]string
+}
+
+func (g previousRevisionGenerator) Generate(ctx context.Context, sg *templatesv1.GitOpsSetGenerator, gs *templatesv1.GitOpsSet) ([]map[string]any, error) {
+	*g.previous = append(*g.previous, generators.PreviousRevision(ctx))
+
+	return g.Generator.Generate(ctx, sg, gs)
+}
+
 func TestDisabledGenerators(t *testing.T) {
 	gen := NewGenerator(logr.Discard(), nil, map[string]generators.GeneratorFactory{
 		"List": list.GeneratorFactory,
@@ -415,6 +503,56 @@ func TestInterval(t *testing.T) {
 	}
 }
 
+func TestDependencies(t *testing.T) {
+	gen := NewGenerator(logr.Discard(), nil, map[string]generators.GeneratorFactory{
+		"List":          list.GeneratorFactory,
+		"GitRepository": gitrepository.GeneratorFactory(nil),
+		"PullRequests":  pullrequests.GeneratorFactory,
+	})
+
+	gs := &templatesv1.GitOpsSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-generator",
+			Namespace: testNamespace,
+		},
+	}
+	sg := &templatesv1.GitOpsSetGenerator{
+		Matrix: &templatesv1.MatrixGenerator{
+			Generators: []templatesv1.GitOpsSetNestedGenerator{
+				{
+					GitRepository: &templatesv1.GitRepositoryGenerator{RepositoryRef: "test-repository"},
+				},
+				{
+					List: &templatesv1.ListGenerator{
+						Elements: []apiextensionsv1.JSON{{Raw: []byte(`{"cluster": "cluster"}`)}},
+					},
+				},
+				{
+					PullRequests: &templatesv1.PullRequestGenerator{
+						Driver:    "fake",
+						ServerURL: "https://example.com",
+						Repo:      "test-org/my-repo",
+						SecretRef: &corev1.LocalObjectReference{Name: "test-secret"},
+					},
+				},
+			},
+		},
+	}
+
+	want := []generators.Dependency{
+		{Kind: "GitRepository", ObjectKey: client.ObjectKey{Name: "test-repository", Namespace: testNamespace}},
+		{Kind: "Secret", ObjectKey: client.ObjectKey{Name: "test-secret", Namespace: testNamespace}},
+	}
+	if diff := cmp.Diff(want, gen.Dependencies(sg, gs)); diff != "" {
+		t.Fatalf("failed to get dependencies:\n%s", diff)
+	}
+
+	kinds := gen.DependencyKinds()
+	if len(kinds) != 2 {
+		t.Fatalf("got %d dependency kinds, want the GitRepository and Secret kinds", len(kinds))
+	}
+}
+
 func TestSingleElement(t *testing.T) {
 	tests := []struct {
 		name      string
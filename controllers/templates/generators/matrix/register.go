@@ -0,0 +1,20 @@
+package matrix
+
+import (
+	templatesv1 "github.com/weaveworks/gitopssets-controller/api/v1beta1"
+	"github.com/weaveworks/gitopssets-controller/controllers/templates/generators"
+	"github.com/weaveworks/gitopssets-controller/pkg/registry"
+)
+
+func init() {
+	registry.Register(registry.Registration{
+		Name: "Matrix",
+		Configured: func(sg *templatesv1.GitOpsSetGenerator) bool {
+			return sg.Matrix != nil
+		},
+		Default: true,
+		Factory: func(opts registry.Options) generators.GeneratorFactory {
+			return GeneratorFactory(opts.Nested)
+		},
+	})
+}
@@ -7,7 +7,7 @@ import (
 
 	sourcev1 "github.com/fluxcd/source-controller/api/v1beta2"
 	"github.com/go-logr/logr"
-	templatesv1 "github.com/weaveworks/gitopssets-controller/api/v1alpha1"
+	templatesv1 "github.com/weaveworks/gitopssets-controller/api/v1beta1"
 	"github.com/weaveworks/gitopssets-controller/controllers/templates/generators"
 	"github.com/weaveworks/gitopssets-controller/pkg/parser"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -92,7 +92,7 @@ func (g *OCIRepositoryGenerator) generateParamsFromOCIDirectories(ctx context.Co
 }
 
 func (g *OCIRepositoryGenerator) loadOCIRepository(ctx context.Context, gen *templatesv1.OCIRepositoryGenerator, ks *templatesv1.GitOpsSet) (*sourcev1.OCIRepository, error) {
-	repoName := client.ObjectKey{Name: gen.RepositoryRef, Namespace: ks.GetNamespace()}
+	repoName := client.ObjectKey{Name: gen.RepositoryRef, Namespace: generators.ReferenceNamespace(gen.Namespace, ks)}
 
 	var or sourcev1.OCIRepository
 	if err := g.Client.Get(ctx, repoName, &or); err != nil {
@@ -104,6 +104,7 @@ func (g *OCIRepositoryGenerator) loadOCIRepository(ctx context.Context, gen *tem
 		g.Logger.Info("OCIRepository does not have an artifact", "repository", repoName)
 		return nil, generators.ArtifactError("OCIRepository", repoName)
 	}
+	generators.RecordRevision(ctx, or.Status.Artifact.Revision)
 
 	return &or, nil
 }
@@ -114,3 +115,21 @@ func (g *OCIRepositoryGenerator) loadOCIRepository(ctx context.Context, gen *tem
 func (g *OCIRepositoryGenerator) Interval(sg *templatesv1.GitOpsSetGenerator) time.Duration {
 	return generators.NoRequeueInterval
 }
+
+// DependencyKinds is an implementation of the generators.DependentGenerator
+// interface.
+func (g *OCIRepositoryGenerator) DependencyKinds() []client.Object {
+	return []client.Object{&sourcev1.OCIRepository{}}
+}
+
+// Dependencies is an implementation of the generators.DependentGenerator
+// interface.
+func (g *OCIRepositoryGenerator) Dependencies(sg *templatesv1.GitOpsSetGenerator, ks *templatesv1.GitOpsSet) []generators.Dependency {
+	if sg.OCIRepository == nil {
+		return nil
+	}
+
+	return []generators.Dependency{
+		{Kind: sourcev1.OCIRepositoryKind, ObjectKey: client.ObjectKey{Name: sg.OCIRepository.RepositoryRef, Namespace: generators.ReferenceNamespace(sg.OCIRepository.Namespace, ks)}},
+	}
+}
@@ -0,0 +1,49 @@
+package ocirepository
+
+import (
+	"context"
+	"path/filepath"
+
+	sourcev1 "github.com/fluxcd/source-controller/api/v1"
+	sourcev1beta2 "github.com/fluxcd/source-controller/api/v1beta2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	templatesv1 "github.com/weaveworks/gitopssets-controller/api/v1beta1"
+	"github.com/weaveworks/gitopssets-controller/controllers/templates/generators"
+	"github.com/weaveworks/gitopssets-controller/pkg/registry"
+)
+
+func init() {
+	registry.Register(registry.Registration{
+		Name: "OCIRepository",
+		Configured: func(sg *templatesv1.GitOpsSetGenerator) bool {
+			return sg.OCIRepository != nil
+		},
+		Default:  true,
+		Nestable: true,
+		Factory: func(opts registry.Options) generators.GeneratorFactory {
+			return GeneratorFactory(opts.Fetcher)
+		},
+		AddToScheme: sourcev1beta2.AddToScheme,
+		LocalReader: readLocalOCIRepository,
+	})
+}
+
+// readLocalOCIRepository provides OCIRepositories with an artifact in the
+// directory with the name of the OCIRepository in the repository root.
+func readLocalOCIRepository(ctx context.Context, repositoryRoot string, key client.ObjectKey, obj client.Object) (bool, error) {
+	repository, ok := obj.(*sourcev1beta2.OCIRepository)
+	if !ok {
+		return false, nil
+	}
+
+	base, err := filepath.Abs(repositoryRoot)
+	if err != nil {
+		return true, err
+	}
+	repository.Status.Artifact = &sourcev1.Artifact{
+		URL: "file://" + filepath.Join(base, key.Name),
+	}
+
+	return true, nil
+}
@@ -0,0 +1,258 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	templatesv1 "github.com/weaveworks/gitopssets-controller/api/v1beta1"
+	"github.com/weaveworks/gitopssets-controller/controllers/templates/generators"
+	pluginapi "github.com/weaveworks/gitopssets-controller/pkg/plugin"
+)
+
+const (
+	// URLKey is the key in the plugin ConfigMap for the URL that requests
+	// are POSTed to.
+	URLKey = "url"
+
+	// TimeoutKey is the key in the plugin ConfigMap for the timeout for
+	// requests, in the format accepted by time.ParseDuration.
+	TimeoutKey = "timeout"
+
+	// TokenSecretKey is the key in the plugin ConfigMap for the name of a
+	// Secret with a bearer token that is sent to the plugin.
+	TokenSecretKey = "tokenSecret"
+
+	// TokenKey is the key in the token Secret for the bearer token.
+	TokenKey = "token"
+)
+
+// DefaultTimeout is used for requests to plugins that don't configure a
+// timeout.
+const DefaultTimeout = 30 * time.Second
+
+// HTTPClientFactory is used to create the http.Clients that requests to the
+// plugins are made with.
+type HTTPClientFactory func(*tls.Config) *http.Client
+
+// GeneratorFactory is a function for creating per-reconciliation generators for
+// the PluginGenerator.
+func GeneratorFactory(factory HTTPClientFactory) generators.GeneratorFactory {
+	return func(l logr.Logger, c client.Reader) generators.Generator {
+		return NewGenerator(l, c, factory)
+	}
+}
+
+// PluginGenerator generates from an external plugin service.
+type PluginGenerator struct {
+	ClientFactory HTTPClientFactory
+	Client        client.Reader
+	logr.Logger
+
+	// requeueAfter records the requeue hints from the plugin responses, the
+	// keys identify the plugin configuration.
+	requeueAfter map[string]time.Duration
+}
+
+// NewGenerator creates and returns a new plugin generator.
+func NewGenerator(l logr.Logger, c client.Reader, clientFactory HTTPClientFactory) *PluginGenerator {
+	return &PluginGenerator{
+		Client:        c,
+		Logger:        l,
+		ClientFactory: clientFactory,
+		requeueAfter:  map[string]time.Duration{},
+	}
+}
+
+// Generate POSTs a request to the plugin that is described in the referenced
+// ConfigMap, and returns the elements from the response.
+func (g *PluginGenerator) Generate(ctx context.Context, sg *templatesv1.GitOpsSetGenerator, gsg *templatesv1.GitOpsSet) ([]map[string]any, error) {
+	if sg == nil {
+		g.Logger.Info("no generator provided")
+		return nil, generators.ErrEmptyGitOpsSet
+	}
+
+	if sg.Plugin == nil {
+		g.Logger.Info("plugin info is nil")
+		return nil, nil
+	}
+
+	cfg, err := g.loadConfig(ctx, client.ObjectKey{Name: sg.Plugin.ConfigMapRef.Name, Namespace: gsg.GetNamespace()})
+	if err != nil {
+		return nil, err
+	}
+
+	g.Logger.Info("generating params from Plugin generator", "url", cfg.url)
+
+	pluginRequest := pluginapi.Request{
+		APIVersion: pluginapi.APIVersion,
+		GitOpsSet: pluginapi.GitOpsSetReference{
+			Name:      gsg.GetName(),
+			Namespace: gsg.GetNamespace(),
+		},
+		DryRun:       gsg.PlanEnabled(),
+		PreviousHash: generators.PreviousRevision(ctx),
+	}
+	if sg.Plugin.Input != nil {
+		pluginRequest.Input = sg.Plugin.Input.Raw
+	}
+
+	resp, err := g.post(ctx, cfg, pluginRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	hash, err := pluginapi.Hash(resp.Elements)
+	if err != nil {
+		return nil, err
+	}
+	generators.RecordRevision(ctx, hash)
+	g.requeueAfter[pluginKey(sg.Plugin)] = time.Duration(resp.RequeueAfterSeconds) * time.Second
+
+	return resp.Elements, nil
+}
+
+// Interval is an implementation of the Generator interface.
+//
+// If the plugin requested a requeue in the response, this takes precedence
+// over the configured interval.
+func (g *PluginGenerator) Interval(sg *templatesv1.GitOpsSetGenerator) time.Duration {
+	if d := g.requeueAfter[pluginKey(sg.Plugin)]; d > 0 {
+		return d
+	}
+
+	return sg.Plugin.Interval.Duration
+}
+
+// DependencyKinds is an implementation of the generators.DependentGenerator
+// interface.
+func (g *PluginGenerator) DependencyKinds() []client.Object {
+	return []client.Object{&corev1.ConfigMap{}}
+}
+
+// Dependencies is an implementation of the generators.DependentGenerator
+// interface.
+//
+// Changes to the plugin ConfigMap trigger a new request before the next poll.
+func (g *PluginGenerator) Dependencies(sg *templatesv1.GitOpsSetGenerator, gsg *templatesv1.GitOpsSet) []generators.Dependency {
+	if sg.Plugin == nil {
+		return nil
+	}
+
+	return []generators.Dependency{
+		{Kind: "ConfigMap", ObjectKey: client.ObjectKey{Name: sg.Plugin.ConfigMapRef.Name, Namespace: gsg.GetNamespace()}},
+	}
+}
+
+type pluginConfig struct {
+	url     string
+	timeout time.Duration
+	token   string
+}
+
+func (g *PluginGenerator) loadConfig(ctx context.Context, name client.ObjectKey) (*pluginConfig, error) {
+	var configMap corev1.ConfigMap
+	if err := g.Client.Get(ctx, name, &configMap); err != nil {
+		return nil, fmt.Errorf("failed to load ConfigMap for Plugin generator %s: %w", name, err)
+	}
+
+	cfg := &pluginConfig{url: configMap.Data[URLKey], timeout: DefaultTimeout}
+	if cfg.url == "" {
+		return nil, fmt.Errorf("plugin ConfigMap %s does not contain %s key", name, URLKey)
+	}
+
+	if v, ok := configMap.Data[TimeoutKey]; ok {
+		timeout, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s in plugin ConfigMap %s: %w", TimeoutKey, name, err)
+		}
+		cfg.timeout = timeout
+	}
+
+	if secretName, ok := configMap.Data[TokenSecretKey]; ok {
+		var s corev1.Secret
+		key := client.ObjectKey{Name: secretName, Namespace: name.Namespace}
+		if err := g.Client.Get(ctx, key, &s); err != nil {
+			return nil, fmt.Errorf("failed to load Secret for Plugin generator %s: %w", key, err)
+		}
+		token, ok := s.Data[TokenKey]
+		if !ok {
+			return nil, fmt.Errorf("secret %s does not contain %s key", key, TokenKey)
+		}
+		cfg.token = string(token)
+	}
+
+	return cfg, nil
+}
+
+func (g *PluginGenerator) post(ctx context.Context, cfg *pluginConfig, pluginRequest pluginapi.Request) (*pluginapi.Response, error) {
+	body, err := json.Marshal(pluginRequest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal plugin request: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, cfg.timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, cfg.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if cfg.token != "" {
+		req.Header.Set("Authorization", "Bearer "+cfg.token)
+	}
+
+	resp, err := g.ClientFactory(nil).Do(req)
+	if err != nil {
+		g.Logger.Error(err, "failed to request plugin", "url", cfg.url)
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		g.Logger.Error(err, "failed to read response", "url", cfg.url)
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		g.Logger.Info("failed to request plugin", "url", cfg.url, "statusCode", resp.StatusCode, "response", string(respBody))
+		return nil, generators.HTTPStatusError{StatusCode: resp.StatusCode, Endpoint: cfg.url}
+	}
+
+	var pluginResponse pluginapi.Response
+	if err := json.Unmarshal(respBody, &pluginResponse); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response from plugin %s: %w", cfg.url, err)
+	}
+
+	if err := pluginResponse.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid response from plugin %s: %w", cfg.url, err)
+	}
+
+	return &pluginResponse, nil
+}
+
+// pluginKey identifies the configuration of a plugin generator, the
+// GitOpsSetGenerators that are provided to Generate and Interval are not
+// necessarily the same values.
+func pluginKey(p *templatesv1.PluginGenerator) string {
+	if p == nil {
+		return ""
+	}
+	key := p.ConfigMapRef.Name
+	if p.Input != nil {
+		key += "/" + string(p.Input.Raw)
+	}
+
+	return key
+}
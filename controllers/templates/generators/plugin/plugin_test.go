@@ -0,0 +1,312 @@
+package plugin
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	templatesv1 "github.com/weaveworks/gitopssets-controller/api/v1beta1"
+	"github.com/weaveworks/gitopssets-controller/controllers/templates/generators"
+	"github.com/weaveworks/gitopssets-controller/controllers/templates/generators/apiclient"
+	pluginapi "github.com/weaveworks/gitopssets-controller/pkg/plugin"
+	"github.com/weaveworks/gitopssets-controller/pkg/plugin/plugintest"
+	"github.com/weaveworks/gitopssets-controller/test"
+)
+
+var _ generators.Generator = (*PluginGenerator)(nil)
+var _ generators.DependentGenerator = (*PluginGenerator)(nil)
+
+func TestGenerate_with_no_generator(t *testing.T) {
+	gen := GeneratorFactory(apiclient.DefaultClientFactory)(logr.Discard(), nil)
+	_, err := gen.Generate(context.TODO(), nil, nil)
+
+	if err != generators.ErrEmptyGitOpsSet {
+		t.Errorf("got error %v", err)
+	}
+}
+
+func TestGenerate_with_no_config(t *testing.T) {
+	gen := GeneratorFactory(apiclient.DefaultClientFactory)(logr.Discard(), nil)
+	got, err := gen.Generate(context.TODO(), &templatesv1.GitOpsSetGenerator{}, nil)
+
+	if err != nil {
+		t.Errorf("got an error with no plugin: %s", err)
+	}
+	if got != nil {
+		t.Errorf("got %v, want %v with no Plugin generator", got, nil)
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	ts, requests := plugintest.RecordingServer(t, pluginapi.Response{
+		Elements: []map[string]any{
+			{"name": "inventory-1", "region": "eu-west-1"},
+			{"name": "inventory-2", "region": "us-east-1"},
+		},
+		RequeueAfterSeconds: 60,
+	})
+	gen := NewGenerator(logr.Discard(), newFakeClient(t,
+		newPluginConfigMap(map[string]string{URLKey: ts.URL, TokenSecretKey: "plugin-token"}),
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "plugin-token", Namespace: "default"},
+			Data:       map[string][]byte{TokenKey: []byte("test-token")},
+		},
+	), apiclient.DefaultClientFactory)
+
+	gs := newGitOpsSet(func(gs *templatesv1.GitOpsSet) {
+		gs.Spec.Mode = templatesv1.PlanMode
+	})
+	sg := newGenerator(`{"environment":"production"}`)
+	report := &generators.GenerationReport{PreviousRevision: "sha256:previous"}
+	got, err := gen.Generate(generators.WithReport(context.TODO(), report), sg, gs)
+	test.AssertNoError(t, err)
+
+	want := []map[string]any{
+		{"name": "inventory-1", "region": "eu-west-1"},
+		{"name": "inventory-2", "region": "us-east-1"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("failed to generate elements:\n%s", diff)
+	}
+
+	wantRequests := []pluginapi.Request{
+		{
+			APIVersion:   pluginapi.APIVersion,
+			GitOpsSet:    pluginapi.GitOpsSetReference{Name: "demo-set", Namespace: "default"},
+			Input:        []byte(`{"environment":"production"}`),
+			DryRun:       true,
+			PreviousHash: "sha256:previous",
+		},
+	}
+	if diff := cmp.Diff(wantRequests, *requests); diff != "" {
+		t.Fatalf("failed to send request:\n%s", diff)
+	}
+
+	hash, err := pluginapi.Hash(want)
+	test.AssertNoError(t, err)
+	if report.Revision != hash {
+		t.Fatalf("got revision %q, want %q", report.Revision, hash)
+	}
+
+	if d := gen.Interval(newGenerator(`{"environment":"production"}`)); d != time.Minute {
+		t.Fatalf("got interval %v, want the requeue hint from the plugin", d)
+	}
+}
+
+func TestGenerate_dry_run(t *testing.T) {
+	dryRunTests := []struct {
+		name string
+		gs   *templatesv1.GitOpsSet
+		want bool
+	}{
+		{
+			name: "apply mode",
+			gs:   newGitOpsSet(),
+		},
+		{
+			name: "plan mode",
+			gs: newGitOpsSet(func(gs *templatesv1.GitOpsSet) {
+				gs.Spec.Mode = templatesv1.PlanMode
+			}),
+			want: true,
+		},
+		{
+			name: "plan annotation",
+			gs: newGitOpsSet(func(gs *templatesv1.GitOpsSet) {
+				gs.SetAnnotations(map[string]string{templatesv1.PlanAnnotation: "true"})
+			}),
+			want: true,
+		},
+	}
+
+	for _, tt := range dryRunTests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts, requests := plugintest.RecordingServer(t, pluginapi.Response{Elements: []map[string]any{}})
+			gen := NewGenerator(logr.Discard(), newFakeClient(t, newPluginConfigMap(map[string]string{URLKey: ts.URL})), apiclient.DefaultClientFactory)
+
+			_, err := gen.Generate(context.TODO(), newGenerator(""), tt.gs)
+			test.AssertNoError(t, err)
+
+			if got := (*requests)[0].DryRun; got != tt.want {
+				t.Fatalf("got DryRun %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGenerate_token(t *testing.T) {
+	var authorization string
+	ts := plugintest.NewServer(t, func(ctx context.Context, req pluginapi.Request) (pluginapi.Response, error) {
+		return pluginapi.Response{Elements: []map[string]any{}}, nil
+	})
+	factory := func(*tls.Config) *http.Client {
+		return &http.Client{Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			authorization = req.Header.Get("Authorization")
+			return http.DefaultTransport.RoundTrip(req)
+		})}
+	}
+	gen := NewGenerator(logr.Discard(), newFakeClient(t,
+		newPluginConfigMap(map[string]string{URLKey: ts.URL, TokenSecretKey: "plugin-token"}),
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "plugin-token", Namespace: "default"},
+			Data:       map[string][]byte{TokenKey: []byte("test-token")},
+		},
+	), factory)
+
+	_, err := gen.Generate(context.TODO(), newGenerator(""), newGitOpsSet())
+	test.AssertNoError(t, err)
+
+	if authorization != "Bearer test-token" {
+		t.Fatalf("got Authorization %q, want the bearer token", authorization)
+	}
+}
+
+func TestGenerate_errors(t *testing.T) {
+	failing := plugintest.NewServer(t, func(ctx context.Context, req pluginapi.Request) (pluginapi.Response, error) {
+		return pluginapi.Response{}, errors.New("inventory unavailable")
+	})
+	invalid := plugintest.NewServer(t, func(ctx context.Context, req pluginapi.Request) (pluginapi.Response, error) {
+		return pluginapi.Response{APIVersion: "templates.weave.works/plugin/v0"}, nil
+	})
+
+	errorTests := []struct {
+		name    string
+		objs    []runtime.Object
+		wantErr string
+	}{
+		{
+			name:    "missing ConfigMap",
+			wantErr: `failed to load ConfigMap for Plugin generator default/test-plugin: configmaps "test-plugin" not found`,
+		},
+		{
+			name:    "ConfigMap without a url",
+			objs:    []runtime.Object{newPluginConfigMap(map[string]string{})},
+			wantErr: "plugin ConfigMap default/test-plugin does not contain url key",
+		},
+		{
+			name:    "invalid timeout",
+			objs:    []runtime.Object{newPluginConfigMap(map[string]string{URLKey: failing.URL, TimeoutKey: "soon"})},
+			wantErr: `failed to parse timeout in plugin ConfigMap default/test-plugin: time: invalid duration "soon"`,
+		},
+		{
+			name:    "missing token Secret",
+			objs:    []runtime.Object{newPluginConfigMap(map[string]string{URLKey: failing.URL, TokenSecretKey: "plugin-token"})},
+			wantErr: `failed to load Secret for Plugin generator default/plugin-token: secrets "plugin-token" not found`,
+		},
+		{
+			name:    "error response",
+			objs:    []runtime.Object{newPluginConfigMap(map[string]string{URLKey: failing.URL})},
+			wantErr: "got 500 response from endpoint " + failing.URL,
+		},
+		{
+			name:    "unsupported version",
+			objs:    []runtime.Object{newPluginConfigMap(map[string]string{URLKey: invalid.URL})},
+			wantErr: `invalid response from plugin ` + invalid.URL + `: unsupported apiVersion "templates.weave.works/plugin/v0" in plugin response`,
+		},
+	}
+
+	for _, tt := range errorTests {
+		t.Run(tt.name, func(t *testing.T) {
+			gen := NewGenerator(logr.Discard(), newFakeClient(t, tt.objs...), apiclient.DefaultClientFactory)
+
+			_, err := gen.Generate(context.TODO(), newGenerator(""), newGitOpsSet())
+			test.AssertErrorMatch(t, tt.wantErr, err)
+		})
+	}
+}
+
+func TestGenerate_error_status(t *testing.T) {
+	failing := plugintest.NewServer(t, func(ctx context.Context, req pluginapi.Request) (pluginapi.Response, error) {
+		return pluginapi.Response{}, errors.New("inventory unavailable")
+	})
+	gen := NewGenerator(logr.Discard(), newFakeClient(t, newPluginConfigMap(map[string]string{URLKey: failing.URL})), apiclient.DefaultClientFactory)
+
+	_, err := gen.Generate(context.TODO(), newGenerator(""), newGitOpsSet())
+
+	var statusErr generators.HTTPStatusError
+	if !errors.As(err, &statusErr) {
+		t.Fatalf("got error %v, want an HTTPStatusError", err)
+	}
+	if statusErr.StatusCode != http.StatusInternalServerError {
+		t.Fatalf("got status code %d, want %d", statusErr.StatusCode, http.StatusInternalServerError)
+	}
+}
+
+func TestInterval(t *testing.T) {
+	gen := NewGenerator(logr.Discard(), nil, apiclient.DefaultClientFactory)
+	sg := newGenerator("")
+	sg.Plugin.Interval = metav1.Duration{Duration: 10 * time.Minute}
+
+	if d := gen.Interval(sg); d != 10*time.Minute {
+		t.Fatalf("got %v, want %v", d, 10*time.Minute)
+	}
+}
+
+func TestDependencies(t *testing.T) {
+	gen := NewGenerator(logr.Discard(), nil, apiclient.DefaultClientFactory)
+
+	want := []generators.Dependency{
+		{Kind: "ConfigMap", ObjectKey: client.ObjectKey{Name: "test-plugin", Namespace: "default"}},
+	}
+	if diff := cmp.Diff(want, gen.Dependencies(newGenerator(""), newGitOpsSet())); diff != "" {
+		t.Fatalf("failed to get dependencies:\n%s", diff)
+	}
+}
+
+func newGenerator(input string) *templatesv1.GitOpsSetGenerator {
+	sg := &templatesv1.GitOpsSetGenerator{
+		Plugin: &templatesv1.PluginGenerator{
+			ConfigMapRef: corev1.LocalObjectReference{Name: "test-plugin"},
+		},
+	}
+	if input != "" {
+		sg.Plugin.Input = &apiextensionsv1.JSON{Raw: []byte(input)}
+	}
+
+	return sg
+}
+
+func newGitOpsSet(opts ...func(*templatesv1.GitOpsSet)) *templatesv1.GitOpsSet {
+	gs := &templatesv1.GitOpsSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "demo-set", Namespace: "default"},
+	}
+	for _, opt := range opts {
+		opt(gs)
+	}
+
+	return gs
+}
+
+func newPluginConfigMap(data map[string]string) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-plugin", Namespace: "default"},
+		Data:       data,
+	}
+}
+
+func newFakeClient(t *testing.T, objs ...runtime.Object) client.Client {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	test.AssertNoError(t, clientgoscheme.AddToScheme(scheme))
+
+	return fake.NewClientBuilder().WithScheme(scheme).WithRuntimeObjects(objs...).Build()
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
@@ -0,0 +1,26 @@
+package plugin
+
+import (
+	templatesv1 "github.com/weaveworks/gitopssets-controller/api/v1beta1"
+	"github.com/weaveworks/gitopssets-controller/controllers/templates/generators"
+	"github.com/weaveworks/gitopssets-controller/controllers/templates/generators/apiclient"
+	"github.com/weaveworks/gitopssets-controller/pkg/registry"
+)
+
+func init() {
+	registry.Register(registry.Registration{
+		Name: "Plugin",
+		Configured: func(sg *templatesv1.GitOpsSetGenerator) bool {
+			return sg.Plugin != nil
+		},
+		Default:  true,
+		Nestable: true,
+		Factory: func(opts registry.Options) generators.GeneratorFactory {
+			if opts.HTTPClientFactory == nil {
+				return GeneratorFactory(apiclient.DefaultClientFactory)
+			}
+
+			return GeneratorFactory(opts.HTTPClientFactory)
+		},
+	})
+}
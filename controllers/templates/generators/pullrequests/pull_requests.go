@@ -3,13 +3,14 @@ package pullrequests
 import (
 	"context"
 	"fmt"
+	"net/http"
 	"strconv"
 	"time"
 
 	"github.com/go-logr/logr"
 	"github.com/jenkins-x/go-scm/scm"
 	"github.com/jenkins-x/go-scm/scm/factory"
-	templatesv1 "github.com/weaveworks/gitopssets-controller/api/v1alpha1"
+	templatesv1 "github.com/weaveworks/gitopssets-controller/api/v1beta1"
 	"github.com/weaveworks/gitopssets-controller/controllers/templates/generators"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/types"
@@ -80,8 +81,11 @@ func (g *PullRequestGenerator) Generate(ctx context.Context, sg *templatesv1.Git
 		return nil, fmt.Errorf("failed to create client: %w", err)
 	}
 
-	prs, _, err := scmClient.PullRequests.List(ctx, sg.PullRequests.Repo, listOptionsFromConfig(sg.PullRequests))
+	prs, resp, err := scmClient.PullRequests.List(ctx, sg.PullRequests.Repo, listOptionsFromConfig(sg.PullRequests))
 	if err != nil {
+		if resp != nil && resp.Status >= http.StatusBadRequest {
+			err = generators.HTTPStatusError{StatusCode: resp.Status, Endpoint: sg.PullRequests.ServerURL, Err: err}
+		}
 		return nil, fmt.Errorf("failed to list pull requests: %w", err)
 	}
 
@@ -92,9 +96,9 @@ func (g *PullRequestGenerator) Generate(ctx context.Context, sg *templatesv1.Git
 			continue
 		}
 
-		// if Forks flag is set to false, and repo is a fork, don't include it
+		// forks are only included if the ForkPolicy includes them
 		isFork := sg.PullRequests.Repo != pr.Fork
-		if !sg.PullRequests.Forks && isFork {
+		if sg.PullRequests.ForkPolicy != templatesv1.IncludeForkPolicy && isFork {
 			continue
 		}
 		// TODO: This should provide additional fields, including the
@@ -120,6 +124,27 @@ func (g *PullRequestGenerator) Interval(sg *templatesv1.GitOpsSetGenerator) time
 	return sg.PullRequests.Interval.Duration
 }
 
+// DependencyKinds is an implementation of the generators.DependentGenerator
+// interface.
+func (g *PullRequestGenerator) DependencyKinds() []client.Object {
+	return []client.Object{&corev1.Secret{}}
+}
+
+// Dependencies is an implementation of the generators.DependentGenerator
+// interface.
+//
+// Changes to the credentials Secret trigger the generation of the pull
+// requests before the next poll.
+func (g *PullRequestGenerator) Dependencies(sg *templatesv1.GitOpsSetGenerator, ks *templatesv1.GitOpsSet) []generators.Dependency {
+	if sg.PullRequests == nil || sg.PullRequests.SecretRef == nil {
+		return nil
+	}
+
+	return []generators.Dependency{
+		{Kind: "Secret", ObjectKey: client.ObjectKey{Name: sg.PullRequests.SecretRef.Name, Namespace: ks.GetNamespace()}},
+	}
+}
+
 // label filtering is only supported by GitLab (that I'm aware of)
 // The fetched PRs are filtered on labels across all providers, but providing
 // the labels optimises the load from GitLab.
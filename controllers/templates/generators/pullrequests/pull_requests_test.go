@@ -11,7 +11,7 @@ import (
 	"github.com/jenkins-x/go-scm/scm"
 	fakescm "github.com/jenkins-x/go-scm/scm/driver/fake"
 	"github.com/jenkins-x/go-scm/scm/factory"
-	templatesv1 "github.com/weaveworks/gitopssets-controller/api/v1alpha1"
+	templatesv1 "github.com/weaveworks/gitopssets-controller/api/v1beta1"
 	"github.com/weaveworks/gitopssets-controller/controllers/templates/generators"
 	"github.com/weaveworks/gitopssets-controller/test"
 	corev1 "k8s.io/api/core/v1"
@@ -22,6 +22,7 @@ import (
 )
 
 var _ generators.Generator = (*PullRequestGenerator)(nil)
+var _ generators.DependentGenerator = (*PullRequestGenerator)(nil)
 
 func TestGenerate_with_no_generator(t *testing.T) {
 	gen := GeneratorFactory(logr.Discard(), nil)
@@ -51,7 +52,7 @@ func TestGenerate(t *testing.T) {
 		initObjs      []runtime.Object
 		secretRef     *corev1.LocalObjectReference
 		labels        []string
-		forks         bool
+		forkPolicy    templatesv1.ForkPolicy
 		clientFactory func(*scm.Client) clientFactoryFunc
 		want          []map[string]any
 	}{
@@ -77,7 +78,7 @@ func TestGenerate(t *testing.T) {
 					Fork: "test-org/my-repo",
 				}
 			},
-			forks:         false,
+			forkPolicy:    templatesv1.ExcludeForkPolicy,
 			clientFactory: defaultClientFactory,
 			want: []map[string]any{
 				{
@@ -132,7 +133,7 @@ func TestGenerate(t *testing.T) {
 				}
 			},
 			labels:        []string{"testing"},
-			forks:         false,
+			forkPolicy:    templatesv1.ExcludeForkPolicy,
 			clientFactory: defaultClientFactory,
 			want: []map[string]any{
 				{
@@ -153,7 +154,7 @@ func TestGenerate(t *testing.T) {
 					Namespace: "default",
 				}),
 			},
-			forks: false,
+			forkPolicy: templatesv1.ExcludeForkPolicy,
 			clientFactory: func(c *scm.Client) clientFactoryFunc {
 				return func(_, _, auth string, opts ...factory.ClientOptionFunc) (*scm.Client, error) {
 					if auth != "top-secret" {
@@ -219,7 +220,7 @@ func TestGenerate(t *testing.T) {
 					Fork: "test-org-2/my-repo-fork",
 				}
 			},
-			forks:         true,
+			forkPolicy:    templatesv1.IncludeForkPolicy,
 			clientFactory: defaultClientFactory,
 			want: []map[string]any{
 				{
@@ -273,7 +274,7 @@ func TestGenerate(t *testing.T) {
 					Labels: []*scm.Label{{Name: "testing"}},
 				}
 			},
-			forks:         false,
+			forkPolicy:    templatesv1.ExcludeForkPolicy,
 			clientFactory: defaultClientFactory,
 			want: []map[string]any{
 				{
@@ -297,12 +298,12 @@ func TestGenerate(t *testing.T) {
 
 			gsg := templatesv1.GitOpsSetGenerator{
 				PullRequests: &templatesv1.PullRequestGenerator{
-					Driver:    "fake",
-					ServerURL: "https://example.com",
-					Repo:      "test-org/my-repo",
-					SecretRef: tt.secretRef,
-					Labels:    tt.labels,
-					Forks:     tt.forks,
+					Driver:     "fake",
+					ServerURL:  "https://example.com",
+					Repo:       "test-org/my-repo",
+					SecretRef:  tt.secretRef,
+					Labels:     tt.labels,
+					ForkPolicy: tt.forkPolicy,
 				},
 			}
 
@@ -411,6 +412,30 @@ func TestPullRequestGenerator_GetInterval(t *testing.T) {
 	}
 }
 
+func TestPullRequestGenerator_Dependencies(t *testing.T) {
+	gen := NewGenerator(logr.Discard(), fake.NewFakeClient())
+	gs := &templatesv1.GitOpsSet{ObjectMeta: metav1.ObjectMeta{Name: "demo-set", Namespace: "default"}}
+	sg := &templatesv1.GitOpsSetGenerator{
+		PullRequests: &templatesv1.PullRequestGenerator{
+			Driver:    "fake",
+			ServerURL: "https://example.com",
+			Repo:      "test-org/my-repo",
+		},
+	}
+
+	if deps := gen.Dependencies(sg, gs); deps != nil {
+		t.Fatalf("got dependencies %v without a SecretRef", deps)
+	}
+
+	sg.PullRequests.SecretRef = &corev1.LocalObjectReference{Name: "test-secret"}
+	want := []generators.Dependency{
+		{Kind: "Secret", ObjectKey: types.NamespacedName{Name: "test-secret", Namespace: "default"}},
+	}
+	if diff := cmp.Diff(want, gen.Dependencies(sg, gs)); diff != "" {
+		t.Fatalf("failed to get dependencies:\n%s", diff)
+	}
+}
+
 func newSecret(name types.NamespacedName, opts ...func(*corev1.Secret)) *corev1.Secret {
 	s := &corev1.Secret{
 		TypeMeta: metav1.TypeMeta{
@@ -1,8 +1,10 @@
 package generators
 
 import (
+	"encoding/json"
 	"fmt"
-	"reflect"
+
+	templatesv1 "github.com/weaveworks/gitopssets-controller/api/v1beta1"
 )
 
 // GeneratorNotEnabledError is returned when a generator is not enabled
@@ -18,27 +20,20 @@ func (g GeneratorNotEnabledError) Error() string {
 	return fmt.Sprintf("generator %s not enabled", g.Name)
 }
 
-// FindRelevantGenerators takes a struct with keys of the same type as
-// Generators in the map and finds relevant generators.
-func FindRelevantGenerators(setGenerator any, enabledGenerators map[string]Generator) ([]Generator, error) {
-	res := []Generator{}
-	v := reflect.Indirect(reflect.ValueOf(setGenerator))
-	for i := 0; i < v.NumField(); i++ {
-		field := v.Field(i)
-		fieldName := v.Type().Field(i).Name
-		if !field.CanInterface() || fieldName == "Name" {
-			continue
-		}
+// MakeGitOpsSetGenerator converts a GitOpsSetNestedGenerator struct to a
+// GitOpsSetGenerator struct.
+// This is needed because MatrixGenerator includes GitOpsSetNestedGenerator
+// structs, but generators are configured from GitOpsSetGenerator structs.
+func MakeGitOpsSetGenerator(mg *templatesv1.GitOpsSetNestedGenerator) (*templatesv1.GitOpsSetGenerator, error) {
+	mgJSON, err := json.Marshal(mg)
+	if err != nil {
+		return nil, err
+	}
 
-		if !reflect.ValueOf(field.Interface()).IsNil() {
-			gen, ok := enabledGenerators[fieldName]
-			if !ok {
-				return nil, GeneratorNotEnabledError{Name: fieldName}
-			}
-			res = append(res, gen)
-			continue
-		}
+	var gs templatesv1.GitOpsSetGenerator
+	if err = json.Unmarshal(mgJSON, &gs); err != nil {
+		return nil, err
 	}
 
-	return res, nil
+	return &gs, nil
 }
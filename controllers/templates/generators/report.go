@@ -0,0 +1,64 @@
+package generators
+
+import (
+	"context"
+)
+
+// GenerationReport records details of the elements generated by a generator.
+//
+// Generators record the revision of the source they consumed, and the Matrix
+// generator records the results of its named nested generators.
+//
+// The revisions recorded by the previous generation are provided to the
+// generators in PreviousRevision and PreviousNested.
+type GenerationReport struct {
+	Revision string
+	Nested   []NestedGenerationReport
+
+	PreviousRevision string
+	PreviousNested   map[string]string
+}
+
+// NestedGenerationReport records the result of a named generator within a
+// Matrix generator.
+type NestedGenerationReport struct {
+	Name     string
+	Type     string
+	Elements int
+	Revision string
+	Err      error
+}
+
+type reportKey struct{}
+
+// WithReport returns a context that generators record details of the
+// generation in.
+func WithReport(ctx context.Context, report *GenerationReport) context.Context {
+	return context.WithValue(ctx, reportKey{}, report)
+}
+
+// ReportFromContext returns the report in the context, or nil if there is no
+// report.
+func ReportFromContext(ctx context.Context) *GenerationReport {
+	report, _ := ctx.Value(reportKey{}).(*GenerationReport)
+	return report
+}
+
+// RecordRevision records the revision of the source that was consumed by the
+// generator, this could be the revision of an artifact, or the ETag of an HTTP
+// response.
+func RecordRevision(ctx context.Context, revision string) {
+	if report := ReportFromContext(ctx); report != nil {
+		report.Revision = revision
+	}
+}
+
+// PreviousRevision returns the revision that was recorded by the generator in
+// the previous generation, or an empty string if it's not known.
+func PreviousRevision(ctx context.Context) string {
+	if report := ReportFromContext(ctx); report != nil {
+		return report.PreviousRevision
+	}
+
+	return ""
+}
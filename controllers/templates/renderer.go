@@ -3,8 +3,12 @@ package templates
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"strconv"
 	"strings"
 	"text/template"
 
@@ -14,12 +18,14 @@ import (
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	yamlserializer "k8s.io/apimachinery/pkg/runtime/serializer/yaml"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/apimachinery/pkg/util/yaml"
 	"k8s.io/client-go/util/jsonpath"
 	syaml "sigs.k8s.io/yaml"
 
-	templatesv1 "github.com/weaveworks/gitopssets-controller/api/v1alpha1"
+	templatesv1 "github.com/weaveworks/gitopssets-controller/api/v1beta1"
 	"github.com/weaveworks/gitopssets-controller/controllers/templates/generators"
+	"github.com/weaveworks/gitopssets-controller/pkg/registry"
 )
 
 // TemplateDelimiterAnnotation can be added to a Template to change the Go
@@ -30,36 +36,172 @@ import (
 // {{ and }}.
 const TemplateDelimiterAnnotation string = "templates.weave.works/delimiters"
 
+const (
+	// NameLabel is added to all generated resources and records the name of
+	// the GitOpsSet that generated the resource.
+	NameLabel string = "templates.weave.works/name"
+
+	// NamespaceLabel is added to all generated resources and records the
+	// namespace of the GitOpsSet that generated the resource.
+	NamespaceLabel string = "templates.weave.works/namespace"
+)
+
+// PruneAnnotation can be added to a Template with the value "disabled" to
+// prevent the controller from deleting the generated resource when it is no
+// longer generated, or the GitOpsSet is deleted.
+//
+// The resource is orphaned instead.
+const PruneAnnotation string = "templates.weave.works/prune"
+
+// WaveAnnotation is added to generated resources from templates with a wave,
+// and records the wave that the resource is applied in.
+//
+// This can also be added to resources in the template content to put
+// individual resources in different waves, and takes precedence over the wave
+// on the template.
+const WaveAnnotation string = "templates.weave.works/wave"
+
+// TargetClusterAnnotation is added to generated resources from templates with
+// a target cluster, and records the GitopsCluster that the resource is applied
+// to, in the format "namespace/name".
+//
+// This can also be added to resources in the template content, and takes
+// precedence over the target cluster on the template.
+const TargetClusterAnnotation string = "templates.weave.works/target-cluster"
+
 var templateFuncs template.FuncMap = makeTemplateFunctions()
 
+// GeneratedElements are the elements generated by each of the generators in a
+// GitOpsSet, by the key of the generator.
+type GeneratedElements map[string][]map[string]any
+
+// GeneratorKey returns the key for the elements generated by a generator, this
+// is a digest of the generator, so that the elements are not used for another
+// generator when the generators are reordered, added or removed.
+func GeneratorKey(gen templatesv1.GitOpsSetGenerator) (string, error) {
+	b, err := json.Marshal(gen)
+	if err != nil {
+		return "", fmt.Errorf("failed to calculate key for generator: %w", err)
+	}
+
+	return fmt.Sprintf("sha256:%x", sha256.Sum256(b)), nil
+}
+
+// RenderResult is the result of rendering a GitOpsSet.
+type RenderResult struct {
+	// Resources are the rendered resources.
+	Resources []*unstructured.Unstructured
+
+	// Elements are the elements that were used for each generator, including
+	// elements that were kept from an earlier generation.
+	Elements GeneratedElements
+
+	// Degraded is the error from the generators that failed, when the
+	// generator error policy allows the resources to be rendered without them.
+	Degraded error
+}
+
 // Render parses the GitOpsSet and renders the template resources using
 // the configured generators and templates.
+//
+// All the generators are run, even if one fails, and the status of each
+// generator is recorded in the status of the GitOpsSet.
 func Render(ctx context.Context, r *templatesv1.GitOpsSet, configuredGenerators map[string]generators.Generator) ([]*unstructured.Unstructured, error) {
-	rendered := []*unstructured.Unstructured{}
+	result, err := RenderWithElements(ctx, r, configuredGenerators, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return result.Resources, nil
+}
+
+// RenderWithElements renders the GitOpsSet in the same way as Render, applying
+// the generator error policy of the GitOpsSet to failed generators.
+//
+// With the KeepLast policy, the last elements are used for generators that
+// fail, generators that fail without last elements fail the rendering.
+//
+// With the Skip policy, the resources are not rendered for generators that
+// fail, the last elements are kept, so that the ElementIndex of the elements
+// from the later generators doesn't change.
+func RenderWithElements(ctx context.Context, r *templatesv1.GitOpsSet, configuredGenerators map[string]generators.Generator, last GeneratedElements) (*RenderResult, error) {
+	previous := r.Status.Generators
+	var statuses []templatesv1.GeneratorStatus
+	var generateErr, degradedErr error
+
+	result := &RenderResult{Elements: GeneratedElements{}}
+	keys := make([]string, len(r.Spec.Generators))
+	skipped := map[string]bool{}
+	for i, gen := range r.Spec.Generators {
+		key, err := GeneratorKey(gen)
+		if err != nil {
+			return nil, err
+		}
+		keys[i] = key
+
+		report := previousReport(previous, i)
+		generated, err := generate(generators.WithReport(ctx, report), gen, configuredGenerators, r)
+
+		statuses = append(statuses, generatorStatus(previous, i, "", generatorType(gen), countElements(generated), report.Revision, err))
+		for _, nested := range report.Nested {
+			statuses = append(statuses, generatorStatus(previous, i, nested.Name, nested.Type, nested.Elements, nested.Revision, nested.Err))
+		}
 
-	index := 0
-	for _, gen := range r.Spec.Generators {
-		generated, err := generate(ctx, gen, configuredGenerators, r)
 		if err != nil {
-			return nil, fmt.Errorf("failed to generate template for set %s: %w", r.GetName(), err)
+			err = fmt.Errorf("failed to generate template for set %s: %w", r.GetName(), err)
+			switch elements, ok := last[key]; {
+			case r.Spec.GeneratorErrorPolicy == templatesv1.KeepLastGeneratorErrorPolicy && ok:
+				result.Elements[key] = elements
+				degradedErr = errors.Join(degradedErr, err)
+			case r.Spec.GeneratorErrorPolicy == templatesv1.SkipGeneratorErrorPolicy:
+				if ok {
+					result.Elements[key] = elements
+				}
+				skipped[key] = true
+				degradedErr = errors.Join(degradedErr, err)
+			default:
+				generateErr = errors.Join(generateErr, err)
+			}
+			continue
 		}
 
+		var elements []map[string]any
 		for _, params := range generated {
-			for _, param := range params {
-				for _, template := range r.Spec.Templates {
-					res, err := renderTemplateParams(index, template, param, *r)
-					if err != nil {
-						return nil, fmt.Errorf("failed to render template params for set %s: %w", r.GetName(), err)
-					}
-
-					rendered = append(rendered, res...)
-					index++
+			elements = append(elements, params...)
+		}
+		result.Elements[key] = elements
+	}
+	r.Status.Generators = statuses
+
+	if generateErr != nil {
+		return nil, generateErr
+	}
+	result.Degraded = degradedErr
+
+	rendered := []*unstructured.Unstructured{}
+
+	index := 0
+	for _, key := range keys {
+		if skipped[key] {
+			index += len(result.Elements[key]) * len(r.Spec.Templates)
+			continue
+		}
+
+		for _, param := range result.Elements[key] {
+			for _, template := range r.Spec.Templates {
+				res, err := renderTemplateParams(index, template, param, *r)
+				if err != nil {
+					return nil, fmt.Errorf("failed to render template params for set %s: %w", r.GetName(), err)
 				}
+
+				rendered = append(rendered, res...)
+				index++
 			}
 		}
 	}
+	result.Resources = rendered
 
-	return rendered, nil
+	return result, nil
 }
 
 func repeat(index int, tmpl templatesv1.GitOpsSetTemplate, params map[string]any) ([]map[string]any, error) {
@@ -140,6 +282,14 @@ func renderTemplateParams(index int, tmpl templatesv1.GitOpsSetTemplate, params
 			return nil, err
 		}
 
+		var targetCluster []byte
+		if tmpl.TargetCluster != "" {
+			targetCluster, err = render([]byte(tmpl.TargetCluster), p, gs)
+			if err != nil {
+				return nil, fmt.Errorf("failed to render target cluster: %w", err)
+			}
+		}
+
 		// Technically multiple objects could be in the YAML...
 		decoder := yaml.NewYAMLOrJSONDecoder(bytes.NewReader(rendered), 100)
 		for {
@@ -171,8 +321,8 @@ func renderTemplateParams(index int, tmpl templatesv1.GitOpsSetTemplate, params
 
 			// Add source labels
 			labels := map[string]string{
-				"templates.weave.works/name":      gs.GetName(),
-				"templates.weave.works/namespace": gs.GetNamespace(),
+				NameLabel:      gs.GetName(),
+				NamespaceLabel: gs.GetNamespace(),
 			}
 
 			renderedLabels := uns.GetLabels()
@@ -181,6 +331,21 @@ func renderTemplateParams(index int, tmpl templatesv1.GitOpsSetTemplate, params
 			}
 			uns.SetLabels(labels)
 
+			if tmpl.Wave != 0 {
+				annotations := uns.GetAnnotations()
+				if annotations == nil {
+					annotations = map[string]string{}
+				}
+				if _, ok := annotations[WaveAnnotation]; !ok {
+					annotations[WaveAnnotation] = strconv.Itoa(int(tmpl.Wave))
+					uns.SetAnnotations(annotations)
+				}
+			}
+
+			if err := setTargetCluster(uns, strings.TrimSpace(string(targetCluster)), gs); err != nil {
+				return nil, err
+			}
+
 			objects = append(objects, uns)
 		}
 	}
@@ -188,11 +353,60 @@ func renderTemplateParams(index int, tmpl templatesv1.GitOpsSetTemplate, params
 	return objects, nil
 }
 
+// setTargetCluster records the target cluster in the annotations of the
+// resource, unless the resource already has a target cluster.
+//
+// Target clusters without a namespace are qualified with the namespace of the
+// GitOpsSet.
+func setTargetCluster(uns *unstructured.Unstructured, targetCluster string, gs templatesv1.GitOpsSet) error {
+	annotations := uns.GetAnnotations()
+	if v, ok := annotations[TargetClusterAnnotation]; ok {
+		targetCluster = v
+	}
+	if targetCluster == "" {
+		return nil
+	}
+
+	if err := checkTargetCluster(targetCluster); err != nil {
+		return fmt.Errorf("invalid target cluster %q: %w", targetCluster, err)
+	}
+
+	if !strings.Contains(targetCluster, "/") {
+		targetCluster = gs.GetNamespace() + "/" + targetCluster
+	}
+
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[TargetClusterAnnotation] = targetCluster
+	uns.SetAnnotations(annotations)
+
+	return nil
+}
+
+// checkTargetCluster returns an error if the target cluster is not in the
+// format "namespace/name" or "name".
+func checkTargetCluster(targetCluster string) error {
+	parts := strings.Split(targetCluster, "/")
+	if len(parts) > 2 {
+		return errors.New(`must be in the format "namespace/name" or "name"`)
+	}
+
+	if len(parts) == 2 {
+		if errs := validation.IsDNS1123Label(parts[0]); len(errs) > 0 {
+			return fmt.Errorf("invalid namespace: %s", strings.Join(errs, ", "))
+		}
+	}
+
+	if errs := validation.IsDNS1123Subdomain(parts[len(parts)-1]); len(errs) > 0 {
+		return fmt.Errorf("invalid name: %s", strings.Join(errs, ", "))
+	}
+
+	return nil
+}
+
 func render(b []byte, params map[string]any, gs templatesv1.GitOpsSet) ([]byte, error) {
-	t, err := template.New(fmt.Sprintf("%s/%s", gs.GetNamespace(), gs.GetName())).
-		Option("missingkey=error").
-		Delims(templateDelims(gs)).
-		Funcs(templateFuncs).Parse(string(b))
+	t, err := parseTemplate(b, gs)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse template: %w", err)
 	}
@@ -209,6 +423,15 @@ func render(b []byte, params map[string]any, gs templatesv1.GitOpsSet) ([]byte,
 	return out.Bytes(), nil
 }
 
+// parseTemplate parses the template content with the delimiters and functions
+// that are used when rendering.
+func parseTemplate(b []byte, gs templatesv1.GitOpsSet) (*template.Template, error) {
+	return template.New(fmt.Sprintf("%s/%s", gs.GetNamespace(), gs.GetName())).
+		Option("missingkey=error").
+		Delims(templateDelims(gs)).
+		Funcs(templateFuncs).Parse(string(b))
+}
+
 func templateParams(gs templatesv1.GitOpsSet) map[string]any {
 	return map[string]any{
 		"GitOpsSet": map[string]any{
@@ -220,7 +443,7 @@ func templateParams(gs templatesv1.GitOpsSet) map[string]any {
 
 func generate(ctx context.Context, generator templatesv1.GitOpsSetGenerator, allGenerators map[string]generators.Generator, gitopsSet *templatesv1.GitOpsSet) ([][]map[string]any, error) {
 	generated := [][]map[string]any{}
-	generators, err := generators.FindRelevantGenerators(&generator, allGenerators)
+	generators, err := registry.Default.FindRelevantGenerators(&generator, allGenerators)
 	if err != nil {
 		return nil, err
 	}
@@ -4,11 +4,14 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/go-logr/logr"
 	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
 	corev1 "k8s.io/api/core/v1"
 	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -18,7 +21,7 @@ import (
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/intstr"
 
-	templatesv1 "github.com/weaveworks/gitopssets-controller/api/v1alpha1"
+	templatesv1 "github.com/weaveworks/gitopssets-controller/api/v1beta1"
 	"github.com/weaveworks/gitopssets-controller/controllers/templates/generators"
 	"github.com/weaveworks/gitopssets-controller/controllers/templates/generators/list"
 	"github.com/weaveworks/gitopssets-controller/pkg/setup"
@@ -446,6 +449,82 @@ func TestRender(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "template with a wave",
+			elements: []apiextensionsv1.JSON{
+				{Raw: []byte(`{"env": "engineering-dev","externalIP": "192.168.50.50"}`)},
+			},
+			setOptions: []func(*templatesv1.GitOpsSet){
+				func(s *templatesv1.GitOpsSet) {
+					s.Spec.Templates = []templatesv1.GitOpsSetTemplate{
+						{
+							Wave: 2,
+							Content: runtime.RawExtension{
+								Raw: mustMarshalJSON(t, makeTestNamespace("{{ .Element.env }}")),
+							},
+						},
+						{
+							Wave: 2,
+							Content: runtime.RawExtension{
+								Raw: mustMarshalJSON(t, makeTestNamespace("{{ .Element.env }}-apps", func(ns *corev1.Namespace) {
+									ns.ObjectMeta.Annotations = map[string]string{
+										"templates.weave.works/wave": "3",
+									}
+								})),
+							},
+						},
+					}
+				},
+			},
+			want: []*unstructured.Unstructured{
+				test.ToUnstructured(t, makeTestNamespace("engineering-dev", func(ns *corev1.Namespace) {
+					ns.ObjectMeta.Annotations = map[string]string{"templates.weave.works/wave": "2"}
+					ns.ObjectMeta.Labels = map[string]string{"templates.weave.works/name": "test-gitops-set", "templates.weave.works/namespace": testNS}
+				})),
+				test.ToUnstructured(t, makeTestNamespace("engineering-dev-apps", func(ns *corev1.Namespace) {
+					ns.ObjectMeta.Annotations = map[string]string{"templates.weave.works/wave": "3"}
+					ns.ObjectMeta.Labels = map[string]string{"templates.weave.works/name": "test-gitops-set", "templates.weave.works/namespace": testNS}
+				})),
+			},
+		},
+		{
+			name: "template with a target cluster",
+			elements: []apiextensionsv1.JSON{
+				{Raw: []byte(`{"env": "engineering-dev","cluster": "dev-cluster"}`)},
+			},
+			setOptions: []func(*templatesv1.GitOpsSet){
+				func(s *templatesv1.GitOpsSet) {
+					s.Spec.Templates = []templatesv1.GitOpsSetTemplate{
+						{
+							TargetCluster: "{{ .Element.cluster }}",
+							Content: runtime.RawExtension{
+								Raw: mustMarshalJSON(t, makeTestNamespace("{{ .Element.env }}")),
+							},
+						},
+						{
+							TargetCluster: "{{ .Element.cluster }}",
+							Content: runtime.RawExtension{
+								Raw: mustMarshalJSON(t, makeTestNamespace("{{ .Element.env }}-apps", func(ns *corev1.Namespace) {
+									ns.ObjectMeta.Annotations = map[string]string{
+										"templates.weave.works/target-cluster": "clusters/apps-cluster",
+									}
+								})),
+							},
+						},
+					}
+				},
+			},
+			want: []*unstructured.Unstructured{
+				test.ToUnstructured(t, makeTestNamespace("engineering-dev", func(ns *corev1.Namespace) {
+					ns.ObjectMeta.Annotations = map[string]string{"templates.weave.works/target-cluster": "demo/dev-cluster"}
+					ns.ObjectMeta.Labels = map[string]string{"templates.weave.works/name": "test-gitops-set", "templates.weave.works/namespace": testNS}
+				})),
+				test.ToUnstructured(t, makeTestNamespace("engineering-dev-apps", func(ns *corev1.Namespace) {
+					ns.ObjectMeta.Annotations = map[string]string{"templates.weave.works/target-cluster": "clusters/apps-cluster"}
+					ns.ObjectMeta.Labels = map[string]string{"templates.weave.works/name": "test-gitops-set", "templates.weave.works/namespace": testNS}
+				})),
+			},
+		},
 		{
 			name: "toYaml function",
 			elements: []apiextensionsv1.JSON{
@@ -600,6 +679,32 @@ func TestRender_errors(t *testing.T) {
 			},
 			wantErr: `failed to render template.*at <.element.env>: map has no entry for key "element"`,
 		},
+		{
+			name: "invalid target cluster",
+			setOptions: []func(*templatesv1.GitOpsSet){
+				func(gs *templatesv1.GitOpsSet) {
+					gs.Spec.Generators = []templatesv1.GitOpsSetGenerator{
+						{
+							List: &templatesv1.ListGenerator{
+								Elements: []apiextensionsv1.JSON{
+									{Raw: []byte(`{"env": "engineering-dev","cluster": "clusters/dev/eu"}`)},
+								},
+							},
+						},
+					}
+
+					gs.Spec.Templates = []templatesv1.GitOpsSetTemplate{
+						{
+							TargetCluster: "{{ .Element.cluster }}",
+							Content: runtime.RawExtension{
+								Raw: mustMarshalJSON(t, makeTestNamespace("{{ .Element.env }}")),
+							},
+						},
+					}
+				},
+			},
+			wantErr: `invalid target cluster "clusters/dev/eu": must be in the format "namespace/name" or "name"`,
+		},
 	}
 	testGenerators := map[string]generators.Generator{
 		"List": list.NewGenerator(logr.Discard()),
@@ -626,6 +731,205 @@ func TestRender_disabled(t *testing.T) {
 	}
 }
 
+func TestRender_generatorStatus(t *testing.T) {
+	testGenerators := map[string]generators.Generator{
+		"List":          list.NewGenerator(logr.Discard()),
+		"GitRepository": failingGenerator{err: errors.New("no artifact")},
+	}
+	lastGenerated := metav1.NewTime(time.Date(2023, time.January, 1, 0, 0, 0, 0, time.UTC))
+	gset := makeTestGitOpsSet(t, listElements([]apiextensionsv1.JSON{
+		{Raw: []byte(`{"env": "engineering-dev","externalIP": "192.168.50.50"}`)},
+		{Raw: []byte(`{"env": "engineering-prod","externalIP": "192.168.100.20"}`)},
+	}), func(gs *templatesv1.GitOpsSet) {
+		gs.Spec.Generators = append(gs.Spec.Generators, templatesv1.GitOpsSetGenerator{
+			GitRepository: &templatesv1.GitRepositoryGenerator{RepositoryRef: "test-repo"},
+		})
+		gs.Status.Generators = []templatesv1.GeneratorStatus{
+			{Index: 1, Type: "GitRepository", LastGenerated: &lastGenerated},
+		}
+	})
+
+	_, err := Render(context.TODO(), gset, testGenerators)
+	test.AssertErrorMatch(t, "failed to generate template for set test-gitops-set: no artifact", err)
+
+	want := []templatesv1.GeneratorStatus{
+		{Index: 0, Type: "List", Elements: 2},
+		{Index: 1, Type: "GitRepository", LastGenerated: &lastGenerated, Error: "no artifact"},
+	}
+	if diff := cmp.Diff(want, gset.Status.Generators, cmpopts.IgnoreFields(templatesv1.GeneratorStatus{}, "LastGenerated")); diff != "" {
+		t.Fatalf("failed to record generator status:\n%s", diff)
+	}
+	if gset.Status.Generators[0].LastGenerated == nil {
+		t.Fatal("expected the successful generator to record the generation time")
+	}
+	if !gset.Status.Generators[1].LastGenerated.Equal(&lastGenerated) {
+		t.Fatalf("got LastGenerated %v, want %v", gset.Status.Generators[1].LastGenerated, lastGenerated)
+	}
+}
+
+func TestRender_previousRevision(t *testing.T) {
+	recorder := &revisionRecordingGenerator{}
+	testGenerators := map[string]generators.Generator{
+		"List": recorder,
+	}
+	gset := makeTestGitOpsSet(t, listElements(nil), func(gs *templatesv1.GitOpsSet) {
+		gs.Status.Generators = []templatesv1.GeneratorStatus{
+			{Index: 0, Type: "List", Revision: "sha256:previous"},
+		}
+	})
+
+	_, err := Render(context.TODO(), gset, testGenerators)
+	test.AssertNoError(t, err)
+
+	if recorder.previous != "sha256:previous" {
+		t.Fatalf("got previous revision %q, want %q", recorder.previous, "sha256:previous")
+	}
+	if rev := gset.Status.Generators[0].Revision; rev != "sha256:current" {
+		t.Fatalf("got revision %q, want %q", rev, "sha256:current")
+	}
+}
+
+func TestRenderWithElements_generatorErrorPolicy(t *testing.T) {
+	listGenerator := templatesv1.GitOpsSetGenerator{
+		List: &templatesv1.ListGenerator{Elements: []apiextensionsv1.JSON{{Raw: []byte(`{"env": "dev"}`)}}},
+	}
+	gitGenerator := templatesv1.GitOpsSetGenerator{
+		GitRepository: &templatesv1.GitRepositoryGenerator{RepositoryRef: "test-repo"},
+	}
+	listKey := mustGeneratorKey(t, listGenerator)
+	gitKey := mustGeneratorKey(t, gitGenerator)
+
+	policyTests := []struct {
+		name         string
+		policy       templatesv1.GeneratorErrorPolicy
+		generators   []templatesv1.GitOpsSetGenerator
+		last         GeneratedElements
+		wantErr      string
+		wantDegraded string
+		wantNames    []string
+	}{
+		{
+			name:    "fail policy",
+			policy:  templatesv1.FailGeneratorErrorPolicy,
+			last:    GeneratedElements{gitKey: {{"env": "staging"}}},
+			wantErr: "failed to generate template for set test-gitops-set: no artifact",
+		},
+		{
+			name:         "skip policy",
+			policy:       templatesv1.SkipGeneratorErrorPolicy,
+			wantDegraded: "failed to generate template for set test-gitops-set: no artifact",
+			wantNames:    []string{"dev-0"},
+		},
+		{
+			name:         "skip policy with last elements",
+			policy:       templatesv1.SkipGeneratorErrorPolicy,
+			generators:   []templatesv1.GitOpsSetGenerator{gitGenerator, listGenerator},
+			last:         GeneratedElements{gitKey: {{"env": "staging"}, {"env": "production"}}},
+			wantDegraded: "failed to generate template for set test-gitops-set: no artifact",
+			wantNames:    []string{"dev-2"},
+		},
+		{
+			name:         "keep last policy",
+			policy:       templatesv1.KeepLastGeneratorErrorPolicy,
+			last:         GeneratedElements{listKey: {{"env": "production"}}, gitKey: {{"env": "staging"}}},
+			wantDegraded: "failed to generate template for set test-gitops-set: no artifact",
+			wantNames:    []string{"dev-0", "staging-1"},
+		},
+		{
+			name:         "keep last policy with reordered generators",
+			policy:       templatesv1.KeepLastGeneratorErrorPolicy,
+			generators:   []templatesv1.GitOpsSetGenerator{gitGenerator, listGenerator},
+			last:         GeneratedElements{listKey: {{"env": "production"}}, gitKey: {{"env": "staging"}}},
+			wantDegraded: "failed to generate template for set test-gitops-set: no artifact",
+			wantNames:    []string{"staging-0", "dev-1"},
+		},
+		{
+			name:    "keep last policy without last elements",
+			policy:  templatesv1.KeepLastGeneratorErrorPolicy,
+			last:    GeneratedElements{listKey: {{"env": "production"}}},
+			wantErr: "failed to generate template for set test-gitops-set: no artifact",
+		},
+	}
+
+	testGenerators := map[string]generators.Generator{
+		"List":          list.NewGenerator(logr.Discard()),
+		"GitRepository": failingGenerator{err: errors.New("no artifact")},
+	}
+
+	for _, tt := range policyTests {
+		t.Run(tt.name, func(t *testing.T) {
+			gset := makeTestGitOpsSet(t, setTemplates(templatesv1.GitOpsSetTemplate{
+				Content: runtime.RawExtension{Raw: []byte(`{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "{{ .Element.env }}-{{ .ElementIndex }}"}}`)},
+			}), func(gs *templatesv1.GitOpsSet) {
+				gs.Spec.GeneratorErrorPolicy = tt.policy
+				gs.Spec.Generators = tt.generators
+				if gs.Spec.Generators == nil {
+					gs.Spec.Generators = []templatesv1.GitOpsSetGenerator{listGenerator, gitGenerator}
+				}
+			})
+
+			result, err := RenderWithElements(context.TODO(), gset, testGenerators, tt.last)
+			if tt.wantErr != "" {
+				test.AssertErrorMatch(t, tt.wantErr, err)
+				return
+			}
+			test.AssertNoError(t, err)
+			test.AssertErrorMatch(t, tt.wantDegraded, result.Degraded)
+
+			names := []string{}
+			for _, res := range result.Resources {
+				names = append(names, res.GetName())
+			}
+			if diff := cmp.Diff(tt.wantNames, names); diff != "" {
+				t.Fatalf("failed to render resources:\n%s", diff)
+			}
+
+			want := GeneratedElements{listKey: {{"env": "dev"}}}
+			if elements, ok := tt.last[gitKey]; ok {
+				want[gitKey] = elements
+			}
+			if diff := cmp.Diff(want, result.Elements); diff != "" {
+				t.Fatalf("failed to record elements:\n%s", diff)
+			}
+		})
+	}
+}
+
+func mustGeneratorKey(t *testing.T, gen templatesv1.GitOpsSetGenerator) string {
+	t.Helper()
+	key, err := GeneratorKey(gen)
+	test.AssertNoError(t, err)
+
+	return key
+}
+
+type revisionRecordingGenerator struct {
+	previous string
+}
+
+func (g *revisionRecordingGenerator) Generate(ctx context.Context, _ *templatesv1.GitOpsSetGenerator, _ *templatesv1.GitOpsSet) ([]map[string]any, error) {
+	g.previous = generators.PreviousRevision(ctx)
+	generators.RecordRevision(ctx, "sha256:current")
+
+	return nil, nil
+}
+
+func (g *revisionRecordingGenerator) Interval(*templatesv1.GitOpsSetGenerator) time.Duration {
+	return generators.NoRequeueInterval
+}
+
+type failingGenerator struct {
+	err error
+}
+
+func (g failingGenerator) Generate(context.Context, *templatesv1.GitOpsSetGenerator, *templatesv1.GitOpsSet) ([]map[string]any, error) {
+	return nil, g.err
+}
+
+func (g failingGenerator) Interval(*templatesv1.GitOpsSetGenerator) time.Duration {
+	return generators.NoRequeueInterval
+}
+
 func listElements(el []apiextensionsv1.JSON) func(*templatesv1.GitOpsSet) {
 	return func(gs *templatesv1.GitOpsSet) {
 		if gs.Spec.Generators == nil {
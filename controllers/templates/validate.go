@@ -0,0 +1,168 @@
+package templates
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/client-go/util/jsonpath"
+	syaml "sigs.k8s.io/yaml"
+
+	templatesv1 "github.com/weaveworks/gitopssets-controller/api/v1beta1"
+	"github.com/weaveworks/gitopssets-controller/controllers/templates/generators"
+	"github.com/weaveworks/gitopssets-controller/pkg/registry"
+)
+
+// Validate checks the GitOpsSet for errors that would otherwise only be
+// reported when the GitOpsSet is reconciled.
+//
+// The templates are parsed in the same way as they are when rendering, and
+// the generators are checked against the enabled generators.
+func Validate(gs *templatesv1.GitOpsSet, enabledGenerators map[string]generators.Generator) field.ErrorList {
+	var allErrs field.ErrorList
+
+	delimsValid := true
+	if ann, ok := gs.GetAnnotations()[TemplateDelimiterAnnotation]; ok {
+		if elems := strings.Split(ann, ","); len(elems) != 2 || elems[0] == "" || elems[1] == "" {
+			delimsValid = false
+			allErrs = append(allErrs, field.Invalid(
+				field.NewPath("metadata", "annotations").Key(TemplateDelimiterAnnotation), ann,
+				"must be the left and right delimiters separated by a comma"))
+		}
+	}
+
+	generatorsPath := field.NewPath("spec", "generators")
+	for i, gen := range gs.Spec.Generators {
+		genPath := generatorsPath.Index(i)
+		allErrs = append(allErrs, validateGenerator(genPath, gen, enabledGenerators)...)
+		if gen.APIClient != nil {
+			allErrs = append(allErrs, validateAPIClient(genPath.Child("apiClient"), gen.APIClient)...)
+		}
+
+		if gen.Matrix == nil {
+			continue
+		}
+		for j, nested := range gen.Matrix.Generators {
+			nestedPath := genPath.Child("matrix", "generators").Index(j)
+			nestedGenerator, err := generators.MakeGitOpsSetGenerator(&nested)
+			if err != nil {
+				allErrs = append(allErrs, field.Invalid(nestedPath, field.OmitValueType{}, err.Error()))
+				continue
+			}
+			allErrs = append(allErrs, validateGenerator(nestedPath, *nestedGenerator, enabledGenerators)...)
+			if nested.APIClient != nil {
+				allErrs = append(allErrs, validateAPIClient(nestedPath.Child("apiClient"), nested.APIClient)...)
+			}
+		}
+	}
+
+	templatesPath := field.NewPath("spec", "templates")
+	for i, tmpl := range gs.Spec.Templates {
+		tmplPath := templatesPath.Index(i)
+		if tmpl.Repeat != "" {
+			if err := jsonpath.New("repeat").Parse(tmpl.Repeat); err != nil {
+				allErrs = append(allErrs, field.Invalid(tmplPath.Child("repeat"), tmpl.Repeat, err.Error()))
+			}
+		}
+
+		// The templates can't be parsed with the configured delimiters if they
+		// are invalid.
+		if !delimsValid {
+			continue
+		}
+
+		if tmpl.TargetCluster != "" {
+			allErrs = append(allErrs, validateTargetCluster(tmplPath.Child("targetCluster"), tmpl.TargetCluster, *gs)...)
+		}
+		allErrs = append(allErrs, validateTargetClusterAnnotation(tmplPath.Child("content"), tmpl.Content.Raw, *gs)...)
+
+		yamlBytes, err := syaml.JSONToYAML(tmpl.Content.Raw)
+		if err != nil {
+			allErrs = append(allErrs, field.Invalid(tmplPath.Child("content"), field.OmitValueType{}, err.Error()))
+			continue
+		}
+
+		if _, err := parseTemplate(yamlBytes, *gs); err != nil {
+			allErrs = append(allErrs, field.Invalid(tmplPath.Child("content"), field.OmitValueType{}, err.Error()))
+		}
+	}
+
+	return allErrs
+}
+
+// validateGenerator checks that no more than one generator is configured, and
+// that it's enabled.
+func validateGenerator(path *field.Path, setGenerator templatesv1.GitOpsSetGenerator, enabledGenerators map[string]generators.Generator) field.ErrorList {
+	if types := registry.Default.GeneratorTypes(&setGenerator); len(types) > 1 {
+		return field.ErrorList{field.Invalid(path, strings.Join(types, ","), "only one generator can be configured")}
+	}
+
+	_, err := registry.Default.FindRelevantGenerators(&setGenerator, enabledGenerators)
+	var notEnabled generators.GeneratorNotEnabledError
+	if errors.As(err, &notEnabled) {
+		return field.ErrorList{field.Invalid(path, notEnabled.Name, err.Error())}
+	}
+
+	return nil
+}
+
+// validateTargetCluster checks that the target cluster of a template is in the
+// format "namespace/name" or "name".
+//
+// Target clusters that are templated are parsed, and the rendered values are
+// checked when the resources are rendered.
+func validateTargetCluster(path *field.Path, targetCluster string, gs templatesv1.GitOpsSet) field.ErrorList {
+	if left, _ := templateDelims(gs); strings.Contains(targetCluster, left) {
+		if _, err := parseTemplate([]byte(targetCluster), gs); err != nil {
+			return field.ErrorList{field.Invalid(path, targetCluster, err.Error())}
+		}
+
+		return nil
+	}
+
+	if err := checkTargetCluster(targetCluster); err != nil {
+		return field.ErrorList{field.Invalid(path, targetCluster, err.Error())}
+	}
+
+	return nil
+}
+
+// validateTargetClusterAnnotation checks the target cluster annotation of the
+// resource in the template content.
+//
+// Templated annotations are parsed with the rest of the content.
+func validateTargetClusterAnnotation(path *field.Path, content []byte, gs templatesv1.GitOpsSet) field.ErrorList {
+	var obj map[string]any
+	if err := json.Unmarshal(content, &obj); err != nil {
+		return nil
+	}
+
+	targetCluster, ok, err := unstructured.NestedString(obj, "metadata", "annotations", TargetClusterAnnotation)
+	if err != nil || !ok {
+		return nil
+	}
+
+	if left, _ := templateDelims(gs); strings.Contains(targetCluster, left) {
+		return nil
+	}
+
+	if err := checkTargetCluster(targetCluster); err != nil {
+		return field.ErrorList{field.Invalid(path.Child("metadata", "annotations").Key(TargetClusterAnnotation), targetCluster, err.Error())}
+	}
+
+	return nil
+}
+
+func validateAPIClient(path *field.Path, ac *templatesv1.APIClientGenerator) field.ErrorList {
+	if ac.JSONPath == "" {
+		return nil
+	}
+
+	if err := jsonpath.New("apiclient").Parse(ac.JSONPath); err != nil {
+		return field.ErrorList{field.Invalid(path.Child("jsonPath"), ac.JSONPath, err.Error())}
+	}
+
+	return nil
+}
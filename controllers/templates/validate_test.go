@@ -0,0 +1,243 @@
+package templates
+
+import (
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/google/go-cmp/cmp"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+
+	templatesv1 "github.com/weaveworks/gitopssets-controller/api/v1beta1"
+	"github.com/weaveworks/gitopssets-controller/controllers/templates/generators"
+	"github.com/weaveworks/gitopssets-controller/controllers/templates/generators/apiclient"
+	"github.com/weaveworks/gitopssets-controller/controllers/templates/generators/list"
+	"github.com/weaveworks/gitopssets-controller/controllers/templates/generators/matrix"
+)
+
+func TestValidate(t *testing.T) {
+	validationTests := []struct {
+		name       string
+		setOptions []func(*templatesv1.GitOpsSet)
+		want       field.ErrorList
+	}{
+		{
+			name:       "valid GitOpsSet",
+			setOptions: []func(*templatesv1.GitOpsSet){listElements(nil)},
+		},
+		{
+			name: "custom delimiters",
+			setOptions: []func(*templatesv1.GitOpsSet){
+				listElements(nil),
+				setDelimiters("$[,]"),
+				setTemplates(templatesv1.GitOpsSetTemplate{
+					Content: runtime.RawExtension{Raw: []byte(`{"name": "$[ .Element.env ]"}`)},
+				}),
+			},
+		},
+		{
+			name: "invalid delimiters",
+			setOptions: []func(*templatesv1.GitOpsSet){
+				listElements(nil),
+				setDelimiters("$["),
+			},
+			want: field.ErrorList{
+				field.Invalid(field.NewPath("metadata", "annotations").Key(TemplateDelimiterAnnotation), "$[",
+					"must be the left and right delimiters separated by a comma"),
+			},
+		},
+		{
+			name: "template that doesn't parse",
+			setOptions: []func(*templatesv1.GitOpsSet){
+				listElements(nil),
+				setTemplates(templatesv1.GitOpsSetTemplate{
+					Content: runtime.RawExtension{Raw: []byte(`"{{ .test | tested }}"`)},
+				}),
+			},
+			want: field.ErrorList{
+				field.Invalid(field.NewPath("spec", "templates").Index(0).Child("content"), field.OmitValueType{},
+					`template: demo/test-gitops-set:1: function "tested" not defined`),
+			},
+		},
+		{
+			name: "template that doesn't parse with custom delimiters",
+			setOptions: []func(*templatesv1.GitOpsSet){
+				listElements(nil),
+				setDelimiters("$[,]"),
+				setTemplates(templatesv1.GitOpsSetTemplate{
+					Content: runtime.RawExtension{Raw: []byte(`"$[ .test | tested ]"`)},
+				}),
+			},
+			want: field.ErrorList{
+				field.Invalid(field.NewPath("spec", "templates").Index(0).Child("content"), field.OmitValueType{},
+					`template: demo/test-gitops-set:1: function "tested" not defined`),
+			},
+		},
+		{
+			name: "invalid repeat",
+			setOptions: []func(*templatesv1.GitOpsSet){
+				listElements(nil),
+				func(gs *templatesv1.GitOpsSet) {
+					gs.Spec.Templates[0].Repeat = "{ .Element.items["
+				},
+			},
+			want: field.ErrorList{
+				field.Invalid(field.NewPath("spec", "templates").Index(0).Child("repeat"), "{ .Element.items[",
+					"unterminated array"),
+			},
+		},
+		{
+			name: "valid target clusters",
+			setOptions: []func(*templatesv1.GitOpsSet){
+				listElements(nil),
+				setTemplates(
+					templatesv1.GitOpsSetTemplate{
+						TargetCluster: "clusters/prod-eu",
+						Content:       runtime.RawExtension{Raw: []byte(`{"metadata": {"annotations": {"templates.weave.works/target-cluster": "dev"}}}`)},
+					},
+					templatesv1.GitOpsSetTemplate{
+						TargetCluster: "{{ .Element.cluster }}",
+						Content:       runtime.RawExtension{Raw: []byte(`{"metadata": {"annotations": {"templates.weave.works/target-cluster": "{{ .Element.cluster }}/test"}}}`)},
+					},
+				),
+			},
+		},
+		{
+			name: "invalid target cluster",
+			setOptions: []func(*templatesv1.GitOpsSet){
+				listElements(nil),
+				setTemplates(templatesv1.GitOpsSetTemplate{
+					TargetCluster: "clusters/prod/eu",
+					Content:       runtime.RawExtension{Raw: []byte(`{"metadata": {"name": "test"}}`)},
+				}),
+			},
+			want: field.ErrorList{
+				field.Invalid(field.NewPath("spec", "templates").Index(0).Child("targetCluster"), "clusters/prod/eu",
+					`must be in the format "namespace/name" or "name"`),
+			},
+		},
+		{
+			name: "target cluster that doesn't parse",
+			setOptions: []func(*templatesv1.GitOpsSet){
+				listElements(nil),
+				setTemplates(templatesv1.GitOpsSetTemplate{
+					TargetCluster: "{{ .Element.cluster | tested }}",
+					Content:       runtime.RawExtension{Raw: []byte(`{"metadata": {"name": "test"}}`)},
+				}),
+			},
+			want: field.ErrorList{
+				field.Invalid(field.NewPath("spec", "templates").Index(0).Child("targetCluster"), "{{ .Element.cluster | tested }}",
+					`template: demo/test-gitops-set:1: function "tested" not defined`),
+			},
+		},
+		{
+			name: "invalid target cluster annotation",
+			setOptions: []func(*templatesv1.GitOpsSet){
+				listElements(nil),
+				setTemplates(templatesv1.GitOpsSetTemplate{
+					Content: runtime.RawExtension{Raw: []byte(`{"metadata": {"annotations": {"templates.weave.works/target-cluster": "Clusters/dev"}}}`)},
+				}),
+			},
+			want: field.ErrorList{
+				field.Invalid(field.NewPath("spec", "templates").Index(0).Child("content", "metadata", "annotations").Key(TargetClusterAnnotation), "Clusters/dev",
+					"invalid namespace: a lowercase RFC 1123 label must consist of lower case alphanumeric characters or '-', and must start and end with an alphanumeric character (e.g. 'my-name',  or '123-abc', regex used for validation is '[a-z0-9]([-a-z0-9]*[a-z0-9])?')"),
+			},
+		},
+		{
+			name: "invalid APIClient JSONPath",
+			setOptions: []func(*templatesv1.GitOpsSet){
+				setGenerators(templatesv1.GitOpsSetGenerator{
+					APIClient: &templatesv1.APIClientGenerator{Endpoint: "https://example.com", JSONPath: "{ .items["},
+				}),
+			},
+			want: field.ErrorList{
+				field.Invalid(field.NewPath("spec", "generators").Index(0).Child("apiClient", "jsonPath"), "{ .items[",
+					"unterminated array"),
+			},
+		},
+		{
+			name: "generator that isn't enabled",
+			setOptions: []func(*templatesv1.GitOpsSet){
+				setGenerators(templatesv1.GitOpsSetGenerator{
+					GitRepository: &templatesv1.GitRepositoryGenerator{RepositoryRef: "test-repo"},
+				}),
+			},
+			want: field.ErrorList{
+				field.Invalid(field.NewPath("spec", "generators").Index(0), "GitRepository", "generator GitRepository not enabled"),
+			},
+		},
+		{
+			name: "multiple generators",
+			setOptions: []func(*templatesv1.GitOpsSet){
+				setGenerators(templatesv1.GitOpsSetGenerator{
+					List:      &templatesv1.ListGenerator{},
+					APIClient: &templatesv1.APIClientGenerator{Endpoint: "https://example.com"},
+				}),
+			},
+			want: field.ErrorList{
+				field.Invalid(field.NewPath("spec", "generators").Index(0), "APIClient,List", "only one generator can be configured"),
+			},
+		},
+		{
+			name: "invalid nested generators",
+			setOptions: []func(*templatesv1.GitOpsSet){
+				setGenerators(templatesv1.GitOpsSetGenerator{
+					Matrix: &templatesv1.MatrixGenerator{
+						Generators: []templatesv1.GitOpsSetNestedGenerator{
+							{
+								List: &templatesv1.ListGenerator{Elements: []apiextensionsv1.JSON{{Raw: []byte(`{"env": "dev"}`)}}},
+							},
+							{
+								GitRepository: &templatesv1.GitRepositoryGenerator{RepositoryRef: "test-repo"},
+							},
+							{
+								APIClient: &templatesv1.APIClientGenerator{Endpoint: "https://example.com", JSONPath: "{ .items["},
+							},
+						},
+					},
+				}),
+			},
+			want: field.ErrorList{
+				field.Invalid(field.NewPath("spec", "generators").Index(0).Child("matrix", "generators").Index(1), "GitRepository",
+					"generator GitRepository not enabled"),
+				field.Invalid(field.NewPath("spec", "generators").Index(0).Child("matrix", "generators").Index(2).Child("apiClient", "jsonPath"), "{ .items[",
+					"unterminated array"),
+			},
+		},
+	}
+
+	testGenerators := map[string]generators.Generator{
+		"List":      list.NewGenerator(logr.Discard()),
+		"APIClient": apiclient.NewGenerator(logr.Discard(), nil, apiclient.DefaultClientFactory),
+		"Matrix":    matrix.NewGenerator(logr.Discard(), nil, nil),
+	}
+
+	for _, tt := range validationTests {
+		t.Run(tt.name, func(t *testing.T) {
+			gset := makeTestGitOpsSet(t, tt.setOptions...)
+
+			if diff := cmp.Diff(tt.want, Validate(gset, testGenerators)); diff != "" {
+				t.Fatalf("failed to validate:\n%s", diff)
+			}
+		})
+	}
+}
+
+func setDelimiters(delims string) func(*templatesv1.GitOpsSet) {
+	return func(gs *templatesv1.GitOpsSet) {
+		gs.SetAnnotations(map[string]string{TemplateDelimiterAnnotation: delims})
+	}
+}
+
+func setGenerators(gens ...templatesv1.GitOpsSetGenerator) func(*templatesv1.GitOpsSet) {
+	return func(gs *templatesv1.GitOpsSet) {
+		gs.Spec.Generators = gens
+	}
+}
+
+func setTemplates(tmpls ...templatesv1.GitOpsSetTemplate) func(*templatesv1.GitOpsSet) {
+	return func(gs *templatesv1.GitOpsSet) {
+		gs.Spec.Templates = tmpls
+	}
+}
@@ -0,0 +1,143 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	templatesv1 "github.com/weaveworks/gitopssets-controller/api/v1beta1"
+	"github.com/weaveworks/gitopssets-controller/controllers/templates"
+)
+
+// wave is a set of resources that are applied together.
+type wave struct {
+	number    int
+	resources []*unstructured.Unstructured
+}
+
+// sortIntoWaves groups the resources into waves, in the order they should be
+// applied.
+//
+// Within each wave, Namespaces and CustomResourceDefinitions are sorted first,
+// so that the resources that depend on them can be created, otherwise the
+// rendered order is preserved.
+func sortIntoWaves(resources []*unstructured.Unstructured) ([]wave, error) {
+	numbers := make(map[*unstructured.Unstructured]int, len(resources))
+	for _, resource := range resources {
+		n, err := resourceWave(resource)
+		if err != nil {
+			return nil, err
+		}
+		numbers[resource] = n
+	}
+
+	sorted := make([]*unstructured.Unstructured, len(resources))
+	copy(sorted, resources)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if numbers[sorted[i]] != numbers[sorted[j]] {
+			return numbers[sorted[i]] < numbers[sorted[j]]
+		}
+
+		return kindPriority(sorted[i]) < kindPriority(sorted[j])
+	})
+
+	var waves []wave
+	for _, resource := range sorted {
+		n := numbers[resource]
+		if len(waves) == 0 || waves[len(waves)-1].number != n {
+			waves = append(waves, wave{number: n})
+		}
+		waves[len(waves)-1].resources = append(waves[len(waves)-1].resources, resource)
+	}
+
+	return waves, nil
+}
+
+func resourceWave(obj *unstructured.Unstructured) (int, error) {
+	v, ok := obj.GetAnnotations()[templates.WaveAnnotation]
+	if !ok {
+		return 0, nil
+	}
+
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s annotation %q on %s/%s: %w", templates.WaveAnnotation, v, obj.GetNamespace(), obj.GetName(), err)
+	}
+
+	return n, nil
+}
+
+func kindPriority(obj *unstructured.Unstructured) int {
+	gvk := obj.GroupVersionKind()
+	switch {
+	case gvk.Group == "" && gvk.Kind == "Namespace":
+		return 0
+	case gvk.Group == "apiextensions.k8s.io" && gvk.Kind == "CustomResourceDefinition":
+		return 1
+	default:
+		return 2
+	}
+}
+
+// waveNotHealthyError is returned when the resources in a wave are not
+// healthy, the later waves are not applied until they are.
+type waveNotHealthyError struct {
+	number    int
+	unhealthy []string
+	// timeout is set when the resources were not healthy within the timeout
+	// after the wave was applied.
+	timeout time.Duration
+}
+
+func (e waveNotHealthyError) Error() string {
+	if e.timeout > 0 {
+		return fmt.Sprintf("wave %d is not healthy after %s: %d resources are not healthy: %s",
+			e.number, e.timeout, len(e.unhealthy), strings.Join(e.unhealthy, ", "))
+	}
+
+	return fmt.Sprintf("waiting for wave %d to become healthy: %d resources are not healthy: %s",
+		e.number, len(e.unhealthy), strings.Join(e.unhealthy, ", "))
+}
+
+// checkWave checks whether the resources in a wave are healthy before the
+// next wave is applied.
+//
+// The wave is recorded in the status of the GitOpsSet while its resources are
+// not healthy, and a waveNotHealthyError is returned, the wave is checked
+// again when the GitOpsSet is requeued.
+func checkWave(ctx context.Context, clients *clusterClients, gitOpsSet *templatesv1.GitOpsSet, w wave) error {
+	inventory := &templatesv1.ResourceInventory{}
+	for _, resource := range w.resources {
+		ref, err := resourceRefFromObject(resource)
+		if err != nil {
+			return err
+		}
+		inventory.Entries = append(inventory.Entries, ref)
+	}
+
+	unhealthy, err := unhealthyResources(ctx, clients, inventory)
+	if err != nil {
+		return fmt.Errorf("failed to check health of wave %d: %w", w.number, err)
+	}
+
+	if len(unhealthy) == 0 {
+		return nil
+	}
+
+	if gitOpsSet.Status.Wave == nil || gitOpsSet.Status.Wave.Number != w.number {
+		gitOpsSet.Status.Wave = &templatesv1.WaveStatus{Number: w.number, AppliedTime: metav1.Now()}
+	}
+
+	waveErr := waveNotHealthyError{number: w.number, unhealthy: unhealthy}
+	if time.Since(gitOpsSet.Status.Wave.AppliedTime.Time) > gitOpsSet.GetTimeout() {
+		waveErr.timeout = gitOpsSet.GetTimeout()
+	}
+
+	return waveErr
+}
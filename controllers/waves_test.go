@@ -0,0 +1,89 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	templatesv1 "github.com/weaveworks/gitopssets-controller/api/v1beta1"
+	"github.com/weaveworks/gitopssets-controller/test"
+)
+
+func TestSortIntoWaves(t *testing.T) {
+	inWave := func(wave string) func(*corev1.ConfigMap) {
+		return func(cm *corev1.ConfigMap) {
+			cm.Name = "cm-" + wave
+			cm.Annotations = map[string]string{"templates.weave.works/wave": wave}
+		}
+	}
+
+	kustomization := test.ToUnstructured(t, test.MakeTestKustomization(nsn("default", "test-ks")))
+	namespace := test.ToUnstructured(t, test.NewNamespace("test-ns"))
+	crd := test.ToUnstructured(t, &apiextensionsv1.CustomResourceDefinition{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "apiextensions.k8s.io/v1", Kind: "CustomResourceDefinition"},
+		ObjectMeta: metav1.ObjectMeta{Name: "tests.example.com"},
+	})
+	wave1 := test.ToUnstructured(t, test.NewConfigMap(inWave("1")))
+	waveMinus1 := test.ToUnstructured(t, test.NewConfigMap(inWave("-1")))
+
+	waves, err := sortIntoWaves([]*unstructured.Unstructured{wave1, kustomization, crd, namespace, waveMinus1})
+	test.AssertNoError(t, err)
+
+	want := []wave{
+		{number: -1, resources: []*unstructured.Unstructured{waveMinus1}},
+		{number: 0, resources: []*unstructured.Unstructured{namespace, crd, kustomization}},
+		{number: 1, resources: []*unstructured.Unstructured{wave1}},
+	}
+	if diff := cmp.Diff(want, waves, cmp.AllowUnexported(wave{})); diff != "" {
+		t.Fatalf("failed to sort into waves:\n%s", diff)
+	}
+}
+
+func TestSortIntoWaves_errors(t *testing.T) {
+	cm := test.ToUnstructured(t, test.NewConfigMap(func(cm *corev1.ConfigMap) {
+		cm.Annotations = map[string]string{"templates.weave.works/wave": "first"}
+	}))
+
+	_, err := sortIntoWaves([]*unstructured.Unstructured{cm})
+	test.AssertErrorMatch(t, `invalid templates.weave.works/wave annotation "first" on default/demo-cm`, err)
+}
+
+func TestCheckWave(t *testing.T) {
+	healthy := test.NewConfigMap()
+	unhealthy := &appsv1.Deployment{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "apps/v1", Kind: "Deployment"},
+		ObjectMeta: metav1.ObjectMeta{Name: "demo-deploy", Namespace: "default"},
+		Spec:       appsv1.DeploymentSpec{Replicas: ptr.To[int32](1)},
+	}
+	clients := &clusterClients{local: fake.NewClientBuilder().WithObjects(healthy, unhealthy).Build()}
+	gs := &templatesv1.GitOpsSet{
+		Spec: templatesv1.GitOpsSetSpec{Timeout: &metav1.Duration{Duration: time.Minute}},
+	}
+
+	err := checkWave(context.TODO(), clients, gs, wave{number: 0, resources: []*unstructured.Unstructured{test.ToUnstructured(t, healthy)}})
+	test.AssertNoError(t, err)
+	if gs.Status.Wave != nil {
+		t.Fatalf("got wave %v recorded for a healthy wave", gs.Status.Wave)
+	}
+
+	unhealthyWave := wave{number: 1, resources: []*unstructured.Unstructured{test.ToUnstructured(t, healthy), test.ToUnstructured(t, unhealthy)}}
+	err = checkWave(context.TODO(), clients, gs, unhealthyWave)
+	test.AssertErrorMatch(t, `waiting for wave 1 to become healthy: 1 resources are not healthy: default_demo-deploy_apps_Deployment \(InProgress\)`, err)
+	if gs.Status.Wave == nil || gs.Status.Wave.Number != 1 {
+		t.Fatalf("got wave %v, want wave 1 to be recorded", gs.Status.Wave)
+	}
+
+	// The wave fails once it has not been healthy for the timeout.
+	gs.Status.Wave.AppliedTime = metav1.NewTime(time.Now().Add(-2 * time.Minute))
+	err = checkWave(context.TODO(), clients, gs, unhealthyWave)
+	test.AssertErrorMatch(t, `wave 1 is not healthy after 1m0s: 1 resources are not healthy`, err)
+}
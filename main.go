@@ -2,13 +2,14 @@ package main
 
 import (
 	"os"
+	"time"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
-	"k8s.io/client-go/rest"
 
 	"github.com/fluxcd/pkg/http/fetch"
+	"github.com/fluxcd/pkg/runtime/acl"
 	runtimeclient "github.com/fluxcd/pkg/runtime/client"
 	runtimeCtrl "github.com/fluxcd/pkg/runtime/controller"
 	"github.com/fluxcd/pkg/runtime/events"
@@ -18,16 +19,18 @@ import (
 	"github.com/fluxcd/pkg/tar"
 	flag "github.com/spf13/pflag"
 	"github.com/weaveworks/gitopssets-controller/controllers/templates/generators/apiclient"
+	"github.com/weaveworks/gitopssets-controller/pkg/impersonation"
+	"github.com/weaveworks/gitopssets-controller/pkg/inventory"
 	"github.com/weaveworks/gitopssets-controller/pkg/setup"
 	corev1 "k8s.io/api/core/v1"
 	ctrl "sigs.k8s.io/controller-runtime"
 	ctrlcache "sigs.k8s.io/controller-runtime/pkg/cache"
 	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
-	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
 
-	templatesv1 "github.com/weaveworks/gitopssets-controller/api/v1alpha1"
+	templatesv1 "github.com/weaveworks/gitopssets-controller/api/v1beta1"
 	"github.com/weaveworks/gitopssets-controller/controllers"
 )
 
@@ -50,7 +53,17 @@ func main() {
 		enabledGenerators     []string
 		clientOptions         runtimeclient.Options
 		logOptions            logger.Options
+		aclOptions            acl.Options
+		rateLimiterOptions    runtimeCtrl.RateLimiterOptions
 		eventsAddr            string
+		inventoryThreshold    int
+		maxRenderedResources  int
+		impersonationCache    int
+		concurrent            int
+		requeueDependency     time.Duration
+		watchLabelSelector    string
+		enableWebhooks        bool
+		webhookPort           int
 	)
 
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
@@ -61,10 +74,25 @@ func main() {
 			"Enabling this will ensure there is only one active controller manager.")
 	flag.BoolVar(&watchAllNamespaces, "watch-all-namespaces", true,
 		"Watch for custom resources in all namespaces, if set to false it will only watch the runtime namespace.")
+	flag.StringVar(&watchLabelSelector, "watch-label-selector", "",
+		"Watch for GitOpsSets with matching labels e.g. 'sharding.fluxcd.io/key=shard1'.")
 	flag.StringVar(&defaultServiceAccount, "default-service-account", "", "Default service account used for impersonation.")
 	flag.StringSliceVar(&enabledGenerators, "enabled-generators", setup.DefaultGenerators, "Generators to enable.")
+	flag.IntVar(&inventoryThreshold, "inventory-configmap-threshold", inventory.DefaultThreshold,
+		"The number of generated resources above which the inventory is stored in ConfigMaps rather than in the GitOpsSet status.")
+	flag.IntVar(&maxRenderedResources, "max-rendered-resources", 0,
+		"The maximum number of resources that a GitOpsSet can render, GitOpsSets can override this, zero means no limit.")
+	flag.IntVar(&impersonationCache, "impersonation-client-cache-size", impersonation.DefaultCacheSize,
+		"The number of clients for impersonated service accounts that are cached.")
+	flag.IntVar(&concurrent, "concurrent", 4, "The number of concurrent GitOpsSet reconciles.")
+	flag.DurationVar(&requeueDependency, "requeue-dependency", 30*time.Second,
+		"The interval at which GitOpsSets that are waiting for the artifact of a source are reconciled again.")
+	flag.BoolVar(&enableWebhooks, "enable-webhooks", false, "Serve the validating admission and conversion webhooks for GitOpsSets.")
+	flag.IntVar(&webhookPort, "webhook-port", 9443, "The port that the webhook server listens on.")
 
 	logOptions.BindFlags(flag.CommandLine)
+	aclOptions.BindFlags(flag.CommandLine)
+	rateLimiterOptions.BindFlags(flag.CommandLine)
 	clientOptions.BindFlags(flag.CommandLine)
 
 	flag.Parse()
@@ -85,6 +113,12 @@ func main() {
 	}
 	setupLog.Info("Enabled generators", "generators", enabledGenerators)
 
+	watchSelector, err := runtimeCtrl.GetWatchSelector(runtimeCtrl.WatchOptions{LabelSelector: watchLabelSelector})
+	if err != nil {
+		setupLog.Error(err, "unable to configure watch label selector")
+		os.Exit(1)
+	}
+
 	scheme, err := setup.NewSchemeForGenerators(enabledGenerators)
 	if err != nil {
 		setupLog.Error(err, "unable to create scheme")
@@ -94,6 +128,7 @@ func main() {
 	ctrlOptions := ctrl.Options{
 		Scheme:                 scheme,
 		HealthProbeBindAddress: probeAddr,
+		WebhookServer:          webhook.NewServer(webhook.Options{Port: webhookPort}),
 		LeaderElection:         enableLeaderElection,
 		LeaderElectionID:       "539e4b66.weave.works",
 		Metrics: metricsserver.Options{
@@ -125,6 +160,11 @@ func main() {
 			},
 		},
 	}
+	// Only the GitOpsSets in the shard are cached, so that the objects they
+	// depend on only queue the GitOpsSets in the shard.
+	ctrlOptions.Cache.ByObject = map[ctrlclient.Object]ctrlcache.ByObject{
+		&templatesv1.GitOpsSet{}: {Label: watchSelector},
+	}
 	if watchNamespace != "" {
 		ctrlOptions.Cache.DefaultNamespaces = map[string]ctrlcache.Config{
 			watchNamespace: ctrlcache.Config{},
@@ -138,17 +178,6 @@ func main() {
 		os.Exit(1)
 	}
 
-	httpClient, err := rest.HTTPClientFor(restConfig)
-	if err != nil {
-		setupLog.Error(err, "error creating httpClient using kubeconfig")
-		os.Exit(1)
-	}
-
-	mapper, err := apiutil.NewDynamicRESTMapper(restConfig, httpClient)
-	if err != nil {
-		setupLog.Error(err, "unable to create REST Mapper")
-		os.Exit(1)
-	}
 	metricsH := runtimeCtrl.NewMetrics(mgr, metrics.MustMakeRecorder(), templatesv1.GitOpsSetFinalizer)
 	var eventRecorder *events.Recorder
 	if eventRecorder, err = events.NewRecorder(mgr, ctrl.Log, eventsAddr, controllerName); err != nil {
@@ -157,21 +186,43 @@ func main() {
 	}
 
 	fetcher := fetch.NewArchiveFetcher(retries, tar.UnlimitedUntarSize, tar.UnlimitedUntarSize, "")
+	// TODO: Figure how to configure the DefaultClient.
+	generators := setup.GetGenerators(enabledGenerators, fetcher, apiclient.DefaultClientFactory)
 
 	if err = (&controllers.GitOpsSetReconciler{
 		Client:                mgr.GetClient(),
 		DefaultServiceAccount: defaultServiceAccount,
 		Config:                mgr.GetConfig(),
 		Scheme:                mgr.GetScheme(),
-		Mapper:                mapper,
-		// TODO: Figure how to configure the DefaultClient.
-		Generators:    setup.GetGenerators(enabledGenerators, fetcher, apiclient.DefaultClientFactory),
-		Metrics:       metricsH,
-		EventRecorder: eventRecorder,
+		Mapper:                mgr.GetRESTMapper(),
+		Generators:            generators,
+		Watches:               setup.GetWatches(enabledGenerators),
+		Metrics:               metricsH,
+		EventRecorder:         eventRecorder,
+
+		InventoryThreshold:   inventoryThreshold,
+		MaxRenderedResources: maxRenderedResources,
+		NoCrossNamespaceRefs: aclOptions.NoCrossNamespaceRefs,
+
+		ImpersonationCacheSize: impersonationCache,
+
+		MaxConcurrentReconciles: concurrent,
+		RateLimiter:             runtimeCtrl.GetRateLimiter(rateLimiterOptions),
+		RequeueDependency:       requeueDependency,
+		WatchLabelSelector:      watchSelector,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", controllerName)
 		os.Exit(1)
 	}
+
+	if enableWebhooks {
+		if err = (&controllers.GitOpsSetValidator{
+			Generators: generators,
+		}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", controllerName)
+			os.Exit(1)
+		}
+	}
 	//+kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
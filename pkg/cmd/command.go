@@ -21,9 +21,10 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/config"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
 	"sigs.k8s.io/yaml"
 
-	templatesv1 "github.com/weaveworks/gitopssets-controller/api/v1alpha1"
+	templatesv1 "github.com/weaveworks/gitopssets-controller/api/v1beta1"
 	"github.com/weaveworks/gitopssets-controller/controllers/templates"
 	"github.com/weaveworks/gitopssets-controller/controllers/templates/generators"
 	"github.com/weaveworks/gitopssets-controller/controllers/templates/generators/apiclient"
@@ -53,10 +54,16 @@ func NewGenerateCommand(name string) *cobra.Command {
 	return cmd
 }
 
-func makeClients(fakeClients bool, repositoryRoot string, scheme *runtime.Scheme, logger logr.Logger) (corev1.ServicesGetter, client.Reader, error) {
+func makeClients(fakeClients bool, repositoryRoot string, enabledGenerators []string, scheme *runtime.Scheme, logger logr.Logger) (corev1.ServicesGetter, client.Reader, error) {
 	if fakeClients {
 		if repositoryRoot != "" {
-			return fakeclientgo.NewSimpleClientset().CoreV1(), localObjectReader{repositoryRoot: repositoryRoot, logger: logger}, nil
+			reader := localObjectReader{
+				repositoryRoot: repositoryRoot,
+				readers:        setup.GetLocalReaders(enabledGenerators),
+				logger:         logger,
+			}
+
+			return fakeclientgo.NewSimpleClientset().CoreV1(), reader, nil
 		}
 
 		return fakeclientgo.NewSimpleClientset().CoreV1(), fake.NewClientBuilder().WithScheme(scheme).Build(), nil
@@ -160,12 +167,32 @@ func bytesToGitOpsSet(scheme *runtime.Scheme, b []byte) (*templatesv1.GitOpsSet,
 	}
 
 	u := &unstructured.Unstructured{Object: raw}
-	newObj, err := scheme.New(u.GetObjectKind().GroupVersionKind())
+	gvk := u.GetObjectKind().GroupVersionKind()
+	newObj, err := scheme.New(gvk)
 	if err != nil {
 		return nil, err
 	}
 
-	return newObj.(*templatesv1.GitOpsSet), scheme.Convert(u, newObj, nil)
+	if err := scheme.Convert(u, newObj, nil); err != nil {
+		return nil, err
+	}
+
+	switch set := newObj.(type) {
+	case *templatesv1.GitOpsSet:
+		return set, nil
+	case conversion.Convertible:
+		// GitOpsSets in earlier versions are converted to the hub version
+		// in the same way as they are by the conversion webhook.
+		hub := &templatesv1.GitOpsSet{}
+		if err := set.ConvertTo(hub); err != nil {
+			return nil, fmt.Errorf("failed to convert %s: %w", gvk, err)
+		}
+		hub.SetGroupVersionKind(templatesv1.GroupVersion.WithKind("GitOpsSet"))
+
+		return hub, nil
+	}
+
+	return nil, fmt.Errorf("%s is not a GitOpsSet", gvk)
 }
 
 func renderGitOpsSet(filename string, enabledGenerators []string, disableClusterAccess bool, repositoryRoot string, out io.Writer) error {
@@ -184,7 +211,7 @@ func renderGitOpsSet(filename string, enabledGenerators []string, disableCluster
 		return err
 	}
 
-	services, cl, err := makeClients(disableClusterAccess, repositoryRoot, scheme, logger)
+	services, cl, err := makeClients(disableClusterAccess, repositoryRoot, enabledGenerators, scheme, logger)
 	if err != nil {
 		return err
 	}
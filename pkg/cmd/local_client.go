@@ -9,10 +9,10 @@ import (
 	"os"
 	"path/filepath"
 
-	sourcev1 "github.com/fluxcd/source-controller/api/v1"
-	sourcev1beta2 "github.com/fluxcd/source-controller/api/v1beta2"
 	"github.com/go-logr/logr"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/weaveworks/gitopssets-controller/pkg/registry"
 )
 
 func ignoreExists(err error) error {
@@ -81,36 +81,21 @@ func (l localFetcher) Fetch(archiveURL, checksum, dir string) error {
 
 type localObjectReader struct {
 	repositoryRoot string
+	readers        []registry.LocalReader
 	logger         logr.Logger
 }
 
 func (l localObjectReader) Get(ctx context.Context, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
-	base, err := filepath.Abs(l.repositoryRoot)
-	if err != nil {
-		return err
-	}
-
-	l.logger.Info("reading from local filesystem", "base", base)
+	l.logger.Info("reading from local filesystem", "base", l.repositoryRoot)
 
-	switch v := obj.(type) {
-	case *sourcev1beta2.GitRepository:
-		v.Status.Artifact = &sourcev1.Artifact{
-			URL: "file://" + filepath.Join(base, key.Name),
-		}
-	case *sourcev1.GitRepository:
-		v.Status.Artifact = &sourcev1.Artifact{
-			URL: "file://" + filepath.Join(base, key.Name),
-		}
-	case *sourcev1beta2.OCIRepository:
-		v.Status.Artifact = &sourcev1.Artifact{
-			URL: "file://" + filepath.Join(base, key.Name),
+	for _, read := range l.readers {
+		handled, err := read(ctx, l.repositoryRoot, key, obj)
+		if handled {
+			return err
 		}
-
-	default:
-		return fmt.Errorf("filesystem access for %T not implemented", obj)
 	}
 
-	return nil
+	return fmt.Errorf("filesystem access for %T not implemented", obj)
 }
 
 func (l localObjectReader) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
@@ -8,14 +8,16 @@ import (
 	sourcev1 "github.com/fluxcd/source-controller/api/v1"
 	"github.com/fluxcd/source-controller/api/v1beta2"
 	"github.com/go-logr/logr"
+	"github.com/weaveworks/gitopssets-controller/pkg/setup"
 	"github.com/weaveworks/gitopssets-controller/test"
+	corev1 "k8s.io/api/core/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 var _ client.Reader = (*localObjectReader)(nil)
 
 func TestLocalObjectReader_Get_v1GitRepository(t *testing.T) {
-	v := localObjectReader{logger: logr.Discard(), repositoryRoot: "testdata"}
+	v := localObjectReader{logger: logr.Discard(), repositoryRoot: "testdata", readers: setup.GetLocalReaders(setup.AllGenerators)}
 
 	gr := sourcev1.GitRepository{}
 	test.AssertNoError(t, v.Get(context.TODO(), client.ObjectKey{Name: "testing", Namespace: "testing"}, &gr))
@@ -29,7 +31,7 @@ func TestLocalObjectReader_Get_v1GitRepository(t *testing.T) {
 }
 
 func TestLocalObjectReader_Get_v1beta2GitRepository(t *testing.T) {
-	v := localObjectReader{logger: logr.Discard(), repositoryRoot: "testdata"}
+	v := localObjectReader{logger: logr.Discard(), repositoryRoot: "testdata", readers: setup.GetLocalReaders(setup.AllGenerators)}
 
 	gr := v1beta2.GitRepository{}
 	test.AssertNoError(t, v.Get(context.TODO(), client.ObjectKey{Name: "demo-gr", Namespace: "testing"}, &gr))
@@ -43,7 +45,7 @@ func TestLocalObjectReader_Get_v1beta2GitRepository(t *testing.T) {
 }
 
 func TestLocalObjectReader_Get_v1beta2OCIRepository(t *testing.T) {
-	v := localObjectReader{logger: logr.Discard(), repositoryRoot: "testdata"}
+	v := localObjectReader{logger: logr.Discard(), repositoryRoot: "testdata", readers: setup.GetLocalReaders(setup.AllGenerators)}
 
 	gr := v1beta2.OCIRepository{}
 	test.AssertNoError(t, v.Get(context.TODO(), client.ObjectKey{Name: "demo-or", Namespace: "testing"}, &gr))
@@ -55,3 +57,11 @@ func TestLocalObjectReader_Get_v1beta2OCIRepository(t *testing.T) {
 		t.Fatalf("got Artifact URL %q, want %q", wantURL, gr.Status.Artifact.URL)
 	}
 }
+
+func TestLocalObjectReader_Get_unsupported(t *testing.T) {
+	v := localObjectReader{logger: logr.Discard(), repositoryRoot: "testdata", readers: setup.GetLocalReaders(setup.AllGenerators)}
+
+	var cm corev1.ConfigMap
+	err := v.Get(context.TODO(), client.ObjectKey{Name: "demo-cm", Namespace: "testing"}, &cm)
+	test.AssertErrorMatch(t, `filesystem access for \*v1.ConfigMap not implemented`, err)
+}
@@ -0,0 +1,146 @@
+package elements
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	templatesv1 "github.com/weaveworks/gitopssets-controller/api/v1beta1"
+	"github.com/weaveworks/gitopssets-controller/controllers/templates"
+)
+
+const (
+	// ElementsLabel is the label applied to the Secrets that store the
+	// generated elements, the value is the name of the GitOpsSet.
+	ElementsLabel = "templates.weave.works/elements"
+
+	// elementsKey is the key in the Secret that the compressed elements are
+	// stored in.
+	elementsKey = "elements.json.gz"
+)
+
+// Store loads and saves the last elements generated for GitOpsSets.
+//
+// The elements are compressed and stored in a Secret that is owned by the
+// GitOpsSet, so that they are deleted along with it. A Secret is used because
+// the elements can include values read from Secrets by the Config generator.
+type Store struct {
+	Client client.Client
+}
+
+// NewStore creates and returns a new Store.
+func NewStore(c client.Client) *Store {
+	return &Store{Client: c}
+}
+
+// Load returns the last elements that were saved for the GitOpsSet, or nil if
+// none have been saved.
+//
+// Secrets that are not owned by the GitOpsSet are ignored.
+func (s *Store) Load(ctx context.Context, gitOpsSet *templatesv1.GitOpsSet) (templates.GeneratedElements, error) {
+	name := secretName(gitOpsSet)
+	var secret corev1.Secret
+	if err := s.Client.Get(ctx, client.ObjectKey{Name: name, Namespace: gitOpsSet.GetNamespace()}, &secret); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("failed to get elements Secret %s: %w", name, err)
+	}
+
+	if !metav1.IsControlledBy(&secret, gitOpsSet) {
+		return nil, nil
+	}
+
+	elements, err := decodeElements(secret.Data[elementsKey])
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode elements Secret %s: %w", name, err)
+	}
+
+	return elements, nil
+}
+
+// Save stores the elements for the GitOpsSet, the Secret is only updated if
+// the elements have changed.
+func (s *Store) Save(ctx context.Context, gitOpsSet *templatesv1.GitOpsSet, elements templates.GeneratedElements) error {
+	name := secretName(gitOpsSet)
+	data, err := encodeElements(elements)
+	if err != nil {
+		return fmt.Errorf("failed to encode elements Secret %s: %w", name, err)
+	}
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: gitOpsSet.GetNamespace(),
+		},
+	}
+
+	_, err = controllerutil.CreateOrUpdate(ctx, s.Client, secret, func() error {
+		// The name of the Secret is predictable, Secrets that were not
+		// created for this GitOpsSet are not overwritten.
+		if secret.ResourceVersion != "" && !metav1.IsControlledBy(secret, gitOpsSet) {
+			return fmt.Errorf("Secret is not owned by GitOpsSet %s", gitOpsSet.GetName())
+		}
+
+		if secret.Labels == nil {
+			secret.Labels = map[string]string{}
+		}
+		secret.Labels[ElementsLabel] = gitOpsSet.GetName()
+		secret.Type = corev1.SecretTypeOpaque
+		secret.Data = map[string][]byte{elementsKey: data}
+
+		return controllerutil.SetControllerReference(gitOpsSet, secret, s.Client.Scheme())
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write elements Secret %s: %w", name, err)
+	}
+
+	return nil
+}
+
+func secretName(gitOpsSet *templatesv1.GitOpsSet) string {
+	return gitOpsSet.GetName() + "-elements"
+}
+
+func encodeElements(elements templates.GeneratedElements) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if err := json.NewEncoder(zw).Encode(elements); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func decodeElements(data []byte) (templates.GeneratedElements, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	b, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, err
+	}
+
+	var elements templates.GeneratedElements
+	if err := json.Unmarshal(b, &elements); err != nil {
+		return nil, err
+	}
+
+	return elements, nil
+}
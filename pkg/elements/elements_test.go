@@ -0,0 +1,125 @@
+package elements
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	templatesv1 "github.com/weaveworks/gitopssets-controller/api/v1beta1"
+	"github.com/weaveworks/gitopssets-controller/controllers/templates"
+	"github.com/weaveworks/gitopssets-controller/test"
+)
+
+func TestStore_Save(t *testing.T) {
+	c := newFakeClient(t)
+	gs := makeTestGitOpsSet()
+	store := NewStore(c)
+	elements := templates.GeneratedElements{
+		"sha256:1234": {{"env": "dev", "replicas": float64(2)}, {"env": "staging"}},
+		"sha256:5678": {{"env": "production", "team": map[string]any{"name": "engineering"}}},
+	}
+
+	test.AssertNoError(t, store.Save(context.TODO(), gs, elements))
+
+	loaded, err := store.Load(context.TODO(), gs)
+	test.AssertNoError(t, err)
+	if diff := cmp.Diff(elements, loaded); diff != "" {
+		t.Fatalf("failed to load elements:\n%s", diff)
+	}
+
+	var secret corev1.Secret
+	test.AssertNoError(t, c.Get(context.TODO(), client.ObjectKey{Name: "demo-set-elements", Namespace: "default"}, &secret))
+	if !metav1.IsControlledBy(&secret, gs) {
+		t.Fatal("Secret is not controlled by the GitOpsSet")
+	}
+	if v := secret.Labels[ElementsLabel]; v != "demo-set" {
+		t.Fatalf("got label %q, want %q", v, "demo-set")
+	}
+}
+
+func TestStore_Save_replaces_elements(t *testing.T) {
+	store := NewStore(newFakeClient(t))
+	gs := makeTestGitOpsSet()
+
+	test.AssertNoError(t, store.Save(context.TODO(), gs, templates.GeneratedElements{"sha256:1234": {{"env": "dev"}}}))
+	test.AssertNoError(t, store.Save(context.TODO(), gs, templates.GeneratedElements{"sha256:5678": {{"env": "staging"}}}))
+
+	loaded, err := store.Load(context.TODO(), gs)
+	test.AssertNoError(t, err)
+	if diff := cmp.Diff(templates.GeneratedElements{"sha256:5678": {{"env": "staging"}}}, loaded); diff != "" {
+		t.Fatalf("failed to load elements:\n%s", diff)
+	}
+}
+
+func TestStore_Save_existing_secret(t *testing.T) {
+	c := newFakeClient(t, newUnownedSecret())
+	gs := makeTestGitOpsSet()
+
+	err := NewStore(c).Save(context.TODO(), gs, templates.GeneratedElements{"sha256:1234": {{"env": "dev"}}})
+	test.AssertErrorMatch(t, "failed to write elements Secret demo-set-elements: Secret is not owned by GitOpsSet demo-set", err)
+
+	var secret corev1.Secret
+	test.AssertNoError(t, c.Get(context.TODO(), client.ObjectKey{Name: "demo-set-elements", Namespace: "default"}, &secret))
+	if diff := cmp.Diff(map[string][]byte{"password": []byte("secret")}, secret.Data); diff != "" {
+		t.Fatalf("failed to preserve existing Secret:\n%s", diff)
+	}
+}
+
+func TestStore_Load_existing_secret(t *testing.T) {
+	loaded, err := NewStore(newFakeClient(t, newUnownedSecret())).Load(context.TODO(), makeTestGitOpsSet())
+	test.AssertNoError(t, err)
+
+	if loaded != nil {
+		t.Fatalf("got %v, want no elements", loaded)
+	}
+}
+
+func TestStore_Load_no_elements(t *testing.T) {
+	loaded, err := NewStore(newFakeClient(t)).Load(context.TODO(), makeTestGitOpsSet())
+	test.AssertNoError(t, err)
+
+	if loaded != nil {
+		t.Fatalf("got %v, want no elements", loaded)
+	}
+}
+
+func newUnownedSecret() *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "demo-set-elements", Namespace: "default"},
+		Data:       map[string][]byte{"password": []byte("secret")},
+	}
+}
+
+func makeTestGitOpsSet() *templatesv1.GitOpsSet {
+	return &templatesv1.GitOpsSet{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "GitOpsSet",
+			APIVersion: "templates.weave.works/v1beta1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "demo-set",
+			Namespace: "default",
+			UID:       "d5b4b3b6-3b4e-4a8a-9d2c-6b7c0b0e5e4f",
+		},
+	}
+}
+
+func newFakeClient(t *testing.T, objs ...runtime.Object) client.Client {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := templatesv1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	return fake.NewClientBuilder().WithScheme(scheme).WithRuntimeObjects(objs...).Build()
+}
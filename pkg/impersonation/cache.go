@@ -0,0 +1,125 @@
+package impersonation
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/transport"
+	"k8s.io/utils/lru"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// DefaultCacheSize is the number of clients that are cached when no size is
+// configured.
+const DefaultCacheSize = 100
+
+var (
+	cacheHits = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "gitopsset_impersonation_client_cache_hits_total",
+			Help: "The number of times that a cached client was used to impersonate a ServiceAccount.",
+		},
+	)
+	cacheMisses = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "gitopsset_impersonation_client_cache_misses_total",
+			Help: "The number of times that a client was created to impersonate a ServiceAccount.",
+		},
+	)
+)
+
+func init() {
+	metrics.Registry.MustRegister(cacheHits, cacheMisses)
+}
+
+// Cache provides clients that impersonate ServiceAccounts.
+//
+// The clients share the HTTP transport and RESTMapper, so creating a client
+// doesn't open new connections or repeat the discovery of the API, and the
+// least recently used clients are removed when the cache is full.
+//
+// The clients are cached by the namespace and name of the ServiceAccount, so
+// all the clients in a Cache are created from the same config, a Cache must
+// not be shared between configs for different clusters or users.
+type Cache struct {
+	config     *rest.Config
+	httpClient *http.Client
+	scheme     *runtime.Scheme
+	mapper     meta.RESTMapper
+	clients    *lru.Cache
+}
+
+// NewCache creates and returns a new Cache that holds up to size clients, if
+// the size is zero the DefaultCacheSize is used.
+func NewCache(config *rest.Config, httpClient *http.Client, scheme *runtime.Scheme, mapper meta.RESTMapper, size int) *Cache {
+	if size <= 0 {
+		size = DefaultCacheSize
+	}
+
+	return &Cache{
+		config:     config,
+		httpClient: httpClient,
+		scheme:     scheme,
+		mapper:     mapper,
+		clients:    lru.New(size),
+	}
+}
+
+// Client returns a client that impersonates the ServiceAccount in the
+// namespace, creating it if it's not already cached.
+func (c *Cache) Client(namespace, serviceAccountName string) (client.Client, error) {
+	key := cacheKey(namespace, serviceAccountName)
+	if cached, ok := c.clients.Get(key); ok {
+		cacheHits.Inc()
+		return cached.(client.Client), nil
+	}
+	cacheMisses.Inc()
+
+	// The shared client has no transport when the config doesn't need one
+	// other than the default.
+	rt := c.httpClient.Transport
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	httpClient := &http.Client{
+		Transport: transport.NewImpersonatingRoundTripper(
+			transport.ImpersonationConfig{UserName: UserName(namespace, serviceAccountName)}, rt),
+		Timeout: c.httpClient.Timeout,
+	}
+
+	cl, err := client.New(c.config, client.Options{HTTPClient: httpClient, Scheme: c.scheme, Mapper: c.mapper})
+	if err != nil {
+		return nil, err
+	}
+	c.clients.Add(key, cl)
+
+	return cl, nil
+}
+
+// Remove removes the client for the ServiceAccount from the cache, this is
+// called when the ServiceAccount is deleted.
+func (c *Cache) Remove(namespace, serviceAccountName string) {
+	c.clients.Remove(cacheKey(namespace, serviceAccountName))
+}
+
+// Len returns the number of cached clients.
+func (c *Cache) Len() int {
+	return c.clients.Len()
+}
+
+// UserName returns the name of the user that a ServiceAccount authenticates
+// as.
+func UserName(namespace, serviceAccountName string) string {
+	return fmt.Sprintf("system:serviceaccount:%s:%s", namespace, serviceAccountName)
+}
+
+// cacheKey doesn't identify the config, because each Cache has a single
+// config.
+func cacheKey(namespace, serviceAccountName string) string {
+	return namespace + "/" + serviceAccountName
+}
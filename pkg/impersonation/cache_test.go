@@ -0,0 +1,123 @@
+package impersonation
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/weaveworks/gitopssets-controller/test"
+)
+
+func TestCache_Client(t *testing.T) {
+	cache, _ := newTestCache(t, 10)
+
+	hits, misses := testutil.ToFloat64(cacheHits), testutil.ToFloat64(cacheMisses)
+
+	cl1, err := cache.Client("default", "test-sa")
+	test.AssertNoError(t, err)
+	cl2, err := cache.Client("default", "test-sa")
+	test.AssertNoError(t, err)
+	if cl1 != cl2 {
+		t.Fatal("expected the cached client to be returned")
+	}
+
+	cl3, err := cache.Client("other", "test-sa")
+	test.AssertNoError(t, err)
+	if cl1 == cl3 {
+		t.Fatal("expected a different client for a ServiceAccount in another namespace")
+	}
+
+	if v := testutil.ToFloat64(cacheHits) - hits; v != 1 {
+		t.Errorf("got %v cache hits, want 1", v)
+	}
+	if v := testutil.ToFloat64(cacheMisses) - misses; v != 2 {
+		t.Errorf("got %v cache misses, want 2", v)
+	}
+}
+
+func TestCache_Client_impersonates(t *testing.T) {
+	cache, users := newTestCache(t, 10)
+
+	for _, sa := range []string{"test-sa", "other-sa"} {
+		cl, err := cache.Client("default", sa)
+		test.AssertNoError(t, err)
+
+		var cm corev1.ConfigMap
+		test.AssertNoError(t, cl.Get(context.TODO(), client.ObjectKey{Name: "test-cm", Namespace: "default"}, &cm))
+	}
+
+	want := []string{"system:serviceaccount:default:test-sa", "system:serviceaccount:default:other-sa"}
+	if diff := cmp.Diff(want, *users); diff != "" {
+		t.Fatalf("failed to impersonate ServiceAccounts:\n%s", diff)
+	}
+}
+
+func TestCache_Remove(t *testing.T) {
+	cache, _ := newTestCache(t, 10)
+
+	cl1, err := cache.Client("default", "test-sa")
+	test.AssertNoError(t, err)
+
+	cache.Remove("default", "test-sa")
+	if l := cache.Len(); l != 0 {
+		t.Fatalf("got %d cached clients, want 0", l)
+	}
+
+	cl2, err := cache.Client("default", "test-sa")
+	test.AssertNoError(t, err)
+	if cl1 == cl2 {
+		t.Fatal("expected a new client after the ServiceAccount was removed")
+	}
+}
+
+func TestCache_size(t *testing.T) {
+	cache, _ := newTestCache(t, 2)
+
+	for _, sa := range []string{"sa-1", "sa-2", "sa-3"} {
+		_, err := cache.Client("default", sa)
+		test.AssertNoError(t, err)
+	}
+
+	if l := cache.Len(); l != 2 {
+		t.Fatalf("got %d cached clients, want 2", l)
+	}
+}
+
+// newTestCache returns a Cache with clients for a server that records the
+// users that are impersonated.
+func newTestCache(t *testing.T, size int) (*Cache, *[]string) {
+	t.Helper()
+	users := []string{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		users = append(users, r.Header.Get("Impersonate-User"))
+		w.Header().Set("Content-Type", "application/json")
+		test.AssertNoError(t, json.NewEncoder(w).Encode(corev1.ConfigMap{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "ConfigMap"},
+			ObjectMeta: metav1.ObjectMeta{Name: "test-cm", Namespace: "default"},
+		}))
+	}))
+	t.Cleanup(ts.Close)
+
+	scheme := runtime.NewScheme()
+	test.AssertNoError(t, corev1.AddToScheme(scheme))
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}, meta.RESTScopeNamespace)
+
+	cfg := &rest.Config{Host: ts.URL}
+	httpClient, err := rest.HTTPClientFor(cfg)
+	test.AssertNoError(t, err)
+
+	return NewCache(cfg, httpClient, scheme, mapper, size), &users
+}
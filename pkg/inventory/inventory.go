@@ -0,0 +1,246 @@
+package inventory
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/gitops-tools/pkg/sets"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	templatesv1 "github.com/weaveworks/gitopssets-controller/api/v1beta1"
+)
+
+const (
+	// DefaultThreshold is the number of entries above which the inventory is
+	// stored in ConfigMaps rather than in the status of the GitOpsSet.
+	DefaultThreshold = 1000
+
+	// InventoryLabel is the label applied to the ConfigMaps that store
+	// inventories, the value is the name of the GitOpsSet.
+	InventoryLabel = "templates.weave.works/inventory"
+
+	// entriesKey is the key in the ConfigMap that the compressed entries are
+	// stored in.
+	entriesKey = "entries.json.gz"
+
+	// entriesPerConfigMap is the maximum number of entries stored in each
+	// ConfigMap, this keeps each ConfigMap well below the size limit for
+	// objects, even before compression.
+	entriesPerConfigMap = 5000
+)
+
+// Store loads and saves the inventories of GitOpsSets.
+//
+// When an inventory has more entries than the threshold, the entries are
+// compressed and stored in ConfigMaps that are owned by the GitOpsSet, and
+// the inventory in the status refers to the ConfigMaps.
+type Store struct {
+	Client    client.Client
+	Threshold int
+}
+
+// NewStore creates and returns a new Store, if the threshold is zero the
+// DefaultThreshold is used.
+func NewStore(c client.Client, threshold int) *Store {
+	if threshold == 0 {
+		threshold = DefaultThreshold
+	}
+
+	return &Store{Client: c, Threshold: threshold}
+}
+
+// Load returns the inventory of the GitOpsSet with all the entries, reading
+// the entries from ConfigMaps if they are stored outside of the status.
+func Load(ctx context.Context, c client.Reader, gitOpsSet *templatesv1.GitOpsSet) (*templatesv1.ResourceInventory, error) {
+	inventory := gitOpsSet.Status.Inventory
+	if inventory == nil || len(inventory.ConfigMaps) == 0 {
+		return inventory, nil
+	}
+
+	var entries []templatesv1.ResourceRef
+	for _, name := range inventory.ConfigMaps {
+		var configMap corev1.ConfigMap
+		if err := c.Get(ctx, client.ObjectKey{Name: name, Namespace: gitOpsSet.GetNamespace()}, &configMap); err != nil {
+			return nil, fmt.Errorf("failed to get inventory ConfigMap %s: %w", name, err)
+		}
+
+		shard, err := decodeEntries(configMap.BinaryData[entriesKey])
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode inventory ConfigMap %s: %w", name, err)
+		}
+		entries = append(entries, shard...)
+	}
+
+	digest, err := entriesDigest(entries)
+	if err != nil {
+		return nil, err
+	}
+
+	if digest != inventory.Digest {
+		return nil, fmt.Errorf("inventory digest %s does not match the digest of the stored entries %s", inventory.Digest, digest)
+	}
+
+	return &templatesv1.ResourceInventory{Entries: entries}, nil
+}
+
+// Save stores the inventory for the GitOpsSet and returns the inventory to be
+// recorded in the status.
+//
+// Inventories with more entries than the threshold are stored in ConfigMaps
+// that are named from the digest of the entries, so the ConfigMaps of the
+// inventory in the current status are never overwritten, and can still be
+// loaded if the status is not updated. The ConfigMaps are only written if the
+// entries have changed.
+//
+// ConfigMaps that are no longer needed are removed by Prune once the status
+// has been updated.
+func (s *Store) Save(ctx context.Context, gitOpsSet *templatesv1.GitOpsSet, inventory *templatesv1.ResourceInventory) (*templatesv1.ResourceInventory, error) {
+	if inventory == nil || len(inventory.ConfigMaps) > 0 || len(inventory.Entries) <= s.Threshold {
+		return inventory, nil
+	}
+
+	digest, err := entriesDigest(inventory.Entries)
+	if err != nil {
+		return nil, err
+	}
+
+	if current := gitOpsSet.Status.Inventory; current != nil && current.Digest == digest && len(current.ConfigMaps) > 0 {
+		return current, nil
+	}
+
+	var names []string
+	for i := 0; i*entriesPerConfigMap < len(inventory.Entries); i++ {
+		end := min((i+1)*entriesPerConfigMap, len(inventory.Entries))
+		name := configMapName(gitOpsSet, digest, i)
+		if err := s.writeConfigMap(ctx, gitOpsSet, name, inventory.Entries[i*entriesPerConfigMap:end]); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+
+	return &templatesv1.ResourceInventory{ConfigMaps: names, Digest: digest}, nil
+}
+
+// Prune deletes the inventory ConfigMaps owned by the GitOpsSet that are not
+// referenced by the inventory, this is called after the inventory has been
+// recorded in the status.
+func (s *Store) Prune(ctx context.Context, gitOpsSet *templatesv1.GitOpsSet, inventory *templatesv1.ResourceInventory) error {
+	var configMaps corev1.ConfigMapList
+	if err := s.Client.List(ctx, &configMaps, client.InNamespace(gitOpsSet.GetNamespace()),
+		client.MatchingLabels{InventoryLabel: gitOpsSet.GetName()}); err != nil {
+		return fmt.Errorf("failed to list inventory ConfigMaps: %w", err)
+	}
+
+	keep := sets.New[string]()
+	if inventory != nil {
+		keep.Insert(inventory.ConfigMaps...)
+	}
+
+	for i := range configMaps.Items {
+		configMap := &configMaps.Items[i]
+		if keep.Has(configMap.GetName()) || !metav1.IsControlledBy(configMap, gitOpsSet) {
+			continue
+		}
+
+		if err := s.Client.Delete(ctx, configMap); err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete inventory ConfigMap %s: %w", configMap.GetName(), err)
+		}
+	}
+
+	return nil
+}
+
+func (s *Store) writeConfigMap(ctx context.Context, gitOpsSet *templatesv1.GitOpsSet, name string, entries []templatesv1.ResourceRef) error {
+	data, err := encodeEntries(entries)
+	if err != nil {
+		return fmt.Errorf("failed to encode inventory ConfigMap %s: %w", name, err)
+	}
+
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: gitOpsSet.GetNamespace(),
+		},
+	}
+
+	_, err = controllerutil.CreateOrUpdate(ctx, s.Client, configMap, func() error {
+		// The names of the ConfigMaps are predictable, ConfigMaps that were
+		// not created for this GitOpsSet are not overwritten.
+		if configMap.ResourceVersion != "" && !metav1.IsControlledBy(configMap, gitOpsSet) {
+			return fmt.Errorf("ConfigMap is not owned by GitOpsSet %s", gitOpsSet.GetName())
+		}
+
+		if configMap.Labels == nil {
+			configMap.Labels = map[string]string{}
+		}
+		configMap.Labels[InventoryLabel] = gitOpsSet.GetName()
+		configMap.BinaryData = map[string][]byte{entriesKey: data}
+
+		return controllerutil.SetControllerReference(gitOpsSet, configMap, s.Client.Scheme())
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write inventory ConfigMap %s: %w", name, err)
+	}
+
+	return nil
+}
+
+// configMapName returns the name of a ConfigMap for the entries with the
+// digest, the name includes the start of the digest.
+func configMapName(gitOpsSet *templatesv1.GitOpsSet, digest string, i int) string {
+	_, sum, _ := strings.Cut(digest, ":")
+
+	return fmt.Sprintf("%s-inventory-%s-%d", gitOpsSet.GetName(), sum[:10], i)
+}
+
+func entriesDigest(entries []templatesv1.ResourceRef) (string, error) {
+	b, err := json.Marshal(entries)
+	if err != nil {
+		return "", fmt.Errorf("failed to calculate digest of inventory: %w", err)
+	}
+
+	return fmt.Sprintf("sha256:%x", sha256.Sum256(b)), nil
+}
+
+func encodeEntries(entries []templatesv1.ResourceRef) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if err := json.NewEncoder(zw).Encode(entries); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func decodeEntries(data []byte) ([]templatesv1.ResourceRef, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	b, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []templatesv1.ResourceRef
+	if err := json.Unmarshal(b, &entries); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,232 @@
+package inventory
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	templatesv1 "github.com/weaveworks/gitopssets-controller/api/v1beta1"
+	"github.com/weaveworks/gitopssets-controller/test"
+)
+
+func TestStore_Save_below_threshold(t *testing.T) {
+	c := newFakeClient(t)
+	gs := makeTestGitOpsSet()
+	inventory := makeTestInventory(10)
+
+	saved, err := NewStore(c, 10).Save(context.TODO(), gs, inventory)
+	test.AssertNoError(t, err)
+
+	if diff := cmp.Diff(inventory, saved); diff != "" {
+		t.Fatalf("failed to save inventory:\n%s", diff)
+	}
+	assertConfigMaps(t, c)
+}
+
+func TestStore_Save_above_threshold(t *testing.T) {
+	c := newFakeClient(t)
+	gs := makeTestGitOpsSet()
+	inventory := makeTestInventory(12000)
+
+	saved, err := NewStore(c, 10).Save(context.TODO(), gs, inventory)
+	test.AssertNoError(t, err)
+
+	digest := mustDigest(t, inventory.Entries)
+	want := []string{
+		"demo-set-inventory-" + digest[7:17] + "-0",
+		"demo-set-inventory-" + digest[7:17] + "-1",
+		"demo-set-inventory-" + digest[7:17] + "-2",
+	}
+	if diff := cmp.Diff(want, saved.ConfigMaps); diff != "" {
+		t.Fatalf("failed to save inventory:\n%s", diff)
+	}
+	if len(saved.Entries) != 0 {
+		t.Fatalf("expected no entries in the saved inventory, got %d", len(saved.Entries))
+	}
+	assertConfigMaps(t, c, want...)
+
+	gs.Status.Inventory = saved
+	loaded, err := Load(context.TODO(), c, gs)
+	test.AssertNoError(t, err)
+
+	if diff := cmp.Diff(inventory, loaded); diff != "" {
+		t.Fatalf("failed to load inventory:\n%s", diff)
+	}
+}
+
+func TestStore_Save_preserves_current_configmaps(t *testing.T) {
+	c := newFakeClient(t)
+	gs := makeTestGitOpsSet()
+	store := NewStore(c, 10)
+	inventory := makeTestInventory(12000)
+
+	saved, err := store.Save(context.TODO(), gs, inventory)
+	test.AssertNoError(t, err)
+	gs.Status.Inventory = saved
+
+	// The status is not updated with the new inventory.
+	updated, err := store.Save(context.TODO(), gs, makeTestInventory(20))
+	test.AssertNoError(t, err)
+	assertConfigMaps(t, c, append(saved.ConfigMaps, updated.ConfigMaps...)...)
+
+	loaded, err := Load(context.TODO(), c, gs)
+	test.AssertNoError(t, err)
+	if diff := cmp.Diff(inventory, loaded); diff != "" {
+		t.Fatalf("failed to load inventory:\n%s", diff)
+	}
+}
+
+func TestStore_Prune(t *testing.T) {
+	c := newFakeClient(t, &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "unowned-inventory",
+			Namespace: "default",
+			Labels:    map[string]string{InventoryLabel: "demo-set"},
+		},
+	})
+	gs := makeTestGitOpsSet()
+	store := NewStore(c, 10)
+
+	saved, err := store.Save(context.TODO(), gs, makeTestInventory(12000))
+	test.AssertNoError(t, err)
+	gs.Status.Inventory = saved
+
+	updated, err := store.Save(context.TODO(), gs, makeTestInventory(20))
+	test.AssertNoError(t, err)
+	test.AssertNoError(t, store.Prune(context.TODO(), gs, updated))
+
+	var configMaps corev1.ConfigMapList
+	test.AssertNoError(t, c.List(context.TODO(), &configMaps, client.InNamespace("default")))
+	names := []string{}
+	for _, configMap := range configMaps.Items {
+		names = append(names, configMap.Name)
+	}
+	want := append([]string{}, updated.ConfigMaps...)
+	want = append(want, "unowned-inventory")
+	if diff := cmp.Diff(want, names); diff != "" {
+		t.Fatalf("failed to prune ConfigMaps:\n%s", diff)
+	}
+
+	test.AssertNoError(t, store.Prune(context.TODO(), gs, makeTestInventory(5)))
+	configMaps = corev1.ConfigMapList{}
+	test.AssertNoError(t, c.List(context.TODO(), &configMaps, client.InNamespace("default")))
+	if l := len(configMaps.Items); l != 1 {
+		t.Fatalf("expected only the unowned ConfigMap to remain, got %d ConfigMaps", l)
+	}
+}
+
+func TestStore_Save_existing_configmap(t *testing.T) {
+	inventory := makeTestInventory(20)
+	name := "demo-set-inventory-" + mustDigest(t, inventory.Entries)[7:17] + "-0"
+	c := newFakeClient(t, &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Data:       map[string]string{"key": "value"},
+	})
+	gs := makeTestGitOpsSet()
+
+	_, err := NewStore(c, 10).Save(context.TODO(), gs, inventory)
+	test.AssertErrorMatch(t, "failed to write inventory ConfigMap "+name+": ConfigMap is not owned by GitOpsSet demo-set", err)
+
+	var configMap corev1.ConfigMap
+	test.AssertNoError(t, c.Get(context.TODO(), client.ObjectKey{Name: name, Namespace: "default"}, &configMap))
+	if diff := cmp.Diff(map[string]string{"key": "value"}, configMap.Data); diff != "" {
+		t.Fatalf("failed to preserve existing ConfigMap:\n%s", diff)
+	}
+}
+
+func TestLoad_digest_mismatch(t *testing.T) {
+	c := newFakeClient(t)
+	gs := makeTestGitOpsSet()
+
+	saved, err := NewStore(c, 10).Save(context.TODO(), gs, makeTestInventory(20))
+	test.AssertNoError(t, err)
+	saved.Digest = "sha256:1234"
+	gs.Status.Inventory = saved
+
+	_, err = Load(context.TODO(), c, gs)
+	test.AssertErrorMatch(t, "inventory digest sha256:1234 does not match", err)
+}
+
+func TestLoad_missing_configmap(t *testing.T) {
+	gs := makeTestGitOpsSet()
+	gs.Status.Inventory = &templatesv1.ResourceInventory{ConfigMaps: []string{"demo-set-inventory-0"}, Digest: "sha256:1234"}
+
+	_, err := Load(context.TODO(), newFakeClient(t), gs)
+	test.AssertErrorMatch(t, `failed to get inventory ConfigMap demo-set-inventory-0: .* not found`, err)
+}
+
+func mustDigest(t *testing.T, entries []templatesv1.ResourceRef) string {
+	t.Helper()
+	digest, err := entriesDigest(entries)
+	test.AssertNoError(t, err)
+
+	return digest
+}
+
+func assertConfigMaps(t *testing.T, c client.Client, want ...string) {
+	t.Helper()
+	var configMaps corev1.ConfigMapList
+	test.AssertNoError(t, c.List(context.TODO(), &configMaps, client.InNamespace("default"), client.MatchingLabels{InventoryLabel: "demo-set"}))
+
+	names := []string{}
+	for _, configMap := range configMaps.Items {
+		if !metav1.IsControlledBy(&configMap, makeTestGitOpsSet()) {
+			t.Errorf("ConfigMap %s is not controlled by the GitOpsSet", configMap.Name)
+		}
+		names = append(names, configMap.Name)
+	}
+
+	if want == nil {
+		want = []string{}
+	}
+	if diff := cmp.Diff(want, names); diff != "" {
+		t.Fatalf("failed to match ConfigMaps:\n%s", diff)
+	}
+}
+
+func makeTestInventory(n int) *templatesv1.ResourceInventory {
+	inventory := &templatesv1.ResourceInventory{}
+	for i := 0; i < n; i++ {
+		inventory.Entries = append(inventory.Entries, templatesv1.ResourceRef{
+			ID:      fmt.Sprintf("default_test-cm-%d__ConfigMap", i),
+			Version: "v1",
+		})
+	}
+
+	return inventory
+}
+
+func makeTestGitOpsSet() *templatesv1.GitOpsSet {
+	return &templatesv1.GitOpsSet{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "GitOpsSet",
+			APIVersion: "templates.weave.works/v1beta1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "demo-set",
+			Namespace: "default",
+			UID:       "d5b4b3b6-3b4e-4a8a-9d2c-6b7c0b0e5e4f",
+		},
+	}
+}
+
+func newFakeClient(t *testing.T, objs ...runtime.Object) client.Client {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := templatesv1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	return fake.NewClientBuilder().WithScheme(scheme).WithRuntimeObjects(objs...).Build()
+}
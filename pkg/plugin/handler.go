@@ -0,0 +1,55 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// GenerateFunc generates the elements for a request.
+type GenerateFunc func(context.Context, Request) (Response, error)
+
+// Handler returns an http.Handler that implements the contract by decoding
+// requests and calling the GenerateFunc.
+//
+// The APIVersion of the response is set if it's empty, and errors returned by
+// the GenerateFunc are reported with a 500 status code.
+func Handler(generate GenerateFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, fmt.Sprintf("method %s not allowed", r.Method), http.StatusMethodNotAllowed)
+			return
+		}
+
+		var req Request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, fmt.Sprintf("failed to decode request: %s", err), http.StatusBadRequest)
+			return
+		}
+
+		if req.APIVersion != APIVersion {
+			http.Error(w, fmt.Sprintf("unsupported apiVersion %q", req.APIVersion), http.StatusBadRequest)
+			return
+		}
+
+		resp, err := generate(r.Context(), req)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if resp.APIVersion == "" {
+			resp.APIVersion = APIVersion
+		}
+		if resp.Elements == nil {
+			resp.Elements = []map[string]any{}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			http.Error(w, fmt.Sprintf("failed to encode response: %s", err), http.StatusInternalServerError)
+		}
+	})
+}
@@ -0,0 +1,90 @@
+// Package plugin defines the contract between the Plugin generator and the
+// services that generate elements for it.
+//
+// The generator POSTs a Request encoded as JSON to the URL of the plugin, and
+// the plugin responds with a Response encoded as JSON with a 200 status code.
+package plugin
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+)
+
+// APIVersion is the version of the contract that is implemented by the
+// Plugin generator.
+const APIVersion = "templates.weave.works/plugin/v1"
+
+// Request is the body of the requests that are sent to plugins.
+type Request struct {
+	// APIVersion is the version of the contract, plugins should reject
+	// versions that they don't support.
+	APIVersion string `json:"apiVersion"`
+
+	// GitOpsSet identifies the GitOpsSet that elements are being generated
+	// for.
+	GitOpsSet GitOpsSetReference `json:"gitOpsSet"`
+
+	// Input is the input configured in the generator, it is passed through
+	// without interpretation.
+	Input json.RawMessage `json:"input,omitempty"`
+
+	// DryRun is true when the GitOpsSet is being planned, plugins should not
+	// make changes to external systems in response to dry-run requests.
+	DryRun bool `json:"dryRun,omitempty"`
+
+	// PreviousHash is the hash of the elements that were returned in the
+	// previous response, this is empty if there was no previous response.
+	//
+	// See Hash for the calculation of the hash.
+	PreviousHash string `json:"previousHash,omitempty"`
+}
+
+// GitOpsSetReference identifies a GitOpsSet.
+type GitOpsSetReference struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+}
+
+// Response is the body of the responses from plugins.
+type Response struct {
+	// APIVersion is the version of the contract, this must match the version
+	// in the request.
+	APIVersion string `json:"apiVersion"`
+
+	// Elements are the generated elements, these are provided to the
+	// templates.
+	Elements []map[string]any `json:"elements"`
+
+	// RequeueAfterSeconds is a hint for when the plugin should be requested
+	// again, if this is zero, the interval from the generator is used.
+	RequeueAfterSeconds int64 `json:"requeueAfterSeconds,omitempty"`
+}
+
+// Validate returns an error if the response doesn't conform to the contract.
+func (r Response) Validate() error {
+	if r.APIVersion != APIVersion {
+		return fmt.Errorf("unsupported apiVersion %q in plugin response, want %q", r.APIVersion, APIVersion)
+	}
+
+	if r.Elements == nil {
+		return fmt.Errorf("plugin response has no elements")
+	}
+
+	if r.RequeueAfterSeconds < 0 {
+		return fmt.Errorf("plugin response has a negative requeueAfterSeconds %d", r.RequeueAfterSeconds)
+	}
+
+	return nil
+}
+
+// Hash returns the hash of a set of elements, this is the sha256 of the JSON
+// encoding of the elements.
+func Hash(elements []map[string]any) (string, error) {
+	b, err := json.Marshal(elements)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal elements: %w", err)
+	}
+
+	return fmt.Sprintf("sha256:%x", sha256.Sum256(b)), nil
+}
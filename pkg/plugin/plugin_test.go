@@ -0,0 +1,66 @@
+package plugin
+
+import (
+	"testing"
+
+	"github.com/weaveworks/gitopssets-controller/test"
+)
+
+func TestResponse_Validate(t *testing.T) {
+	validationTests := []struct {
+		name     string
+		response Response
+		wantErr  string
+	}{
+		{
+			name:     "valid response",
+			response: Response{APIVersion: APIVersion, Elements: []map[string]any{{"name": "test"}}},
+		},
+		{
+			name:     "valid response with no elements",
+			response: Response{APIVersion: APIVersion, Elements: []map[string]any{}},
+		},
+		{
+			name:     "unsupported version",
+			response: Response{APIVersion: "v1", Elements: []map[string]any{}},
+			wantErr:  `unsupported apiVersion "v1" in plugin response, want "templates.weave.works/plugin/v1"`,
+		},
+		{
+			name:     "missing elements",
+			response: Response{APIVersion: APIVersion},
+			wantErr:  "plugin response has no elements",
+		},
+		{
+			name:     "negative requeue",
+			response: Response{APIVersion: APIVersion, Elements: []map[string]any{}, RequeueAfterSeconds: -1},
+			wantErr:  "plugin response has a negative requeueAfterSeconds -1",
+		},
+	}
+
+	for _, tt := range validationTests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.response.Validate()
+			if tt.wantErr == "" {
+				test.AssertNoError(t, err)
+				return
+			}
+			test.AssertErrorMatch(t, tt.wantErr, err)
+		})
+	}
+}
+
+func TestHash(t *testing.T) {
+	first, err := Hash([]map[string]any{{"name": "test", "region": "eu-west-1"}})
+	test.AssertNoError(t, err)
+	second, err := Hash([]map[string]any{{"region": "eu-west-1", "name": "test"}})
+	test.AssertNoError(t, err)
+	if first != second {
+		t.Fatalf("got different hashes %q and %q for the same elements", first, second)
+	}
+
+	third, err := Hash([]map[string]any{{"name": "other"}})
+	test.AssertNoError(t, err)
+	if first == third {
+		t.Fatal("got the same hash for different elements")
+	}
+}
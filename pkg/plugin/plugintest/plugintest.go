@@ -0,0 +1,125 @@
+// Package plugintest provides tests that plugin authors can run to check that
+// their plugins implement the contract in the plugin package, and fake
+// plugins for testing the Plugin generator.
+package plugintest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/weaveworks/gitopssets-controller/pkg/plugin"
+)
+
+// RunConformance runs the conformance tests against a plugin.
+//
+// The input is sent in the requests to the plugin, it should be an input that
+// the plugin generates elements for successfully.
+func RunConformance(t *testing.T, h http.Handler, input json.RawMessage) {
+	t.Helper()
+
+	t.Run("generates elements", func(t *testing.T) {
+		resp := post(t, h, newRequest(input))
+		assertValidResponse(t, resp)
+	})
+
+	t.Run("generates elements for dry-run requests", func(t *testing.T) {
+		req := newRequest(input)
+		req.DryRun = true
+		resp := post(t, h, req)
+		assertValidResponse(t, resp)
+	})
+
+	t.Run("generates elements with a previous hash", func(t *testing.T) {
+		req := newRequest(input)
+		req.PreviousHash = "sha256:0000000000000000000000000000000000000000000000000000000000000000"
+		resp := post(t, h, req)
+		assertValidResponse(t, resp)
+	})
+
+	t.Run("rejects unsupported versions", func(t *testing.T) {
+		req := newRequest(input)
+		req.APIVersion = "templates.weave.works/plugin/v0"
+		resp := post(t, h, req)
+		if resp.Code < http.StatusBadRequest || resp.Code >= http.StatusInternalServerError {
+			t.Fatalf("got %d response for an unsupported apiVersion, want a 4xx response", resp.Code)
+		}
+	})
+
+	t.Run("rejects methods other than POST", func(t *testing.T) {
+		resp := httptest.NewRecorder()
+		h.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/", nil))
+		if resp.Code < http.StatusBadRequest || resp.Code >= http.StatusInternalServerError {
+			t.Fatalf("got %d response for a GET request, want a 4xx response", resp.Code)
+		}
+	})
+}
+
+// NewServer starts a plugin server that generates elements with the
+// GenerateFunc, the server is closed when the test completes.
+func NewServer(t *testing.T, generate plugin.GenerateFunc) *httptest.Server {
+	t.Helper()
+	ts := httptest.NewServer(plugin.Handler(generate))
+	t.Cleanup(ts.Close)
+
+	return ts
+}
+
+// RecordingServer starts a plugin server that responds with the response and
+// records the requests that it receives, the server is closed when the test
+// completes.
+func RecordingServer(t *testing.T, response plugin.Response) (*httptest.Server, *[]plugin.Request) {
+	t.Helper()
+	var requests []plugin.Request
+	ts := NewServer(t, func(_ context.Context, req plugin.Request) (plugin.Response, error) {
+		requests = append(requests, req)
+		return response, nil
+	})
+
+	return ts, &requests
+}
+
+func newRequest(input json.RawMessage) plugin.Request {
+	return plugin.Request{
+		APIVersion: plugin.APIVersion,
+		GitOpsSet: plugin.GitOpsSetReference{
+			Name:      "conformance-set",
+			Namespace: "default",
+		},
+		Input: input,
+	}
+}
+
+func post(t *testing.T, h http.Handler, req plugin.Request) *httptest.ResponseRecorder {
+	t.Helper()
+	b, err := json.Marshal(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(b))
+	r.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, r)
+
+	return resp
+}
+
+func assertValidResponse(t *testing.T, resp *httptest.ResponseRecorder) {
+	t.Helper()
+	if resp.Code != http.StatusOK {
+		t.Fatalf("got %d response, want %d: %s", resp.Code, http.StatusOK, resp.Body.String())
+	}
+
+	var body plugin.Response
+	if err := json.Unmarshal(resp.Body.Bytes(), &body); err != nil {
+		t.Fatalf("failed to decode response: %s", err)
+	}
+
+	if err := body.Validate(); err != nil {
+		t.Fatal(err)
+	}
+}
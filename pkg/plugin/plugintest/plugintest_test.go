@@ -0,0 +1,46 @@
+package plugintest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/weaveworks/gitopssets-controller/pkg/plugin"
+)
+
+func TestRunConformance(t *testing.T) {
+	RunConformance(t, plugin.Handler(func(ctx context.Context, req plugin.Request) (plugin.Response, error) {
+		var input map[string]any
+		if err := json.Unmarshal(req.Input, &input); err != nil {
+			return plugin.Response{}, err
+		}
+
+		return plugin.Response{Elements: []map[string]any{input}}, nil
+	}), json.RawMessage(`{"environment":"production"}`))
+}
+
+func TestRecordingServer(t *testing.T) {
+	ts, requests := RecordingServer(t, plugin.Response{Elements: []map[string]any{{"name": "test"}}})
+
+	req := newRequest(json.RawMessage(`{"environment":"production"}`))
+	b, err := json.Marshal(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.Post(ts.URL, "application/json", bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("got %d response, want %d", resp.StatusCode, http.StatusOK)
+	}
+
+	if diff := cmp.Diff([]plugin.Request{req}, *requests); diff != "" {
+		t.Fatalf("failed to record requests:\n%s", diff)
+	}
+}
@@ -0,0 +1,283 @@
+package registry
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+
+	"golang.org/x/exp/slices"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+
+	templatesv1 "github.com/weaveworks/gitopssets-controller/api/v1beta1"
+	"github.com/weaveworks/gitopssets-controller/controllers/templates/generators"
+	"github.com/weaveworks/gitopssets-controller/pkg/parser"
+)
+
+// Options are provided to the factories of the registered generators when the
+// generators are created for the controller or the CLI.
+type Options struct {
+	// Fetcher is used by generators that read the artifacts of Flux sources.
+	Fetcher parser.ArchiveFetcher
+
+	// HTTPClientFactory is used by generators that make HTTP requests.
+	HTTPClientFactory func(*tls.Config) *http.Client
+
+	// Nested are the factories for the enabled generators that can be nested
+	// within other generators.
+	Nested map[string]generators.GeneratorFactory
+}
+
+// Watch is a kind of object that the controller watches while a generator is
+// enabled.
+//
+// Generators that depend on specific objects should implement the
+// generators.DependentGenerator interface, a Watch is for objects that are
+// matched to GitOpsSets in other ways, for example by label selectors.
+type Watch struct {
+	Object client.Object
+
+	// MapFunc returns a function that maps a watched object to the GitOpsSets
+	// that should be reconciled, the client reads GitOpsSets.
+	MapFunc func(client.Reader) handler.MapFunc
+}
+
+// LocalReader reads objects for a generator from the local filesystem when
+// the CLI renders GitOpsSets without access to a cluster.
+//
+// The objects are read relative to the repository root, LocalReaders return
+// false if they don't handle the type of the object.
+type LocalReader func(ctx context.Context, repositoryRoot string, key client.ObjectKey, obj client.Object) (bool, error)
+
+// Registration describes a generator.
+type Registration struct {
+	// Name is the name of the generator, this is used to enable the generator
+	// and is reported as the type of the generator in the status.
+	Name string
+
+	// Configured returns true if the generator is configured in the
+	// GitOpsSetGenerator, generators nested within Matrix generators are
+	// converted to GitOpsSetGenerators.
+	Configured func(*templatesv1.GitOpsSetGenerator) bool
+
+	// Default generators are enabled unless the enabled generators are
+	// configured.
+	Default bool
+
+	// Nestable generators can be nested within Matrix generators.
+	Nestable bool
+
+	// Factory creates the factory for the generator.
+	Factory func(Options) generators.GeneratorFactory
+
+	// AddToScheme registers the types that the generator reads.
+	AddToScheme func(*runtime.Scheme) error
+
+	// Watches are the additional kinds that are watched while the generator
+	// is enabled.
+	Watches []Watch
+
+	// LocalReader reads the objects for the generator when cluster access is
+	// disabled in the CLI.
+	LocalReader LocalReader
+}
+
+// Registry is a set of generators that can be enabled.
+type Registry struct {
+	mu            sync.RWMutex
+	registrations map[string]Registration
+}
+
+// New creates and returns a new empty Registry.
+func New() *Registry {
+	return &Registry{registrations: map[string]Registration{}}
+}
+
+// Default is the Registry that the built-in generators are registered with.
+var Default = New()
+
+// Register adds a generator to the Default registry, it panics if the
+// generator can't be registered.
+//
+// This is intended to be called from the init functions of packages that
+// provide generators.
+func Register(registration Registration) {
+	if err := Default.Register(registration); err != nil {
+		panic(err)
+	}
+}
+
+// Register adds a generator to the registry.
+func (r *Registry) Register(registration Registration) error {
+	if registration.Name == "" {
+		return fmt.Errorf("generator registration has no name")
+	}
+
+	if registration.Factory == nil {
+		return fmt.Errorf("generator %s has no factory", registration.Name)
+	}
+
+	if registration.Configured == nil {
+		return fmt.Errorf("generator %s has no configured func", registration.Name)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.registrations[registration.Name]; ok {
+		return fmt.Errorf("generator %s is already registered", registration.Name)
+	}
+	r.registrations[registration.Name] = registration
+
+	return nil
+}
+
+// Names returns the names of all the registered generators.
+func (r *Registry) Names() []string {
+	return r.names(func(Registration) bool { return true })
+}
+
+// DefaultNames returns the names of the generators that are enabled by
+// default.
+func (r *Registry) DefaultNames() []string {
+	return r.names(func(registration Registration) bool { return registration.Default })
+}
+
+// GeneratorTypes returns the names of the registered generators that are
+// configured in a GitOpsSetGenerator, ordered by name.
+func (r *Registry) GeneratorTypes(setGenerator *templatesv1.GitOpsSetGenerator) []string {
+	return r.names(func(registration Registration) bool { return registration.Configured(setGenerator) })
+}
+
+// FindRelevantGenerators returns the enabled generators that are configured in
+// a GitOpsSetGenerator, in the same order as GeneratorTypes.
+//
+// A generators.GeneratorNotEnabledError is returned if a configured generator
+// is not enabled.
+func (r *Registry) FindRelevantGenerators(setGenerator *templatesv1.GitOpsSetGenerator, enabledGenerators map[string]generators.Generator) ([]generators.Generator, error) {
+	res := []generators.Generator{}
+	for _, name := range r.GeneratorTypes(setGenerator) {
+		gen, ok := enabledGenerators[name]
+		if !ok {
+			return nil, generators.GeneratorNotEnabledError{Name: name}
+		}
+		res = append(res, gen)
+	}
+
+	return res, nil
+}
+
+// Validate returns an error if any of the enabled generators is not
+// registered.
+func (r *Registry) Validate(enabledGenerators []string) error {
+	valid := r.Names()
+	for _, name := range enabledGenerators {
+		if !slices.Contains(valid, name) {
+			return fmt.Errorf("invalid generator %q. valid values: %q", name, valid)
+		}
+	}
+
+	return nil
+}
+
+// AddToScheme registers the types for the enabled generators with the scheme.
+func (r *Registry) AddToScheme(enabledGenerators []string, scheme *runtime.Scheme) error {
+	for _, registration := range r.enabled(enabledGenerators) {
+		if registration.AddToScheme == nil {
+			continue
+		}
+		if err := registration.AddToScheme(scheme); err != nil {
+			return fmt.Errorf("failed to add types for generator %s to scheme: %w", registration.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// Factories returns the generator factories for the enabled generators.
+//
+// Generators that nest other generators are provided with the factories for
+// the enabled generators that can be nested.
+func (r *Registry) Factories(enabledGenerators []string, opts Options) map[string]generators.GeneratorFactory {
+	enabled := r.enabled(enabledGenerators)
+
+	opts.Nested = map[string]generators.GeneratorFactory{}
+	for _, registration := range enabled {
+		if registration.Nestable {
+			opts.Nested[registration.Name] = registration.Factory(opts)
+		}
+	}
+
+	factories := map[string]generators.GeneratorFactory{}
+	for _, registration := range enabled {
+		factories[registration.Name] = registration.Factory(opts)
+	}
+
+	return factories
+}
+
+// Watches returns the additional watches for the enabled generators.
+func (r *Registry) Watches(enabledGenerators []string) []Watch {
+	var watches []Watch
+	for _, registration := range r.enabled(enabledGenerators) {
+		watches = append(watches, registration.Watches...)
+	}
+
+	return watches
+}
+
+// LocalReaders returns the LocalReaders for the enabled generators.
+func (r *Registry) LocalReaders(enabledGenerators []string) []LocalReader {
+	var readers []LocalReader
+	for _, registration := range r.enabled(enabledGenerators) {
+		if registration.LocalReader != nil {
+			readers = append(readers, registration.LocalReader)
+		}
+	}
+
+	return readers
+}
+
+// enabled returns the registrations for the enabled generators ordered by
+// name, names that are not registered are ignored.
+func (r *Registry) enabled(enabledGenerators []string) []Registration {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var registrations []Registration
+	for _, name := range r.sortedNames() {
+		if slices.Contains(enabledGenerators, name) {
+			registrations = append(registrations, r.registrations[name])
+		}
+	}
+
+	return registrations
+}
+
+func (r *Registry) names(filter func(Registration) bool) []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	names := []string{}
+	for _, name := range r.sortedNames() {
+		if filter(r.registrations[name]) {
+			names = append(names, name)
+		}
+	}
+
+	return names
+}
+
+func (r *Registry) sortedNames() []string {
+	names := make([]string, 0, len(r.registrations))
+	for name := range r.registrations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
@@ -0,0 +1,244 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	templatesv1 "github.com/weaveworks/gitopssets-controller/api/v1beta1"
+	"github.com/weaveworks/gitopssets-controller/controllers/templates/generators"
+	"github.com/weaveworks/gitopssets-controller/test"
+)
+
+func TestRegistry_Register(t *testing.T) {
+	r := New()
+	test.AssertNoError(t, r.Register(testRegistration("Test")))
+
+	test.AssertErrorMatch(t, "generator Test is already registered", r.Register(testRegistration("Test")))
+	test.AssertErrorMatch(t, "generator registration has no name", r.Register(testRegistration("")))
+	test.AssertErrorMatch(t, "generator Other has no factory", r.Register(Registration{Name: "Other"}))
+
+	noConfigured := testRegistration("Other")
+	noConfigured.Configured = nil
+	test.AssertErrorMatch(t, "generator Other has no configured func", r.Register(noConfigured))
+}
+
+func TestRegistry_Names(t *testing.T) {
+	r := newTestRegistry(t)
+
+	if diff := cmp.Diff([]string{"Nested", "Nesting", "Optional"}, r.Names()); diff != "" {
+		t.Fatalf("failed to get names:\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"Nested", "Nesting"}, r.DefaultNames()); diff != "" {
+		t.Fatalf("failed to get default names:\n%s", diff)
+	}
+}
+
+func TestRegistry_Validate(t *testing.T) {
+	r := newTestRegistry(t)
+
+	test.AssertNoError(t, r.Validate([]string{"Nested", "Optional"}))
+	test.AssertErrorMatch(t, `invalid generator "Unknown". valid values: \["Nested" "Nesting" "Optional"\]`, r.Validate([]string{"Nested", "Unknown"}))
+}
+
+func TestRegistry_GeneratorTypes(t *testing.T) {
+	r := newTestRegistry(t)
+
+	typesTests := []struct {
+		name string
+		set  templatesv1.GitOpsSetGenerator
+		want []string
+	}{
+		{
+			name: "empty set",
+			set:  templatesv1.GitOpsSetGenerator{},
+			want: []string{},
+		},
+		{
+			name: "one generator",
+			set:  templatesv1.GitOpsSetGenerator{List: &templatesv1.ListGenerator{}},
+			want: []string{"Nested"},
+		},
+		{
+			name: "two generators",
+			set: templatesv1.GitOpsSetGenerator{
+				Matrix: &templatesv1.MatrixGenerator{},
+				List:   &templatesv1.ListGenerator{},
+			},
+			want: []string{"Nested", "Nesting"},
+		},
+		{
+			name: "unregistered generator",
+			set:  templatesv1.GitOpsSetGenerator{Cluster: &templatesv1.ClusterGenerator{}},
+			want: []string{},
+		},
+	}
+
+	for _, tt := range typesTests {
+		t.Run(tt.name, func(t *testing.T) {
+			if diff := cmp.Diff(tt.want, r.GeneratorTypes(&tt.set)); diff != "" {
+				t.Fatalf("failed to get generator types:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestRegistry_FindRelevantGenerators(t *testing.T) {
+	r := newTestRegistry(t)
+	enabled := map[string]generators.Generator{
+		"Nested":  testGenerator{name: "Nested"},
+		"Nesting": testGenerator{name: "Nesting"},
+	}
+
+	relevant, err := r.FindRelevantGenerators(&templatesv1.GitOpsSetGenerator{
+		List:   &templatesv1.ListGenerator{},
+		Matrix: &templatesv1.MatrixGenerator{},
+	}, enabled)
+	test.AssertNoError(t, err)
+	want := []generators.Generator{testGenerator{name: "Nested"}, testGenerator{name: "Nesting"}}
+	if diff := cmp.Diff(want, relevant, cmp.AllowUnexported(testGenerator{})); diff != "" {
+		t.Fatalf("failed to find relevant generators:\n%s", diff)
+	}
+
+	_, err = r.FindRelevantGenerators(&templatesv1.GitOpsSetGenerator{
+		List:   &templatesv1.ListGenerator{},
+		Config: &templatesv1.ConfigGenerator{},
+	}, enabled)
+	test.AssertErrorMatch(t, "generator Optional not enabled", err)
+	if !errors.Is(err, generators.GeneratorNotEnabledError{Name: "Optional"}) {
+		t.Fatalf(`got %v, want GeneratorNotEnabledError{Name: "Optional"}`, err)
+	}
+}
+
+func TestRegistry_AddToScheme(t *testing.T) {
+	r := newTestRegistry(t)
+
+	scheme := runtime.NewScheme()
+	test.AssertNoError(t, r.AddToScheme([]string{"Nested"}, scheme))
+	if scheme.IsGroupRegistered("") {
+		t.Fatal("types registered for a generator that is not enabled")
+	}
+
+	test.AssertNoError(t, r.AddToScheme([]string{"Nested", "Optional"}, scheme))
+	if !scheme.IsGroupRegistered("") {
+		t.Fatal("types not registered for an enabled generator")
+	}
+}
+
+func TestRegistry_Factories(t *testing.T) {
+	r := newTestRegistry(t)
+
+	factories := r.Factories([]string{"Nested", "Nesting", "Unknown"}, Options{})
+	if diff := cmp.Diff([]string{"Nested", "Nesting"}, sortedKeys(factories)); diff != "" {
+		t.Fatalf("failed to get factories:\n%s", diff)
+	}
+
+	nesting := factories["Nesting"](logr.Discard(), nil).(testGenerator)
+	if diff := cmp.Diff([]string{"Nested"}, sortedKeys(nesting.nested)); diff != "" {
+		t.Fatalf("failed to provide nested factories:\n%s", diff)
+	}
+}
+
+func TestRegistry_Watches(t *testing.T) {
+	r := newTestRegistry(t)
+
+	if watches := r.Watches([]string{"Nested", "Nesting"}); len(watches) != 0 {
+		t.Fatalf("got %d watches for generators without watches", len(watches))
+	}
+
+	watches := r.Watches([]string{"Nested", "Optional"})
+	if len(watches) != 1 {
+		t.Fatalf("got %d watches, want 1", len(watches))
+	}
+	if _, ok := watches[0].Object.(*corev1.ConfigMap); !ok {
+		t.Fatalf("got watch for %T, want a ConfigMap", watches[0].Object)
+	}
+}
+
+func TestRegistry_LocalReaders(t *testing.T) {
+	r := newTestRegistry(t)
+
+	readers := r.LocalReaders([]string{"Nested", "Nesting"})
+	if len(readers) != 1 {
+		t.Fatalf("got %d local readers, want 1", len(readers))
+	}
+
+	handled, err := readers[0](context.TODO(), "testdata", client.ObjectKey{Name: "test"}, &corev1.ConfigMap{})
+	test.AssertNoError(t, err)
+	if !handled {
+		t.Fatal("expected the local reader to handle the ConfigMap")
+	}
+}
+
+func newTestRegistry(t *testing.T) *Registry {
+	t.Helper()
+	r := New()
+
+	nested := testRegistration("Nested")
+	nested.Configured = func(sg *templatesv1.GitOpsSetGenerator) bool { return sg.List != nil }
+	nested.Default = true
+	nested.Nestable = true
+	nested.LocalReader = func(ctx context.Context, repositoryRoot string, key client.ObjectKey, obj client.Object) (bool, error) {
+		_, ok := obj.(*corev1.ConfigMap)
+		return ok, nil
+	}
+	test.AssertNoError(t, r.Register(nested))
+
+	nesting := testRegistration("Nesting")
+	nesting.Configured = func(sg *templatesv1.GitOpsSetGenerator) bool { return sg.Matrix != nil }
+	nesting.Default = true
+	test.AssertNoError(t, r.Register(nesting))
+
+	optional := testRegistration("Optional")
+	optional.Configured = func(sg *templatesv1.GitOpsSetGenerator) bool { return sg.Config != nil }
+	optional.AddToScheme = corev1.AddToScheme
+	optional.Watches = []Watch{{Object: &corev1.ConfigMap{}}}
+	test.AssertNoError(t, r.Register(optional))
+
+	return r
+}
+
+func testRegistration(name string) Registration {
+	return Registration{
+		Name: name,
+		Configured: func(*templatesv1.GitOpsSetGenerator) bool {
+			return false
+		},
+		Factory: func(opts Options) generators.GeneratorFactory {
+			return func(logr.Logger, client.Reader) generators.Generator {
+				return testGenerator{name: name, nested: opts.Nested}
+			}
+		},
+	}
+}
+
+type testGenerator struct {
+	name   string
+	nested map[string]generators.GeneratorFactory
+}
+
+func (g testGenerator) Generate(context.Context, *templatesv1.GitOpsSetGenerator, *templatesv1.GitOpsSet) ([]map[string]any, error) {
+	return nil, nil
+}
+
+func (g testGenerator) Interval(*templatesv1.GitOpsSetGenerator) time.Duration {
+	return generators.NoRequeueInterval
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
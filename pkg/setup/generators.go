@@ -1,40 +1,41 @@
 package setup
 
 import (
-	"fmt"
-
-	"golang.org/x/exp/slices"
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
-	imagev1 "github.com/fluxcd/image-reflector-controller/api/v1beta2"
 	sourcev1 "github.com/fluxcd/source-controller/api/v1beta2"
-	clustersv1 "github.com/weaveworks/cluster-controller/api/v1alpha1"
-	templatesv1 "github.com/weaveworks/gitopssets-controller/api/v1alpha1"
+	templatesv1alpha1 "github.com/weaveworks/gitopssets-controller/api/v1alpha1"
+	templatesv1 "github.com/weaveworks/gitopssets-controller/api/v1beta1"
 	"github.com/weaveworks/gitopssets-controller/pkg/parser"
+	"github.com/weaveworks/gitopssets-controller/pkg/registry"
 	"k8s.io/apimachinery/pkg/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 
 	"github.com/weaveworks/gitopssets-controller/controllers/templates/generators"
 	"github.com/weaveworks/gitopssets-controller/controllers/templates/generators/apiclient"
-	"github.com/weaveworks/gitopssets-controller/controllers/templates/generators/cluster"
-	"github.com/weaveworks/gitopssets-controller/controllers/templates/generators/config"
-	"github.com/weaveworks/gitopssets-controller/controllers/templates/generators/gitrepository"
-	"github.com/weaveworks/gitopssets-controller/controllers/templates/generators/imagepolicy"
-	"github.com/weaveworks/gitopssets-controller/controllers/templates/generators/list"
-	"github.com/weaveworks/gitopssets-controller/controllers/templates/generators/matrix"
-	"github.com/weaveworks/gitopssets-controller/controllers/templates/generators/ocirepository"
-	"github.com/weaveworks/gitopssets-controller/controllers/templates/generators/pullrequests"
+
+	// The built-in generators are registered when their packages are
+	// imported.
+	_ "github.com/weaveworks/gitopssets-controller/controllers/templates/generators/cluster"
+	_ "github.com/weaveworks/gitopssets-controller/controllers/templates/generators/config"
+	_ "github.com/weaveworks/gitopssets-controller/controllers/templates/generators/gitrepository"
+	_ "github.com/weaveworks/gitopssets-controller/controllers/templates/generators/imagepolicy"
+	_ "github.com/weaveworks/gitopssets-controller/controllers/templates/generators/list"
+	_ "github.com/weaveworks/gitopssets-controller/controllers/templates/generators/matrix"
+	_ "github.com/weaveworks/gitopssets-controller/controllers/templates/generators/ocirepository"
+	_ "github.com/weaveworks/gitopssets-controller/controllers/templates/generators/plugin"
+	_ "github.com/weaveworks/gitopssets-controller/controllers/templates/generators/pullrequests"
 	//+kubebuilder:scaffold:imports
 )
 
 // AllGenerators contains the name of all possible Generators.
-var AllGenerators = []string{"GitRepository", "OCIRepository", "Cluster", "PullRequests", "List", "APIClient", "ImagePolicy", "Matrix", "Config"}
+var AllGenerators = registry.Default.Names()
 
 // DefaultGenerators contains the name of the default set of enabled Generators,
 // this leaves out generators that require optional dependencies.
-var DefaultGenerators = []string{"GitRepository", "OCIRepository", "PullRequests", "List", "APIClient", "Matrix", "Config"}
+var DefaultGenerators = registry.Default.DefaultNames()
 
 // NewSchemeForGenerators creates and returns a runtime.Scheme configured with
 // the correct schemes for the enabled generators.
@@ -42,23 +43,20 @@ func NewSchemeForGenerators(enabledGenerators []string) (*runtime.Scheme, error)
 	builder := runtime.SchemeBuilder{
 		clientgoscheme.AddToScheme,
 		sourcev1.AddToScheme,
+		templatesv1alpha1.AddToScheme,
 		templatesv1.AddToScheme,
 	}
 
-	if isGeneratorEnabled(enabledGenerators, "Cluster") {
-		builder.Register(clustersv1.AddToScheme)
-	}
-
-	if isGeneratorEnabled(enabledGenerators, "ImagePolicy") {
-		builder.Register(imagev1.AddToScheme)
-	}
-
 	scheme := runtime.NewScheme()
 
 	if err := builder.AddToScheme(scheme); err != nil {
 		return nil, err
 	}
 
+	if err := registry.Default.AddToScheme(enabledGenerators, scheme); err != nil {
+		return nil, err
+	}
+
 	return scheme, nil
 }
 
@@ -67,52 +65,26 @@ func NewSchemeForGenerators(enabledGenerators []string) (*runtime.Scheme, error)
 //
 // If all provided names are valid, no error is returned.
 func ValidateEnabledGenerators(enabledGenerators []string) error {
-	for _, generator := range enabledGenerators {
-		if !slices.Contains(AllGenerators, generator) {
-			return fmt.Errorf("invalid generator %q. valid values: %q", generator, AllGenerators)
-		}
-	}
-
-	return nil
+	return registry.Default.Validate(enabledGenerators)
 }
 
 // GetGenenerators returns a set of generator factories for the set of enabled
 // generators.
 func GetGenerators(enabledGenerators []string, fetcher parser.ArchiveFetcher, clientFactory apiclient.HTTPClientFactory) map[string]generators.GeneratorFactory {
-	matrixGenerators := filterEnabledGenerators(enabledGenerators, map[string]generators.GeneratorFactory{
-		"List":          list.GeneratorFactory,
-		"GitRepository": gitrepository.GeneratorFactory(fetcher),
-		"OCIRepository": ocirepository.GeneratorFactory(fetcher),
-		"PullRequests":  pullrequests.GeneratorFactory,
-		"Cluster":       cluster.GeneratorFactory,
-		"ImagePolicy":   imagepolicy.GeneratorFactory,
-		"APIClient":     apiclient.GeneratorFactory(clientFactory),
-		"Config":        config.GeneratorFactory,
-	})
-
-	return filterEnabledGenerators(enabledGenerators, map[string]generators.GeneratorFactory{
-		"List":          list.GeneratorFactory,
-		"GitRepository": gitrepository.GeneratorFactory(fetcher),
-		"OCIRepository": ocirepository.GeneratorFactory(fetcher),
-		"PullRequests":  pullrequests.GeneratorFactory,
-		"Cluster":       cluster.GeneratorFactory,
-		"APIClient":     apiclient.GeneratorFactory(clientFactory),
-		"ImagePolicy":   imagepolicy.GeneratorFactory,
-		"Matrix":        matrix.GeneratorFactory(matrixGenerators),
-		"Config":        config.GeneratorFactory,
+	return registry.Default.Factories(enabledGenerators, registry.Options{
+		Fetcher:           fetcher,
+		HTTPClientFactory: clientFactory,
 	})
 }
 
-func filterEnabledGenerators(enabledGenerators []string, gens map[string]generators.GeneratorFactory) map[string]generators.GeneratorFactory {
-	newGenerators := make(map[string]generators.GeneratorFactory)
-	for generatorName := range gens {
-		if isGeneratorEnabled(enabledGenerators, generatorName) {
-			newGenerators[generatorName] = gens[generatorName]
-		}
-	}
-	return newGenerators
+// GetWatches returns the additional kinds to watch for the set of enabled
+// generators.
+func GetWatches(enabledGenerators []string) []registry.Watch {
+	return registry.Default.Watches(enabledGenerators)
 }
 
-func isGeneratorEnabled(enabledGenerators []string, generatorName string) bool {
-	return slices.Contains(enabledGenerators, generatorName)
+// GetLocalReaders returns the readers that provide the objects for the set of
+// enabled generators when the CLI renders without cluster access.
+func GetLocalReaders(enabledGenerators []string) []registry.LocalReader {
+	return registry.Default.LocalReaders(enabledGenerators)
 }
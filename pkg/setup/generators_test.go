@@ -12,7 +12,7 @@ import (
 	imagev1 "github.com/fluxcd/image-reflector-controller/api/v1beta2"
 	sourcev1 "github.com/fluxcd/source-controller/api/v1beta2"
 	clustersv1 "github.com/weaveworks/cluster-controller/api/v1alpha1"
-	templatesv1 "github.com/weaveworks/gitopssets-controller/api/v1alpha1"
+	templatesv1 "github.com/weaveworks/gitopssets-controller/api/v1beta1"
 
 	"github.com/weaveworks/gitopssets-controller/test"
 )
@@ -160,12 +160,12 @@ func TestValidateEnabledGenerators(t *testing.T) {
 		{
 			"unknown enabled generators raise error",
 			[]string{"Cluster", "List", "foo"},
-			`invalid generator "foo". valid values: \["GitRepository" "OCIRepository" "Cluster" "PullRequests" "List" "APIClient" "ImagePolicy" "Matrix" "Config"\]`,
+			`invalid generator "foo". valid values: \["APIClient" "Cluster" "Config" "GitRepository" "ImagePolicy" "List" "Matrix" "OCIRepository" "Plugin" "PullRequests"\]`,
 		},
 		{
 			"case insensitive generators",
 			[]string{"cluster", "List"},
-			`invalid generator "cluster". valid values: \["GitRepository" "OCIRepository" "Cluster" "PullRequests" "List" "APIClient" "ImagePolicy" "Matrix" "Config"\]`,
+			`invalid generator "cluster". valid values: \["APIClient" "Cluster" "Config" "GitRepository" "ImagePolicy" "List" "Matrix" "OCIRepository" "Plugin" "PullRequests"\]`,
 		},
 	}
 
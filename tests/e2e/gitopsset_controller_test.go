@@ -25,7 +25,7 @@ import (
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
-	templatesv1 "github.com/weaveworks/gitopssets-controller/api/v1alpha1"
+	templatesv1 "github.com/weaveworks/gitopssets-controller/api/v1beta1"
 	"github.com/weaveworks/gitopssets-controller/test"
 
 	clustersv1 "github.com/weaveworks/cluster-controller/api/v1alpha1"
@@ -1029,7 +1029,7 @@ func TestEventsWithFailingReconciling(t *testing.T) {
 		want := []*test.EventData{
 			{
 				EventType: corev1.EventTypeWarning,
-				Reason:    "ReconciliationFailed",
+				Reason:    "ApplyFailed",
 			},
 		}
 
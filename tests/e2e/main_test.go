@@ -21,7 +21,7 @@ import (
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
 
-	gitopssetsv1 "github.com/weaveworks/gitopssets-controller/api/v1alpha1"
+	gitopssetsv1 "github.com/weaveworks/gitopssets-controller/api/v1beta1"
 	"github.com/weaveworks/gitopssets-controller/controllers"
 	"github.com/weaveworks/gitopssets-controller/controllers/templates/generators"
 	"github.com/weaveworks/gitopssets-controller/controllers/templates/generators/cluster"
@@ -32,6 +32,7 @@ import (
 	"github.com/weaveworks/gitopssets-controller/controllers/templates/generators/matrix"
 	"github.com/weaveworks/gitopssets-controller/controllers/templates/generators/ocirepository"
 	"github.com/weaveworks/gitopssets-controller/controllers/templates/generators/pullrequests"
+	"github.com/weaveworks/gitopssets-controller/pkg/setup"
 	// +kubebuilder:scaffold:imports
 )
 
@@ -84,6 +85,7 @@ func TestMain(m *testing.M) {
 			"ImagePolicy":   imagepolicy.GeneratorFactory,
 			"Config":        config.GeneratorFactory,
 		},
+		Watches:       setup.GetWatches([]string{"Cluster"}),
 		EventRecorder: eventRecorder,
 	}).SetupWithManager(testEnv); err != nil {
 		panic(fmt.Sprintf("Failed to start GitOpsSetReconciler: %v", err))